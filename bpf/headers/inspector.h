@@ -58,6 +58,15 @@ struct tuple {
   u8 pad;
 } __attribute__((packed));
 
+// task_meta is the current task when the event happens, it is unrelated to the
+// event in softirq context, userspace checks it against netns of the event.
+struct task_meta {
+  u32 pid;
+  u32 tid;
+  u64 cgroup_id;
+  char comm[TASK_COMM_LEN];
+} __attribute__((packed));
+
 static __always_inline u16 get_sock_protocol(struct sock *sock) {
   u16 protocol = 0;
 
@@ -229,11 +238,14 @@ static __always_inline void set_meta_sock(struct sock *sk,
   // meta->sk_state = BPF_CORE_READ(sk, __sk_common.skc_state);
 }
 
-static __always_inline void set_tuple_sock(struct sock *sk, struct tuple *tpl) {
+static __always_inline void set_addr_sock(struct sock *sk, struct tuple *tpl) {
   short unsigned int skc_family;
   skc_family = BPF_CORE_READ(sk, __sk_common.skc_family);
   if (skc_family == PF_INET6) {
-    // TODO: add v6 sock support
+    bpf_probe_read(&tpl->saddr, sizeof(tpl->saddr),
+                   &sk->__sk_common.skc_v6_rcv_saddr);
+    bpf_probe_read(&tpl->daddr, sizeof(tpl->daddr),
+                   &sk->__sk_common.skc_v6_daddr);
     tpl->l3_proto = ETH_P_IPV6;
   } else {
     bpf_probe_read(&tpl->saddr, sizeof(tpl->saddr.v4addr),
@@ -242,9 +254,21 @@ static __always_inline void set_tuple_sock(struct sock *sk, struct tuple *tpl) {
                    &sk->__sk_common.skc_daddr);
     tpl->l3_proto = ETH_P_IP;
   }
+}
+
+static __always_inline void set_tuple_sock(struct sock *sk, struct tuple *tpl) {
+  set_addr_sock(sk, tpl);
 
   tpl->sport = BPF_CORE_READ(sk, __sk_common.skc_num);
   tpl->dport = BPF_CORE_READ(sk, __sk_common.skc_dport);
   tpl->l4_proto = get_sock_protocol(sk);
   ;
 }
+
+static __always_inline void set_task_meta(struct task_meta *meta) {
+  u64 pid_tgid = bpf_get_current_pid_tgid();
+  meta->pid = pid_tgid >> 32;
+  meta->tid = (u32)pid_tgid;
+  meta->cgroup_id = bpf_get_current_cgroup_id();
+  bpf_get_current_comm(&meta->comm, sizeof(meta->comm));
+}
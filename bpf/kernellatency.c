@@ -37,6 +37,7 @@ struct insp_kl_event_t {
 	u64 point2;
 	u64 point3;
 	u64 point4;
+	u64 timestamp;
 };
 
 // struct insp_kernelrx_event_t {
@@ -96,6 +97,7 @@ static inline int update_rxlat(void *ctx, struct sk_buff * skb, struct rxlatency
 			bpf_probe_read(&event.point2,sizeof(event.point1),&lat->rcvfinish);
 			bpf_probe_read(&event.point3,sizeof(event.point1),&lat->local);
 			bpf_probe_read(&event.point4,sizeof(event.point1),&lat->localfinish);
+			event.timestamp = bpf_ktime_get_ns();
 			bpf_perf_event_output(ctx, &insp_klatency_event, BPF_F_CURRENT_CPU, &event, sizeof(event));
 		}
 	}
@@ -121,6 +123,7 @@ static inline int update_txlat(void *ctx, struct sk_buff * skb, struct txlatency
 			bpf_probe_read(&event.point3,sizeof(event.point1),&lat->output);
 			bpf_probe_read(&event.point4,sizeof(event.point1),&lat->finish);
 			// bpf_core_read(&event.latency,sizeof(event.latency),&lat);
+			event.timestamp = bpf_ktime_get_ns();
 			bpf_perf_event_output(ctx, &insp_klatency_event, BPF_F_CURRENT_CPU, &event, sizeof(event));
 		}
 	}
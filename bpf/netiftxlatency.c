@@ -49,6 +49,7 @@ struct insp_nftxlat_event_t {
 	u32 cpu;
 	u64 latency;
 	s64 stack_id;
+	u64 timestamp;
 };
 
 struct insp_nftxlat_metric_t {
@@ -108,6 +109,7 @@ static inline int report_txlat_events(void *ctx, struct sk_buff * skb, u64 laten
     set_tuple(skb, &event.tuple);
 	set_meta(skb,&event.skb_meta);
 	event.latency = latency;
+	event.timestamp = bpf_ktime_get_ns();
 	bpf_perf_event_output(ctx, &insp_sklat_event, BPF_F_CURRENT_CPU, &event, sizeof(event));
     return 0;
 }
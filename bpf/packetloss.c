@@ -17,10 +17,21 @@ struct kfree_skb_args {
   unsigned short protocol;
 };
 
+/* reason of the tracepoint is available since 5.17, values vary between kernel versions */
+enum skb_drop_reason___new {
+  SKB_DROP_REASON_NOT_SPECIFIED___new = 0,
+};
+
+struct trace_event_raw_kfree_skb___new {
+  enum skb_drop_reason___new reason;
+} __attribute__((preserve_access_index));
+
 struct insp_pl_event_t {
   struct tuple tuple;
   u64 location;
   s64 stack_id;
+  u32 reason;
+  u64 timestamp;
 };
 
 const struct insp_pl_event_t *unused_insp_pl_event_t __attribute__((unused));
@@ -49,6 +60,11 @@ int kfree_skb(struct kfree_skb_args *args) {
   }
   event.location = (u64)args->location;
 
+  struct trace_event_raw_kfree_skb___new *ctx = (void *)args;
+  if (bpf_core_field_exists(ctx->reason)) {
+    event.reason = BPF_CORE_READ(ctx, reason);
+  }
+
   int packetloss_stack_key = 0;
   bool enable_packetloss_stack = is_enable(packetloss_stack_key);
 
@@ -57,6 +73,7 @@ int kfree_skb(struct kfree_skb_args *args) {
                                     KERN_STACKID_FLAGS);
   }
 
+  event.timestamp = bpf_ktime_get_ns();
   bpf_perf_event_output(args, &insp_pl_event,
                         BPF_F_CURRENT_CPU, &event, sizeof(event));
 
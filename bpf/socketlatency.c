@@ -37,6 +37,8 @@ struct insp_sklat_event_t {
   u32 cpu;
   u32 direction;
   u64 latency;
+  u64 cgroup_id;
+  u64 timestamp;
 };
 
 struct insp_sklat_metric_t {
@@ -73,9 +75,11 @@ static __always_inline void report_events(struct pt_regs * ctx,struct sock * sk,
     set_meta_sock(sk,&event.skb_meta);
     bpf_get_current_comm(&event.target, sizeof(event.target));
     event.pid = bpf_get_current_pid_tgid()>> 32;
+    event.cgroup_id = bpf_get_current_cgroup_id();
     event.cpu = bpf_get_smp_processor_id();
 	event.latency = latency;
 	event.direction = direction;
+	event.timestamp = bpf_ktime_get_ns();
 	bpf_perf_event_output((struct pt_regs *)ctx, &insp_sklat_events,BPF_F_CURRENT_CPU, &event, sizeof(event));
 }
 
@@ -35,6 +35,7 @@ struct insp_softirq_event_t {
 	u32 phase;
 	u32 vec_nr;
     u64 latency;
+    u64 timestamp;
 };
 
 struct {
@@ -57,6 +58,7 @@ static inline int report_softirq_events(void *ctx, u64 latency, u32 phase, u32 v
 	event.latency = latency;
 	event.phase = phase;
 	event.vec_nr = vec_nr;
+	event.timestamp = bpf_ktime_get_ns();
 	bpf_perf_event_output(ctx, &insp_softirq_events, BPF_F_CURRENT_CPU, &event, sizeof(event));
     return 0;
 }
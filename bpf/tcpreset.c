@@ -42,6 +42,8 @@ struct insp_tcpreset_event_t {
 	struct tuple tuple;
 	struct skb_meta skb_meta;
 	s64 stack_id;
+	struct task_meta task;
+	u64 timestamp;
 };
 
 struct insp_tcpreset_event_t *unused_event __attribute__((unused));
@@ -57,6 +59,7 @@ struct {
 	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
 } insp_tcpreset_events SEC(".maps");
 
+// also attached to tcp_v6_send_reset, which has the same arguments
 SEC("kprobe/tcp_v4_send_reset")
 int trace_sendreset(struct pt_regs * ctx)
 {
@@ -73,7 +76,9 @@ int trace_sendreset(struct pt_regs * ctx)
 	event.stack_id = bpf_get_stackid((struct pt_regs *)ctx, &insp_tcpreset_stack, BPF_F_FAST_STACK_CMP);
 	set_tuple(skb, &event.tuple);
 	set_meta(skb,&event.skb_meta);
+	set_task_meta(&event.task);
 
+	event.timestamp = bpf_ktime_get_ns();
 	bpf_perf_event_output((struct pt_regs *)ctx,&insp_tcpreset_events,BPF_F_CURRENT_CPU,&event,sizeof(event));
 	return 0;
 }
@@ -88,6 +93,8 @@ int trace_sendactive(struct pt_regs * ctx)
 	bpf_core_read(&event.state,sizeof(event.state),&sk->__sk_common.skc_state);
 	set_tuple_sock(sk,&event.tuple);
 	set_meta_sock(sk,&event.skb_meta);
+	set_task_meta(&event.task);
+	event.timestamp = bpf_ktime_get_ns();
 	bpf_perf_event_output((struct pt_regs *)ctx,&insp_tcpreset_events,BPF_F_CURRENT_CPU,&event,sizeof(event));
 	return 0;
 }
@@ -105,6 +112,8 @@ int insp_rstrx(struct receive_reset_args *ctx)
 	bpf_core_read(&event.state,sizeof(event.state),&sk->__sk_common.skc_state);
 	set_tuple_sock(sk,&event.tuple);
 	set_meta_sock(sk,&event.skb_meta);
+	set_task_meta(&event.task);
+	event.timestamp = bpf_ktime_get_ns();
 	bpf_perf_event_output((struct pt_regs *)ctx,&insp_tcpreset_events,BPF_F_CURRENT_CPU,&event,sizeof(event));
 	return 0;
 }
@@ -24,6 +24,9 @@ struct tp_tcp_retransmit_skb_args {
 struct insp_tcpretrans_event_t {
   struct tuple tuple;
   s64 stack_id;
+  struct task_meta task;
+  u32 netns;
+  u64 timestamp;
 };
 
 const struct insp_tcpretrans_event_t *unused_insp_tcpretrans_event_t __attribute__((unused));
@@ -48,11 +51,16 @@ int tcpretrans(struct tp_tcp_retransmit_skb_args *args) {
   bpf_probe_read_kernel(&tuple->dport, sizeof(tuple->dport), &args->dport);
   event.tuple.l4_proto = IPPROTO_TCP;
 
-  bpf_probe_read_kernel(&tuple->saddr.v6addr, sizeof(tuple->saddr.v6addr), &args->saddr_v6);
-  bpf_probe_read_kernel(&tuple->daddr.v6addr, sizeof(tuple->daddr.v6addr), &args->daddr_v6);
+  // read addresses from sock, layout of address fields in the tracepoint
+  // changes between kernel versions.
+  struct sock *sk = (struct sock *)args->skaddr;
+  set_addr_sock(sk, tuple);
+  set_task_meta(&event.task);
+  event.netns = get_sock_netns(sk);
 
   event.stack_id = bpf_get_stackid(args, &insp_tcp_retrans_stack,
                                 KERN_STACKID_FLAGS);
+  event.timestamp = bpf_ktime_get_ns();
   bpf_perf_event_output(args, &insp_tcp_retrans_event,
                         BPF_F_CURRENT_CPU, &event, sizeof(event));
 
@@ -18,6 +18,7 @@ struct insp_biolat_event_t {
     char disk[TASK_COMM_LEN];
     u32 pid;
     u64 latency;
+    u64 timestamp;
 };
 
 struct insp_biolat_entry_t  {
@@ -82,6 +83,7 @@ int biolat_finish(struct pt_regs *ctx)
            event.latency = latency;
            event.pid = biot->pid;
            bpf_probe_read(&event.target,sizeof(event.target),&biot->target);
+           event.timestamp = bpf_ktime_get_ns();
            bpf_perf_event_output(ctx, &insp_biolat_evts, BPF_F_CURRENT_CPU, &event, sizeof(event));
 	   }
        bpf_map_delete_elem(&insp_biolat_entry, &rq);
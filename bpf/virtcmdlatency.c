@@ -10,6 +10,7 @@ struct insp_virtcmdlat_event_t {
     u32 pid;
 	u32 cpu;
     u64 latency;
+    u64 timestamp;
 };
 
 struct {
@@ -24,6 +25,7 @@ static inline int report_virtcmdlat_events(void *ctx, u64 latency)
 	event.pid = bpf_get_current_pid_tgid() >> 32;
     event.cpu = bpf_get_smp_processor_id();
 	event.latency = latency;
+	event.timestamp = bpf_ktime_get_ns();
 	bpf_perf_event_output(ctx, &insp_virtcmdlat_events, BPF_F_CURRENT_CPU, &event, sizeof(event));
     return 0;
 }
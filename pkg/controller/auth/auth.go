@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	log "k8s.io/klog/v2"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultCacheTTL = time.Minute
+
+	serviceAccountPrefix = "system:serviceaccount:"
+	extraNodeName        = "authentication.kubernetes.io/node-name"
+	extraPodName         = "authentication.kubernetes.io/pod-name"
+	extraPodUID          = "authentication.kubernetes.io/pod-uid"
+)
+
+// Config authenticates agents by service account tokens sent by them, agents are bound to
+// nodes of their pods.
+type Config struct {
+	Enable bool `yaml:"enable"`
+	// ServiceAccounts are allowed service accounts in namespace/name, it is required when
+	// enabled since any token bound to a pod would be accepted otherwise.
+	ServiceAccounts []string `yaml:"serviceAccounts"`
+	// Audiences are audiences the tokens must be issued for, the audience of the apiserver is used if empty.
+	Audiences []string `yaml:"audiences"`
+	// CacheTTL is the time a reviewed token is cached.
+	CacheTTL time.Duration `yaml:"cacheTTL"`
+}
+
+// Validate checks the config when it is enabled.
+func (c *Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if len(c.ServiceAccounts) == 0 {
+		return fmt.Errorf("serviceAccounts: allowed service accounts are required")
+	}
+	for _, sa := range c.ServiceAccounts {
+		if ns, name, ok := strings.Cut(sa, "/"); !ok || ns == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("serviceAccounts: %q is not in namespace/name", sa)
+		}
+	}
+	return nil
+}
+
+// Identity is an authenticated agent.
+type Identity struct {
+	ServiceAccount string
+	Node           string
+}
+
+type cachedIdentity struct {
+	identity *Identity
+	expire   time.Time
+}
+
+// Authenticator reviews tokens of agents by the TokenReview api.
+type Authenticator struct {
+	client kubernetes.Interface
+	config Config
+
+	lock  sync.Mutex
+	cache map[[sha256.Size]byte]cachedIdentity
+}
+
+func NewAuthenticator(client kubernetes.Interface, config *Config) *Authenticator {
+	cfg := *config
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = defaultCacheTTL
+	}
+	return &Authenticator{
+		client: client,
+		config: cfg,
+		cache:  make(map[[sha256.Size]byte]cachedIdentity),
+	}
+}
+
+// Authenticate returns the identity of the token, the token must be bound to a pod so the
+// node of the agent is known.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	a.lock.Lock()
+	cached, ok := a.cache[key]
+	a.lock.Unlock()
+	if ok && now.Before(cached.expire) {
+		return cached.identity, nil
+	}
+
+	identity, err := a.review(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for k, v := range a.cache {
+		if !now.Before(v.expire) {
+			delete(a.cache, k)
+		}
+	}
+	a.cache[key] = cachedIdentity{identity: identity, expire: now.Add(a.config.CacheTTL)}
+	return identity, nil
+}
+
+func (a *Authenticator) review(ctx context.Context, token string) (*Identity, error) {
+	review, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: a.config.Audiences},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed review token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, fmt.Errorf("token not authenticated: %s", review.Status.Error)
+	}
+
+	user := review.Status.User
+	if !strings.HasPrefix(user.Username, serviceAccountPrefix) {
+		return nil, fmt.Errorf("%s is not a service account", user.Username)
+	}
+	sa := strings.Replace(strings.TrimPrefix(user.Username, serviceAccountPrefix), ":", "/", 1)
+	if !lo.Contains(a.config.ServiceAccounts, sa) {
+		return nil, fmt.Errorf("service account %s is not allowed", sa)
+	}
+
+	node, err := a.nodeOf(ctx, strings.SplitN(sa, "/", 2)[0], user.Extra)
+	if err != nil {
+		return nil, fmt.Errorf("failed get node of service account %s: %w", sa, err)
+	}
+	return &Identity{ServiceAccount: sa, Node: node}, nil
+}
+
+// nodeOf returns the node the token is bound to, the node name claim is only added by
+// kubernetes 1.30+, nodes of older tokens are got from their pods.
+func (a *Authenticator) nodeOf(ctx context.Context, namespace string, extra map[string]authenticationv1.ExtraValue) (string, error) {
+	if node := extraValue(extra, extraNodeName); node != "" {
+		return node, nil
+	}
+
+	podName, podUID := extraValue(extra, extraPodName), extraValue(extra, extraPodUID)
+	if podName == "" {
+		return "", fmt.Errorf("token is not bound to a pod")
+	}
+	pod, err := a.client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed get pod %s/%s: %w", namespace, podName, err)
+	}
+	if string(pod.UID) != podUID {
+		return "", fmt.Errorf("pod %s/%s has been recreated", namespace, podName)
+	}
+	if pod.Spec.NodeName == "" {
+		return "", fmt.Errorf("pod %s/%s is not scheduled", namespace, podName)
+	}
+	return pod.Spec.NodeName, nil
+}
+
+func extraValue(extra map[string]authenticationv1.ExtraValue, key string) string {
+	if v := extra[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+type identityKey struct{}
+
+// NewContext returns a context carrying the identity of an authenticated agent.
+func NewContext(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity of the agent authenticated by interceptors.
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
+
+func (a *Authenticator) authenticateContext(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "bearer token required")
+	}
+	identity, err := a.Authenticate(ctx, strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		log.Warningf("failed authenticate agent: %v", err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return NewContext(ctx, identity), nil
+}
+
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticateContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Authenticator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticateContext(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &identityStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newFakeClient(reviews *int) *fake.Clientset {
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kubeskoop", Name: "agent-abc", UID: "uid-1"},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+	})
+	users := map[string]authenticationv1.UserInfo{
+		"pod-bound": {
+			Username: "system:serviceaccount:kubeskoop:agent",
+			Extra: map[string]authenticationv1.ExtraValue{
+				extraPodName: {"agent-abc"},
+				extraPodUID:  {"uid-1"},
+			},
+		},
+		"node-bound": {
+			Username: "system:serviceaccount:kubeskoop:agent",
+			Extra:    map[string]authenticationv1.ExtraValue{extraNodeName: {"node-2"}},
+		},
+		"recreated": {
+			Username: "system:serviceaccount:kubeskoop:agent",
+			Extra: map[string]authenticationv1.ExtraValue{
+				extraPodName: {"agent-abc"},
+				extraPodUID:  {"uid-0"},
+			},
+		},
+		"legacy":      {Username: "system:serviceaccount:kubeskoop:agent"},
+		"other-sa":    {Username: "system:serviceaccount:default:default", Extra: map[string]authenticationv1.ExtraValue{extraNodeName: {"node-1"}}},
+		"normal-user": {Username: "admin"},
+	}
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		*reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if user, ok := users[review.Spec.Token]; ok {
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: user}
+		}
+		return true, review, nil
+	})
+	return client
+}
+
+func TestAuthenticate(t *testing.T) {
+	var reviews int
+	a := NewAuthenticator(newFakeClient(&reviews), &Config{Enable: true, ServiceAccounts: []string{"kubeskoop/agent"}})
+	ctx := context.Background()
+
+	identity, err := a.Authenticate(ctx, "pod-bound")
+	assert.NoError(t, err)
+	assert.Equal(t, &Identity{ServiceAccount: "kubeskoop/agent", Node: "node-1"}, identity)
+
+	identity, err = a.Authenticate(ctx, "node-bound")
+	assert.NoError(t, err)
+	assert.Equal(t, "node-2", identity.Node)
+
+	// reviewed tokens are cached
+	_, err = a.Authenticate(ctx, "pod-bound")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reviews)
+
+	for _, token := range []string{"recreated", "legacy", "other-sa", "normal-user", "invalid"} {
+		_, err = a.Authenticate(ctx, token)
+		assert.Error(t, err, token)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, (&Config{}).Validate())
+	assert.NoError(t, (&Config{Enable: true, ServiceAccounts: []string{"kubeskoop/agent"}}).Validate())
+	assert.Error(t, (&Config{Enable: true}).Validate())
+	for _, sa := range []string{"agent", "/agent", "kubeskoop/", "kubeskoop/agent/x"} {
+		assert.Error(t, (&Config{Enable: true, ServiceAccounts: []string{sa}}).Validate(), sa)
+	}
+}
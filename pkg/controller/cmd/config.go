@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/alibaba/kubeskoop/pkg/controller/auth"
 	"github.com/alibaba/kubeskoop/pkg/controller/k8s"
+	"github.com/alibaba/kubeskoop/pkg/exporter/tlsutil"
 
 	"github.com/alibaba/kubeskoop/pkg/controller/service"
 	"gopkg.in/yaml.v3"
@@ -13,6 +15,34 @@ import (
 type ServerConfig struct {
 	AgentPort int `yaml:"agentPort"`
 	HTTPPort  int `yaml:"httpPort"`
+	// AgentTLS serves agents over tls, client certificates of agents are required if caFile is set.
+	AgentTLS *tlsutil.Config `yaml:"agentTLS"`
+	// HTTPTLS serves the http api over tls, client certificates are required if caFile is set.
+	HTTPTLS *tlsutil.Config `yaml:"httpTLS"`
+	// AgentAuth authenticates agents by their service account tokens, AgentTLS is required when it is enabled.
+	AgentAuth *auth.Config `yaml:"agentAuth"`
+}
+
+func (c *ServerConfig) validate() error {
+	if c.AgentTLS.Enabled() {
+		if err := c.AgentTLS.Validate(true); err != nil {
+			return fmt.Errorf("agentTLS: %w", err)
+		}
+	}
+	if c.HTTPTLS.Enabled() {
+		if err := c.HTTPTLS.Validate(true); err != nil {
+			return fmt.Errorf("httpTLS: %w", err)
+		}
+	}
+	if c.AgentAuth != nil && c.AgentAuth.Enable {
+		if !c.AgentTLS.Enabled() {
+			return fmt.Errorf("agentAuth: tokens cannot be received over plaintext, agentTLS is required")
+		}
+		if err := c.AgentAuth.Validate(); err != nil {
+			return fmt.Errorf("agentAuth: %w", err)
+		}
+	}
+	return nil
 }
 
 type Config struct {
@@ -35,5 +65,9 @@ func loadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed parse config file: %s: %w", path, err)
 	}
 
+	if err = config.Server.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
 	return &config, nil
 }
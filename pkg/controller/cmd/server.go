@@ -14,6 +14,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/alibaba/kubeskoop/pkg/controller/auth"
 	"github.com/alibaba/kubeskoop/pkg/controller/ipcache"
 	"github.com/alibaba/kubeskoop/pkg/controller/k8s"
 
@@ -25,6 +26,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/common/model"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 const (
@@ -44,7 +46,7 @@ func NewServer(config *Config) *Server {
 		log.Fatalf("error create controller service: %v", err)
 	}
 
-	cache := ipcache.NewService(k8s.PodInformer, k8s.NodeInformer)
+	cache := ipcache.NewService(k8s.PodInformer, k8s.NodeInformer, k8s.ServiceInformer)
 
 	return &Server{
 		config:         config.Server,
@@ -70,7 +72,21 @@ func (s *Server) RunAgentServer(port int, done <-chan struct{}) {
 	if port == 0 {
 		port = defaultAgentPort
 	}
-	grpcServer := grpc.NewServer(grpc.MaxRecvMsgSize(102 * 1024 * 1024))
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(102 * 1024 * 1024)}
+	if s.config.AgentTLS.Enabled() {
+		tlsConfig, err := s.config.AgentTLS.ServerConfig()
+		if err != nil {
+			log.Fatalf("failed load agent tls config: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	if s.config.AgentAuth != nil && s.config.AgentAuth.Enable {
+		authenticator := auth.NewAuthenticator(k8s.Client, s.config.AgentAuth)
+		opts = append(opts,
+			grpc.UnaryInterceptor(authenticator.UnaryInterceptor()),
+			grpc.StreamInterceptor(authenticator.StreamInterceptor()))
+	}
+	grpcServer := grpc.NewServer(opts...)
 	rpc.RegisterControllerRegisterServiceServer(grpcServer, s.controller)
 	rpc.RegisterIPCacheServiceServer(grpcServer, s.ipCacheService)
 
@@ -109,13 +125,29 @@ func (s *Server) RunHTTPServer(port int, done <-chan struct{}) {
 	r.GET("/config", s.GetExporterConfig)
 	r.PUT("/config", s.UpdateExporterConfig)
 
-	go func() {
-		err := r.Run(fmt.Sprintf("0.0.0.0:%d", port))
+	server := &http.Server{Addr: fmt.Sprintf("0.0.0.0:%d", port), Handler: r}
+	if s.config.HTTPTLS.Enabled() {
+		tlsConfig, err := s.config.HTTPTLS.ServerConfig()
 		if err != nil {
+			log.Fatalf("failed load http tls config: %v", err)
+		}
+		server.TLSConfig = tlsConfig
+	}
+
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			// certificates are provided by the tls config
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("error run http server: %v", err)
 		}
 	}()
 	<-done
+	_ = server.Close()
 }
 
 // CommitDiagnoseTask commit diagnose task
@@ -151,7 +151,7 @@ func findNext(slice []*changeLog, target uint64) int {
 	return ret
 }
 
-func NewService(podInformer coreinformers.PodInformer, nodeInformer coreinformers.NodeInformer) *Service {
+func NewService(podInformer coreinformers.PodInformer, nodeInformer coreinformers.NodeInformer, serviceInformer coreinformers.ServiceInformer) *Service {
 	s := &Service{
 		storage: storage{
 			snapshot: snapshot{
@@ -180,6 +180,15 @@ func NewService(podInformer coreinformers.PodInformer, nodeInformer coreinformer
 		log.Fatalf("failed to add node resource handler: %v", err)
 	}
 
+	_, err = serviceInformer.Informer().AddEventHandler(&cache.ResourceEventHandlerFuncs{
+		AddFunc:    s.onAddService,
+		DeleteFunc: s.onDeleteService,
+		UpdateFunc: s.onUpdateService,
+	})
+	if err != nil {
+		log.Fatalf("failed to add service resource handler: %v", err)
+	}
+
 	go s.syncControl()
 
 	return s
@@ -246,6 +255,40 @@ func allNodeIPs(node *v1.Node) []string {
 	return ret
 }
 
+// allServiceIPs returns cluster ips of the service. External and load balancer ips are not
+// included, they may be ips of nodes and the cache is keyed by ip.
+func allServiceIPs(svc *v1.Service) []string {
+	var ret []string
+	clusterIPs := svc.Spec.ClusterIPs
+	if len(clusterIPs) == 0 && svc.Spec.ClusterIP != "" {
+		clusterIPs = []string{svc.Spec.ClusterIP}
+	}
+	for _, ip := range clusterIPs {
+		// headless service
+		if ip != v1.ClusterIPNone {
+			ret = append(ret, ip)
+		}
+	}
+	return ret
+}
+
+func createCacheEntries4Service(svc *v1.Service) []*rpc.CacheEntry {
+	var entries []*rpc.CacheEntry
+	for _, ip := range allServiceIPs(svc) {
+		entries = append(entries, &rpc.CacheEntry{
+			IP:   ip,
+			Type: rpc.ValueType_Service,
+			Meta: &rpc.CacheEntry_Service{
+				Service: &rpc.ServiceMeta{
+					Namespace: svc.Namespace,
+					Name:      svc.Name,
+				},
+			},
+		})
+	}
+	return entries
+}
+
 func (s *Service) onAddPod(obj interface{}) {
 	pod := obj.(*v1.Pod)
 	entries := createCacheEntries4Pod(pod)
@@ -354,6 +397,59 @@ func (s *Service) onUpdateNode(old interface{}, cur interface{}) {
 	}
 }
 
+func (s *Service) onAddService(obj interface{}) {
+	svc := obj.(*v1.Service)
+	entries := createCacheEntries4Service(svc)
+	if len(entries) > 0 {
+		s.logChange(rpc.OpCode_Set, entries)
+	}
+}
+
+func (s *Service) onDeleteService(obj interface{}) {
+	svc, ok := obj.(*v1.Service)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("couldn't get object from tombstone %#v", obj))
+			return
+		}
+		svc, ok = tombstone.Obj.(*v1.Service)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("tombstone contained object that is not a service %#v", obj))
+			return
+		}
+	}
+
+	entries := createCacheEntries4Service(svc)
+	if len(entries) > 0 {
+		s.logChange(rpc.OpCode_Del, entries)
+	}
+}
+
+func (s *Service) onUpdateService(old interface{}, cur interface{}) {
+	newSvc := cur.(*v1.Service)
+	oldSvc := old.(*v1.Service)
+	if newSvc.ResourceVersion == oldSvc.ResourceVersion {
+		return
+	}
+
+	oldIPs := allServiceIPs(oldSvc)
+	newIPs := allServiceIPs(newSvc)
+
+	if reflect.DeepEqual(oldIPs, newIPs) {
+		return
+	}
+
+	toRemove := createCacheEntries4Service(oldSvc)
+	if len(toRemove) > 0 {
+		s.logChange(rpc.OpCode_Del, toRemove)
+	}
+	toAdd := createCacheEntries4Service(newSvc)
+	if len(toAdd) > 0 {
+		s.logChange(rpc.OpCode_Set, toAdd)
+	}
+}
+
 func (s *Service) logChange(op rpc.OpCode, entries []*rpc.CacheEntry) {
 	if len(entries) == 0 {
 		return
@@ -15,6 +15,7 @@ import (
 var sharedInformerFactory informers.SharedInformerFactory
 var PodInformer v1.PodInformer
 var NodeInformer v1.NodeInformer
+var ServiceInformer v1.ServiceInformer
 
 func InitInformer(k8sClient kubernetes.Interface) error {
 	if k8sClient == nil {
@@ -24,6 +25,7 @@ func InitInformer(k8sClient kubernetes.Interface) error {
 	sharedInformerFactory = informers.NewSharedInformerFactory(k8sClient, time.Minute*1)
 	PodInformer = sharedInformerFactory.Core().V1().Pods()
 	NodeInformer = sharedInformerFactory.Core().V1().Nodes()
+	ServiceInformer = sharedInformerFactory.Core().V1().Services()
 
 	_ = PodInformer.Informer().GetIndexer().AddIndexers(cache.Indexers{
 		"nodeName": func(obj interface{}) ([]string, error) {
@@ -70,8 +70,9 @@ func (AddressFamily) EnumDescriptor() ([]byte, []int) {
 type ValueType int32
 
 const (
-	ValueType_Pod  ValueType = 0
-	ValueType_Node ValueType = 1
+	ValueType_Pod     ValueType = 0
+	ValueType_Node    ValueType = 1
+	ValueType_Service ValueType = 2
 )
 
 // Enum value maps for ValueType.
@@ -79,10 +80,12 @@ var (
 	ValueType_name = map[int32]string{
 		0: "Pod",
 		1: "Node",
+		2: "Service",
 	}
 	ValueType_value = map[string]int32{
-		"Pod":  0,
-		"Node": 1,
+		"Pod":     0,
+		"Node":    1,
+		"Service": 2,
 	}
 )
 
@@ -261,6 +264,61 @@ func (x *NodeMeta) GetName() string {
 	return ""
 }
 
+type ServiceMeta struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Name      string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *ServiceMeta) Reset() {
+	*x = ServiceMeta{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ipcache_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServiceMeta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceMeta) ProtoMessage() {}
+
+func (x *ServiceMeta) ProtoReflect() protoreflect.Message {
+	mi := &file_ipcache_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceMeta.ProtoReflect.Descriptor instead.
+func (*ServiceMeta) Descriptor() ([]byte, []int) {
+	return file_ipcache_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ServiceMeta) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ServiceMeta) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
 type CacheEntry struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -269,16 +327,16 @@ type CacheEntry struct {
 	IP   string    `protobuf:"bytes,1,opt,name=IP,proto3" json:"IP,omitempty"`
 	Type ValueType `protobuf:"varint,2,opt,name=type,proto3,enum=controller_rpc.ValueType" json:"type,omitempty"`
 	// Types that are assignable to Meta:
-	//
 	//	*CacheEntry_Pod
 	//	*CacheEntry_Node
+	//	*CacheEntry_Service
 	Meta isCacheEntry_Meta `protobuf_oneof:"meta"`
 }
 
 func (x *CacheEntry) Reset() {
 	*x = CacheEntry{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_ipcache_proto_msgTypes[2]
+		mi := &file_ipcache_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -291,7 +349,7 @@ func (x *CacheEntry) String() string {
 func (*CacheEntry) ProtoMessage() {}
 
 func (x *CacheEntry) ProtoReflect() protoreflect.Message {
-	mi := &file_ipcache_proto_msgTypes[2]
+	mi := &file_ipcache_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -304,7 +362,7 @@ func (x *CacheEntry) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CacheEntry.ProtoReflect.Descriptor instead.
 func (*CacheEntry) Descriptor() ([]byte, []int) {
-	return file_ipcache_proto_rawDescGZIP(), []int{2}
+	return file_ipcache_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *CacheEntry) GetIP() string {
@@ -342,6 +400,13 @@ func (x *CacheEntry) GetNode() *NodeMeta {
 	return nil
 }
 
+func (x *CacheEntry) GetService() *ServiceMeta {
+	if x, ok := x.GetMeta().(*CacheEntry_Service); ok {
+		return x.Service
+	}
+	return nil
+}
+
 type isCacheEntry_Meta interface {
 	isCacheEntry_Meta()
 }
@@ -354,10 +419,16 @@ type CacheEntry_Node struct {
 	Node *NodeMeta `protobuf:"bytes,4,opt,name=node,proto3,oneof"`
 }
 
+type CacheEntry_Service struct {
+	Service *ServiceMeta `protobuf:"bytes,5,opt,name=service,proto3,oneof"`
+}
+
 func (*CacheEntry_Pod) isCacheEntry_Meta() {}
 
 func (*CacheEntry_Node) isCacheEntry_Meta() {}
 
+func (*CacheEntry_Service) isCacheEntry_Meta() {}
+
 type ListCacheRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -367,7 +438,7 @@ type ListCacheRequest struct {
 func (x *ListCacheRequest) Reset() {
 	*x = ListCacheRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_ipcache_proto_msgTypes[3]
+		mi := &file_ipcache_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -380,7 +451,7 @@ func (x *ListCacheRequest) String() string {
 func (*ListCacheRequest) ProtoMessage() {}
 
 func (x *ListCacheRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_ipcache_proto_msgTypes[3]
+	mi := &file_ipcache_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -393,7 +464,7 @@ func (x *ListCacheRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListCacheRequest.ProtoReflect.Descriptor instead.
 func (*ListCacheRequest) Descriptor() ([]byte, []int) {
-	return file_ipcache_proto_rawDescGZIP(), []int{3}
+	return file_ipcache_proto_rawDescGZIP(), []int{4}
 }
 
 type ListCacheResponse struct {
@@ -409,7 +480,7 @@ type ListCacheResponse struct {
 func (x *ListCacheResponse) Reset() {
 	*x = ListCacheResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_ipcache_proto_msgTypes[4]
+		mi := &file_ipcache_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -422,7 +493,7 @@ func (x *ListCacheResponse) String() string {
 func (*ListCacheResponse) ProtoMessage() {}
 
 func (x *ListCacheResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_ipcache_proto_msgTypes[4]
+	mi := &file_ipcache_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -435,7 +506,7 @@ func (x *ListCacheResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListCacheResponse.ProtoReflect.Descriptor instead.
 func (*ListCacheResponse) Descriptor() ([]byte, []int) {
-	return file_ipcache_proto_rawDescGZIP(), []int{4}
+	return file_ipcache_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ListCacheResponse) GetPeriod() string {
@@ -471,7 +542,7 @@ type WatchCacheRequest struct {
 func (x *WatchCacheRequest) Reset() {
 	*x = WatchCacheRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_ipcache_proto_msgTypes[5]
+		mi := &file_ipcache_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -484,7 +555,7 @@ func (x *WatchCacheRequest) String() string {
 func (*WatchCacheRequest) ProtoMessage() {}
 
 func (x *WatchCacheRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_ipcache_proto_msgTypes[5]
+	mi := &file_ipcache_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -497,7 +568,7 @@ func (x *WatchCacheRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use WatchCacheRequest.ProtoReflect.Descriptor instead.
 func (*WatchCacheRequest) Descriptor() ([]byte, []int) {
-	return file_ipcache_proto_rawDescGZIP(), []int{5}
+	return file_ipcache_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *WatchCacheRequest) GetPeriod() string {
@@ -527,7 +598,7 @@ type WatchCacheResponse struct {
 func (x *WatchCacheResponse) Reset() {
 	*x = WatchCacheResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_ipcache_proto_msgTypes[6]
+		mi := &file_ipcache_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -540,7 +611,7 @@ func (x *WatchCacheResponse) String() string {
 func (*WatchCacheResponse) ProtoMessage() {}
 
 func (x *WatchCacheResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_ipcache_proto_msgTypes[6]
+	mi := &file_ipcache_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -553,7 +624,7 @@ func (x *WatchCacheResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use WatchCacheResponse.ProtoReflect.Descriptor instead.
 func (*WatchCacheResponse) Descriptor() ([]byte, []int) {
-	return file_ipcache_proto_rawDescGZIP(), []int{6}
+	return file_ipcache_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *WatchCacheResponse) GetRevision() uint64 {
@@ -587,61 +658,69 @@ var file_ipcache_proto_rawDesc = []byte{
 	0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65,
 	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x1e, 0x0a, 0x08,
 	0x4e, 0x6f, 0x64, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0xb0, 0x01, 0x0a,
-	0x0a, 0x43, 0x61, 0x63, 0x68, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x0e, 0x0a, 0x02, 0x49,
-	0x50, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x49, 0x50, 0x12, 0x2d, 0x0a, 0x04, 0x74,
-	0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x63, 0x6f, 0x6e, 0x74,
-	0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65,
-	0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x2b, 0x0a, 0x03, 0x70, 0x6f,
-	0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f,
-	0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x50, 0x6f, 0x64, 0x4d, 0x65, 0x74, 0x61,
-	0x48, 0x00, 0x52, 0x03, 0x70, 0x6f, 0x64, 0x12, 0x2e, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18,
-	0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c,
-	0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x48,
-	0x00, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x42, 0x06, 0x0a, 0x04, 0x6d, 0x65, 0x74, 0x61, 0x22,
-	0x12, 0x0a, 0x10, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x22, 0x7d, 0x0a, 0x11, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x63, 0x68, 0x65,
-	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x65, 0x72, 0x69,
-	0x6f, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64,
-	0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x04, 0x52, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x34, 0x0a, 0x07,
-	0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
-	0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x43,
-	0x61, 0x63, 0x68, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69,
-	0x65, 0x73, 0x22, 0x47, 0x0a, 0x11, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x61, 0x63, 0x68, 0x65,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x65, 0x72, 0x69, 0x6f,
-	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x12,
-	0x1a, 0x0a, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x04, 0x52, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x92, 0x01, 0x0a, 0x12,
-	0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x2e,
-	0x0a, 0x06, 0x6f, 0x70, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16,
-	0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e,
-	0x4f, 0x70, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x06, 0x6f, 0x70, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x30,
-	0x0a, 0x05, 0x65, 0x6e, 0x74, 0x72, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
-	0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x43,
-	0x61, 0x63, 0x68, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x65, 0x6e, 0x74, 0x72, 0x79,
-	0x2a, 0x2a, 0x0a, 0x0d, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x46, 0x61, 0x6d, 0x69, 0x6c,
-	0x79, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x46, 0x5f, 0x49, 0x4e, 0x45, 0x54, 0x10, 0x00, 0x12, 0x0c,
-	0x0a, 0x08, 0x41, 0x46, 0x5f, 0x49, 0x4e, 0x45, 0x54, 0x36, 0x10, 0x01, 0x2a, 0x1e, 0x0a, 0x09,
-	0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x07, 0x0a, 0x03, 0x50, 0x6f, 0x64,
-	0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x4e, 0x6f, 0x64, 0x65, 0x10, 0x01, 0x2a, 0x1a, 0x0a, 0x06,
-	0x4f, 0x70, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x07, 0x0a, 0x03, 0x53, 0x65, 0x74, 0x10, 0x00, 0x12,
-	0x07, 0x0a, 0x03, 0x44, 0x65, 0x6c, 0x10, 0x01, 0x32, 0xb9, 0x01, 0x0a, 0x0e, 0x49, 0x50, 0x43,
-	0x61, 0x63, 0x68, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x50, 0x0a, 0x09, 0x4c,
-	0x69, 0x73, 0x74, 0x43, 0x61, 0x63, 0x68, 0x65, 0x12, 0x20, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72,
-	0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61,
-	0x63, 0x68, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x63, 0x6f, 0x6e,
-	0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x4c, 0x69, 0x73, 0x74,
-	0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x55, 0x0a,
-	0x0a, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x61, 0x63, 0x68, 0x65, 0x12, 0x21, 0x2e, 0x63, 0x6f,
-	0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x57, 0x61, 0x74,
-	0x63, 0x68, 0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22,
-	0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e,
-	0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x30, 0x01, 0x42, 0x08, 0x5a, 0x06, 0x2e, 0x2f, 0x3b, 0x72, 0x70, 0x63, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x3f, 0x0a, 0x0b,
+	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x12, 0x1c, 0x0a, 0x09, 0x6e,
+	0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0xe9, 0x01,
+	0x0a, 0x0a, 0x43, 0x61, 0x63, 0x68, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x0e, 0x0a, 0x02,
+	0x49, 0x50, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x49, 0x50, 0x12, 0x2d, 0x0a, 0x04,
+	0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x19, 0x2e, 0x63, 0x6f, 0x6e,
+	0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x56, 0x61, 0x6c, 0x75,
+	0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x2b, 0x0a, 0x03, 0x70,
+	0x6f, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72,
+	0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x50, 0x6f, 0x64, 0x4d, 0x65, 0x74,
+	0x61, 0x48, 0x00, 0x52, 0x03, 0x70, 0x6f, 0x64, 0x12, 0x2e, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c,
+	0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x4d, 0x65, 0x74, 0x61,
+	0x48, 0x00, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x37, 0x0a, 0x07, 0x73, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x6f, 0x6e, 0x74,
+	0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x48, 0x00, 0x52, 0x07, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x42, 0x06, 0x0a, 0x04, 0x6d, 0x65, 0x74, 0x61, 0x22, 0x12, 0x0a, 0x10, 0x4c, 0x69, 0x73,
+	0x74, 0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x7d, 0x0a,
+	0x11, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65,
+	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x72, 0x65,
+	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x34, 0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65,
+	0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f,
+	0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x43, 0x61, 0x63, 0x68, 0x65, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0x47, 0x0a, 0x11,
+	0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x76,
+	0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x72, 0x65, 0x76,
+	0x69, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x92, 0x01, 0x0a, 0x12, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43,
+	0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1a, 0x0a, 0x08,
+	0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08,
+	0x72, 0x65, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x2e, 0x0a, 0x06, 0x6f, 0x70, 0x63, 0x6f,
+	0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72,
+	0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x4f, 0x70, 0x43, 0x6f, 0x64, 0x65,
+	0x52, 0x06, 0x6f, 0x70, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x30, 0x0a, 0x05, 0x65, 0x6e, 0x74, 0x72,
+	0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f,
+	0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x43, 0x61, 0x63, 0x68, 0x65, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x05, 0x65, 0x6e, 0x74, 0x72, 0x79, 0x2a, 0x2a, 0x0a, 0x0d, 0x41, 0x64,
+	0x64, 0x72, 0x65, 0x73, 0x73, 0x46, 0x61, 0x6d, 0x69, 0x6c, 0x79, 0x12, 0x0b, 0x0a, 0x07, 0x41,
+	0x46, 0x5f, 0x49, 0x4e, 0x45, 0x54, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x41, 0x46, 0x5f, 0x49,
+	0x4e, 0x45, 0x54, 0x36, 0x10, 0x01, 0x2a, 0x2b, 0x0a, 0x09, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54,
+	0x79, 0x70, 0x65, 0x12, 0x07, 0x0a, 0x03, 0x50, 0x6f, 0x64, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04,
+	0x4e, 0x6f, 0x64, 0x65, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x10, 0x02, 0x2a, 0x1a, 0x0a, 0x06, 0x4f, 0x70, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x07, 0x0a,
+	0x03, 0x53, 0x65, 0x74, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x44, 0x65, 0x6c, 0x10, 0x01, 0x32,
+	0xb9, 0x01, 0x0a, 0x0e, 0x49, 0x50, 0x43, 0x61, 0x63, 0x68, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x12, 0x50, 0x0a, 0x09, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x63, 0x68, 0x65, 0x12,
+	0x20, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72, 0x70, 0x63,
+	0x2e, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x21, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f, 0x72,
+	0x70, 0x63, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x55, 0x0a, 0x0a, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x61, 0x63,
+	0x68, 0x65, 0x12, 0x21, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x5f,
+	0x72, 0x70, 0x63, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x61, 0x63, 0x68, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c,
+	0x65, 0x72, 0x5f, 0x72, 0x70, 0x63, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x43, 0x61, 0x63, 0x68,
+	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x42, 0x08, 0x5a, 0x06, 0x2e,
+	0x2f, 0x3b, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -657,35 +736,37 @@ func file_ipcache_proto_rawDescGZIP() []byte {
 }
 
 var file_ipcache_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_ipcache_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_ipcache_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_ipcache_proto_goTypes = []interface{}{
 	(AddressFamily)(0),         // 0: controller_rpc.AddressFamily
 	(ValueType)(0),             // 1: controller_rpc.ValueType
 	(OpCode)(0),                // 2: controller_rpc.OpCode
 	(*PodMeta)(nil),            // 3: controller_rpc.PodMeta
 	(*NodeMeta)(nil),           // 4: controller_rpc.NodeMeta
-	(*CacheEntry)(nil),         // 5: controller_rpc.CacheEntry
-	(*ListCacheRequest)(nil),   // 6: controller_rpc.ListCacheRequest
-	(*ListCacheResponse)(nil),  // 7: controller_rpc.ListCacheResponse
-	(*WatchCacheRequest)(nil),  // 8: controller_rpc.WatchCacheRequest
-	(*WatchCacheResponse)(nil), // 9: controller_rpc.WatchCacheResponse
+	(*ServiceMeta)(nil),        // 5: controller_rpc.ServiceMeta
+	(*CacheEntry)(nil),         // 6: controller_rpc.CacheEntry
+	(*ListCacheRequest)(nil),   // 7: controller_rpc.ListCacheRequest
+	(*ListCacheResponse)(nil),  // 8: controller_rpc.ListCacheResponse
+	(*WatchCacheRequest)(nil),  // 9: controller_rpc.WatchCacheRequest
+	(*WatchCacheResponse)(nil), // 10: controller_rpc.WatchCacheResponse
 }
 var file_ipcache_proto_depIdxs = []int32{
-	1, // 0: controller_rpc.CacheEntry.type:type_name -> controller_rpc.ValueType
-	3, // 1: controller_rpc.CacheEntry.pod:type_name -> controller_rpc.PodMeta
-	4, // 2: controller_rpc.CacheEntry.node:type_name -> controller_rpc.NodeMeta
-	5, // 3: controller_rpc.ListCacheResponse.entries:type_name -> controller_rpc.CacheEntry
-	2, // 4: controller_rpc.WatchCacheResponse.opcode:type_name -> controller_rpc.OpCode
-	5, // 5: controller_rpc.WatchCacheResponse.entry:type_name -> controller_rpc.CacheEntry
-	6, // 6: controller_rpc.IPCacheService.ListCache:input_type -> controller_rpc.ListCacheRequest
-	8, // 7: controller_rpc.IPCacheService.WatchCache:input_type -> controller_rpc.WatchCacheRequest
-	7, // 8: controller_rpc.IPCacheService.ListCache:output_type -> controller_rpc.ListCacheResponse
-	9, // 9: controller_rpc.IPCacheService.WatchCache:output_type -> controller_rpc.WatchCacheResponse
-	8, // [8:10] is the sub-list for method output_type
-	6, // [6:8] is the sub-list for method input_type
-	6, // [6:6] is the sub-list for extension type_name
-	6, // [6:6] is the sub-list for extension extendee
-	0, // [0:6] is the sub-list for field type_name
+	1,  // 0: controller_rpc.CacheEntry.type:type_name -> controller_rpc.ValueType
+	3,  // 1: controller_rpc.CacheEntry.pod:type_name -> controller_rpc.PodMeta
+	4,  // 2: controller_rpc.CacheEntry.node:type_name -> controller_rpc.NodeMeta
+	5,  // 3: controller_rpc.CacheEntry.service:type_name -> controller_rpc.ServiceMeta
+	6,  // 4: controller_rpc.ListCacheResponse.entries:type_name -> controller_rpc.CacheEntry
+	2,  // 5: controller_rpc.WatchCacheResponse.opcode:type_name -> controller_rpc.OpCode
+	6,  // 6: controller_rpc.WatchCacheResponse.entry:type_name -> controller_rpc.CacheEntry
+	7,  // 7: controller_rpc.IPCacheService.ListCache:input_type -> controller_rpc.ListCacheRequest
+	9,  // 8: controller_rpc.IPCacheService.WatchCache:input_type -> controller_rpc.WatchCacheRequest
+	8,  // 9: controller_rpc.IPCacheService.ListCache:output_type -> controller_rpc.ListCacheResponse
+	10, // 10: controller_rpc.IPCacheService.WatchCache:output_type -> controller_rpc.WatchCacheResponse
+	9,  // [9:11] is the sub-list for method output_type
+	7,  // [7:9] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_ipcache_proto_init() }
@@ -719,7 +800,7 @@ func file_ipcache_proto_init() {
 			}
 		}
 		file_ipcache_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CacheEntry); i {
+			switch v := v.(*ServiceMeta); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -731,7 +812,7 @@ func file_ipcache_proto_init() {
 			}
 		}
 		file_ipcache_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ListCacheRequest); i {
+			switch v := v.(*CacheEntry); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -743,7 +824,7 @@ func file_ipcache_proto_init() {
 			}
 		}
 		file_ipcache_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ListCacheResponse); i {
+			switch v := v.(*ListCacheRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -755,7 +836,7 @@ func file_ipcache_proto_init() {
 			}
 		}
 		file_ipcache_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*WatchCacheRequest); i {
+			switch v := v.(*ListCacheResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -767,6 +848,18 @@ func file_ipcache_proto_init() {
 			}
 		}
 		file_ipcache_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WatchCacheRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ipcache_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*WatchCacheResponse); i {
 			case 0:
 				return &v.state
@@ -779,9 +872,10 @@ func file_ipcache_proto_init() {
 			}
 		}
 	}
-	file_ipcache_proto_msgTypes[2].OneofWrappers = []interface{}{
+	file_ipcache_proto_msgTypes[3].OneofWrappers = []interface{}{
 		(*CacheEntry_Pod)(nil),
 		(*CacheEntry_Node)(nil),
+		(*CacheEntry_Service)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -789,7 +883,7 @@ func file_ipcache_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_ipcache_proto_rawDesc,
 			NumEnums:      3,
-			NumMessages:   7,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
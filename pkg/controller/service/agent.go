@@ -4,9 +4,14 @@ import (
 	"context"
 	"fmt"
 	"sync/atomic"
+	"time"
 
+	"github.com/alibaba/kubeskoop/pkg/controller/auth"
 	"github.com/alibaba/kubeskoop/pkg/controller/rpc"
 	"github.com/samber/lo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	log "k8s.io/klog/v2"
 )
 
@@ -15,15 +20,51 @@ type taskWatcher struct {
 	filter   *rpc.TaskFilter
 }
 
-func (c *controller) RegisterAgent(_ context.Context, _ *rpc.AgentInfo) (*rpc.ControllerInfo, error) {
+// taskNode is a task committed to the agent of a node, tasks of capturing in several nodes
+// share the same id.
+type taskNode struct {
+	id   string
+	node string
+}
+
+// checkNode rejects agents authenticated on other nodes, agents are not checked when
+// authentication is disabled.
+func checkNode(ctx context.Context, node, action string) error {
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok || identity.Node == node {
+		return nil
+	}
+	log.Warningf("agent %s on node %s %s of node %s", identity.ServiceAccount, identity.Node, action, node)
+	return status.Errorf(codes.PermissionDenied, "agent on node %s cannot %s of node %s", identity.Node, action, node)
+}
+
+func (c *controller) RegisterAgent(ctx context.Context, info *rpc.AgentInfo) (*rpc.ControllerInfo, error) {
+	if err := checkNode(ctx, info.GetNodeName(), "register agent"); err != nil {
+		return nil, err
+	}
 	return nil, nil
 }
 
-func (c *controller) ReportEvents(_ rpc.ControllerRegisterService_ReportEventsServer) error {
-	return nil
+// nodeNameMetadata is the metadata key of the node events are reported from, events carry
+// no node of their own.
+const nodeNameMetadata = "kubeskoop-node-name"
+
+// ReportEvents only accepts events of the node in metadata from the agent on it.
+func (c *controller) ReportEvents(server rpc.ControllerRegisterService_ReportEventsServer) error {
+	var node string
+	if md, ok := metadata.FromIncomingContext(server.Context()); ok {
+		if values := md.Get(nodeNameMetadata); len(values) > 0 {
+			node = values[0]
+		}
+	}
+	return checkNode(server.Context(), node, "report events")
 }
 
 func (c *controller) WatchTasks(filter *rpc.TaskFilter, server rpc.ControllerRegisterService_WatchTasksServer) error {
+	// authenticated agents only receive tasks of their own nodes
+	if err := checkNode(server.Context(), filter.GetNodeName(), "watch tasks"); err != nil {
+		return err
+	}
 	w := &taskWatcher{
 		taskChan: make(chan *rpc.ServerTask, 1),
 		filter:   filter,
@@ -47,7 +88,30 @@ func (c *controller) WatchTasks(filter *rpc.TaskFilter, server rpc.ControllerReg
 	}
 }
 
+// checkTaskResult rejects results uploaded by agents on nodes the task was not committed to.
+func (c *controller) checkTaskResult(ctx context.Context, result *rpc.TaskResult) error {
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if _, committed := c.taskNodes.Load(taskNode{id: result.GetId(), node: identity.Node}); !committed {
+		log.Warningf("agent %s on node %s uploads result of task %s not committed to it", identity.ServiceAccount, identity.Node, result.GetId())
+		return status.Errorf(codes.PermissionDenied, "task %s is not committed to node %s", result.GetId(), identity.Node)
+	}
+	if result.GetType() == rpc.TaskType_Capture {
+		return checkNode(ctx, result.GetTask().GetNode().GetName(), "upload capture result")
+	}
+	return nil
+}
+
 func (c *controller) UploadTaskResult(ctx context.Context, result *rpc.TaskResult) (*rpc.TaskResultReply, error) {
+	if err := c.checkTaskResult(ctx, result); err != nil {
+		return nil, err
+	}
+	if identity, ok := auth.IdentityFromContext(ctx); ok {
+		c.taskNodes.Delete(taskNode{id: result.GetId(), node: identity.Node})
+	}
+
 	id := result.Id
 	if watcher, ok := c.resultWatchers.Load(id); ok {
 		wchan := watcher.(chan *rpc.TaskResult)
@@ -75,16 +139,34 @@ var (
 	taskIdx int64
 )
 
+const (
+	// taskResultTTL is how long results of a committed task are accepted.
+	taskResultTTL = time.Hour
+	// taskNodesSweepInterval is the interval tasks whose results never came back are forgotten.
+	taskNodesSweepInterval = time.Minute
+)
+
 func getTaskIdx() int64 {
 	return atomic.AddInt64(&taskIdx, 1)
 }
 
+// sweepTaskNodes forgets tasks whose results never came back.
+func (c *controller) sweepTaskNodes(now time.Time) {
+	c.taskNodes.Range(func(key, value interface{}) bool {
+		if now.Sub(value.(time.Time)) > taskResultTTL {
+			c.taskNodes.Delete(key)
+		}
+		return true
+	})
+}
+
 func (c *controller) commitTask(node string, task *rpc.Task) ([]string, error) {
 	var commitedNode []string
 	c.taskWatcher.Range(func(key, value interface{}) bool {
 		filter := key.(*rpc.TaskFilter)
 		if filter.GetNodeName() == node {
 			if lo.Reduce[rpc.TaskType, bool](filter.GetType(), func(acc bool, t rpc.TaskType, _ int) bool { return acc || t == task.Type }, false) {
+				c.taskNodes.Store(taskNode{id: task.Id, node: node}, time.Now())
 				valueChan := value.(*taskWatcher)
 				valueChan.taskChan <- &rpc.ServerTask{
 					Server: &rpc.ControllerInfo{Version: ""},
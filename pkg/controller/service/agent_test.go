@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/controller/auth"
+	"github.com/alibaba/kubeskoop/pkg/controller/rpc"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAgentNodeBinding(t *testing.T) {
+	c := &controller{}
+	filter := &rpc.TaskFilter{NodeName: "node-1", Type: []rpc.TaskType{rpc.TaskType_Capture, rpc.TaskType_Ping}}
+	w := &taskWatcher{taskChan: make(chan *rpc.ServerTask, 2), filter: filter}
+	c.taskWatcher.Store(filter, w)
+
+	_, err := c.commitTask("node-1", &rpc.Task{Type: rpc.TaskType_Ping, Id: "1"})
+	assert.NoError(t, err)
+
+	node1 := auth.NewContext(context.Background(), &auth.Identity{ServiceAccount: "kubeskoop/agent", Node: "node-1"})
+	node2 := auth.NewContext(context.Background(), &auth.Identity{ServiceAccount: "kubeskoop/agent", Node: "node-2"})
+
+	_, err = c.RegisterAgent(node2, &rpc.AgentInfo{NodeName: "node-1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = c.RegisterAgent(node1, &rpc.AgentInfo{NodeName: "node-1"})
+	assert.NoError(t, err)
+
+	// results are only accepted from nodes the task was committed to
+	result := &rpc.TaskResult{Id: "1", Type: rpc.TaskType_Ping}
+	assert.Equal(t, codes.PermissionDenied, status.Code(c.checkTaskResult(node2, result)))
+	assert.NoError(t, c.checkTaskResult(node1, result))
+	assert.NoError(t, c.checkTaskResult(context.Background(), result))
+
+	// capture results cannot claim captures of other nodes in the same task
+	_, err = c.commitTask("node-1", &rpc.Task{Type: rpc.TaskType_Capture, Id: "2"})
+	assert.NoError(t, err)
+	capture := &rpc.TaskResult{Id: "2", Type: rpc.TaskType_Capture, Task: &rpc.CaptureInfo{Node: &rpc.NodeInfo{Name: "node-2"}}}
+	assert.Equal(t, codes.PermissionDenied, status.Code(c.checkTaskResult(node1, capture)))
+	capture.Task.Node.Name = "node-1"
+	assert.NoError(t, c.checkTaskResult(node1, capture))
+}
+
+type fakeReportEventsServer struct {
+	rpc.ControllerRegisterService_ReportEventsServer
+	ctx context.Context
+}
+
+func (s *fakeReportEventsServer) Context() context.Context {
+	return s.ctx
+}
+
+func TestReportEventsNodeBinding(t *testing.T) {
+	c := &controller{}
+	node1 := auth.NewContext(context.Background(), &auth.Identity{ServiceAccount: "kubeskoop/agent", Node: "node-1"})
+	report := func(ctx context.Context, node ...string) error {
+		if len(node) > 0 {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(nodeNameMetadata, node[0]))
+		}
+		return c.ReportEvents(&fakeReportEventsServer{ctx: ctx})
+	}
+
+	assert.NoError(t, report(node1, "node-1"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(report(node1, "node-2")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(report(node1)))
+	assert.NoError(t, report(context.Background()))
+}
+
+func TestSweepTaskNodes(t *testing.T) {
+	c := &controller{}
+	now := time.Now()
+	c.taskNodes.Store(taskNode{id: "1", node: "node-1"}, now.Add(-2*taskResultTTL))
+	c.taskNodes.Store(taskNode{id: "2", node: "node-1"}, now)
+
+	c.sweepTaskNodes(now)
+	_, ok := c.taskNodes.Load(taskNode{id: "1", node: "node-1"})
+	assert.False(t, ok)
+	_, ok = c.taskNodes.Load(taskNode{id: "2", node: "node-1"})
+	assert.True(t, ok)
+}
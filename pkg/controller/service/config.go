@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"fmt"
 
 	exporter "github.com/alibaba/kubeskoop/pkg/exporter/cmd"
 	"gopkg.in/yaml.v3"
@@ -22,6 +23,9 @@ func (c *controller) GetExporterConfig(ctx context.Context) (*exporter.InspServe
 }
 
 func (c *controller) UpdateExporterConfig(ctx context.Context, cfg *exporter.InspServerConfig) error {
+	if err := exporter.ValidateConfig(cfg); err != nil {
+		return fmt.Errorf("invalid exporter config: %w", err)
+	}
 	cm, err := c.getConfigMap(ctx, c.Namespace, c.ConfigMapName)
 	if err != nil {
 		return err
@@ -95,6 +95,12 @@ func NewControllerService(k8sClient *kubernetes.Clientset, config *Config) (Cont
 	}
 	ctrl.diagnostor = diagnose.NewDiagnoseController(ctrl.Namespace, &config.Diagnose)
 
+	go func() {
+		for now := range time.Tick(taskNodesSweepInterval) {
+			ctrl.sweepTaskNodes(now)
+		}
+	}()
+
 	return ctrl, nil
 }
 
@@ -104,8 +110,10 @@ type controller struct {
 	k8sClient      *kubernetes.Clientset
 	taskWatcher    sync.Map
 	resultWatchers sync.Map
-	promClient     api.Client
-	lokiClient     *lokiwrapper.Client
-	Namespace      string
-	ConfigMapName  string
+	// taskNodes are tasks committed to nodes, results of them are only accepted from agents on the nodes
+	taskNodes     sync.Map
+	promClient    api.Client
+	lokiClient    *lokiwrapper.Client
+	Namespace     string
+	ConfigMapName string
 }
@@ -93,9 +93,12 @@ func GetHumanTimes(ns uint64) string {
 }
 
 func GetCommString(comm [20]int8) string {
-	buf := make([]byte, 20)
-	for idx := range comm {
-		buf[idx] = byte(comm[idx])
+	buf := make([]byte, 0, len(comm))
+	for _, c := range comm {
+		if c == 0 {
+			break
+		}
+		buf = append(buf, byte(c))
 	}
 
 	return strings.TrimSpace(string(buf))
@@ -0,0 +1,51 @@
+package bpfutil
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cilium/ebpf"
+)
+
+// ObjectLoader returns the CollectionSpec of an embedded bpf object.
+type ObjectLoader func() (*ebpf.CollectionSpec, error)
+
+var (
+	objectsLock sync.Mutex
+	objects     = make(map[string]ObjectLoader)
+)
+
+// RegisterBPFObject registers the embedded bpf object of a probe, btfhack generates minimized
+// btf for registered objects.
+func RegisterBPFObject(name string, loader ObjectLoader) {
+	objectsLock.Lock()
+	defer objectsLock.Unlock()
+	if _, ok := objects[name]; ok {
+		panic(fmt.Sprintf("bpf object %s already registered", name))
+	}
+	objects[name] = loader
+}
+
+// ListBPFObjects returns names of registered bpf objects in order.
+func ListBPFObjects() []string {
+	objectsLock.Lock()
+	defer objectsLock.Unlock()
+	var names []string
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadBPFObjectSpec returns the CollectionSpec of the registered bpf object.
+func LoadBPFObjectSpec(name string) (*ebpf.CollectionSpec, error) {
+	objectsLock.Lock()
+	loader, ok := objects[name]
+	objectsLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("bpf object %s not registered", name)
+	}
+	return loader()
+}
@@ -1,10 +1,15 @@
 package bpfutil
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/cilium/ebpf"
+	log "github.com/sirupsen/logrus"
 )
 
 // MustPin pin a map, will remove old one default, prevent asynchrouny
@@ -50,3 +55,174 @@ func MustLoadPin(name string) (*ebpf.Map, error) {
 
 	return ebpf.LoadPinnedMap(path, &ebpf.LoadPinOptions{ReadOnly: true})
 }
+
+var (
+	// PinRoot is the directory of maps and programs pinned by probes, one sub directory per probe.
+	PinRoot = fmt.Sprintf("%s/kubeskoop", BPFFSPath)
+
+	pinning    bool
+	pinLock    sync.Mutex
+	unpinHooks = make(map[string]func() error)
+)
+
+// EnablePinning makes LoadObjects pin maps of probes, so counters and in-flight state
+// survive exporter restarts.
+func EnablePinning(enable bool) {
+	pinning = enable
+}
+
+// PinningEnabled returns whether maps of probes are pinned.
+func PinningEnabled() bool {
+	return pinning
+}
+
+// PinPath returns the directory of maps and programs pinned by the probe.
+func PinPath(probe string) string {
+	return filepath.Join(PinRoot, probe)
+}
+
+// RegisterUnpinHook registers a hook called before pins of the probe are removed, e.g. to
+// detach programs which are kept attached across restarts.
+func RegisterUnpinHook(probe string, hook func() error) {
+	pinLock.Lock()
+	defer pinLock.Unlock()
+	unpinHooks[probe] = hook
+}
+
+func ensurePinPath(probe string) (string, error) {
+	fs := filepath.Dir(PinRoot)
+	if err := MountAt(fs); err != nil {
+		return "", fmt.Errorf("failed mount bpf fs to %s: %w", fs, err)
+	}
+	dir := PinPath(probe)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed create pin path %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// pinnable returns whether the map keeps state worth pinning, event buffers and global
+// variables of the object are not pinned.
+func pinnable(spec *ebpf.MapSpec) bool {
+	if spec.Name == "" || strings.HasPrefix(spec.Name, ".") {
+		return false
+	}
+	return spec.Type != ebpf.PerfEventArray && spec.Type != ebpf.RingBuf
+}
+
+// pinMaps sets maps of the collection to be pinned by name under dir, pinned maps which are
+// incompatible with the spec are removed so they are recreated.
+func pinMaps(spec *ebpf.CollectionSpec, dir string) error {
+	for _, m := range spec.Maps {
+		if !pinnable(m) {
+			continue
+		}
+		m.Pinning = ebpf.PinByName
+
+		path := filepath.Join(dir, m.Name)
+		pinned, err := ebpf.LoadPinnedMap(path, nil)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			err = m.Compatible(pinned)
+			pinned.Close()
+			if err == nil {
+				continue
+			}
+		}
+		log.Infof("remove incompatible pinned map %s: %v", path, err)
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed remove pinned map %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// LoadObjects loads the registered bpf object of the probe and assigns it to objs like
+// loadBpfObjects generated by bpf2go. Maps are pinned under PinPath of the probe and reused
+// on next load when pinning is enabled, programs are recorded for ListProgramStats.
+func LoadObjects(probe string, objs interface{}, opts *ebpf.CollectionOptions) error {
+	spec, err := LoadBPFObjectSpec(probe)
+	if err != nil {
+		return err
+	}
+	return LoadAndAssign(probe, spec, objs, opts)
+}
+
+// LoadAndAssign is LoadObjects with a spec modified by the probe, e.g. with rewritten constants.
+func LoadAndAssign(probe string, spec *ebpf.CollectionSpec, objs interface{}, opts *ebpf.CollectionOptions) error {
+	if pinning {
+		dir, err := ensurePinPath(probe)
+		if err != nil {
+			return err
+		}
+		if err := pinMaps(spec, dir); err != nil {
+			return err
+		}
+		opts.Maps.PinPath = dir
+	}
+
+	if err := spec.LoadAndAssign(objs, opts); err != nil {
+		return err
+	}
+	registerPrograms(probe, objs)
+	return nil
+}
+
+// PinProgram pins the program of the probe by name when pinning is enabled, the old pin
+// is replaced.
+func PinProgram(probe, name string, prog *ebpf.Program) error {
+	if !pinning {
+		return nil
+	}
+	dir, err := ensurePinPath(probe)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed remove pinned program %s: %w", path, err)
+	}
+	if err := prog.Pin(path); err != nil {
+		return fmt.Errorf("failed pin program to %s: %w", path, err)
+	}
+	return nil
+}
+
+// ListPinned returns probes which have pinned maps or programs.
+func ListPinned() ([]string, error) {
+	entries, err := os.ReadDir(PinRoot)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed list %s: %w", PinRoot, err)
+	}
+	var ret []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ret = append(ret, e.Name())
+		}
+	}
+	return ret, nil
+}
+
+// Unpin runs the unpin hook of the probe and removes its pinned maps and programs, it should
+// only be called when the probe is removed rather than stopped.
+func Unpin(probe string) error {
+	pinLock.Lock()
+	hook := unpinHooks[probe]
+	pinLock.Unlock()
+	if hook != nil {
+		if err := hook(); err != nil {
+			return fmt.Errorf("failed run unpin hook of %s: %w", probe, err)
+		}
+	}
+
+	dir := PinPath(probe)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed remove pin path %s: %w", dir, err)
+	}
+	return nil
+}
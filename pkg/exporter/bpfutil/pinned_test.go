@@ -0,0 +1,95 @@
+package bpfutil
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
+	"github.com/stretchr/testify/assert"
+)
+
+type pinTestObjects struct {
+	Counters *ebpf.Map `ebpf:"counters"`
+	Events   *ebpf.Map `ebpf:"events"`
+}
+
+func (o *pinTestObjects) Close() {
+	o.Counters.Close()
+	o.Events.Close()
+}
+
+func pinTestSpec(maxEntries uint32) *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"counters": {Name: "counters", Type: ebpf.Hash, KeySize: 4, ValueSize: 8, MaxEntries: maxEntries},
+			"events":   {Name: "events", Type: ebpf.PerfEventArray},
+		},
+	}
+}
+
+func setupPinRoot(t *testing.T) {
+	dir := t.TempDir()
+	if err := syscall.Mount(dir, dir, "bpf", 0, ""); err != nil {
+		t.Skipf("cannot mount bpf fs: %v", err)
+	}
+	t.Cleanup(func() { _ = syscall.Unmount(dir, 0) })
+	if err := rlimit.RemoveMemlock(); err != nil {
+		t.Skipf("cannot remove memlock: %v", err)
+	}
+
+	root := PinRoot
+	PinRoot = filepath.Join(dir, "kubeskoop")
+	EnablePinning(true)
+	t.Cleanup(func() {
+		PinRoot = root
+		EnablePinning(false)
+	})
+}
+
+func TestLoadPinnedObjects(t *testing.T) {
+	setupPinRoot(t)
+
+	load := func(maxEntries uint32) *pinTestObjects {
+		var objs pinTestObjects
+		assert.NoError(t, LoadAndAssign("test", pinTestSpec(maxEntries), &objs, &ebpf.CollectionOptions{}))
+		return &objs
+	}
+
+	objs := load(16)
+	assert.NoError(t, objs.Counters.Put(uint32(1), uint64(42)))
+	objs.Close()
+
+	// event buffers are not pinned
+	pinned, err := ListPinned()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test"}, pinned)
+	_, err = os.Stat(filepath.Join(PinPath("test"), "events"))
+	assert.True(t, os.IsNotExist(err))
+
+	// counters survive reloading with the same spec
+	objs = load(16)
+	var val uint64
+	assert.NoError(t, objs.Counters.Lookup(uint32(1), &val))
+	assert.Equal(t, uint64(42), val)
+	objs.Close()
+
+	// incompatible pinned map is replaced
+	objs = load(32)
+	assert.Error(t, objs.Counters.Lookup(uint32(1), &val))
+	assert.Equal(t, uint32(32), objs.Counters.MaxEntries())
+	objs.Close()
+
+	var unpinned bool
+	RegisterUnpinHook("test", func() error {
+		unpinned = true
+		return nil
+	})
+	assert.NoError(t, Unpin("test"))
+	assert.True(t, unpinned)
+	pinned, err = ListPinned()
+	assert.NoError(t, err)
+	assert.Empty(t, pinned)
+}
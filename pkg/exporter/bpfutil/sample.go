@@ -0,0 +1,16 @@
+package bpfutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// ReadSample decodes a perf sample into event, the sample may be longer than the event as
+// perf pads it to 8 bytes alignment.
+func ReadSample(raw []byte, event interface{}) error {
+	if size := binary.Size(event); size > len(raw) {
+		return fmt.Errorf("invalid sample size %d, expect at least %d", len(raw), size)
+	}
+	return binary.Read(bytes.NewReader(raw), binary.NativeEndian, event)
+}
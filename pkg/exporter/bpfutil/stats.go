@@ -0,0 +1,194 @@
+package bpfutil
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+)
+
+const bpfStatsSysctl = "/proc/sys/kernel/bpf_stats_enabled"
+
+// ProgramStats is the runtime stats of a bpf program loaded by a probe, stats are zero
+// unless runtime stats are enabled.
+type ProgramStats struct {
+	ID       ebpf.ProgramID
+	Probe    string
+	Program  string
+	RunTime  time.Duration
+	RunCount uint64
+}
+
+type programRef struct {
+	probe   string
+	program string
+}
+
+var (
+	programsLock sync.Mutex
+	programs     = make(map[ebpf.ProgramID]programRef)
+)
+
+// EnableRuntimeStats enables runtime stats of bpf programs until the returned closer is closed,
+// bpf_stats_enabled sysctl is used on kernels without BPF_ENABLE_STATS.
+func EnableRuntimeStats() (io.Closer, error) {
+	closer, err := ebpf.EnableStats(unix.BPF_STATS_RUN_TIME)
+	if err == nil {
+		return closer, nil
+	}
+
+	old, sysctlErr := os.ReadFile(bpfStatsSysctl)
+	if sysctlErr != nil {
+		return nil, fmt.Errorf("failed enable bpf stats: %w", err)
+	}
+	if err := os.WriteFile(bpfStatsSysctl, []byte("1"), 0644); err != nil {
+		return nil, fmt.Errorf("failed enable bpf stats by %s: %w", bpfStatsSysctl, err)
+	}
+	return sysctlRestorer(old), nil
+}
+
+type sysctlRestorer []byte
+
+func (s sysctlRestorer) Close() error {
+	return os.WriteFile(bpfStatsSysctl, s, 0644)
+}
+
+// registerPrograms records ids of programs in objs assigned by LoadAndAssign, so their
+// stats are attributed to the probe.
+func registerPrograms(probe string, objs interface{}) {
+	programsLock.Lock()
+	defer programsLock.Unlock()
+	walkPrograms(reflect.ValueOf(objs), func(name string, prog *ebpf.Program) {
+		info, err := prog.Info()
+		if err != nil {
+			return
+		}
+		if id, ok := info.ID(); ok {
+			programs[id] = programRef{probe: probe, program: name}
+		}
+	})
+}
+
+func walkPrograms(v reflect.Value, f func(string, *ebpf.Program)) {
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		// objects generated by bpf2go embed unexported structs of programs and maps
+		field := v.Type().Field(i)
+		if field.Anonymous {
+			walkPrograms(v.Field(i), f)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if prog, ok := v.Field(i).Interface().(*ebpf.Program); ok && prog != nil {
+			f(field.Tag.Get("ebpf"), prog)
+		}
+	}
+}
+
+// ListProgramStats returns stats of programs loaded by probes, programs which have been
+// unloaded are dropped.
+func ListProgramStats() []ProgramStats {
+	programsLock.Lock()
+	defer programsLock.Unlock()
+
+	var ret []ProgramStats
+	for id, ref := range programs {
+		info, err := programInfo(id)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				delete(programs, id)
+			}
+			continue
+		}
+		stats := ProgramStats{ID: id, Probe: ref.probe, Program: ref.program}
+		stats.RunTime, _ = info.Runtime()
+		stats.RunCount, _ = info.RunCount()
+		ret = append(ret, stats)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Probe != ret[j].Probe {
+			return ret[i].Probe < ret[j].Probe
+		}
+		return ret[i].Program < ret[j].Program
+	})
+	return ret
+}
+
+func programInfo(id ebpf.ProgramID) (*ebpf.ProgramInfo, error) {
+	prog, err := ebpf.NewProgramFromID(id)
+	if err != nil {
+		return nil, err
+	}
+	defer prog.Close()
+	return prog.Info()
+}
+
+// ListMapMemory returns memory in bytes of maps used by programs of each probe.
+func ListMapMemory() map[string]uint64 {
+	programsLock.Lock()
+	maps := make(map[ebpf.MapID]string)
+	for id, ref := range programs {
+		info, err := programInfo(id)
+		if err != nil {
+			continue
+		}
+		ids, _ := info.MapIDs()
+		for _, mid := range ids {
+			maps[mid] = ref.probe
+		}
+	}
+	programsLock.Unlock()
+
+	ret := make(map[string]uint64)
+	for id, probe := range maps {
+		m, err := ebpf.NewMapFromID(id)
+		if err != nil {
+			continue
+		}
+		memlock, err := fdInfoValue(m.FD(), "memlock")
+		m.Close()
+		if err != nil {
+			continue
+		}
+		ret[probe] += memlock
+	}
+	return ret
+}
+
+// fdInfoValue reads a numeric field of /proc/self/fdinfo/<fd>.
+func fdInfoValue(fd int, key string) (uint64, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/self/fdinfo/%d", fd))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), ":")
+		if ok && k == key {
+			return strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("field %s not found in fdinfo of %d", key, fd)
+}
@@ -0,0 +1,47 @@
+package bpfutil
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/rlimit"
+	"github.com/stretchr/testify/assert"
+)
+
+type statsTestPrograms struct {
+	Filter *ebpf.Program `ebpf:"filter"`
+}
+
+type statsTestObjects struct {
+	statsTestPrograms
+}
+
+func TestListProgramStats(t *testing.T) {
+	if err := rlimit.RemoveMemlock(); err != nil {
+		t.Skipf("cannot remove memlock: %v", err)
+	}
+	spec := &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"filter": {
+				Name:         "filter",
+				Type:         ebpf.SocketFilter,
+				License:      "GPL",
+				Instructions: asm.Instructions{asm.LoadImm(asm.R0, 0, asm.DWord), asm.Return()},
+			},
+		},
+	}
+
+	var objs statsTestObjects
+	if err := LoadAndAssign("test", spec, &objs, &ebpf.CollectionOptions{}); err != nil {
+		t.Skipf("cannot load bpf program: %v", err)
+	}
+
+	stats := ListProgramStats()
+	assert.Len(t, stats, 1)
+	assert.Equal(t, "test", stats[0].Probe)
+	assert.Equal(t, "filter", stats[0].Program)
+
+	objs.Filter.Close()
+	assert.Empty(t, ListProgramStats())
+}
@@ -37,6 +37,15 @@ var (
 				return
 			}
 
+			if hasGenerateSource() {
+				btffile, err = generateBTF(btfDstPath)
+				if err == nil {
+					log.Printf("Generate btf file %s succeed\n", btffile)
+					return
+				}
+				log.Printf("Generate btf error: %s\n", err)
+			}
+
 			btffile, err = downloadBTFOnline(btfDstPath)
 			if err != nil {
 				log.Printf("Download btf error: %s\n", err)
@@ -67,4 +76,5 @@ func init() {
 
 	flags.StringVarP(&btfSrcPath, "src", "s", "", "btf source file")
 	flags.StringVarP(&btfDstPath, "dst", "p", "", "btf destination directory")
+	addGenerateFlags(flags)
 }
@@ -0,0 +1,271 @@
+package btfhack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/bpfutil"
+	// register bpf objects of all probes
+	_ "github.com/alibaba/kubeskoop/pkg/exporter/probe/all"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// generateCmd represents the generate command
+var (
+	generateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "generate minimized btf for bpf objects of probes from local kernel debug info",
+		Run: func(_ *cobra.Command, _ []string) {
+			if btfDstPath == "" {
+				btfDstPath = defaultBTFDstPath
+			}
+			btffile, err := generateBTF(btfDstPath)
+			if err != nil {
+				log.Fatalf("Generate btf error: %s\n", err)
+			}
+			log.Printf("Generate btf file %s succeed\n", btffile)
+		},
+	}
+
+	vmlinuxPath   string
+	debugInfoPath string
+	archivePath   string
+	skipVerify    bool
+)
+
+// vmlinux paths of kernel debuginfo packages relative to the mounted root
+var debugInfoCandidates = []string{
+	"usr/lib/debug/boot/vmlinux-%s",
+	"usr/lib/debug/lib/modules/%s/vmlinux",
+	"usr/lib/debug/usr/lib/modules/%s/vmlinux",
+	"lib/modules/%s/vmlinux",
+	"boot/vmlinux-%s",
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+
+	flags := generateCmd.PersistentFlags()
+	addGenerateFlags(flags)
+	flags.StringVarP(&btfDstPath, "dst", "p", "", "btf destination directory")
+}
+
+func addGenerateFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&vmlinuxPath, "vmlinux", "", "local vmlinux with btf or dwarf debug info")
+	flags.StringVar(&debugInfoPath, "debuginfo", "", "root directory of kernel debuginfo packages, e.g. host root mounted in the container")
+	flags.StringVar(&archivePath, "archive", "", "directory of btf archive, e.g. btfhub-archive")
+	flags.BoolVar(&skipVerify, "skip-verify", false, "skip verifying generated btf by loading bpf programs")
+}
+
+func hasGenerateSource() bool {
+	return vmlinuxPath != "" || debugInfoPath != "" || archivePath != ""
+}
+
+// generateBTF generates minimized btf of the running kernel to dstPath from the first available
+// source of vmlinux, debuginfo and archive.
+func generateBTF(dstPath string) (string, error) {
+	release, err := bpfutil.KernelRelease()
+	if err != nil {
+		return "", err
+	}
+
+	spec, src, err := loadSourceBTF(release)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Load source btf from %s\n", src)
+
+	specs := make(map[string]*ebpf.CollectionSpec)
+	for _, name := range bpfutil.ListBPFObjects() {
+		s, err := bpfutil.LoadBPFObjectSpec(name)
+		if err != nil {
+			return "", fmt.Errorf("failed load bpf object %s: %w", name, err)
+		}
+		specs[name] = s
+	}
+
+	raw, err := minimizeBTF(spec, specs)
+	if errors.Is(err, errReloLayout) {
+		log.Printf("Failed minimize btf, use the full btf: %v\n", err)
+		raw, err = marshalBTF(spec)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !skipVerify {
+		minimized, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+		if err != nil {
+			return "", fmt.Errorf("failed load generated btf: %w", err)
+		}
+		if err := verifyBTF(minimized, spec, specs); err != nil {
+			return "", err
+		}
+	}
+
+	dst := filepath.Join(dstPath, fmt.Sprintf("vmlinux-%s", release))
+	if err := os.WriteFile(dst, raw, 0644); err != nil {
+		return "", fmt.Errorf("failed write btf: %w", err)
+	}
+	return dst, nil
+}
+
+func loadSourceBTF(release string) (*btf.Spec, string, error) {
+	var errs []string
+	if vmlinuxPath != "" {
+		spec, err := loadVmlinux(vmlinuxPath)
+		if err == nil {
+			return spec, vmlinuxPath, nil
+		}
+		errs = append(errs, err.Error())
+	}
+
+	if debugInfoPath != "" {
+		for _, c := range debugInfoCandidates {
+			path := filepath.Join(debugInfoPath, fmt.Sprintf(c, release))
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			spec, err := loadVmlinux(path)
+			if err == nil {
+				return spec, path, nil
+			}
+			errs = append(errs, err.Error())
+		}
+		errs = append(errs, fmt.Sprintf("no usable vmlinux of %s in %s", release, debugInfoPath))
+	}
+
+	if archivePath != "" {
+		path, err := findInArchive(archivePath, release)
+		if err == nil {
+			var spec *btf.Spec
+			spec, err = loadArchivedBTF(path)
+			if err == nil {
+				return spec, path, nil
+			}
+		}
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) == 0 {
+		return nil, "", fmt.Errorf("no btf source, use --vmlinux, --debuginfo or --archive")
+	}
+	return nil, "", fmt.Errorf("no btf source available: %s", strings.Join(errs, "; "))
+}
+
+// loadVmlinux loads btf of vmlinux, btf is encoded by pahole if vmlinux only has dwarf.
+func loadVmlinux(path string) (*btf.Spec, error) {
+	spec, err := btf.LoadSpec(path)
+	if err == nil {
+		return spec, nil
+	}
+
+	tmp, err := os.CreateTemp("", "vmlinux-btf-")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	out, err := exec.Command("pahole", "--btf_encode_detached="+tmp.Name(), path).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed encode btf of %s with pahole: %w, output: %s", path, err, strings.TrimSpace(string(out)))
+	}
+	return btf.LoadSpec(tmp.Name())
+}
+
+// findInArchive finds btf of the kernel release in the archive, both btfhub layout
+// <id>/<version>/<arch>/<release>.btf.tar.xz and flat vmlinux-<release> are supported.
+func findInArchive(dir, release string) (string, error) {
+	names := map[string]bool{
+		release + ".btf":        true,
+		release + ".btf.tar.xz": true,
+		"vmlinux-" + release:    true,
+	}
+	var found string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && names[d.Name()] {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed walk archive %s: %w", dir, err)
+	}
+	if found == "" {
+		return "", fmt.Errorf("btf of %s not found in archive %s", release, dir)
+	}
+	return found, nil
+}
+
+func loadArchivedBTF(path string) (*btf.Spec, error) {
+	if !strings.HasSuffix(path, ".tar.xz") {
+		return bpfutil.LoadBTFFromFile(path)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "btf-archive-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	out, err := exec.Command("tar", "-xJf", path, "-C", tmpDir).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed extract %s: %w, output: %s", path, err, strings.TrimSpace(string(out)))
+	}
+	name := strings.TrimSuffix(filepath.Base(path), ".tar.xz")
+	return bpfutil.LoadBTFFromFile(filepath.Join(tmpDir, name))
+}
+
+// verifyBTF loads programs of each bpf object with the generated btf, objects which cannot be
+// loaded with the source btf either are skipped, e.g. helpers are not supported by the kernel.
+func verifyBTF(minimized, source *btf.Spec, specs map[string]*ebpf.CollectionSpec) error {
+	var failed []string
+	for _, name := range bpfutil.ListBPFObjects() {
+		s, ok := specs[name]
+		if !ok {
+			continue
+		}
+		err := loadWithBTF(s, minimized)
+		if err == nil {
+			log.Printf("Verify btf with %s succeed\n", name)
+			continue
+		}
+		if srcErr := loadWithBTF(s, source); srcErr != nil {
+			log.Printf("Skip verifying btf with %s, cannot load with source btf: %s\n", name, srcErr)
+			continue
+		}
+		log.Printf("Verify btf with %s failed: %s\n", name, err)
+		failed = append(failed, name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed load programs of %s with generated btf", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func loadWithBTF(spec *ebpf.CollectionSpec, types *btf.Spec) error {
+	coll, err := ebpf.NewCollectionWithOptions(spec, ebpf.CollectionOptions{
+		Programs: ebpf.ProgramOptions{KernelTypes: types},
+	})
+	if err != nil {
+		return err
+	}
+	coll.Close()
+	return nil
+}
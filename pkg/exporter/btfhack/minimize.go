@@ -0,0 +1,371 @@
+package btfhack
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+)
+
+// kinds of CO-RE relocations, see enum bpf_core_relo_kind in include/uapi/linux/bpf.h
+const (
+	reloFieldByteOffset = iota
+	reloFieldByteSize
+	reloFieldExists
+	reloFieldSigned
+	reloFieldLShiftU64
+	reloFieldRShiftU64
+	reloTypeIDLocal
+	reloTypeIDTarget
+	reloTypeExists
+	reloTypeSize
+	reloEnumvalExists
+	reloEnumvalValue
+)
+
+// coreRelo is a CO-RE relocation of an instruction.
+type coreRelo struct {
+	typ      btf.Type
+	accessor []int
+	kind     uint32
+}
+
+// errReloLayout is returned when the private fields of CO-RE relocations in cilium/ebpf change,
+// the full btf is used instead of the minimized one.
+var errReloLayout = errors.New("unknown layout of cilium/ebpf CO-RE relocations")
+
+// reloOf returns the CO-RE relocation of the instruction. cilium/ebpf keeps fields of relocations
+// private, they are read by reflection and checked in case the layout changes.
+func reloOf(ins *asm.Instruction) (*coreRelo, error) {
+	r := btf.CORERelocationMetadata(ins)
+	if r == nil {
+		return nil, nil
+	}
+	v := reflect.ValueOf(r).Elem()
+	typ := v.FieldByName("typ")
+	accessor := v.FieldByName("accessor")
+	kind := v.FieldByName("kind")
+	if !typ.IsValid() || !typ.Type().Implements(reflect.TypeOf((*btf.Type)(nil)).Elem()) {
+		return nil, fmt.Errorf("%w: field typ", errReloLayout)
+	}
+	if !accessor.IsValid() || accessor.Kind() != reflect.Slice || !accessor.Type().Elem().ConvertibleTo(reflect.TypeOf(0)) {
+		return nil, fmt.Errorf("%w: field accessor", errReloLayout)
+	}
+	if !kind.IsValid() || !kind.CanUint() {
+		return nil, fmt.Errorf("%w: field kind", errReloLayout)
+	}
+
+	t, ok := reflect.NewAt(typ.Type(), unsafe.Pointer(typ.UnsafeAddr())).Elem().Interface().(btf.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: nil typ", errReloLayout)
+	}
+	ret := &coreRelo{typ: t, kind: uint32(kind.Uint())}
+	for i := 0; i < accessor.Len(); i++ {
+		ret.accessor = append(ret.accessor, int(accessor.Index(i).Int()))
+	}
+	return ret, nil
+}
+
+// collectRelos returns CO-RE relocations of all programs in the collection.
+func collectRelos(spec *ebpf.CollectionSpec) ([]*coreRelo, error) {
+	var ret []*coreRelo
+	for _, prog := range spec.Programs {
+		for i := range prog.Instructions {
+			r, err := reloOf(&prog.Instructions[i])
+			if err != nil {
+				return nil, err
+			}
+			if r != nil {
+				ret = append(ret, r)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// essentialName strips the flavor of the type name, e.g. sk_buff___old is sk_buff.
+func essentialName(name string) string {
+	if i := strings.LastIndex(name, "___"); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func sameKind(a, b btf.Type) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b)
+}
+
+// minimizer collects types and members of the kernel btf referenced by CO-RE relocations.
+type minimizer struct {
+	target *btf.Spec
+	roots  []btf.Type
+	seen   map[btf.Type]bool
+	// used members of structs and unions
+	members map[btf.Type]map[int]bool
+	copies  map[btf.Type]btf.Type
+}
+
+func newMinimizer(target *btf.Spec) *minimizer {
+	return &minimizer{
+		target:  target,
+		seen:    make(map[btf.Type]bool),
+		members: make(map[btf.Type]map[int]bool),
+		copies:  make(map[btf.Type]btf.Type),
+	}
+}
+
+func (m *minimizer) addRoot(t btf.Type) {
+	if !m.seen[t] {
+		m.seen[t] = true
+		m.roots = append(m.roots, t)
+	}
+	if _, ok := membersOf(t); ok && m.members[t] == nil {
+		m.members[t] = make(map[int]bool)
+	}
+}
+
+// candidates returns types of the kernel with the same essential name and kind as local.
+func (m *minimizer) candidates(local btf.Type) ([]btf.Type, error) {
+	name := essentialName(local.TypeName())
+	if name == "" {
+		return nil, nil
+	}
+	types, err := m.target.AnyTypesByName(name)
+	if errors.Is(err, btf.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ret []btf.Type
+	for _, t := range types {
+		if sameKind(btf.UnderlyingType(t), local) || sameKind(t, local) {
+			ret = append(ret, t)
+		}
+	}
+	return ret, nil
+}
+
+// add records kernel types needed by the relocation, relocations without a matching kernel type
+// are skipped as they are guarded by existence checks or fail at load time.
+func (m *minimizer) add(r *coreRelo) error {
+	if r.kind == reloTypeIDLocal {
+		return nil
+	}
+	cands, err := m.candidates(r.typ)
+	if err != nil {
+		return err
+	}
+	for _, cand := range cands {
+		switch r.kind {
+		case reloTypeIDTarget, reloTypeExists, reloTypeSize, reloEnumvalExists, reloEnumvalValue:
+			m.addRoot(cand)
+		default:
+			used, ok := walkAccessor(r.typ, cand, r.accessor)
+			if !ok {
+				continue
+			}
+			m.addRoot(cand)
+			for _, u := range used {
+				if m.members[u.typ] == nil {
+					m.members[u.typ] = make(map[int]bool)
+				}
+				m.members[u.typ][u.index] = true
+			}
+		}
+	}
+	return nil
+}
+
+func membersOf(t btf.Type) ([]btf.Member, bool) {
+	switch v := t.(type) {
+	case *btf.Struct:
+		return v.Members, true
+	case *btf.Union:
+		return v.Members, true
+	}
+	return nil, false
+}
+
+type memberRef struct {
+	typ   btf.Type
+	index int
+}
+
+// findMember finds the member by name in the target, members of anonymous structs and unions
+// are searched as well.
+func findMember(target btf.Type, name string) ([]memberRef, btf.Type, bool) {
+	members, _ := membersOf(target)
+	for i, mb := range members {
+		if mb.Name == name {
+			return []memberRef{{target, i}}, mb.Type, true
+		}
+		if mb.Name != "" {
+			continue
+		}
+		inner := btf.UnderlyingType(mb.Type)
+		if path, typ, ok := findMember(inner, name); ok {
+			return append([]memberRef{{target, i}}, path...), typ, true
+		}
+	}
+	return nil, nil, false
+}
+
+// walkAccessor follows the accessor of a field relocation in the local type and the target type
+// and returns members of the target used on the way. Like libbpf, anonymous local members are
+// skipped and named members are searched in anonymous members of the target.
+func walkAccessor(local, target btf.Type, accessor []int) ([]memberRef, bool) {
+	var used []memberRef
+	// the first index is an array index on the root type
+	for _, idx := range accessor[1:] {
+		local = btf.UnderlyingType(local)
+		target = btf.UnderlyingType(target)
+		switch l := local.(type) {
+		case *btf.Struct, *btf.Union:
+			localMembers, _ := membersOf(l)
+			if idx >= len(localMembers) {
+				return nil, false
+			}
+			lm := localMembers[idx]
+			if lm.Name == "" {
+				local = lm.Type
+				continue
+			}
+			path, typ, ok := findMember(target, lm.Name)
+			if !ok {
+				return nil, false
+			}
+			used = append(used, path...)
+			local, target = lm.Type, typ
+		case *btf.Array:
+			t, ok := target.(*btf.Array)
+			if !ok {
+				return nil, false
+			}
+			local, target = l.Type, t.Type
+		default:
+			return nil, false
+		}
+	}
+	return used, true
+}
+
+// shrink copies the kernel type with only used members of structs and unions, other
+// structs and unions keep their names and sizes without members.
+func (m *minimizer) shrink(t btf.Type) btf.Type {
+	if c, ok := m.copies[t]; ok {
+		return c
+	}
+	switch v := t.(type) {
+	case *btf.Struct:
+		c := &btf.Struct{Name: v.Name, Size: v.Size}
+		m.copies[t] = c
+		c.Members = m.shrinkMembers(t, v.Members)
+		return c
+	case *btf.Union:
+		c := &btf.Union{Name: v.Name, Size: v.Size}
+		m.copies[t] = c
+		c.Members = m.shrinkMembers(t, v.Members)
+		return c
+	case *btf.Pointer:
+		c := &btf.Pointer{}
+		m.copies[t] = c
+		c.Target = m.shrink(v.Target)
+		return c
+	case *btf.Array:
+		c := &btf.Array{Nelems: v.Nelems}
+		m.copies[t] = c
+		c.Index = m.shrink(v.Index)
+		c.Type = m.shrink(v.Type)
+		return c
+	case *btf.Typedef:
+		c := &btf.Typedef{Name: v.Name}
+		m.copies[t] = c
+		c.Type = m.shrink(v.Type)
+		return c
+	case *btf.Volatile:
+		c := &btf.Volatile{}
+		m.copies[t] = c
+		c.Type = m.shrink(v.Type)
+		return c
+	case *btf.Const:
+		c := &btf.Const{}
+		m.copies[t] = c
+		c.Type = m.shrink(v.Type)
+		return c
+	case *btf.Restrict:
+		c := &btf.Restrict{}
+		m.copies[t] = c
+		c.Type = m.shrink(v.Type)
+		return c
+	case *btf.FuncProto:
+		// function pointers are never followed by relocations
+		c := &btf.FuncProto{Return: &btf.Void{}}
+		m.copies[t] = c
+		return c
+	default:
+		// ints, floats, enums and forward declarations have no references
+		m.copies[t] = t
+		return t
+	}
+}
+
+func (m *minimizer) shrinkMembers(t btf.Type, members []btf.Member) []btf.Member {
+	var ret []btf.Member
+	for i, mb := range members {
+		if !m.members[t][i] {
+			continue
+		}
+		mb.Type = m.shrink(mb.Type)
+		ret = append(ret, mb)
+	}
+	return ret
+}
+
+// marshal encodes the minimized btf in raw format.
+func (m *minimizer) marshal() ([]byte, error) {
+	var types []btf.Type
+	for _, t := range m.roots {
+		types = append(types, m.shrink(t))
+	}
+	b, err := btf.NewBuilder(types)
+	if err != nil {
+		return nil, fmt.Errorf("failed build btf: %w", err)
+	}
+	return b.Marshal(nil, nil)
+}
+
+// minimizeBTF generates btf with only types of target needed by CO-RE relocations of specs.
+func minimizeBTF(target *btf.Spec, specs map[string]*ebpf.CollectionSpec) ([]byte, error) {
+	m := newMinimizer(target)
+	for name, spec := range specs {
+		relos, err := collectRelos(spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range relos {
+			if err := m.add(r); err != nil {
+				return nil, fmt.Errorf("failed resolve relocation of %s: %w", name, err)
+			}
+		}
+	}
+	return m.marshal()
+}
+
+// marshalBTF encodes all types of spec in raw format.
+func marshalBTF(spec *btf.Spec) ([]byte, error) {
+	var types []btf.Type
+	for iter := spec.Iterate(); iter.Next(); {
+		types = append(types, iter.Type)
+	}
+	b, err := btf.NewBuilder(types)
+	if err != nil {
+		return nil, fmt.Errorf("failed build btf: %w", err)
+	}
+	return b.Marshal(nil, nil)
+}
@@ -0,0 +1,100 @@
+package btfhack
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/bpfutil"
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEssentialName(t *testing.T) {
+	assert.Equal(t, "sk_buff", essentialName("sk_buff___old"))
+	assert.Equal(t, "sk_buff", essentialName("sk_buff"))
+	assert.Equal(t, "___x", essentialName("___x"))
+}
+
+func fixups(t *testing.T, spec *ebpf.CollectionSpec, target *btf.Spec) []string {
+	var ret []string
+	for _, name := range sortedPrograms(spec) {
+		prog := spec.Programs[name]
+		var relos []*btf.CORERelocation
+		for i := range prog.Instructions {
+			if r := btf.CORERelocationMetadata(&prog.Instructions[i]); r != nil {
+				relos = append(relos, r)
+			}
+		}
+		fs, err := btf.CORERelocate(relos, target, spec.ByteOrder)
+		assert.NoError(t, err, name)
+		for _, f := range fs {
+			ret = append(ret, name+" "+f.String())
+		}
+	}
+	return ret
+}
+
+func TestMinimizeBTF(t *testing.T) {
+	kernel, err := btf.LoadKernelSpec()
+	if err != nil {
+		t.Skipf("kernel btf not available: %v", err)
+	}
+
+	specs := make(map[string]*ebpf.CollectionSpec)
+	for _, name := range bpfutil.ListBPFObjects() {
+		spec, err := bpfutil.LoadBPFObjectSpec(name)
+		assert.NoError(t, err)
+		specs[name] = spec
+	}
+	assert.NotEmpty(t, specs)
+
+	raw, err := minimizeBTF(kernel, specs)
+	assert.NoError(t, err)
+	minimized, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	assert.NoError(t, err)
+
+	// relocations must be resolved to the same offsets and sizes as the full kernel btf
+	for name, spec := range specs {
+		assert.Equal(t, fixups(t, spec, kernel), fixups(t, spec, minimized), name)
+	}
+}
+
+func sortedPrograms(spec *ebpf.CollectionSpec) []string {
+	var names []string
+	for name := range spec.Programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCollectRelos(t *testing.T) {
+	// private fields of relocations are still where reloOf expects them
+	for _, name := range bpfutil.ListBPFObjects() {
+		spec, err := bpfutil.LoadBPFObjectSpec(name)
+		assert.NoError(t, err)
+		_, err = collectRelos(spec)
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestMarshalBTF(t *testing.T) {
+	b, err := btf.NewBuilder([]btf.Type{&btf.Struct{Name: "sock", Size: 8, Members: []btf.Member{
+		{Name: "sk_drops", Type: &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed}},
+	}}})
+	assert.NoError(t, err)
+	raw, err := b.Marshal(nil, nil)
+	assert.NoError(t, err)
+	spec, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	assert.NoError(t, err)
+
+	raw, err = marshalBTF(spec)
+	assert.NoError(t, err)
+	full, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	assert.NoError(t, err)
+	var sock *btf.Struct
+	assert.NoError(t, full.TypeByName("sock", &sock))
+	assert.Equal(t, "sk_drops", sock.Members[0].Name)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/bpfutil"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const cleanupTimeout = 10 * time.Second
+
+var (
+	cleanupCmd = &cobra.Command{
+		Use:   "cleanup",
+		Short: "remove pinned bpf maps and programs of all probes, e.g. on uninstall",
+		Long: "remove pinned bpf maps and programs of all probes, programs kept attached by pinning are detached. " +
+			"With --if-uninstalled it is meant to be the preStop hook of the agent, pins are only removed when " +
+			"the daemonset of the pod is deleted, so they survive restarts and rolling updates.",
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if cleanupIfUninstalled {
+				ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+				defer cancel()
+				uninstalled, err := ownerDaemonSetDeleted(ctx, os.Getenv("POD_NAMESPACE"), os.Getenv("POD_NAME"))
+				if err != nil {
+					return err
+				}
+				if !uninstalled {
+					log.Infof("daemonset of the pod is not deleted, keep pinned probes")
+					return nil
+				}
+			}
+			return unpinAll()
+		},
+	}
+
+	cleanupIfUninstalled bool
+)
+
+func init() {
+	rootCmd.AddCommand(cleanupCmd)
+
+	cleanupCmd.Flags().BoolVar(&cleanupIfUninstalled, "if-uninstalled", false, "only cleanup when the daemonset of the pod in env POD_NAMESPACE and POD_NAME is deleted")
+}
+
+func unpinAll() error {
+	pinned, err := bpfutil.ListPinned()
+	if err != nil {
+		return err
+	}
+	for _, name := range pinned {
+		log.Infof("remove pinned maps of probe %s", name)
+		if err := bpfutil.Unpin(name); err != nil {
+			return fmt.Errorf("failed unpin probe %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ownerDaemonSetDeleted returns whether the daemonset owning the pod is deleted or being deleted.
+func ownerDaemonSetDeleted(ctx context.Context, namespace, name string) (bool, error) {
+	if namespace == "" || name == "" {
+		return false, fmt.Errorf("env POD_NAMESPACE and POD_NAME are required")
+	}
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return false, fmt.Errorf("failed get kubernetes config: %w", err)
+	}
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return false, fmt.Errorf("failed create kubernetes client: %w", err)
+	}
+	return daemonSetDeleted(ctx, c, namespace, name)
+}
+
+func daemonSetDeleted(ctx context.Context, c client.Client, namespace, name string) (bool, error) {
+	pod := &v1.Pod{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pod); err != nil {
+		return false, fmt.Errorf("failed get pod %s/%s: %w", namespace, name, err)
+	}
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind != "DaemonSet" {
+			continue
+		}
+		ds := &appsv1.DaemonSet{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, ds)
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed get daemonset %s/%s: %w", namespace, ref.Name, err)
+		}
+		// a recreated daemonset with the same name is another one
+		return ds.UID != ref.UID || ds.DeletionTimestamp != nil, nil
+	}
+	return false, fmt.Errorf("pod %s/%s is not owned by a daemonset", namespace, name)
+}
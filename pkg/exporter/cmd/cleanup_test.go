@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDaemonSetDeleted(t *testing.T) {
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: "kubeskoop", Name: "agent", UID: "ds1"}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "kubeskoop", Name: "agent-x", OwnerReferences: []metav1.OwnerReference{
+		{Kind: "DaemonSet", Name: "agent", UID: "ds1"},
+	}}}
+	ctx := context.Background()
+
+	// restarts and rolling updates keep the daemonset
+	deleted, err := daemonSetDeleted(ctx, fake.NewClientBuilder().WithObjects(ds, pod).Build(), "kubeskoop", "agent-x")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	deleted, err = daemonSetDeleted(ctx, fake.NewClientBuilder().WithObjects(pod).Build(), "kubeskoop", "agent-x")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleting := ds.DeepCopy()
+	now := metav1.NewTime(time.Now())
+	deleting.DeletionTimestamp = &now
+	deleting.Finalizers = []string{"foregroundDeletion"}
+	deleted, err = daemonSetDeleted(ctx, fake.NewClientBuilder().WithObjects(deleting, pod).Build(), "kubeskoop", "agent-x")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	_, err = daemonSetDeleted(ctx, fake.NewClientBuilder().Build(), "kubeskoop", "agent-x")
+	assert.Error(t, err)
+}
@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/alibaba/kubeskoop/pkg/exporter/otlp"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/alibaba/kubeskoop/pkg/exporter/remotewrite"
+	"github.com/alibaba/kubeskoop/pkg/exporter/sink"
+	task_agent "github.com/alibaba/kubeskoop/pkg/exporter/task-agent"
+	"github.com/alibaba/kubeskoop/pkg/exporter/tlsutil"
 	"gopkg.in/yaml.v3"
 )
 
@@ -18,16 +26,42 @@ type InspServerConfig struct {
 	ControllerAddr   string        `yaml:"controllerAddr" mapstructure:"controllerAddr" json:"controllerAddr"`
 	MetricsConfig    MetricsConfig `yaml:"metrics" mapstructure:"metrics" json:"metrics"`
 	EventConfig      EventConfig   `yaml:"event" mapstructure:"event" json:"event"`
+	// PinBPFMaps pins bpf maps of probes under /sys/fs/bpf/kubeskoop, so counters in bpf maps survive
+	// restarts, changes of it take effect after restart. Counters aggregated from events in the exporter,
+	// e.g. of packetloss and tcpretrans, are not kept. Pins are removed by the cleanup command.
+	PinBPFMaps bool `yaml:"pinBPFMaps" mapstructure:"pinBPFMaps" json:"pinBPFMaps"`
+	// Overhead accounts cpu and memory of probes and throttles probes exceeding cpu budgets.
+	Overhead *OverheadConfig `yaml:"overhead" mapstructure:"overhead" json:"overhead"`
+	// TLS serves metrics, status and pprof over https, client certificates are required if caFile is set,
+	// changes of it take effect after restart.
+	TLS *tlsutil.Config `yaml:"tls" mapstructure:"tls" json:"tls"`
+	// ControllerTLS connects to the controller over tls, the client certificate is sent if certFile is set.
+	ControllerTLS *tlsutil.Config `yaml:"controllerTLS" mapstructure:"controllerTLS" json:"controllerTLS"`
+	// ControllerTokenFile is a service account token sent to the controller to authenticate the agent,
+	// it is read on each connection so rotated tokens are used. ControllerTLS is required when it is set.
+	ControllerTokenFile string `yaml:"controllerTokenFile" mapstructure:"controllerTokenFile" json:"controllerTokenFile"`
 }
 
 type MetricsConfig struct {
 	Probes           []ProbeConfig `yaml:"probes" mapstructure:"probes" json:"probes"`
 	AdditionalLabels []string      `yaml:"additionalLabels" mapstructure:"additionalLabels" json:"additionalLabels"`
+	// ScrapeTimeout is the default collection budget of a probe in a scrape, cached metrics
+	// are served when a probe exceeds it.
+	ScrapeTimeout time.Duration `yaml:"scrapeTimeout" mapstructure:"scrapeTimeout" json:"scrapeTimeout"`
+	// OTLP pushes metrics to an OpenTelemetry collector when endpoint is set
+	OTLP *otlp.Config `yaml:"otlp" mapstructure:"otlp" json:"otlp"`
+	// RemoteWrite pushes metrics with prometheus remote write protocol when url is set
+	RemoteWrite *remotewrite.Config `yaml:"remoteWrite" mapstructure:"remoteWrite" json:"remoteWrite"`
+	// EventMetrics derives metrics from events of event probes, changes of it take effect after restart.
+	EventMetrics []EventMetricsConfig `yaml:"eventMetrics" mapstructure:"eventMetrics" json:"eventMetrics"`
 }
 
 type EventConfig struct {
 	EventSinks []EventSinkConfig `yaml:"sinks" mapstructure:"sinks" json:"sinks"`
 	Probes     []ProbeConfig     `yaml:"probes" mapstructure:"probes" json:"probes"`
+	// Aggregation groups identical events in a time window before they are sent to sinks,
+	// changes of it take effect after restart.
+	Aggregation *EventAggregationConfig `yaml:"aggregation" mapstructure:"aggregation" json:"aggregation"`
 }
 
 type EventSinkConfig struct {
@@ -35,9 +69,23 @@ type EventSinkConfig struct {
 	Args interface{} `yaml:"args" mapstructure:"args" json:"args"`
 }
 
+// probeNames returns names of metrics and event probes in the config.
+func (c *InspServerConfig) probeNames() map[string]bool {
+	ret := make(map[string]bool)
+	for _, p := range c.MetricsConfig.Probes {
+		ret[p.Name] = true
+	}
+	for _, p := range c.EventConfig.Probes {
+		ret[p.Name] = true
+	}
+	return ret
+}
+
 type ProbeConfig struct {
 	Name string                 `yaml:"name" mapstructure:"name" json:"name"`
 	Args map[string]interface{} `yaml:"args" mapstructure:"args" json:"args"`
+	// Timeout overrides the scrape timeout of a metrics probe, ignored by event probes.
+	Timeout time.Duration `yaml:"timeout,omitempty" mapstructure:"timeout" json:"timeout,omitempty"`
 }
 
 func loadConfig(path string) (*InspServerConfig, error) {
@@ -51,6 +99,83 @@ func loadConfig(path string) (*InspServerConfig, error) {
 		return nil, fmt.Errorf("failed parse config file %s: %w", path, err)
 	}
 
+	if err = ValidateConfig(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
 	return &cfg, nil
 
 }
+
+// ValidateConfig checks probe names and args, sink args and label expressions of the config,
+// all problems found are returned in one error.
+func ValidateConfig(cfg *InspServerConfig) error {
+	var errs []error
+	add := func(err error, format string, a ...interface{}) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", fmt.Sprintf(format, a...), err))
+		}
+	}
+
+	if cfg.Address == "" && cfg.Port == 0 {
+		errs = append(errs, fmt.Errorf("address: listen address is empty"))
+	}
+
+	standardLabels, err := probe.StandardMetricsLabelsOf(cfg.MetricsConfig.AdditionalLabels)
+	add(err, "metrics.additionalLabels")
+	if cfg.MetricsConfig.OTLP != nil && cfg.MetricsConfig.OTLP.Endpoint != "" {
+		add(cfg.MetricsConfig.OTLP.Validate(), "metrics.otlp")
+	}
+	if cfg.MetricsConfig.RemoteWrite != nil && cfg.MetricsConfig.RemoteWrite.URL != "" {
+		add(cfg.MetricsConfig.RemoteWrite.Validate(), "metrics.remoteWrite")
+	}
+
+	eventMetrics := make(map[string]bool)
+	for i, m := range cfg.MetricsConfig.EventMetrics {
+		if eventMetrics[m.Name] {
+			add(fmt.Errorf("duplicated name %s", m.Name), "metrics.eventMetrics[%d]", i)
+			continue
+		}
+		eventMetrics[m.Name] = true
+		add(m.Validate(standardLabels), "metrics.eventMetrics[%d](%s)", i, m.Name)
+	}
+
+	validateProbes := func(field string, probes []ProbeConfig, validateArgs func(string, map[string]interface{}) error) {
+		seen := make(map[string]bool)
+		for i, p := range probes {
+			if seen[p.Name] {
+				add(fmt.Errorf("duplicated probe %s", p.Name), "%s[%d]", field, i)
+				continue
+			}
+			seen[p.Name] = true
+			add(validateArgs(p.Name, p.Args), "%s[%d](%s)", field, i, p.Name)
+			if p.Timeout < 0 {
+				add(fmt.Errorf("negative timeout %s", p.Timeout), "%s[%d](%s)", field, i, p.Name)
+			}
+		}
+	}
+	validateProbes("metrics.probes", cfg.MetricsConfig.Probes, probe.ValidateMetricsProbeArgs)
+	validateProbes("event.probes", cfg.EventConfig.Probes, probe.ValidateEventProbeArgs)
+
+	if cfg.Overhead != nil {
+		add(cfg.Overhead.Validate(), "overhead")
+	}
+
+	if cfg.TLS.Enabled() {
+		add(cfg.TLS.Validate(true), "tls")
+	}
+	if cfg.ControllerTLS.Enabled() {
+		add(cfg.ControllerTLS.Validate(false), "controllerTLS")
+	}
+	add(task_agent.ValidateConfig(cfg.ControllerTLS, cfg.ControllerTokenFile), "controllerTokenFile")
+
+	if cfg.EventConfig.Aggregation != nil {
+		add(cfg.EventConfig.Aggregation.Validate(), "event.aggregation")
+	}
+
+	for i, s := range cfg.EventConfig.EventSinks {
+		add(sink.ValidateSinkArgs(s.Name, s.Args), "event.sinks[%d](%s)", i, s.Name)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "exporter config operations",
+		Run: func(cmd *cobra.Command, _ []string) {
+			_ = cmd.Help() // nolint
+		},
+	}
+
+	configValidateCmd = &cobra.Command{
+		Use:          "validate",
+		Short:        "validate probes, probe args, sinks and labels of exporter config",
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if _, err := loadConfig(validateConfigPath); err != nil {
+				return err
+			}
+			fmt.Printf("config %s is valid\n", validateConfigPath)
+			return nil
+		},
+	}
+
+	validateConfigPath string
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	configValidateCmd.Flags().StringVarP(&validateConfigPath, "config", "c", "/etc/config/config.yaml", "config file path")
+}
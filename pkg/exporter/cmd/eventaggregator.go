@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAggregationMaxKeys     = 10000
+	defaultAggregationPassThrough = 1
+	aggregationFlushInterval      = time.Second
+)
+
+// EventAggregationConfig groups identical events of noisy probes in a time window. In each
+// window the first events of a key are passed through as is, the rest are aggregated into
+// one event with count, first seen and last seen time, which is emitted when the window ends.
+type EventAggregationConfig struct {
+	// Window is the default aggregation window, aggregation is disabled when it is 0.
+	Window time.Duration `yaml:"window" mapstructure:"window" json:"window"`
+	// PassThrough is the default number of events of a key passed through in a window, default 1.
+	PassThrough int `yaml:"passThrough" mapstructure:"passThrough" json:"passThrough"`
+	// MaxKeys limits keys tracked at the same time, events of new keys are passed through
+	// when exceeded, default 10000.
+	MaxKeys  int                      `yaml:"maxKeys" mapstructure:"maxKeys" json:"maxKeys"`
+	Policies []EventAggregationPolicy `yaml:"policies" mapstructure:"policies" json:"policies"`
+}
+
+// EventAggregationPolicy overrides aggregation of one event type.
+type EventAggregationPolicy struct {
+	Type string `yaml:"type" mapstructure:"type" json:"type"`
+	// Disable passes through all events of the type.
+	Disable bool `yaml:"disable" mapstructure:"disable" json:"disable"`
+	// Window of the type, the default window is used if it is 0.
+	Window      time.Duration `yaml:"window" mapstructure:"window" json:"window"`
+	PassThrough int           `yaml:"passThrough" mapstructure:"passThrough" json:"passThrough"`
+	// Labels are the labels of the aggregation key, all labels are used if it is empty.
+	Labels []string `yaml:"labels" mapstructure:"labels" json:"labels"`
+}
+
+func (c *EventAggregationConfig) Validate() error {
+	if c.Window < 0 {
+		return fmt.Errorf("negative window %s", c.Window)
+	}
+	if c.PassThrough < 0 {
+		return fmt.Errorf("negative passThrough %d", c.PassThrough)
+	}
+	if c.MaxKeys < 0 {
+		return fmt.Errorf("negative maxKeys %d", c.MaxKeys)
+	}
+	seen := make(map[string]bool)
+	for i, p := range c.Policies {
+		if p.Type == "" {
+			return fmt.Errorf("policies[%d]: empty type", i)
+		}
+		if seen[p.Type] {
+			return fmt.Errorf("policies[%d]: duplicated type %s", i, p.Type)
+		}
+		seen[p.Type] = true
+		if p.Window < 0 {
+			return fmt.Errorf("policies[%d]: negative window %s", i, p.Window)
+		}
+		if p.PassThrough < 0 {
+			return fmt.Errorf("policies[%d]: negative passThrough %d", i, p.PassThrough)
+		}
+	}
+	return nil
+}
+
+type aggregationPolicy struct {
+	window      time.Duration
+	passThrough int
+	labels      map[string]bool
+}
+
+type aggregationEntry struct {
+	expire time.Time
+	passed int
+	// last is the last aggregated event, nil if no event is aggregated
+	last      *probe.Event
+	count     uint32
+	firstSeen int64
+}
+
+type eventAggregator struct {
+	defaultPolicy *aggregationPolicy
+	policies      map[probe.EventType]*aggregationPolicy
+	maxKeys       int
+	entries       map[string]*aggregationEntry
+}
+
+// newEventAggregator returns nil if aggregation is disabled for all events.
+func newEventAggregator(cfg *EventAggregationConfig) *eventAggregator {
+	if cfg == nil {
+		return nil
+	}
+	passThrough := func(n int) int {
+		if n == 0 {
+			return defaultAggregationPassThrough
+		}
+		return n
+	}
+
+	a := &eventAggregator{
+		policies: make(map[probe.EventType]*aggregationPolicy),
+		maxKeys:  cfg.MaxKeys,
+		entries:  make(map[string]*aggregationEntry),
+	}
+	if a.maxKeys == 0 {
+		a.maxKeys = defaultAggregationMaxKeys
+	}
+	if cfg.Window > 0 {
+		a.defaultPolicy = &aggregationPolicy{window: cfg.Window, passThrough: passThrough(cfg.PassThrough)}
+	}
+
+	enabled := a.defaultPolicy != nil
+	for _, p := range cfg.Policies {
+		window := p.Window
+		if window == 0 {
+			window = cfg.Window
+		}
+		if p.Disable || window == 0 {
+			a.policies[probe.EventType(p.Type)] = nil
+			continue
+		}
+		n := p.PassThrough
+		if n == 0 {
+			n = cfg.PassThrough
+		}
+		policy := &aggregationPolicy{window: window, passThrough: passThrough(n)}
+		if len(p.Labels) > 0 {
+			policy.labels = make(map[string]bool)
+			for _, l := range p.Labels {
+				policy.labels[l] = true
+			}
+		}
+		a.policies[probe.EventType(p.Type)] = policy
+		enabled = true
+	}
+
+	if !enabled {
+		return nil
+	}
+	return a
+}
+
+func (a *eventAggregator) policy(t probe.EventType) *aggregationPolicy {
+	if p, ok := a.policies[t]; ok {
+		return p
+	}
+	return a.defaultPolicy
+}
+
+// aggregationKey identifies identical events by type, labels and tuple.
+func aggregationKey(evt *probe.Event, policy *aggregationPolicy) string {
+	var sb strings.Builder
+	sb.WriteString(string(evt.Type))
+	for _, l := range evt.Labels {
+		if policy.labels != nil && !policy.labels[l.Name] {
+			continue
+		}
+		sb.WriteString("|")
+		sb.WriteString(l.Name)
+		sb.WriteString("=")
+		sb.WriteString(l.Value)
+	}
+	if t := evt.Tuple; t != nil {
+		sb.WriteString("|")
+		sb.WriteString(t.Protocol)
+		sb.WriteString(" ")
+		sb.WriteString(t.Src)
+		sb.WriteString(":")
+		sb.WriteString(strconv.Itoa(int(t.Sport)))
+		sb.WriteString("->")
+		sb.WriteString(t.Dst)
+		sb.WriteString(":")
+		sb.WriteString(strconv.Itoa(int(t.Dport)))
+	}
+	return sb.String()
+}
+
+// add returns true if the event should be passed through, otherwise the event is aggregated
+// and will be emitted by flush.
+func (a *eventAggregator) add(evt *probe.Event, now time.Time) bool {
+	policy := a.policy(evt.Type)
+	if policy == nil {
+		return true
+	}
+
+	key := aggregationKey(evt, policy)
+	entry, ok := a.entries[key]
+	if !ok {
+		if len(a.entries) >= a.maxKeys {
+			log.Debugf("too many event aggregation keys, pass through event %s", evt.Type)
+			return true
+		}
+		entry = &aggregationEntry{expire: now.Add(policy.window)}
+		a.entries[key] = entry
+	}
+
+	if entry.passed < policy.passThrough {
+		entry.passed++
+		return true
+	}
+
+	if entry.last == nil {
+		entry.firstSeen = evt.Timestamp
+	}
+	entry.last = evt
+	entry.count++
+	return false
+}
+
+// flush removes entries of ended windows and returns the aggregated events of them.
+func (a *eventAggregator) flush(now time.Time) []*probe.Event {
+	return a.remove(func(entry *aggregationEntry) bool {
+		return !now.Before(entry.expire)
+	})
+}
+
+// flushAll removes all entries and returns the aggregated events of them, windows are ignored.
+func (a *eventAggregator) flushAll() []*probe.Event {
+	return a.remove(func(*aggregationEntry) bool {
+		return true
+	})
+}
+
+func (a *eventAggregator) remove(match func(entry *aggregationEntry) bool) []*probe.Event {
+	var ret []*probe.Event
+	for key, entry := range a.entries {
+		if !match(entry) {
+			continue
+		}
+		delete(a.entries, key)
+		if entry.last == nil {
+			continue
+		}
+		evt := *entry.last
+		evt.Count = entry.count
+		evt.FirstSeen = entry.firstSeen
+		evt.LastSeen = entry.last.Timestamp
+		ret = append(ret, &evt)
+	}
+	return ret
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventAggregator(t *testing.T) {
+	a := newEventAggregator(&EventAggregationConfig{
+		Window: 10 * time.Second,
+		Policies: []EventAggregationPolicy{
+			{Type: "TCPReset", Disable: true},
+			{Type: "PacketLoss", PassThrough: 2, Labels: []string{"pod"}},
+		},
+	})
+	now := time.Unix(1000, 0)
+	newEvent := func(typ probe.EventType, pod string, sport uint16, ts int64) *probe.Event {
+		return &probe.Event{
+			Type:      typ,
+			Timestamp: ts,
+			Labels:    []probe.Label{{Name: "pod", Value: pod}, {Name: "reason", Value: "r"}},
+			Tuple:     &probe.EventTuple{Protocol: "TCP", Src: "10.0.0.1", Dst: "10.0.0.2", Sport: sport, Dport: 80},
+		}
+	}
+
+	// first event of a key passes through, following identical ones are aggregated
+	assert.True(t, a.add(newEvent("TCPRetrans", "p1", 1000, 1), now))
+	assert.False(t, a.add(newEvent("TCPRetrans", "p1", 1000, 2), now))
+	assert.False(t, a.add(newEvent("TCPRetrans", "p1", 1000, 3), now))
+	// different tuple is a different key
+	assert.True(t, a.add(newEvent("TCPRetrans", "p1", 1001, 4), now))
+
+	// disabled type always passes through
+	assert.True(t, a.add(newEvent("TCPReset", "p1", 1000, 5), now))
+	assert.True(t, a.add(newEvent("TCPReset", "p1", 1000, 6), now))
+
+	// key of PacketLoss only has pod label, tuple is still part of the key
+	assert.True(t, a.add(newEvent("PacketLoss", "p1", 1000, 7), now))
+	evt := newEvent("PacketLoss", "p1", 1000, 8)
+	evt.Labels[1].Value = "other"
+	assert.True(t, a.add(evt, now))
+	assert.False(t, a.add(newEvent("PacketLoss", "p1", 1000, 9), now))
+
+	assert.Empty(t, a.flush(now.Add(5*time.Second)))
+
+	flushed := a.flush(now.Add(10 * time.Second))
+	assert.Len(t, flushed, 2)
+	for _, evt := range flushed {
+		switch evt.Type {
+		case "TCPRetrans":
+			assert.Equal(t, uint32(2), evt.Count)
+			assert.Equal(t, int64(2), evt.FirstSeen)
+			assert.Equal(t, int64(3), evt.LastSeen)
+			assert.Equal(t, int64(3), evt.Timestamp)
+		case "PacketLoss":
+			assert.Equal(t, uint32(1), evt.Count)
+			assert.Equal(t, int64(9), evt.FirstSeen)
+			assert.Equal(t, int64(9), evt.LastSeen)
+		default:
+			t.Errorf("unexpected event %s", evt.Type)
+		}
+	}
+	assert.Empty(t, a.entries)
+
+	// a new window starts after flush
+	assert.True(t, a.add(newEvent("TCPRetrans", "p1", 1000, 10), now.Add(10*time.Second)))
+}
+
+func TestEventAggregatorDisabled(t *testing.T) {
+	assert.Nil(t, newEventAggregator(nil))
+	assert.Nil(t, newEventAggregator(&EventAggregationConfig{}))
+	assert.Nil(t, newEventAggregator(&EventAggregationConfig{
+		Policies: []EventAggregationPolicy{{Type: "TCPRetrans"}},
+	}))
+	assert.NotNil(t, newEventAggregator(&EventAggregationConfig{
+		Policies: []EventAggregationPolicy{{Type: "TCPRetrans", Window: time.Second}},
+	}))
+}
+
+func TestEventAggregatorMaxKeys(t *testing.T) {
+	a := newEventAggregator(&EventAggregationConfig{Window: time.Second, MaxKeys: 1})
+	now := time.Now()
+	assert.True(t, a.add(&probe.Event{Type: "A"}, now))
+	assert.False(t, a.add(&probe.Event{Type: "A"}, now))
+	assert.True(t, a.add(&probe.Event{Type: "B"}, now))
+	assert.True(t, a.add(&probe.Event{Type: "B"}, now))
+}
+
+func TestValidateEventAggregationConfig(t *testing.T) {
+	assert.NoError(t, (&EventAggregationConfig{Window: time.Second}).Validate())
+	assert.Error(t, (&EventAggregationConfig{Window: -time.Second}).Validate())
+	assert.Error(t, (&EventAggregationConfig{Policies: []EventAggregationPolicy{{Type: "A"}, {Type: "A"}}}).Validate())
+	assert.Error(t, (&EventAggregationConfig{Policies: []EventAggregationPolicy{{PassThrough: 1}}}).Validate())
+}
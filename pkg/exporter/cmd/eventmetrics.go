@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	EventMetricsCounter   = "counter"
+	EventMetricsHistogram = "histogram"
+
+	eventMetricsSubsystem = "event"
+	// eventLatencyField observes latencyNs of events in seconds
+	eventLatencyField = "latency"
+)
+
+// metrics labels which have different names in event labels
+var eventLabelOfMetricsLabel = map[string]string{
+	"k8s_node":      "node",
+	"k8s_namespace": "namespace",
+	"k8s_pod":       "pod",
+}
+
+// EventMetricsConfig derives a metric from events, so that event only probes can be used in
+// dashboards and alerts. The event probe producing the events must be enabled in event probes.
+type EventMetricsConfig struct {
+	// Name of the metric, the full name is kubeskoop_event_<name>.
+	Name string `yaml:"name" mapstructure:"name" json:"name"`
+	Help string `yaml:"help" mapstructure:"help" json:"help"`
+	// Type is counter or histogram, default counter.
+	Type string `yaml:"type" mapstructure:"type" json:"type"`
+	// EventTypes are types of events counted or observed.
+	EventTypes []string `yaml:"eventTypes" mapstructure:"eventTypes" json:"eventTypes"`
+	// Selector are label values that events must have.
+	Selector map[string]string `yaml:"selector" mapstructure:"selector" json:"selector"`
+	// Labels are event labels added to the standard pod labels of the metric.
+	Labels []string `yaml:"labels" mapstructure:"labels" json:"labels"`
+	// Field is the value observed by histograms, `latency` for latency in seconds, or name of
+	// a label with numeric value.
+	Field   string    `yaml:"field" mapstructure:"field" json:"field"`
+	Buckets []float64 `yaml:"buckets" mapstructure:"buckets" json:"buckets"`
+}
+
+// Validate checks the config, standardLabels are the standard metrics labels the metric is
+// registered with, which cannot be used in Labels.
+func (c *EventMetricsConfig) Validate(standardLabels []string) error {
+	if !model.IsValidMetricName(model.LabelValue(c.Name)) {
+		return fmt.Errorf("invalid name %q", c.Name)
+	}
+	if len(c.EventTypes) == 0 {
+		return fmt.Errorf("eventTypes is empty")
+	}
+	seen := make(map[string]bool)
+	for _, l := range standardLabels {
+		seen[l] = true
+	}
+	for _, l := range c.Labels {
+		if !model.LabelName(l).IsValid() {
+			return fmt.Errorf("invalid label %q", l)
+		}
+		if seen[l] {
+			return fmt.Errorf("label %q is duplicated or conflicts with standard labels %v", l, standardLabels)
+		}
+		seen[l] = true
+	}
+	switch c.Type {
+	case "", EventMetricsCounter:
+	case EventMetricsHistogram:
+		if c.Field == "" {
+			return fmt.Errorf("field is required by histogram")
+		}
+		for i := 1; i < len(c.Buckets); i++ {
+			if c.Buckets[i] <= c.Buckets[i-1] {
+				return fmt.Errorf("buckets are not in increasing order")
+			}
+		}
+	default:
+		return fmt.Errorf("unknown type %q", c.Type)
+	}
+	return nil
+}
+
+type eventMetric struct {
+	cfg       EventMetricsConfig
+	types     map[probe.EventType]bool
+	counter   *prometheus.CounterVec
+	histogram *prometheus.HistogramVec
+}
+
+func (m *eventMetric) match(evt *probe.Event) bool {
+	if !m.types[evt.Type] {
+		return false
+	}
+	for name, value := range m.cfg.Selector {
+		if eventLabel(evt, name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *eventMetric) vec() *prometheus.MetricVec {
+	if m.histogram != nil {
+		return m.histogram.MetricVec
+	}
+	return m.counter.MetricVec
+}
+
+// eventMetrics is a prometheus collector of metrics derived from events.
+type eventMetrics struct {
+	metrics []*eventMetric
+	done    chan struct{}
+}
+
+func newEventMetrics(cfgs []EventMetricsConfig) (*eventMetrics, error) {
+	e := &eventMetrics{done: make(chan struct{})}
+	labels := append([]string{}, probe.StandardMetricsLabels...)
+	for _, cfg := range cfgs {
+		if err := cfg.Validate(probe.StandardMetricsLabels); err != nil {
+			return nil, fmt.Errorf("invalid event metrics %s: %w", cfg.Name, err)
+		}
+		m := &eventMetric{cfg: cfg, types: make(map[probe.EventType]bool)}
+		for _, t := range cfg.EventTypes {
+			m.types[probe.EventType(t)] = true
+		}
+		variableLabels := append(append([]string{}, labels...), cfg.Labels...)
+		if cfg.Type == EventMetricsHistogram {
+			m.histogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: probe.MetricsNamespace,
+				Subsystem: eventMetricsSubsystem,
+				Name:      cfg.Name,
+				Help:      cfg.Help,
+				Buckets:   cfg.Buckets,
+			}, variableLabels)
+		} else {
+			m.counter = prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: probe.MetricsNamespace,
+				Subsystem: eventMetricsSubsystem,
+				Name:      cfg.Name,
+				Help:      cfg.Help,
+			}, variableLabels)
+		}
+		e.metrics = append(e.metrics, m)
+	}
+	return e, nil
+}
+
+func (e *eventMetrics) Describe(descs chan<- *prometheus.Desc) {
+	for _, m := range e.metrics {
+		if m.histogram != nil {
+			m.histogram.Describe(descs)
+		} else {
+			m.counter.Describe(descs)
+		}
+	}
+}
+
+func (e *eventMetrics) Collect(metrics chan<- prometheus.Metric) {
+	for _, m := range e.metrics {
+		if m.histogram != nil {
+			m.histogram.Collect(metrics)
+		} else {
+			m.counter.Collect(metrics)
+		}
+	}
+}
+
+// Observe updates metrics matching the event.
+func (e *eventMetrics) Observe(evt *probe.Event) {
+	var standardLabels []string
+	for _, m := range e.metrics {
+		if !m.match(evt) {
+			continue
+		}
+		if standardLabels == nil {
+			standardLabels = eventStandardLabelValues(evt)
+		}
+		labelValues := append([]string{}, standardLabels...)
+		for _, l := range m.cfg.Labels {
+			labelValues = append(labelValues, eventLabel(evt, l))
+		}
+
+		if m.histogram != nil {
+			value, ok := eventFieldValue(evt, m.cfg.Field)
+			if !ok {
+				continue
+			}
+			m.histogram.WithLabelValues(labelValues...).Observe(value)
+			continue
+		}
+
+		// an aggregated event stands for Count events
+		n := float64(1)
+		if evt.Count > 0 {
+			n = float64(evt.Count)
+		}
+		m.counter.WithLabelValues(labelValues...).Add(n)
+	}
+}
+
+// start removes series of pods when their netns are removed, so that metrics of deleted
+// pods are not kept forever.
+func (e *eventMetrics) start() {
+	ch, unsubscribe := nettop.SubscribeNetnsEvents()
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case evt := <-ch:
+				if evt.Type != nettop.NetnsRemove || evt.Entity == nil || evt.Entity.GetPodName() == "" {
+					continue
+				}
+				labels := prometheus.Labels{
+					"k8s_namespace": evt.Entity.GetPodNamespace(),
+					"k8s_pod":       evt.Entity.GetPodName(),
+				}
+				for _, m := range e.metrics {
+					m.vec().DeletePartialMatch(labels)
+				}
+			case <-e.done:
+				return
+			}
+		}
+	}()
+}
+
+func (e *eventMetrics) stop() {
+	close(e.done)
+}
+
+func eventLabel(evt *probe.Event, name string) string {
+	for _, l := range evt.Labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// eventStandardLabelValues returns values of probe.StandardMetricsLabels. Entity of the netns is
+// preferred, labels of the event are used if the netns is unknown or has gone.
+func eventStandardLabelValues(evt *probe.Event) []string {
+	if evt.Netns != 0 {
+		if et, err := nettop.GetEntityByNetns(int(evt.Netns)); err == nil && et != nil {
+			return probe.BuildStandardMetricsLabelValues(et)
+		}
+	}
+	ret := make([]string, 0, len(probe.StandardMetricsLabels))
+	for _, l := range probe.StandardMetricsLabels {
+		name := l
+		if n, ok := eventLabelOfMetricsLabel[l]; ok {
+			name = n
+		}
+		ret = append(ret, eventLabel(evt, name))
+	}
+	if ret[0] == "" {
+		ret[0] = nettop.GetNodeName()
+	}
+	return ret
+}
+
+func eventFieldValue(evt *probe.Event, field string) (float64, bool) {
+	if field == eventLatencyField {
+		if evt.LatencyNs == 0 {
+			return 0, false
+		}
+		return float64(evt.LatencyNs) / 1e9, true
+	}
+	v, err := strconv.ParseFloat(eventLabel(evt, field), 64)
+	if err != nil {
+		log.Debugf("event %s has no numeric label %s", evt.Type, field)
+		return 0, false
+	}
+	return v, true
+}
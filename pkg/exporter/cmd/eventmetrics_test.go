@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventMetrics(t *testing.T) {
+	m, err := newEventMetrics([]EventMetricsConfig{
+		{
+			Name:       "tcpreset_total",
+			Help:       "tcp resets",
+			EventTypes: []string{"TCPReset"},
+			Selector:   map[string]string{"type": "active"},
+			Labels:     []string{"type"},
+		},
+		{
+			Name:       "biolatency_seconds",
+			Help:       "bio latency",
+			Type:       EventMetricsHistogram,
+			EventTypes: []string{"BIOLAT_10MS", "BIOLAT_100MS"},
+			Field:      "latency",
+			Buckets:    []float64{0.01, 0.1, 1},
+		},
+	})
+	assert.NoError(t, err)
+
+	podLabels := []probe.Label{{Name: "pod", Value: "p1"}, {Name: "namespace", Value: "ns1"}, {Name: "node", Value: "n1"}}
+	withLabel := func(name, value string) []probe.Label {
+		return append(append([]probe.Label{}, podLabels...), probe.Label{Name: name, Value: value})
+	}
+	m.Observe(&probe.Event{Type: "TCPReset", Labels: withLabel("type", "active")})
+	m.Observe(&probe.Event{Type: "TCPReset", Labels: withLabel("type", "active"), Count: 3})
+	m.Observe(&probe.Event{Type: "TCPReset", Labels: withLabel("type", "receive")})
+	m.Observe(&probe.Event{Type: "TCPRetrans", Labels: withLabel("type", "active")})
+	m.Observe(&probe.Event{Type: "BIOLAT_10MS", Labels: podLabels, LatencyNs: 20_000_000})
+	m.Observe(&probe.Event{Type: "BIOLAT_100MS", Labels: podLabels, LatencyNs: 200_000_000})
+	m.Observe(&probe.Event{Type: "BIOLAT_100MS", Labels: podLabels})
+
+	expected := `
+# HELP kubeskoop_event_biolatency_seconds bio latency
+# TYPE kubeskoop_event_biolatency_seconds histogram
+kubeskoop_event_biolatency_seconds_bucket{k8s_namespace="ns1",k8s_node="n1",k8s_pod="p1",le="0.01"} 0
+kubeskoop_event_biolatency_seconds_bucket{k8s_namespace="ns1",k8s_node="n1",k8s_pod="p1",le="0.1"} 1
+kubeskoop_event_biolatency_seconds_bucket{k8s_namespace="ns1",k8s_node="n1",k8s_pod="p1",le="1"} 2
+kubeskoop_event_biolatency_seconds_bucket{k8s_namespace="ns1",k8s_node="n1",k8s_pod="p1",le="+Inf"} 2
+kubeskoop_event_biolatency_seconds_sum{k8s_namespace="ns1",k8s_node="n1",k8s_pod="p1"} 0.22
+kubeskoop_event_biolatency_seconds_count{k8s_namespace="ns1",k8s_node="n1",k8s_pod="p1"} 2
+# HELP kubeskoop_event_tcpreset_total tcp resets
+# TYPE kubeskoop_event_tcpreset_total counter
+kubeskoop_event_tcpreset_total{k8s_namespace="ns1",k8s_node="n1",k8s_pod="p1",type="active"} 4
+`
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(expected)))
+}
+
+func TestValidateEventMetricsConfig(t *testing.T) {
+	assert.NoError(t, (&EventMetricsConfig{Name: "a", EventTypes: []string{"A"}}).Validate(nil))
+	assert.Error(t, (&EventMetricsConfig{Name: "a-b", EventTypes: []string{"A"}}).Validate(nil))
+	assert.Error(t, (&EventMetricsConfig{Name: "a"}).Validate(nil))
+	assert.Error(t, (&EventMetricsConfig{Name: "a", EventTypes: []string{"A"}, Type: "gauge"}).Validate(nil))
+	assert.Error(t, (&EventMetricsConfig{Name: "a", EventTypes: []string{"A"}, Type: EventMetricsHistogram}).Validate(nil))
+	assert.Error(t, (&EventMetricsConfig{Name: "a", EventTypes: []string{"A"}, Type: EventMetricsHistogram, Field: "latency", Buckets: []float64{1, 0.1}}).Validate(nil))
+
+	// labels conflict with standard labels fail registration
+	standard := []string{"k8s_node", "k8s_namespace", "k8s_pod", "zone"}
+	assert.NoError(t, (&EventMetricsConfig{Name: "a", EventTypes: []string{"A"}, Labels: []string{"reason"}}).Validate(standard))
+	assert.Error(t, (&EventMetricsConfig{Name: "a", EventTypes: []string{"A"}, Labels: []string{"k8s_pod"}}).Validate(standard))
+	assert.Error(t, (&EventMetricsConfig{Name: "a", EventTypes: []string{"A"}, Labels: []string{"zone"}}).Validate(standard))
+	assert.Error(t, (&EventMetricsConfig{Name: "a", EventTypes: []string{"A"}, Labels: []string{"reason", "reason"}}).Validate(standard))
+}
@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"context"
+	"io"
+	"sync"
+	"time"
 
 	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
 	"github.com/alibaba/kubeskoop/pkg/exporter/sink"
@@ -12,23 +15,28 @@ type EventServer struct {
 	*DynamicProbeServer[probe.EventProbe]
 }
 
-func newEventServer(sinks []sink.Sink) (*EventServer, error) {
+// newEventServer creates the event server, observe is called with every event before aggregation if it is not nil.
+func newEventServer(sinks []sink.Sink, aggregation *EventAggregationConfig, observe func(*probe.Event)) (*EventServer, error) {
 	var sinkWrappers []*sinkWrapper
 
-	done := make(chan struct{})
+	// sinks stop after the dispatcher has sent all events, including aggregated ones
+	drained := make(chan struct{})
 
 	for _, s := range sinks {
 		sinkWrappers = append(sinkWrappers, &sinkWrapper{
 			ch:   make(chan *probe.Event, 1024),
 			s:    s,
-			done: done,
+			done: drained,
 		})
 	}
 
 	probeManager := &EventProbeManager{
-		sinks:    sinkWrappers,
-		sinkChan: make(chan *probe.Event),
-		done:     done,
+		sinks:      sinkWrappers,
+		sinkChan:   make(chan *probe.Event),
+		done:       make(chan struct{}),
+		drained:    drained,
+		aggregator: newEventAggregator(aggregation),
+		observe:    observe,
 	}
 
 	return &EventServer{
@@ -41,6 +49,15 @@ func (s *EventServer) Start(ctx context.Context, probeConfig []ProbeConfig) erro
 	return s.DynamicProbeServer.Start(ctx, probeConfig)
 }
 
+// emit sends the event of the exporter itself to sinks.
+func (s *EventServer) emit(evt *probe.Event) {
+	m := s.probeManager.(*EventProbeManager)
+	select {
+	case m.sinkChan <- evt:
+	case <-m.done:
+	}
+}
+
 func (s *EventServer) Stop(ctx context.Context) error {
 	if err := s.DynamicProbeServer.Stop(ctx); err != nil {
 		return err
@@ -53,6 +70,12 @@ type EventProbeManager struct {
 	sinkChan chan *probe.Event
 	sinks    []*sinkWrapper
 	done     chan struct{}
+	// drained is closed by the dispatcher when it exits after done
+	drained chan struct{}
+	// aggregator is nil if event aggregation is disabled
+	aggregator *eventAggregator
+	observe    func(*probe.Event)
+	consumers  sync.WaitGroup
 }
 
 type sinkWrapper struct {
@@ -61,57 +84,114 @@ type sinkWrapper struct {
 	done chan struct{}
 }
 
+// stop waits for queued and aggregated events to be written, and closes sinks implementing io.Closer.
 func (m *EventProbeManager) stop() {
 	log.Infof("probe manager stopped")
 	close(m.done)
+	m.consumers.Wait()
+	for _, sw := range m.sinks {
+		if c, ok := sw.s.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Errorf("failed close sink %s: %v", sw.s, err)
+			}
+		}
+	}
 }
 
 func consume(sw *sinkWrapper) {
-loop:
+	write := func(evt *probe.Event) {
+		if err := sw.s.Write(evt); err != nil {
+			log.Errorf("error sink evt %s", err)
+		}
+	}
 	for {
 		select {
 		case evt := <-sw.ch:
-			if err := sw.s.Write(evt); err != nil {
-				log.Errorf("error sink evt %s", err)
-			}
+			write(evt)
 		case <-sw.done:
-			break loop
+			for {
+				select {
+				case evt := <-sw.ch:
+					write(evt)
+				default:
+					return
+				}
+			}
 		}
 	}
 }
 
 func (m *EventProbeManager) start() {
 	for _, s := range m.sinks {
-		go consume(s)
+		m.consumers.Add(1)
+		go func(sw *sinkWrapper) {
+			defer m.consumers.Done()
+			consume(sw)
+		}(s)
 	}
 
 	go func() {
-	loop:
+		defer close(m.drained)
+		var flushChan <-chan time.Time
+		if m.aggregator != nil {
+			ticker := time.NewTicker(aggregationFlushInterval)
+			defer ticker.Stop()
+			flushChan = ticker.C
+		}
 		for {
 			select {
 			case evt := <-m.sinkChan:
-				for _, sw := range m.sinks {
-					select {
-					case sw.ch <- evt:
-						break
-					default:
-						log.Errorf("%s is blocked, discard event.", sw.s)
-					}
+				if m.observe != nil {
+					m.observe(evt)
+				}
+				if m.aggregator == nil || m.aggregator.add(evt, time.Now()) {
+					m.dispatch(evt)
+				}
+			case now := <-flushChan:
+				for _, evt := range m.aggregator.flush(now) {
+					m.dispatch(evt)
 				}
 			case <-m.done:
-				break loop
+				// events of open windows would be lost on stop or reload, send them regardless of expiry
+				if m.aggregator != nil {
+					for _, evt := range m.aggregator.flushAll() {
+						m.dispatchWait(evt)
+					}
+				}
+				return
 			}
 		}
 	}()
 }
 
+// dispatchWait blocks until the event is queued to every sink, sinks are consuming until drained is closed.
+func (m *EventProbeManager) dispatchWait(evt *probe.Event) {
+	for _, sw := range m.sinks {
+		sw.ch <- evt
+	}
+}
+
+func (m *EventProbeManager) dispatch(evt *probe.Event) {
+	for _, sw := range m.sinks {
+		select {
+		case sw.ch <- evt:
+		default:
+			log.Errorf("%s is blocked, discard event.", sw.s)
+		}
+	}
+}
+
 func (m *EventProbeManager) CreateProbe(config ProbeConfig) (probe.EventProbe, error) {
 	return probe.CreateEventProbe(config.Name, m.sinkChan, config.Args)
 }
 
 func (m *EventProbeManager) StartProbe(ctx context.Context, p probe.EventProbe) error {
 	log.Infof("start event probe %s", p.Name())
-	return p.Start(ctx)
+	var err error
+	withProbeLabels(ctx, p.Name(), func(ctx context.Context) {
+		err = p.Start(ctx)
+	})
+	return err
 }
 
 func (m *EventProbeManager) StopProbe(ctx context.Context, p probe.EventProbe) error {
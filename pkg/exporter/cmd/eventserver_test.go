@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/alibaba/kubeskoop/pkg/exporter/sink"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordSink struct {
+	lock   sync.Mutex
+	events []*probe.Event
+	closed bool
+}
+
+func (s *recordSink) String() string {
+	return "record"
+}
+
+func (s *recordSink) Write(evt *probe.Event) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.events = append(s.events, evt)
+	return nil
+}
+
+func (s *recordSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestEventProbeManagerStop(t *testing.T) {
+	s := &recordSink{}
+	server, err := newEventServer([]sink.Sink{s}, nil, nil)
+	assert.NoError(t, err)
+	m := server.probeManager.(*EventProbeManager)
+	m.start()
+	for i := 0; i < 100; i++ {
+		m.dispatch(&probe.Event{Type: "test"})
+	}
+	m.stop()
+
+	// queued events are written before sinks are closed
+	assert.Len(t, s.events, 100)
+	assert.True(t, s.closed)
+}
+
+func TestEventProbeManagerStopFlushesAggregation(t *testing.T) {
+	s := &recordSink{}
+	server, err := newEventServer([]sink.Sink{s}, &EventAggregationConfig{Window: time.Hour}, nil)
+	assert.NoError(t, err)
+	m := server.probeManager.(*EventProbeManager)
+	m.start()
+	for i := 0; i < 3; i++ {
+		m.sinkChan <- &probe.Event{Type: "test", Timestamp: int64(i)}
+	}
+	m.stop()
+
+	// the first event passes through, the other two are sent on stop though the window is open
+	assert.Len(t, s.events, 2)
+	assert.Equal(t, uint32(2), s.events[1].Count)
+	assert.True(t, s.closed)
+}
@@ -4,6 +4,7 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
@@ -15,7 +16,16 @@ var (
 	probeCmd = &cobra.Command{
 		Use:   "probe",
 		Short: "list supported probe with metric exporting",
-		Run: func(_ *cobra.Command, _ []string) {
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if showSchema {
+				data, err := json.MarshalIndent(probe.RegisteredArgsSchemas(), "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+
 			res := make(map[string][]string)
 			res["metrics"] = probe.ListMetricsProbes()
 			res["event"] = probe.ListEventProbes()
@@ -27,10 +37,15 @@ var (
 					fmt.Printf("%s%s\n", indent, s)
 				}
 			}
+			return nil
 		},
 	}
+
+	showSchema bool
 )
 
 func init() {
 	listCmd.AddCommand(probeCmd)
+
+	probeCmd.Flags().BoolVar(&showSchema, "schema", false, "print json schema of probe args")
 }
@@ -3,46 +3,86 @@ package cmd
 import (
 	"context"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/otlp"
 	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/alibaba/kubeskoop/pkg/exporter/remotewrite"
 	"github.com/alibaba/kubeskoop/pkg/exporter/util"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
-func newMetricsServer() (*MetricsServer, error) {
+func newMetricsServer(scrapeTimeout time.Duration) (*MetricsServer, error) {
 
 	r := prometheus.NewRegistry()
 	handler := promhttp.HandlerFor(prometheus.Gatherers{
 		r,
 	}, promhttp.HandlerOpts{})
 
+	scrape := &scrapeMetrics{collectors: make(map[string]*boundedCollector)}
+	r.MustRegister(scrape, probe.CollectErrors(), remotewrite.DroppedRequests())
+
 	probeManager := &MetricsProbeManager{
 		prometheusRegistry: r,
+		scrapeTimeout:      scrapeTimeout,
+		timeouts:           make(map[string]time.Duration),
+		collectors:         make(map[string]*boundedCollector),
+		scrape:             scrape,
 	}
 
 	return &MetricsServer{
 		DynamicProbeServer: NewDynamicProbeServer[probe.MetricsProbe](probeManager),
 		httpHandler:        handler,
+		gatherer:           r,
+		registry:           r,
 	}, nil
 }
 
 type MetricsProbeManager struct {
 	prometheusRegistry *prometheus.Registry
+	// scrapeTimeout is the default collection budget of probes without timeout config
+	scrapeTimeout time.Duration
+	scrape        *scrapeMetrics
+
+	// lock guards timeouts and collectors, probes are started and stopped by reloads and
+	// the overhead accountant concurrently.
+	lock       sync.Mutex
+	timeouts   map[string]time.Duration
+	collectors map[string]*boundedCollector
 }
 
 func (m *MetricsProbeManager) CreateProbe(config ProbeConfig) (probe.MetricsProbe, error) {
 	log.Infof("create metrics probe %s with args %s", config.Name, util.ToJSONString(config.Args))
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = m.scrapeTimeout
+	}
+	m.lock.Lock()
+	m.timeouts[config.Name] = timeout
+	m.lock.Unlock()
 	return probe.CreateMetricsProbe(config.Name, config.Args)
 }
 
 func (m *MetricsProbeManager) StartProbe(ctx context.Context, p probe.MetricsProbe) error {
 	log.Infof("start metrics probe %s", p.Name())
-	if err := p.Start(ctx); err != nil {
+	var err error
+	withProbeLabels(ctx, p.Name(), func(ctx context.Context) {
+		err = p.Start(ctx)
+	})
+	if err != nil {
 		return err
 	}
-	m.prometheusRegistry.MustRegister(p)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	c := newBoundedCollector(p.Name(), p, m.timeouts[p.Name()])
+	m.prometheusRegistry.MustRegister(c)
+	m.collectors[p.Name()] = c
+	m.scrape.add(c)
 	return nil
 }
 
@@ -57,7 +97,14 @@ func (m *MetricsProbeManager) StopProbe(ctx context.Context, p probe.MetricsProb
 	if err := p.Stop(ctx); err != nil {
 		return err
 	}
-	m.prometheusRegistry.Unregister(p)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if c, ok := m.collectors[p.Name()]; ok {
+		m.prometheusRegistry.Unregister(c)
+		m.scrape.remove(p.Name())
+		delete(m.collectors, p.Name())
+	}
 	return nil
 }
 
@@ -66,6 +113,71 @@ var _ ProbeManager[probe.MetricsProbe] = &MetricsProbeManager{}
 type MetricsServer struct {
 	*DynamicProbeServer[probe.MetricsProbe]
 	httpHandler http.Handler
+	gatherer    prometheus.Gatherer
+	registry    *prometheus.Registry
+	otlp        *otlp.MetricsExporter
+	remoteWrite *remotewrite.Writer
+	events      *eventMetrics
+}
+
+// StartOTLP pushes metrics of all running probes to the otlp endpoint periodically.
+func (s *MetricsServer) StartOTLP(ctx context.Context, cfg *otlp.Config) error {
+	exporter, err := otlp.NewMetricsExporter(cfg, s.gatherer, nettop.GetNodeName())
+	if err != nil {
+		return err
+	}
+	log.Infof("start pushing metrics to otlp endpoint %s(%s)", cfg.Endpoint, cfg.Protocol)
+	exporter.Start(ctx)
+	s.otlp = exporter
+	return nil
+}
+
+// StartRemoteWrite pushes metrics of all running probes to the remote write endpoint periodically.
+func (s *MetricsServer) StartRemoteWrite(ctx context.Context, cfg *remotewrite.Config) error {
+	writer, err := remotewrite.NewWriter(cfg, s.gatherer)
+	if err != nil {
+		return err
+	}
+	log.Infof("start pushing metrics to remote write endpoint %s", cfg.URL)
+	writer.Start(ctx)
+	s.remoteWrite = writer
+	return nil
+}
+
+// StartEventMetrics registers metrics derived from events, events are fed by ObserveEvent.
+func (s *MetricsServer) StartEventMetrics(cfgs []EventMetricsConfig) error {
+	m, err := newEventMetrics(cfgs)
+	if err != nil {
+		return err
+	}
+	if err := s.registry.Register(m); err != nil {
+		return err
+	}
+	m.start()
+	s.events = m
+	return nil
+}
+
+// ObserveEvent updates metrics derived from events, it does nothing if there is no such metric.
+func (s *MetricsServer) ObserveEvent(evt *probe.Event) {
+	if s.events != nil {
+		s.events.Observe(evt)
+	}
+}
+
+func (s *MetricsServer) Stop(ctx context.Context) error {
+	if s.events != nil {
+		s.events.stop()
+	}
+	if s.remoteWrite != nil {
+		s.remoteWrite.Stop()
+	}
+	if s.otlp != nil {
+		if err := s.otlp.Stop(); err != nil {
+			log.Errorf("failed stop otlp exporter: %v", err)
+		}
+	}
+	return s.DynamicProbeServer.Stop(ctx)
 }
 
 func (s *MetricsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,540 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/bpfutil"
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/cilium/ebpf"
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// probeLabel is the pprof label of goroutines started by probes
+	probeLabel = "probe"
+
+	overheadActionSample  = "sample"
+	overheadActionDisable = "disable"
+
+	defaultOverheadInterval = time.Minute
+	defaultProfileDuration  = 10 * time.Second
+	// minSampleRatio is the lowest ratio of time a sampled probe runs, probes are disabled below it.
+	minSampleRatio = 0.1
+
+	ProbeThrottled probe.EventType = "ProbeThrottled"
+)
+
+var (
+	bpfRunTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(probe.MetricsNamespace, "exporter", "bpf_program_run_time_seconds_total"),
+		"Total run time of a loaded bpf program of a probe.",
+		[]string{"probe", "program"}, nil,
+	)
+	bpfRunCountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(probe.MetricsNamespace, "exporter", "bpf_program_run_count_total"),
+		"Total number of runs of a loaded bpf program of a probe.",
+		[]string{"probe", "program"}, nil,
+	)
+	probeBPFTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(probe.MetricsNamespace, "exporter", "probe_bpf_cpu_seconds_total"),
+		"Total run time of bpf programs of a probe, including programs unloaded on restarts.",
+		[]string{"probe"}, nil,
+	)
+	probeCPUDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(probe.MetricsNamespace, "exporter", "probe_cpu_usage_cores"),
+		"CPU usage of a probe in the last accounting interval, source is bpf or go.",
+		[]string{"probe", "source"}, nil,
+	)
+	probeMapMemoryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(probe.MetricsNamespace, "exporter", "probe_bpf_map_memory_bytes"),
+		"Memory of bpf maps used by programs of a probe.",
+		[]string{"probe"}, nil,
+	)
+	probeSampleRatioDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(probe.MetricsNamespace, "exporter", "probe_sample_ratio"),
+		"Ratio of time a throttled probe runs, 0 if the probe is disabled.",
+		[]string{"probe"}, nil,
+	)
+)
+
+// OverheadConfig accounts cpu and memory of probes, and throttles probes exceeding their cpu
+// budgets, changes of it take effect after restart.
+type OverheadConfig struct {
+	Enable bool `yaml:"enable" mapstructure:"enable" json:"enable"`
+	// Interval of accounting and budget checks, default 1m.
+	Interval time.Duration `yaml:"interval" mapstructure:"interval" json:"interval"`
+	// ProfileGo accounts go cpu of probes by cpu profiling in every interval, only bpf run time is
+	// accounted if it is false. /debug/pprof/profile fails while profiling, and profiling is skipped
+	// in intervals when another cpu profile is running.
+	ProfileGo bool `yaml:"profileGo" mapstructure:"profileGo" json:"profileGo"`
+	// ProfileDuration is the duration of go cpu profiling in an interval, default 10s.
+	ProfileDuration time.Duration `yaml:"profileDuration" mapstructure:"profileDuration" json:"profileDuration"`
+	// Budget is the default cpu budget of a probe in cores, probes are not throttled if it is 0.
+	Budget float64 `yaml:"budget" mapstructure:"budget" json:"budget"`
+	// Action is taken when a probe exceeds its budget, sample or disable, default sample.
+	Action   string           `yaml:"action" mapstructure:"action" json:"action"`
+	Policies []OverheadPolicy `yaml:"policies" mapstructure:"policies" json:"policies"`
+}
+
+// OverheadPolicy overrides the budget of one probe.
+type OverheadPolicy struct {
+	Probe  string  `yaml:"probe" mapstructure:"probe" json:"probe"`
+	Budget float64 `yaml:"budget" mapstructure:"budget" json:"budget"`
+	Action string  `yaml:"action" mapstructure:"action" json:"action"`
+}
+
+func validOverheadAction(action string) bool {
+	return action == "" || action == overheadActionSample || action == overheadActionDisable
+}
+
+func (c *OverheadConfig) Validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("negative interval %s", c.Interval)
+	}
+	if c.ProfileDuration < 0 {
+		return fmt.Errorf("negative profileDuration %s", c.ProfileDuration)
+	}
+	interval := c.Interval
+	if interval == 0 {
+		interval = defaultOverheadInterval
+	}
+	if c.ProfileDuration >= interval {
+		return fmt.Errorf("profileDuration %s should be less than interval %s", c.ProfileDuration, interval)
+	}
+	if c.Budget < 0 {
+		return fmt.Errorf("negative budget %v", c.Budget)
+	}
+	if !validOverheadAction(c.Action) {
+		return fmt.Errorf("unknown action %s, should be sample or disable", c.Action)
+	}
+	seen := make(map[string]bool)
+	for i, p := range c.Policies {
+		if p.Probe == "" {
+			return fmt.Errorf("policies[%d]: empty probe", i)
+		}
+		if seen[p.Probe] {
+			return fmt.Errorf("policies[%d]: duplicated probe %s", i, p.Probe)
+		}
+		seen[p.Probe] = true
+		if p.Budget < 0 {
+			return fmt.Errorf("policies[%d]: negative budget %v", i, p.Budget)
+		}
+		if !validOverheadAction(p.Action) {
+			return fmt.Errorf("policies[%d]: unknown action %s, should be sample or disable", i, p.Action)
+		}
+	}
+	return nil
+}
+
+// budget returns the cpu budget and action of the probe.
+func (c *OverheadConfig) budget(name string) (float64, string) {
+	budget, action := c.Budget, c.Action
+	for _, p := range c.Policies {
+		if p.Probe == name {
+			budget = p.Budget
+			if p.Action != "" {
+				action = p.Action
+			}
+			break
+		}
+	}
+	if action == "" {
+		action = overheadActionSample
+	}
+	return budget, action
+}
+
+// withProbeLabels runs f with the pprof label of the probe, goroutines started in f inherit
+// the label, so their cpu samples are attributed to the probe.
+func withProbeLabels(ctx context.Context, name string, f func(ctx context.Context)) {
+	pprof.Do(ctx, pprof.Labels(probeLabel, name), f)
+}
+
+// errProfiling is returned when another cpu profile is running, e.g. of /debug/pprof/profile.
+var errProfiling = errors.New("cpu profile is already running")
+
+// profileProbes profiles cpu of the exporter for d and returns cpu time of each probe.
+func profileProbes(d time.Duration, done <-chan struct{}) (map[string]time.Duration, error) {
+	var buf bytes.Buffer
+	// the only error of StartCPUProfile is that profiling is in use
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		return nil, fmt.Errorf("%w: %v", errProfiling, err)
+	}
+	timer := time.NewTimer(d)
+	select {
+	case <-timer.C:
+	case <-done:
+		timer.Stop()
+	}
+	pprof.StopCPUProfile()
+
+	prof, err := profile.Parse(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed parse cpu profile: %w", err)
+	}
+	return probeCPU(prof), nil
+}
+
+// probeCPU sums cpu time of samples by the probe label.
+func probeCPU(prof *profile.Profile) map[string]time.Duration {
+	idx := -1
+	for i, st := range prof.SampleType {
+		if st.Type == "cpu" {
+			idx = i
+		}
+	}
+	ret := make(map[string]time.Duration)
+	if idx < 0 {
+		return ret
+	}
+	for _, s := range prof.Sample {
+		names := s.Label[probeLabel]
+		if len(names) == 0 {
+			continue
+		}
+		ret[names[0]] += time.Duration(s.Value[idx])
+	}
+	return ret
+}
+
+// probeSuspender stops and starts probes without changing the config.
+type probeSuspender interface {
+	suspendProbe(ctx context.Context, name string) error
+	resumeProbe(ctx context.Context, name string) error
+}
+
+type probeUsage struct {
+	bpf   float64
+	goCPU float64
+}
+
+type throttle struct {
+	// ratio is the ratio of time the probe runs in an interval, 0 if it is disabled
+	ratio float64
+	timer *time.Timer
+}
+
+// overheadAccountant accounts bpf run time and go cpu of probes. Probes exceeding their budget
+// are sampled, i.e. only run for a part of every interval, or disabled. Ratios of sampled probes
+// are raised back as their usage falls below budgets, disabled probes are restored on config
+// reload.
+type overheadAccountant struct {
+	cfg     OverheadConfig
+	servers []probeSuspender
+	emit    func(*probe.Event)
+	stats   io.Closer
+	done    chan struct{}
+
+	lock sync.Mutex
+	// runTime is the accumulated bpf run time of probes, lastProgram is the run time of
+	// programs when they were accumulated last time.
+	runTime          map[string]time.Duration
+	lastProgram      map[ebpf.ProgramID]time.Duration
+	lastCheckRunTime map[string]time.Duration
+	lastCheck        time.Time
+	goCPU            map[string]time.Duration
+	usage            map[string]probeUsage
+	throttles        map[string]*throttle
+}
+
+func newOverheadAccountant(cfg *OverheadConfig, servers []probeSuspender, emit func(*probe.Event)) *overheadAccountant {
+	c := *cfg
+	if c.Interval == 0 {
+		c.Interval = defaultOverheadInterval
+	}
+	if c.ProfileDuration == 0 {
+		c.ProfileDuration = defaultProfileDuration
+	}
+	return &overheadAccountant{
+		cfg:              c,
+		servers:          servers,
+		emit:             emit,
+		done:             make(chan struct{}),
+		runTime:          make(map[string]time.Duration),
+		lastProgram:      make(map[ebpf.ProgramID]time.Duration),
+		lastCheckRunTime: make(map[string]time.Duration),
+		usage:            make(map[string]probeUsage),
+		throttles:        make(map[string]*throttle),
+	}
+}
+
+func (a *overheadAccountant) start() {
+	stats, err := bpfutil.EnableRuntimeStats()
+	if err != nil {
+		log.Warnf("bpf runtime stats is not available, only go cpu of probes is accounted: %v", err)
+	}
+	a.stats = stats
+	a.lastCheck = time.Now()
+	go a.run()
+}
+
+func (a *overheadAccountant) stop() {
+	close(a.done)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, t := range a.throttles {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+	}
+	if a.stats != nil {
+		_ = a.stats.Close()
+	}
+}
+
+func (a *overheadAccountant) run() {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		// profiling starts with the interval, when sampled probes are running
+		if a.cfg.ProfileGo {
+			a.profile()
+		}
+		select {
+		case now := <-ticker.C:
+			a.check(now)
+		case <-a.done:
+			return
+		}
+	}
+}
+
+func (a *overheadAccountant) profile() {
+	cpu, err := profileProbes(a.cfg.ProfileDuration, a.done)
+	if errors.Is(err, errProfiling) {
+		log.Infof("skip go cpu accounting of probes in this interval: %v", err)
+	} else if err != nil {
+		log.Warnf("failed profile cpu of probes: %v", err)
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.goCPU = cpu
+}
+
+// accumulate adds run time of programs since last accumulation to their probes.
+func (a *overheadAccountant) accumulate() {
+	seen := make(map[ebpf.ProgramID]bool)
+	for _, s := range bpfutil.ListProgramStats() {
+		seen[s.ID] = true
+		a.runTime[s.Probe] += s.RunTime - a.lastProgram[s.ID]
+		a.lastProgram[s.ID] = s.RunTime
+	}
+	for id := range a.lastProgram {
+		if !seen[id] {
+			delete(a.lastProgram, id)
+		}
+	}
+}
+
+func (a *overheadAccountant) check(now time.Time) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.accumulate()
+	elapsed := now.Sub(a.lastCheck).Seconds()
+	a.lastCheck = now
+
+	usage := make(map[string]probeUsage)
+	for name, rt := range a.runTime {
+		u := usage[name]
+		u.bpf = (rt - a.lastCheckRunTime[name]).Seconds() / elapsed
+		usage[name] = u
+		a.lastCheckRunTime[name] = rt
+	}
+	for name, cpu := range a.goCPU {
+		// go cpu is profiled when the probe is running, it is scaled to the whole interval
+		u := usage[name]
+		u.goCPU = cpu.Seconds() / a.cfg.ProfileDuration.Seconds() * a.ratio(name)
+		usage[name] = u
+	}
+	a.usage = usage
+	a.throttle(usage)
+}
+
+func (a *overheadAccountant) ratio(name string) float64 {
+	if t, ok := a.throttles[name]; ok {
+		return t.ratio
+	}
+	return 1
+}
+
+// throttle samples or disables probes exceeding their budgets, raises ratios of sampled probes
+// whose usage falls below their budgets, and starts duty cycles of sampled probes in this interval.
+func (a *overheadAccountant) throttle(usage map[string]probeUsage) {
+	for name, u := range usage {
+		budget, action := a.cfg.budget(name)
+		total := u.bpf + u.goCPU
+		if budget <= 0 {
+			continue
+		}
+		t, ok := a.throttles[name]
+		if ok && t.ratio == 0 {
+			// usage of disabled probes is unknown, they are restored on reload
+			continue
+		}
+		if total <= budget {
+			if ok {
+				a.relax(name, t, total, budget)
+			}
+			continue
+		}
+		if !ok {
+			t = &throttle{ratio: 1}
+			a.throttles[name] = t
+		}
+
+		ratio := t.ratio * budget / total
+		if action == overheadActionDisable || ratio < minSampleRatio {
+			t.ratio = 0
+			if t.timer != nil {
+				t.timer.Stop()
+			}
+			a.suspend(name)
+			a.throttled(name, overheadActionDisable, total, budget, 0)
+			continue
+		}
+		t.ratio = ratio
+		a.throttled(name, overheadActionSample, total, budget, ratio)
+	}
+
+	for name, t := range a.throttles {
+		if t.ratio == 0 {
+			continue
+		}
+		name, t := name, t
+		a.resume(name)
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		t.timer = time.AfterFunc(time.Duration(float64(a.cfg.Interval)*t.ratio), func() {
+			a.lock.Lock()
+			defer a.lock.Unlock()
+			if a.throttles[name] != t {
+				return
+			}
+			// run time of programs is lost once they are unloaded
+			a.accumulate()
+			a.suspend(name)
+		})
+	}
+}
+
+// relax raises the ratio of the sampled probe whose usage in sampled time is below its budget,
+// the probe is no longer throttled once it fits the budget at full time.
+func (a *overheadAccountant) relax(name string, t *throttle, usage, budget float64) {
+	ratio := 1.0
+	if usage > 0 {
+		ratio = t.ratio * budget / usage
+	}
+	if ratio < 1 {
+		log.Infof("probe %s uses %.3f cores within budget %.3f, sampled at ratio %.2f", name, usage, budget, ratio)
+		t.ratio = ratio
+		return
+	}
+	log.Infof("probe %s uses %.3f cores within budget %.3f, restored", name, usage, budget)
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	delete(a.throttles, name)
+	a.resume(name)
+}
+
+func (a *overheadAccountant) suspend(name string) {
+	for _, s := range a.servers {
+		if err := s.suspendProbe(context.TODO(), name); err != nil {
+			log.Errorf("failed suspend probe %s: %v", name, err)
+		}
+	}
+}
+
+func (a *overheadAccountant) resume(name string) {
+	for _, s := range a.servers {
+		if err := s.resumeProbe(context.TODO(), name); err != nil {
+			log.Errorf("failed resume probe %s: %v", name, err)
+		}
+	}
+}
+
+func (a *overheadAccountant) throttled(name, action string, usage, budget, ratio float64) {
+	msg := fmt.Sprintf("probe %s uses %.3f cores exceeding budget %.3f", name, usage, budget)
+	if action == overheadActionSample {
+		msg = fmt.Sprintf("%s, sampled at ratio %.2f", msg, ratio)
+	} else {
+		msg = fmt.Sprintf("%s, disabled", msg)
+	}
+	log.Warn(msg)
+	if a.emit == nil {
+		return
+	}
+	a.emit(&probe.Event{
+		Timestamp: time.Now().UnixNano(),
+		Type:      ProbeThrottled,
+		Labels: []probe.Label{
+			{Name: "node", Value: nettop.GetNodeName()},
+			{Name: "probe", Value: name},
+			{Name: "action", Value: action},
+			{Name: "usage", Value: strconv.FormatFloat(usage, 'f', 3, 64)},
+			{Name: "budget", Value: strconv.FormatFloat(budget, 'f', 3, 64)},
+			{Name: "ratio", Value: strconv.FormatFloat(ratio, 'f', 2, 64)},
+		},
+		Message: msg,
+		Version: probe.EventSchemaVersion,
+	})
+}
+
+// reset restores all throttled probes.
+func (a *overheadAccountant) reset() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for name, t := range a.throttles {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		log.Infof("restore throttled probe %s", name)
+		a.resume(name)
+	}
+	a.throttles = make(map[string]*throttle)
+}
+
+func (a *overheadAccountant) Describe(descs chan<- *prometheus.Desc) {
+	descs <- bpfRunTimeDesc
+	descs <- bpfRunCountDesc
+	descs <- probeBPFTimeDesc
+	descs <- probeCPUDesc
+	descs <- probeMapMemoryDesc
+	descs <- probeSampleRatioDesc
+}
+
+func (a *overheadAccountant) Collect(metrics chan<- prometheus.Metric) {
+	for _, s := range bpfutil.ListProgramStats() {
+		metrics <- prometheus.MustNewConstMetric(bpfRunTimeDesc, prometheus.CounterValue, s.RunTime.Seconds(), s.Probe, s.Program)
+		metrics <- prometheus.MustNewConstMetric(bpfRunCountDesc, prometheus.CounterValue, float64(s.RunCount), s.Probe, s.Program)
+	}
+	for name, mem := range bpfutil.ListMapMemory() {
+		metrics <- prometheus.MustNewConstMetric(probeMapMemoryDesc, prometheus.GaugeValue, float64(mem), name)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for name, rt := range a.runTime {
+		metrics <- prometheus.MustNewConstMetric(probeBPFTimeDesc, prometheus.CounterValue, rt.Seconds(), name)
+	}
+	for name, u := range a.usage {
+		metrics <- prometheus.MustNewConstMetric(probeCPUDesc, prometheus.GaugeValue, u.bpf, name, "bpf")
+		metrics <- prometheus.MustNewConstMetric(probeCPUDesc, prometheus.GaugeValue, u.goCPU, name, "go")
+	}
+	for name, t := range a.throttles {
+		metrics <- prometheus.MustNewConstMetric(probeSampleRatioDesc, prometheus.GaugeValue, t.ratio, name)
+	}
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverheadConfigValidate(t *testing.T) {
+	assert.NoError(t, (&OverheadConfig{Enable: true, Budget: 0.1}).Validate())
+	assert.Error(t, (&OverheadConfig{Budget: -1}).Validate())
+	assert.Error(t, (&OverheadConfig{Action: "kill"}).Validate())
+	assert.Error(t, (&OverheadConfig{Interval: time.Second, ProfileDuration: time.Second}).Validate())
+	assert.Error(t, (&OverheadConfig{Policies: []OverheadPolicy{{Probe: "flow"}, {Probe: "flow"}}}).Validate())
+
+	cfg := &OverheadConfig{Budget: 0.1, Policies: []OverheadPolicy{{Probe: "kernellatency", Budget: 0.05, Action: overheadActionDisable}}}
+	budget, action := cfg.budget("flow")
+	assert.Equal(t, 0.1, budget)
+	assert.Equal(t, overheadActionSample, action)
+	budget, action = cfg.budget("kernellatency")
+	assert.Equal(t, 0.05, budget)
+	assert.Equal(t, overheadActionDisable, action)
+}
+
+func TestProbeCPU(t *testing.T) {
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		Sample: []*profile.Sample{
+			{Value: []int64{1, 10e6}, Label: map[string][]string{probeLabel: {"flow"}}},
+			{Value: []int64{2, 20e6}, Label: map[string][]string{probeLabel: {"flow"}}},
+			{Value: []int64{1, 10e6}, Label: map[string][]string{probeLabel: {"packetloss"}}},
+			{Value: []int64{5, 50e6}},
+		},
+	}
+	assert.Equal(t, map[string]time.Duration{"flow": 30 * time.Millisecond, "packetloss": 10 * time.Millisecond}, probeCPU(prof))
+}
+
+type fakeSuspender struct {
+	suspended map[string]int
+	resumed   map[string]int
+}
+
+func (f *fakeSuspender) suspendProbe(_ context.Context, name string) error {
+	f.suspended[name]++
+	return nil
+}
+
+func (f *fakeSuspender) resumeProbe(_ context.Context, name string) error {
+	f.resumed[name]++
+	return nil
+}
+
+func TestOverheadThrottle(t *testing.T) {
+	s := &fakeSuspender{suspended: make(map[string]int), resumed: make(map[string]int)}
+	var events []*probe.Event
+	a := newOverheadAccountant(&OverheadConfig{Interval: time.Hour, Budget: 0.1}, []probeSuspender{s}, func(evt *probe.Event) {
+		events = append(events, evt)
+	})
+	defer a.stop()
+
+	// probes within budget are not throttled
+	a.throttle(map[string]probeUsage{"flow": {bpf: 0.05, goCPU: 0.01}})
+	assert.Empty(t, a.throttles)
+	assert.Empty(t, events)
+
+	a.throttle(map[string]probeUsage{"kernellatency": {bpf: 0.15, goCPU: 0.05}})
+	assert.InDelta(t, 0.5, a.throttles["kernellatency"].ratio, 1e-9)
+	assert.Equal(t, 1, s.resumed["kernellatency"])
+	assert.Len(t, events, 1)
+	assert.Equal(t, ProbeThrottled, events[0].Type)
+
+	a.throttle(map[string]probeUsage{"kernellatency": {bpf: 0.2}})
+	assert.InDelta(t, 0.25, a.throttles["kernellatency"].ratio, 1e-9)
+
+	// disabled when the ratio is too low
+	a.throttle(map[string]probeUsage{"kernellatency": {bpf: 0.5}})
+	assert.Equal(t, 0.0, a.throttles["kernellatency"].ratio)
+	assert.Equal(t, 1, s.suspended["kernellatency"])
+	assert.Len(t, events, 3)
+
+	// sampled probes recover as their usage falls below the budget
+	a.throttle(map[string]probeUsage{"socketlatency": {bpf: 0.2}})
+	assert.InDelta(t, 0.5, a.throttles["socketlatency"].ratio, 1e-9)
+	a.throttle(map[string]probeUsage{"socketlatency": {bpf: 0.08}})
+	assert.InDelta(t, 0.625, a.throttles["socketlatency"].ratio, 1e-9)
+	resumed := s.resumed["socketlatency"]
+	a.throttle(map[string]probeUsage{"socketlatency": {bpf: 0.01}})
+	assert.NotContains(t, a.throttles, "socketlatency")
+	assert.Equal(t, resumed+1, s.resumed["socketlatency"])
+
+	// disabled probes are only restored on reload
+	a.throttle(map[string]probeUsage{"kernellatency": {}})
+	assert.Equal(t, 0.0, a.throttles["kernellatency"].ratio)
+
+	a.reset()
+	assert.Empty(t, a.throttles)
+	assert.Equal(t, 3, s.resumed["kernellatency"])
+}
+
+func TestProfileProbesInUse(t *testing.T) {
+	var buf bytes.Buffer
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		t.Skipf("cpu profile is not available: %v", err)
+	}
+	defer pprof.StopCPUProfile()
+
+	_, err := profileProbes(time.Millisecond, nil)
+	assert.ErrorIs(t, err, errProfiling)
+}
@@ -0,0 +1,407 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+// probeOpCmd groups commands that operate probes directly, without the server.
+var (
+	probeOpCmd = &cobra.Command{
+		Use:   "probe",
+		Short: "operate probes directly without server",
+		Run: func(cmd *cobra.Command, _ []string) {
+			_ = cmd.Help() // nolint
+		},
+	}
+
+	probeRunCmd = &cobra.Command{
+		Use:   "run <probe>",
+		Short: "run a single probe and print metrics or events to the terminal",
+		Example: `  inspector probe run tcpreset
+  inspector probe run packetloss --type event --arg enableStack=true
+  inspector probe run sock --namespace default --interval 2s
+  inspector probe run tcpretrans --pod nginx-5d8f7c9b4-abcde`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			return probeRunOpts.validate()
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			return runProbe(args[0], &probeRunOpts)
+		},
+	}
+
+	probeRunOpts = probeRunOptions{}
+)
+
+type probeRunOptions struct {
+	probeType string
+	args      []string
+	pod       string
+	namespace string
+	interval  time.Duration
+	duration  time.Duration
+	top       int
+	once      bool
+	// out is where metrics tables are written, stdout if nil
+	out io.Writer
+}
+
+func (o *probeRunOptions) validate() error {
+	if o.interval <= 0 {
+		return fmt.Errorf("interval should be positive, got %s", o.interval)
+	}
+	if o.duration < 0 {
+		return fmt.Errorf("duration should not be negative, got %s", o.duration)
+	}
+	if o.top < 0 {
+		return fmt.Errorf("top should not be negative, got %d", o.top)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(probeOpCmd)
+	probeOpCmd.AddCommand(probeRunCmd)
+
+	flags := probeRunCmd.Flags()
+	flags.StringVarP(&probeRunOpts.probeType, "type", "t", "", "probe type, metrics or event. Required when the probe provides both")
+	flags.StringArrayVarP(&probeRunOpts.args, "arg", "a", nil, "probe args in key=value format, value is parsed as yaml, can be specified multiple times")
+	flags.StringVarP(&probeRunOpts.pod, "pod", "p", "", "only show data of the pod")
+	flags.StringVarP(&probeRunOpts.namespace, "namespace", "n", "", "only show data of pods in the namespace")
+	flags.DurationVarP(&probeRunOpts.interval, "interval", "i", 5*time.Second, "refresh interval of metrics table")
+	flags.DurationVar(&probeRunOpts.duration, "duration", 0, "stop after the duration, 0 means running until interrupted")
+	flags.IntVar(&probeRunOpts.top, "top", 0, "only show top n rows of metrics ordered by value, 0 means all")
+	flags.BoolVar(&probeRunOpts.once, "once", false, "print metrics once after the first interval and exit")
+}
+
+func parseProbeArgs(args []string) (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+	for _, arg := range args {
+		k, v, ok := strings.Cut(arg, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid arg %q, valid format is key=value", arg)
+		}
+
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(v), &value); err != nil {
+			return nil, fmt.Errorf("failed parse value of arg %s: %w", k, err)
+		}
+		ret[k] = value
+	}
+	return ret, nil
+}
+
+func detectProbeType(name, probeType string) (string, error) {
+	isMetrics := slices.Contains(probe.ListMetricsProbes(), name)
+	isEvent := slices.Contains(probe.ListEventProbes(), name)
+
+	switch probeType {
+	case "metrics":
+		if !isMetrics {
+			return "", fmt.Errorf("metrics probe %s not found", name)
+		}
+	case "event":
+		if !isEvent {
+			return "", fmt.Errorf("event probe %s not found", name)
+		}
+	case "":
+		switch {
+		case isMetrics && isEvent:
+			return "", fmt.Errorf("probe %s provides both metrics and event, specify one with --type", name)
+		case isMetrics:
+			return "metrics", nil
+		case isEvent:
+			return "event", nil
+		default:
+			return "", fmt.Errorf("probe %s not found, use `list probe` to show available probes", name)
+		}
+	default:
+		return "", fmt.Errorf("unknown probe type %s, valid types are metrics and event", probeType)
+	}
+	return probeType, nil
+}
+
+func runProbe(name string, opts *probeRunOptions) error {
+	probeType, err := detectProbeType(name, opts.probeType)
+	if err != nil {
+		return err
+	}
+
+	args, err := parseProbeArgs(opts.args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	if opts.duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	if err := nettop.StartCache(ctx, sidecar); err != nil {
+		return fmt.Errorf("failed start cache: %w", err)
+	}
+	defer nettop.StopCache()
+
+	filter := &labelFilter{pod: opts.pod, namespace: opts.namespace}
+	if probeType == "metrics" {
+		return runMetricsProbe(ctx, name, args, filter, opts)
+	}
+	return runEventProbe(ctx, name, args, filter)
+}
+
+func runMetricsProbe(ctx context.Context, name string, args map[string]interface{}, filter *labelFilter, opts *probeRunOptions) error {
+	p, err := probe.CreateMetricsProbe(name, args)
+	if err != nil {
+		return fmt.Errorf("failed create metrics probe %s: %w", name, err)
+	}
+
+	if err := p.Start(ctx); err != nil {
+		return fmt.Errorf("failed start metrics probe %s: %w", name, err)
+	}
+	defer func() {
+		if err := p.Stop(context.Background()); err != nil {
+			log.Errorf("failed stop metrics probe %s: %v", name, err)
+		}
+	}()
+
+	r := prometheus.NewRegistry()
+	if err := r.Register(p); err != nil {
+		return fmt.Errorf("failed register metrics probe %s: %w", name, err)
+	}
+
+	out := opts.out
+	if out == nil {
+		out = os.Stdout
+	}
+	table := &metricsTable{
+		out:    out,
+		filter: filter,
+		top:    opts.top,
+		clear:  !opts.once,
+		last:   make(map[string]float64),
+	}
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			families, err := r.Gather()
+			if err != nil {
+				log.Errorf("failed gather metrics of %s: %v", name, err)
+			}
+			table.render(families)
+			if opts.once {
+				return nil
+			}
+		}
+	}
+}
+
+func runEventProbe(ctx context.Context, name string, args map[string]interface{}, filter *labelFilter) error {
+	ch := make(chan *probe.Event, 1024)
+	p, err := probe.CreateEventProbe(name, ch, args)
+	if err != nil {
+		return fmt.Errorf("failed create event probe %s: %w", name, err)
+	}
+
+	if err := p.Start(ctx); err != nil {
+		return fmt.Errorf("failed start event probe %s: %w", name, err)
+	}
+	defer func() {
+		if err := p.Stop(context.Background()); err != nil {
+			log.Errorf("failed stop event probe %s: %v", name, err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case evt := <-ch:
+			if !filter.match(eventLabelsToMap(evt.Labels)) {
+				continue
+			}
+			printEvent(os.Stdout, evt)
+		}
+	}
+}
+
+func eventLabelsToMap(labels []probe.Label) map[string]string {
+	ret := make(map[string]string, len(labels))
+	for _, l := range labels {
+		ret[l.Name] = l.Value
+	}
+	return ret
+}
+
+func printEvent(w io.Writer, evt *probe.Event) {
+	var labels []string
+	for _, l := range evt.Labels {
+		if l.Value == "" {
+			continue
+		}
+		labels = append(labels, fmt.Sprintf("%s=%s", l.Name, l.Value))
+	}
+	ts := time.Unix(0, evt.Timestamp).Format("15:04:05.000")
+	fmt.Fprintf(w, "%s %-20s %s %s\n", ts, evt.Type, strings.Join(labels, " "), evt.Message)
+}
+
+// labelFilter matches pod and namespace against both the standard labels of metrics
+// and events, and the source/destination labels of tuple based ones.
+type labelFilter struct {
+	pod       string
+	namespace string
+}
+
+var (
+	podLabelNames       = []string{"k8s_pod", "pod", "src_pod", "dst_pod"}
+	namespaceLabelNames = []string{"k8s_namespace", "namespace", "src_namespace", "dst_namespace"}
+)
+
+func (f *labelFilter) match(labels map[string]string) bool {
+	if f.pod == "" && f.namespace == "" {
+		return true
+	}
+
+	// pod and namespace of the same side must both match
+	for i := range podLabelNames {
+		pod, hasPod := labels[podLabelNames[i]]
+		namespace, hasNamespace := labels[namespaceLabelNames[i]]
+		if !hasPod && !hasNamespace {
+			continue
+		}
+		if (f.pod == "" || f.pod == pod) && (f.namespace == "" || f.namespace == namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+type metricsRow struct {
+	key       string
+	name      string
+	namespace string
+	pod       string
+	labels    string
+	value     float64
+	rate      string
+}
+
+type metricsTable struct {
+	out        io.Writer
+	filter     *labelFilter
+	top        int
+	clear      bool
+	last       map[string]float64
+	lastUpdate time.Time
+}
+
+func (t *metricsTable) rows(families []*dto.MetricFamily) []metricsRow {
+	var rows []metricsRow
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			var all, extra []string
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+				all = append(all, fmt.Sprintf("%s=%s", lp.GetName(), lp.GetValue()))
+				switch lp.GetName() {
+				case "k8s_node", "k8s_namespace", "k8s_pod":
+					continue
+				}
+				if lp.GetValue() != "" {
+					extra = append(extra, fmt.Sprintf("%s=%s", lp.GetName(), lp.GetValue()))
+				}
+			}
+
+			if !t.filter.match(labels) {
+				continue
+			}
+
+			var value float64
+			switch {
+			case m.GetCounter() != nil:
+				value = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				value = m.GetGauge().GetValue()
+			case m.GetUntyped() != nil:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+
+			rows = append(rows, metricsRow{
+				key:       family.GetName() + "{" + strings.Join(all, ",") + "}",
+				name:      family.GetName(),
+				namespace: labels["k8s_namespace"],
+				pod:       labels["k8s_pod"],
+				labels:    strings.Join(extra, ","),
+				value:     value,
+			})
+		}
+	}
+	return rows
+}
+
+func (t *metricsTable) render(families []*dto.MetricFamily) {
+	now := time.Now()
+	rows := t.rows(families)
+
+	elapsed := now.Sub(t.lastUpdate).Seconds()
+	current := make(map[string]float64, len(rows))
+	for i := range rows {
+		current[rows[i].key] = rows[i].value
+		if last, ok := t.last[rows[i].key]; ok && elapsed > 0 {
+			rows[i].rate = fmt.Sprintf("%.2f/s", (rows[i].value-last)/elapsed)
+		}
+	}
+	t.last = current
+	t.lastUpdate = now
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].value != rows[j].value {
+			return rows[i].value > rows[j].value
+		}
+		return rows[i].key < rows[j].key
+	})
+	if t.top > 0 && len(rows) > t.top {
+		rows = rows[:t.top]
+	}
+
+	if t.clear {
+		// move cursor to top-left and clear screen
+		fmt.Fprint(t.out, "\033[H\033[2J")
+	}
+	fmt.Fprintf(t.out, "%s  %d series\n\n", now.Format(time.RFC3339), len(current))
+	w := tabwriter.NewWriter(t.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METRIC\tNAMESPACE\tPOD\tLABELS\tVALUE\tRATE")
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%g\t%s\n", r.name, r.namespace, r.pod, r.labels, r.value, r.rate)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRunArgs struct {
+	Value float64
+}
+
+type fakeRunProbe struct{}
+
+func (p *fakeRunProbe) Start(_ context.Context) error {
+	return nil
+}
+
+func (p *fakeRunProbe) Stop(_ context.Context) error {
+	return nil
+}
+
+func init() {
+	probe.MustRegisterMetricsProbe("probe-run-test", func(args fakeRunArgs) (probe.MetricsProbe, error) {
+		gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "probe_run_test_value",
+			ConstLabels: prometheus.Labels{"k8s_namespace": "default", "k8s_pod": "nginx"},
+		}, func() float64 { return args.Value })
+		return probe.NewMetricsProbe("probe-run-test", &fakeRunProbe{}, gauge), nil
+	})
+}
+
+func TestProbeRunArgs(t *testing.T) {
+	t.Cleanup(func() {
+		_ = probeRunCmd.Flags().Set("interval", "5s")
+		_ = probeRunCmd.Flags().Set("top", "0")
+	})
+
+	require.NoError(t, probeRunCmd.ParseFlags([]string{"--interval", "0s"}))
+	assert.Error(t, probeRunCmd.PreRunE(probeRunCmd, []string{"probe-run-test"}))
+	require.NoError(t, probeRunCmd.ParseFlags([]string{"--interval", "-1s"}))
+	assert.Error(t, probeRunCmd.PreRunE(probeRunCmd, []string{"probe-run-test"}))
+	require.NoError(t, probeRunCmd.ParseFlags([]string{"--interval", "1s", "--top", "-1"}))
+	assert.Error(t, probeRunCmd.PreRunE(probeRunCmd, []string{"probe-run-test"}))
+	require.NoError(t, probeRunCmd.ParseFlags([]string{"--interval", "1s", "--top", "3"}))
+	assert.NoError(t, probeRunCmd.PreRunE(probeRunCmd, []string{"probe-run-test"}))
+
+	args, err := parseProbeArgs([]string{"value=42", "names=[a, b]"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"value": 42, "names": []interface{}{"a", "b"}}, args)
+	_, err = parseProbeArgs([]string{"value"})
+	assert.Error(t, err)
+
+	probeType, err := detectProbeType("probe-run-test", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "metrics", probeType)
+	_, err = detectProbeType("probe-run-test", "event")
+	assert.Error(t, err)
+}
+
+func TestRunMetricsProbeOnce(t *testing.T) {
+	var out bytes.Buffer
+	opts := &probeRunOptions{interval: 10 * time.Millisecond, once: true, out: &out}
+	args, err := parseProbeArgs([]string{"value=42"})
+	require.NoError(t, err)
+
+	err = runMetricsProbe(context.Background(), "probe-run-test", args, &labelFilter{namespace: "default"}, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "1 series")
+	assert.Regexp(t, `probe_run_test_value\s+default\s+nginx\s+42`, out.String())
+
+	// rows of other namespaces are filtered out
+	out.Reset()
+	err = runMetricsProbe(context.Background(), "probe-run-test", args, &labelFilter{namespace: "other"}, opts)
+	assert.NoError(t, err)
+	assert.NotContains(t, out.String(), "probe_run_test_value")
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultScrapeTimeout = 5 * time.Second
+
+var (
+	scrapeDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(probe.MetricsNamespace, "exporter", "probe_scrape_duration_seconds"),
+		"Duration of the last completed collection of a metrics probe.",
+		[]string{"probe"}, nil,
+	)
+	scrapeCachedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(probe.MetricsNamespace, "exporter", "probe_scrape_cached"),
+		"Whether the metrics of the probe in the last scrape were served from cache.",
+		[]string{"probe"}, nil,
+	)
+	// errors of collections are counted by probe_collect_errors_total of the probe package
+	scrapeTimeoutsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(probe.MetricsNamespace, "exporter", "probe_scrape_timeouts_total"),
+		"Total number of scrapes in which collection of a metrics probe exceeded its budget.",
+		[]string{"probe"}, nil,
+	)
+)
+
+// boundedCollector collects the probe in background and waits at most timeout in a scrape.
+// When the collection exceeds the budget, metrics of the last completed collection are served,
+// and the running collection is shared by the following scrapes until it completes.
+type boundedCollector struct {
+	name      string
+	collector prometheus.Collector
+	timeout   time.Duration
+
+	lock     sync.Mutex
+	pending  chan struct{}
+	cache    []prometheus.Metric
+	cached   bool
+	duration time.Duration
+	timeouts float64
+}
+
+func newBoundedCollector(name string, collector prometheus.Collector, timeout time.Duration) *boundedCollector {
+	if timeout <= 0 {
+		timeout = defaultScrapeTimeout
+	}
+	return &boundedCollector{
+		name:      name,
+		collector: collector,
+		timeout:   timeout,
+	}
+}
+
+func (c *boundedCollector) Describe(descs chan<- *prometheus.Desc) {
+	c.collector.Describe(descs)
+}
+
+func (c *boundedCollector) Collect(metrics chan<- prometheus.Metric) {
+	c.lock.Lock()
+	if c.pending == nil {
+		c.pending = make(chan struct{})
+		go c.collect(c.pending)
+	}
+	pending := c.pending
+	c.lock.Unlock()
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	timeout := false
+	select {
+	case <-pending:
+	case <-timer.C:
+		log.Warnf("collect metrics probe %s exceeds %s, serve cached metrics", c.name, c.timeout)
+		timeout = true
+	}
+
+	c.lock.Lock()
+	c.cached = timeout
+	if timeout {
+		c.timeouts++
+	}
+	cache := c.cache
+	c.lock.Unlock()
+
+	for _, m := range cache {
+		metrics <- m
+	}
+}
+
+func (c *boundedCollector) collect(done chan struct{}) {
+	start := time.Now()
+	ch := make(chan prometheus.Metric)
+	go withProbeLabels(context.Background(), c.name, func(context.Context) {
+		c.collector.Collect(ch)
+		close(ch)
+	})
+
+	var result []prometheus.Metric
+	for m := range ch {
+		result = append(result, m)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cache = result
+	c.duration = time.Since(start)
+	c.pending = nil
+	close(done)
+}
+
+// scrapeMetrics exposes collection status of all metrics probes.
+type scrapeMetrics struct {
+	lock       sync.Mutex
+	collectors map[string]*boundedCollector
+}
+
+func (s *scrapeMetrics) add(c *boundedCollector) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.collectors[c.name] = c
+}
+
+func (s *scrapeMetrics) remove(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.collectors, name)
+}
+
+func (s *scrapeMetrics) Describe(descs chan<- *prometheus.Desc) {
+	descs <- scrapeDurationDesc
+	descs <- scrapeCachedDesc
+	descs <- scrapeTimeoutsDesc
+}
+
+func (s *scrapeMetrics) Collect(metrics chan<- prometheus.Metric) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for name, c := range s.collectors {
+		c.lock.Lock()
+		cached := 0.0
+		if c.cached {
+			cached = 1
+		}
+		metrics <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, c.duration.Seconds(), name)
+		metrics <- prometheus.MustNewConstMetric(scrapeCachedDesc, prometheus.GaugeValue, cached, name)
+		metrics <- prometheus.MustNewConstMetric(scrapeTimeoutsDesc, prometheus.CounterValue, c.timeouts, name)
+		c.lock.Unlock()
+	}
+}
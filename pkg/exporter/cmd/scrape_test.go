@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+var testDesc = prometheus.NewDesc("kubeskoop_test_value", "test", nil, nil)
+
+type fakeCollector struct {
+	delay atomic.Int64
+	value atomic.Int64
+}
+
+func (f *fakeCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- testDesc
+}
+
+func (f *fakeCollector) Collect(metrics chan<- prometheus.Metric) {
+	time.Sleep(time.Duration(f.delay.Load()))
+	metrics <- prometheus.MustNewConstMetric(testDesc, prometheus.GaugeValue, float64(f.value.Load()))
+}
+
+func TestBoundedCollector(t *testing.T) {
+	f := &fakeCollector{}
+	f.value.Store(1)
+	c := newBoundedCollector("test", f, 50*time.Millisecond)
+	scrape := &scrapeMetrics{collectors: map[string]*boundedCollector{}}
+	scrape.add(c)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(c))
+
+	// slow collection is served from cache
+	f.delay.Store(int64(200 * time.Millisecond))
+	f.value.Store(2)
+	assert.Equal(t, float64(1), testutil.ToFloat64(c))
+
+	// the pending collection updates the cache after it completes
+	time.Sleep(250 * time.Millisecond)
+	f.delay.Store(0)
+	assert.Equal(t, float64(2), testutil.ToFloat64(c))
+
+	expected := `
+# HELP kubeskoop_exporter_probe_scrape_cached Whether the metrics of the probe in the last scrape were served from cache.
+# TYPE kubeskoop_exporter_probe_scrape_cached gauge
+kubeskoop_exporter_probe_scrape_cached{probe="test"} 0
+# HELP kubeskoop_exporter_probe_scrape_timeouts_total Total number of scrapes in which collection of a metrics probe exceeded its budget.
+# TYPE kubeskoop_exporter_probe_scrape_timeouts_total counter
+kubeskoop_exporter_probe_scrape_timeouts_total{probe="test"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(scrape, strings.NewReader(expected),
+		"kubeskoop_exporter_probe_scrape_cached", "kubeskoop_exporter_probe_scrape_timeouts_total"))
+}
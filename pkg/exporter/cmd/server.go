@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -21,6 +22,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/alibaba/kubeskoop/pkg/exporter/bpfutil"
 	task_agent "github.com/alibaba/kubeskoop/pkg/exporter/task-agent"
 	"github.com/fsnotify/fsnotify"
 
@@ -79,7 +81,7 @@ var (
 					log.Infof("controller address is empty, use dns:controller:10263 as default")
 					cfg.ControllerAddr = "dns:controller:10263"
 				}
-				if err := task_agent.NewTaskAgent(cfg.ControllerAddr).Run(); err != nil {
+				if err := task_agent.NewTaskAgent(cfg.ControllerAddr, cfg.ControllerTLS, cfg.ControllerTokenFile).Run(); err != nil {
 					log.Errorf("failed start agent: %v", err)
 					return
 				}
@@ -205,6 +207,35 @@ func (s *DynamicProbeServer[T]) Reload(ctx context.Context, config []ProbeConfig
 	return nil
 }
 
+// suspendProbe stops the running probe without changing the config, it is started again by resumeProbe.
+func (s *DynamicProbeServer[T]) suspendProbe(ctx context.Context, name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p, ok := s.probes[name]
+	if !ok || p.State() != probe.ProbeStateRunning {
+		return nil
+	}
+	log.Infof("suspend probe %s", name)
+	return s.probeManager.StopProbe(probe.WithSuspend(ctx), p)
+}
+
+// resumeProbe starts the probe stopped by suspendProbe, probes removed from the config are not started.
+func (s *DynamicProbeServer[T]) resumeProbe(ctx context.Context, name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p, ok := s.probes[name]
+	if !ok || p.State() != probe.ProbeStateStopped {
+		return nil
+	}
+	for _, c := range s.lastConfig {
+		if c.Name == name {
+			log.Infof("resume probe %s", name)
+			return s.probeManager.StartProbe(ctx, p)
+		}
+	}
+	return nil
+}
+
 type probeState struct {
 	Name  string `json:"name"`
 	State string `json:"state"`
@@ -229,6 +260,8 @@ type inspServer struct {
 	ctx           context.Context
 	metricsServer *MetricsServer
 	eventServer   *EventServer
+	// overhead is nil if overhead accounting is disabled
+	overhead *overheadAccountant
 }
 
 func (i *inspServer) WatchConfig(done <-chan struct{}) error {
@@ -285,9 +318,34 @@ func (i *inspServer) reload() error {
 		return fmt.Errorf("reload event server error: %s", err)
 	}
 
+	unpinRemovedProbes(cfg)
+	if i.overhead != nil {
+		i.overhead.reset()
+	}
 	return nil
 }
 
+// unpinRemovedProbes removes pinned maps and programs of probes which are removed from the
+// config, pins of all probes are removed if pinning is disabled. Probes which are only stopped,
+// e.g. on restart, keep their pins.
+func unpinRemovedProbes(cfg *InspServerConfig) {
+	pinned, err := bpfutil.ListPinned()
+	if err != nil {
+		log.Errorf("failed list pinned probes: %v", err)
+		return
+	}
+	configured := cfg.probeNames()
+	for _, name := range pinned {
+		if bpfutil.PinningEnabled() && configured[name] {
+			continue
+		}
+		log.Infof("remove pinned maps of probe %s", name)
+		if err := bpfutil.Unpin(name); err != nil {
+			log.Errorf("failed unpin probe %s: %v", name, err)
+		}
+	}
+}
+
 func (i *inspServer) createListener(cfg *InspServerConfig) (net.Listener, error) {
 	if cfg.Address == "" {
 		if cfg.Port != 0 {
@@ -353,6 +411,16 @@ func (i *inspServer) newHTTPServer(cfg *InspServerConfig) (*http.Server, net.Lis
 		return nil, nil, fmt.Errorf("failed create listener: %w", err)
 	}
 
+	if cfg.TLS.Enabled() {
+		tlsConfig, err := cfg.TLS.ServerConfig()
+		if err != nil {
+			listener.Close()
+			return nil, nil, fmt.Errorf("failed load tls config: %w", err)
+		}
+		listener = tls.NewListener(listener, tlsConfig)
+		log.Infof("inspector serves over tls, client certificates required: %t", cfg.TLS.CAFile != "")
+	}
+
 	log.Infof("inspector start metric server, listenAddr: %s", listener.Addr())
 	return &http.Server{}, listener, nil
 }
@@ -364,12 +432,15 @@ func (i *inspServer) start(cfg *InspServerConfig) error {
 
 	var err error
 	ctx := context.TODO()
+	bpfutil.EnablePinning(cfg.PinBPFMaps)
+	unpinRemovedProbes(cfg)
+
 	err = probe.InitAdditionalLabels(cfg.MetricsConfig.AdditionalLabels)
 	if err != nil {
 		return fmt.Errorf("failed init additional labels: %w", err)
 	}
 
-	i.metricsServer, err = newMetricsServer()
+	i.metricsServer, err = newMetricsServer(cfg.MetricsConfig.ScrapeTimeout)
 	if err != nil {
 		return fmt.Errorf("failed create metrics server: %w", err)
 	}
@@ -378,6 +449,24 @@ func (i *inspServer) start(cfg *InspServerConfig) error {
 		return fmt.Errorf("failed start metrics server: %w", err)
 	}
 
+	if len(cfg.MetricsConfig.EventMetrics) > 0 {
+		if err := i.metricsServer.StartEventMetrics(cfg.MetricsConfig.EventMetrics); err != nil {
+			return fmt.Errorf("failed start event metrics: %w", err)
+		}
+	}
+
+	if cfg.MetricsConfig.OTLP != nil && cfg.MetricsConfig.OTLP.Endpoint != "" {
+		if err := i.metricsServer.StartOTLP(ctx, cfg.MetricsConfig.OTLP); err != nil {
+			return fmt.Errorf("failed start otlp metrics exporter: %w", err)
+		}
+	}
+
+	if cfg.MetricsConfig.RemoteWrite != nil && cfg.MetricsConfig.RemoteWrite.URL != "" {
+		if err := i.metricsServer.StartRemoteWrite(ctx, cfg.MetricsConfig.RemoteWrite); err != nil {
+			return fmt.Errorf("failed start remote write: %w", err)
+		}
+	}
+
 	defer func() {
 		_ = i.metricsServer.Stop(ctx)
 	}()
@@ -390,7 +479,7 @@ func (i *inspServer) start(cfg *InspServerConfig) error {
 		log.Warnf("expected to create %d sinks , but %d were created", len(cfg.EventConfig.EventSinks), len(sinks))
 	}
 
-	i.eventServer, err = newEventServer(sinks)
+	i.eventServer, err = newEventServer(sinks, cfg.EventConfig.Aggregation, i.metricsServer.ObserveEvent)
 	if err != nil {
 		return fmt.Errorf("failed create event server: %w", err)
 	}
@@ -403,6 +492,15 @@ func (i *inspServer) start(cfg *InspServerConfig) error {
 		_ = i.eventServer.Stop(ctx)
 	}()
 
+	if cfg.Overhead != nil && cfg.Overhead.Enable {
+		i.overhead = newOverheadAccountant(cfg.Overhead, []probeSuspender{i.metricsServer, i.eventServer}, i.eventServer.emit)
+		if err := i.metricsServer.registry.Register(i.overhead); err != nil {
+			return fmt.Errorf("failed register overhead metrics: %w", err)
+		}
+		i.overhead.start()
+		defer i.overhead.stop()
+	}
+
 	done := make(chan struct{})
 
 	if err = i.WatchConfig(done); err != nil {
@@ -485,6 +583,8 @@ func (i *inspServer) statusPage(w http.ResponseWriter, _ *http.Request) {
 			"event":   probe.ListEventProbes(),
 			"metrics": probe.ListMetricsProbes(),
 		},
+
+		"probe_schemas": probe.RegisteredArgsSchemas(),
 	}
 
 	rawText, err := json.Marshal(res)
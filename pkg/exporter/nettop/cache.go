@@ -6,12 +6,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/vishvananda/netlink"
+	"golang.org/x/exp/slices"
 	"golang.org/x/sys/unix"
 	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
 
@@ -129,8 +131,7 @@ func initDefaultEntity(sidecarMode bool) error {
 			namespace: namespace,
 			name:      name,
 		}
-		ent := []*Entity{defaultEntity}
-		entities.Store(&ent)
+		storeEntities([]*Entity{defaultEntity})
 	}
 	addEntityToCache(defaultEntity, false, true)
 
@@ -169,8 +170,12 @@ type netnsMeta struct {
 }
 
 type podMeta struct {
-	name      string
-	namespace string
+	name        string
+	namespace   string
+	annotations map[string]string
+	ownerKind   string
+	ownerName   string
+	containers  []string
 }
 
 type Entity struct {
@@ -197,6 +202,20 @@ func (e *Entity) GetLabels() map[string]string {
 	return e.labels
 }
 
+func (e *Entity) GetAnnotations() map[string]string {
+	return e.podMeta.annotations
+}
+
+// GetOwner returns kind and name of the workload which owns the pod, e.g. Deployment/nginx.
+func (e *Entity) GetOwner() (string, string) {
+	return e.podMeta.ownerKind, e.podMeta.ownerName
+}
+
+// GetContainerNames returns sorted names of containers in the pod.
+func (e *Entity) GetContainerNames() []string {
+	return e.podMeta.containers
+}
+
 func (e *Entity) IsHostNetwork() bool {
 	return e.netnsMeta.isHostNetwork
 }
@@ -248,6 +267,7 @@ func StartCache(ctx context.Context, sidecarMode bool) error {
 		return fmt.Errorf("failed cache pods, err: %v", err)
 	}
 
+	watchNetnsDirs(ctx, control)
 	go cacheDaemonLoop(ctx, control)
 	return nil
 }
@@ -272,6 +292,16 @@ loop:
 			if err := cachePodsWithTimeout(cacheUpdateInterval); err != nil {
 				log.Errorf("failed cache pods: %v", err)
 			}
+		case <-refreshCh:
+			time.Sleep(refreshDebounce)
+			select {
+			case <-refreshCh:
+			default:
+			}
+			if err := cachePodsWithTimeout(cacheUpdateInterval); err != nil {
+				log.Errorf("failed cache pods: %v", err)
+			}
+			t.Reset(cacheUpdateInterval)
 		}
 	}
 
@@ -374,8 +404,12 @@ func cacheNetTopologyNoCri(_ context.Context) error {
 				ipList:        []string{pod.IP},
 			},
 			podMeta: podMeta{
-				name:      pod.Name,
-				namespace: pod.Namespace,
+				name:        pod.Name,
+				namespace:   pod.Namespace,
+				annotations: pod.Annotations,
+				ownerKind:   pod.OwnerKind,
+				ownerName:   pod.OwnerName,
+				containers:  pod.Containers,
 			},
 			initPid: pod.SandboxPID,
 			labels:  pod.Labels,
@@ -386,7 +420,7 @@ func cacheNetTopologyNoCri(_ context.Context) error {
 		addEntityToCache(e, true, false)
 	}
 
-	entities.Store(&newEntities)
+	storeEntities(newEntities)
 	log.Debug("finished cache process")
 	return nil
 }
@@ -408,6 +442,12 @@ func cacheNetTopology(ctx context.Context) error {
 		return fmt.Errorf("failed list pod sandboxes: %w", err)
 	}
 
+	containers, err := listSandboxContainers()
+	if err != nil {
+		// container names are optional metadata, do not fail the whole cache process
+		log.Warnf("failed list containers: %v", err)
+	}
+
 	for _, sandbox := range sandboxList {
 
 		if contextDone(ctx) {
@@ -471,11 +511,16 @@ func cacheNetTopology(ctx context.Context) error {
 			}
 		}
 
+		ownerKind, ownerName := ownerFromLabels(name, labels)
 		e := &Entity{
 			netnsMeta: ns,
 			podMeta: podMeta{
-				name:      name,
-				namespace: namespace,
+				name:        name,
+				namespace:   namespace,
+				annotations: sandbox.Annotations,
+				ownerKind:   ownerKind,
+				ownerName:   ownerName,
+				containers:  containers[sandbox.Id],
 			},
 			initPid: info.Pid,
 			labels:  labels,
@@ -486,7 +531,28 @@ func cacheNetTopology(ctx context.Context) error {
 		addEntityToCache(e, true, false)
 	}
 
-	entities.Store(&newEntities)
+	storeEntities(newEntities)
 	log.Debug("finished cache process")
 	return nil
 }
+
+// listSandboxContainers returns sorted container names grouped by sandbox id.
+func listSandboxContainers() (map[string][]string, error) {
+	containers, err := criClient.ListContainers(nil)
+	if err != nil {
+		return nil, err
+	}
+	storeContainers(containers)
+
+	ret := make(map[string][]string)
+	for _, c := range containers {
+		if c.Metadata == nil || slices.Contains(ret[c.PodSandboxId], c.Metadata.Name) {
+			continue
+		}
+		ret[c.PodSandboxId] = append(ret[c.PodSandboxId], c.Metadata.Name)
+	}
+	for _, names := range ret {
+		sort.Strings(names)
+	}
+	return ret, nil
+}
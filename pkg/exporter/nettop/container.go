@@ -0,0 +1,166 @@
+package nettop
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/patrickmn/go-cache"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const (
+	criImageNameAnnotation = "io.kubernetes.cri.image-name"
+	podNameLabel           = "io.kubernetes.pod.name"
+	podNamespaceLabel      = "io.kubernetes.pod.namespace"
+)
+
+var (
+	containers = atomic.Pointer[map[string]*Container]{}
+	// cgroupCache maps cgroup v2 ids to container ids, empty id is cached for cgroups
+	// which are not containers.
+	cgroupCache = cache.New(20*cacheUpdateInterval, 20*cacheUpdateInterval)
+	// cgroupLookups queues cgroup ids missing in cgroupCache, they are resolved by a single
+	// background worker because walking the cgroup tree is too slow for perf read loops.
+	cgroupLookups    = make(chan uint64, 1024)
+	cgroupLookupOnce sync.Once
+
+	// container ids in cgroup paths of docker, containerd and cri-o, e.g.
+	// cri-containerd-<id>.scope, docker-<id>.scope or /<id>
+	containerIDRegexp = regexp.MustCompile(`[0-9a-f]{64}`)
+)
+
+// Container is a container of a pod on the node.
+type Container struct {
+	ID           string
+	Name         string
+	Image        string
+	PodName      string
+	PodNamespace string
+}
+
+func storeContainers(list []*v1.Container) {
+	m := make(map[string]*Container, len(list))
+	for _, c := range list {
+		if c.Metadata == nil {
+			continue
+		}
+		container := &Container{
+			ID:           c.Id,
+			Name:         c.Metadata.Name,
+			PodName:      c.Labels[podNameLabel],
+			PodNamespace: c.Labels[podNamespaceLabel],
+		}
+		// image spec of containerd is the image id, the name is kept in annotations
+		if name, ok := c.Annotations[criImageNameAnnotation]; ok {
+			container.Image = name
+		} else if c.Image != nil {
+			container.Image = c.Image.Image
+		}
+		m[c.Id] = container
+	}
+	containers.Store(&m)
+}
+
+func getContainer(id string) *Container {
+	m := containers.Load()
+	if m == nil || id == "" {
+		return nil
+	}
+	return (*m)[id]
+}
+
+// containerIDFromCgroup returns the last container id in the cgroup path.
+func containerIDFromCgroup(path string) string {
+	ids := containerIDRegexp.FindAllString(path, -1)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[len(ids)-1]
+}
+
+// GetContainerByPid returns the container of the process, it returns nil if the process is not in
+// a known container or has exited.
+func GetContainerByPid(pid int) *Container {
+	if pid <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil
+	}
+	return getContainer(containerIDFromCgroup(string(data)))
+}
+
+// GetContainerByCgroupID returns the container of the cgroup v2 id as returned by
+// bpf_get_current_cgroup_id, it returns nil if the cgroup is not a known container.
+// Unknown ids are resolved in background, so it also returns nil until the lookup finishes.
+func GetContainerByCgroupID(id uint64) *Container {
+	if id == 0 {
+		return nil
+	}
+	key := strconv.FormatUint(id, 10)
+	if v, ok := cgroupCache.Get(key); ok {
+		return getContainer(v.(string))
+	}
+
+	// cache an empty id as pending, so every id is only queued once
+	if err := cgroupCache.Add(key, "", cache.DefaultExpiration); err != nil {
+		return nil
+	}
+	cgroupLookupOnce.Do(func() { go resolveCgroups() })
+	select {
+	case cgroupLookups <- id:
+	default:
+		// retry on later events when the queue is full
+		cgroupCache.Delete(key)
+	}
+	return nil
+}
+
+func resolveCgroups() {
+	for id := range cgroupLookups {
+		containerID, err := lookupCgroupByID(id)
+		if err != nil {
+			log.Debugf("failed lookup cgroup %d: %v", id, err)
+		}
+		// cache misses too, walking the cgroup tree is expensive
+		cgroupCache.SetDefault(strconv.FormatUint(id, 10), containerID)
+	}
+}
+
+// lookupCgroupByID walks the unified cgroup hierarchy for the cgroup whose inode is id.
+func lookupCgroupByID(id uint64) (string, error) {
+	if cgroupRoot == "" {
+		return "", fmt.Errorf("cgroup root not found")
+	}
+	root := cgroupRoot
+	// cgroup v1 hosts mount the unified hierarchy at unified when it is enabled
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		root = filepath.Join(cgroupRoot, "unified")
+	}
+
+	var ret string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Ino == id {
+			ret = containerIDFromCgroup(path)
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return ret, err
+}
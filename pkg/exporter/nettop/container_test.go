@@ -0,0 +1,71 @@
+package nettop
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+func TestContainerIDFromCgroup(t *testing.T) {
+	id := "4f2b6c3e1a9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f"
+	paths := []string{
+		"0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + id + ".scope",
+		"12:memory:/kubepods/burstable/pod1234/" + id,
+		"0::/system.slice/docker-" + id + ".scope",
+		"0::/kubepods.slice/kubepods-pod1234.slice/crio-" + id + ".scope",
+	}
+	for _, p := range paths {
+		assert.Equal(t, id, containerIDFromCgroup(p), p)
+	}
+	assert.Equal(t, "", containerIDFromCgroup("0::/user.slice/user-0.slice/session-1.scope"))
+}
+
+func TestStoreContainers(t *testing.T) {
+	storeContainers([]*v1.Container{
+		{
+			Id:          "c1",
+			Metadata:    &v1.ContainerMetadata{Name: "web"},
+			Image:       &v1.ImageSpec{Image: "sha256:abcd"},
+			Labels:      map[string]string{podNameLabel: "p1", podNamespaceLabel: "ns1"},
+			Annotations: map[string]string{criImageNameAnnotation: "nginx:1.25"},
+		},
+		{
+			Id:       "c2",
+			Metadata: &v1.ContainerMetadata{Name: "sidecar"},
+			Image:    &v1.ImageSpec{Image: "envoy:1.28"},
+		},
+		{Id: "c3"},
+	})
+	assert.Equal(t, &Container{ID: "c1", Name: "web", Image: "nginx:1.25", PodName: "p1", PodNamespace: "ns1"}, getContainer("c1"))
+	assert.Equal(t, "envoy:1.28", getContainer("c2").Image)
+	assert.Nil(t, getContainer("c3"))
+	assert.Nil(t, getContainer(""))
+}
+
+func TestGetContainerByCgroupID(t *testing.T) {
+	root := t.TempDir()
+	id := "4f2b6c3e1a9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f"
+	dir := filepath.Join(root, "kubepods.slice", "cri-containerd-"+id+".scope")
+	assert.NoError(t, os.MkdirAll(dir, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.controllers"), nil, 0o644))
+	info, err := os.Stat(dir)
+	assert.NoError(t, err)
+	ino := info.Sys().(*syscall.Stat_t).Ino
+
+	oldRoot := cgroupRoot
+	cgroupRoot = root
+	defer func() { cgroupRoot = oldRoot }()
+	storeContainers([]*v1.Container{{Id: id, Metadata: &v1.ContainerMetadata{Name: "web"}}})
+
+	// the first miss is resolved in background
+	assert.Nil(t, GetContainerByCgroupID(ino))
+	assert.Eventually(t, func() bool {
+		c := GetContainerByCgroupID(ino)
+		return c != nil && c.Name == "web"
+	}, 5*time.Second, 10*time.Millisecond)
+}
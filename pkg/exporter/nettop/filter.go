@@ -0,0 +1,35 @@
+package nettop
+
+// NamespaceArgs are args of probes which can be restricted to pods of namespaces, squash it
+// into args of probes with `mapstructure:",squash"`.
+type NamespaceArgs struct {
+	Namespaces []string `mapstructure:"namespaces" description:"pod namespaces the probe works in, host network is excluded when set, all namespaces by default"`
+}
+
+// EntityFilter selects entities by pod namespaces.
+type EntityFilter struct {
+	namespaces map[string]bool
+}
+
+// NewEntityFilter returns a filter of entities in the namespaces, all entities including host
+// network are selected if namespaces is empty.
+func NewEntityFilter(namespaces []string) *EntityFilter {
+	f := &EntityFilter{namespaces: make(map[string]bool, len(namespaces))}
+	for _, ns := range namespaces {
+		f.namespaces[ns] = true
+	}
+	return f
+}
+
+// Filter returns the entity filter of the args.
+func (a NamespaceArgs) Filter() *EntityFilter {
+	return NewEntityFilter(a.Namespaces)
+}
+
+// Match returns whether the entity is selected, a nil filter selects all entities.
+func (f *EntityFilter) Match(et *Entity) bool {
+	if f == nil || len(f.namespaces) == 0 {
+		return true
+	}
+	return !et.IsHostNetwork() && f.namespaces[et.GetPodNamespace()]
+}
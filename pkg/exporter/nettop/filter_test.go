@@ -0,0 +1,23 @@
+package nettop
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityFilter(t *testing.T) {
+	pod := &Entity{netnsMeta: &netnsMeta{inum: 1}, podMeta: podMeta{namespace: "default"}}
+	other := &Entity{netnsMeta: &netnsMeta{inum: 2}, podMeta: podMeta{namespace: "kube-system"}}
+	host := &Entity{netnsMeta: &netnsMeta{inum: 3, isHostNetwork: true}, podMeta: podMeta{namespace: "default"}}
+
+	for _, f := range []*EntityFilter{nil, NewEntityFilter(nil)} {
+		assert.True(t, f.Match(pod))
+		assert.True(t, f.Match(host))
+	}
+
+	f := NamespaceArgs{Namespaces: []string{"default"}}.Filter()
+	assert.True(t, f.Match(pod))
+	assert.False(t, f.Match(other))
+	assert.False(t, f.Match(host))
+}
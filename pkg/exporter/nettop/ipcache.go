@@ -12,6 +12,7 @@ type IPType string
 
 const IPTypeNode IPType = "node"
 const IPTypePod IPType = "pod"
+const IPTypeService IPType = "service"
 
 type IPInfo struct {
 	Type         IPType
@@ -19,6 +20,9 @@ type IPInfo struct {
 	NodeName     string
 	PodName      string
 	PodNamespace string
+	// ServiceNamespace and ServiceName are set for virtual ips of services
+	ServiceNamespace string
+	ServiceName      string
 }
 
 func (i *IPInfo) String() string {
@@ -78,6 +82,9 @@ func ApplyIPCacheChange(revision uint64, op rpc.OpCode, info *IPInfo) {
 	case rpc.OpCode_Set:
 		cache.entries[info.IP] = info
 	case rpc.OpCode_Del:
-		delete(cache.entries, info.IP)
+		// the ip may have been taken by an entry of another type
+		if cur, ok := cache.entries[info.IP]; ok && cur.Type == info.Type {
+			delete(cache.entries, info.IP)
+		}
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -39,6 +40,10 @@ type PodCacheInfo struct {
 	Name          string
 	Namespace     string
 	Labels        map[string]string
+	Annotations   map[string]string
+	OwnerKind     string
+	OwnerName     string
+	Containers    []string
 	CgroupPath    string
 	SandboxPID    int
 	NetNSPath     string
@@ -172,6 +177,13 @@ func (pc *PodCache) updatePodBasicInfo(pod *v1.Pod) {
 	// Get existing pod info if available
 	existingInfo, exists := pc.podInfoCache[uid]
 
+	var containers []string
+	for _, c := range pod.Spec.Containers {
+		containers = append(containers, c.Name)
+	}
+	sort.Strings(containers)
+	ownerKind, ownerName := ownerFromReferences(pod.Labels, pod.OwnerReferences)
+
 	// Update basic info
 	podInfo := PodCacheInfo{
 		UID:           uid,
@@ -179,6 +191,10 @@ func (pc *PodCache) updatePodBasicInfo(pod *v1.Pod) {
 		Name:          pod.Name,
 		Namespace:     pod.Namespace,
 		Labels:        pod.Labels,
+		Annotations:   pod.Annotations,
+		OwnerKind:     ownerKind,
+		OwnerName:     ownerName,
+		Containers:    containers,
 		IsHostNetwork: pod.Spec.HostNetwork,
 	}
 
@@ -250,6 +266,7 @@ func (pc *PodCache) updatePodCgroupInfo(uid, cgroupPath string) {
 				path:  cgroupPath,
 				inode: pathInode,
 			}, struct{}{})
+			triggerCacheRefresh()
 		} else {
 			logger.Error(err, "error getting netns info", "uid", uid, "cgroupPath", cgroupPath)
 		}
@@ -262,6 +279,7 @@ func (pc *PodCache) deletePodFromCache(uid string) {
 	defer pc.podInfoCacheLock.Unlock()
 	logger.Info("delete pod from cache", "uid", uid)
 	delete(pc.podInfoCache, uid)
+	triggerCacheRefresh()
 }
 
 // initCgroupWatch initializes cgroup watcher
@@ -0,0 +1,208 @@
+package nettop
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+type NetnsEventType string
+
+const (
+	NetnsAdd    NetnsEventType = "add"
+	NetnsRemove NetnsEventType = "remove"
+
+	// wait for more changes before refreshing cache, a pod creation usually triggers several events.
+	refreshDebounce = 300 * time.Millisecond
+)
+
+var (
+	// directories where container runtimes bind mount netns files
+	netnsDirs = []string{"/var/run/netns", "/run/docker/netns"}
+
+	refreshCh = make(chan struct{}, 1)
+	bus       = &netnsBus{
+		subscribers: make(map[*netnsSubscriber]struct{}),
+		current:     make(map[int]*Entity),
+	}
+)
+
+// NetnsEvent notifies a unique netns entity is added or removed, see GetAllUniqueNetnsEntity.
+type NetnsEvent struct {
+	Type   NetnsEventType
+	Entity *Entity
+}
+
+type netnsBus struct {
+	lock        sync.Mutex
+	subscribers map[*netnsSubscriber]struct{}
+	current     map[int]*Entity
+}
+
+// netnsSubscriber buffers events in an unbounded queue, so that a slow subscriber
+// never blocks the cache loop or misses events.
+type netnsSubscriber struct {
+	lock   sync.Mutex
+	queue  []NetnsEvent
+	notify chan struct{}
+	ch     chan NetnsEvent
+	done   chan struct{}
+}
+
+func (s *netnsSubscriber) push(events ...NetnsEvent) {
+	if len(events) == 0 {
+		return
+	}
+	s.lock.Lock()
+	s.queue = append(s.queue, events...)
+	s.lock.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *netnsSubscriber) run() {
+	defer close(s.ch)
+	for {
+		s.lock.Lock()
+		if len(s.queue) == 0 {
+			s.lock.Unlock()
+			select {
+			case <-s.notify:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.lock.Unlock()
+
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// SubscribeNetnsEvents returns a channel of netns add/remove events. Add events of all existing
+// entities are sent first. Call the returned function to unsubscribe, the channel is closed then.
+func SubscribeNetnsEvents() (<-chan NetnsEvent, func()) {
+	s := &netnsSubscriber{
+		notify: make(chan struct{}, 1),
+		ch:     make(chan NetnsEvent),
+		done:   make(chan struct{}),
+	}
+
+	bus.lock.Lock()
+	for _, e := range bus.current {
+		s.push(NetnsEvent{Type: NetnsAdd, Entity: e})
+	}
+	bus.subscribers[s] = struct{}{}
+	bus.lock.Unlock()
+
+	go s.run()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			bus.lock.Lock()
+			delete(bus.subscribers, s)
+			bus.lock.Unlock()
+			close(s.done)
+		})
+	}
+}
+
+// storeEntities replaces cached entities and publishes netns changes to subscribers.
+func storeEntities(newEntities []*Entity) {
+	entities.Store(&newEntities)
+
+	latest := make(map[int]*Entity)
+	for _, e := range newEntities {
+		if e != nil && (e == defaultEntity || !e.IsHostNetwork()) {
+			latest[e.GetNetns()] = e
+		}
+	}
+
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	var events []NetnsEvent
+	for inum, e := range latest {
+		if _, ok := bus.current[inum]; !ok {
+			events = append(events, NetnsEvent{Type: NetnsAdd, Entity: e})
+		}
+	}
+	for inum, e := range bus.current {
+		if _, ok := latest[inum]; !ok {
+			events = append(events, NetnsEvent{Type: NetnsRemove, Entity: e})
+		}
+	}
+	bus.current = latest
+
+	for _, ev := range events {
+		log.Debugf("nettop: netns %d of %s %s", ev.Entity.GetNetns(), ev.Entity, ev.Type)
+	}
+	for s := range bus.subscribers {
+		s.push(events...)
+	}
+}
+
+// triggerCacheRefresh asks the cache loop to refresh entities now instead of waiting for the next tick.
+func triggerCacheRefresh() {
+	select {
+	case refreshCh <- struct{}{}:
+	default:
+	}
+}
+
+// watchNetnsDirs triggers cache refresh when netns files are created or removed by container runtimes.
+func watchNetnsDirs(ctx context.Context, done chan struct{}) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Warnf("nettop: failed create netns watcher, netns changes are found by polling: %v", err)
+		return
+	}
+
+	watched := 0
+	for _, dir := range netnsDirs {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			log.Warnf("nettop: failed watch netns directory %s: %v", dir, err)
+			continue
+		}
+		watched++
+	}
+	if watched == 0 {
+		log.Infof("nettop: no netns directory found, netns changes are found by polling")
+		_ = watcher.Close()
+		return
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case ev := <-watcher.Events:
+				if ev.Op&(fsnotify.Create|fsnotify.Remove) != 0 {
+					triggerCacheRefresh()
+				}
+			case err := <-watcher.Errors:
+				log.Warnf("nettop: netns watcher error: %v", err)
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+}
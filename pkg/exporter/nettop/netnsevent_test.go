@@ -0,0 +1,44 @@
+package nettop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receiveNetnsEvent(t *testing.T, ch <-chan NetnsEvent) NetnsEvent {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting netns event")
+	}
+	return NetnsEvent{}
+}
+
+func TestNetnsEvents(t *testing.T) {
+	pod1 := &Entity{netnsMeta: &netnsMeta{inum: 1}}
+	pod2 := &Entity{netnsMeta: &netnsMeta{inum: 2}}
+	hostPod := &Entity{netnsMeta: &netnsMeta{inum: 3, isHostNetwork: true}}
+
+	storeEntities([]*Entity{pod1})
+	ch, cancel := SubscribeNetnsEvents()
+
+	// existing entities are sent first
+	assert.Equal(t, NetnsEvent{Type: NetnsAdd, Entity: pod1}, receiveNetnsEvent(t, ch))
+
+	storeEntities([]*Entity{pod1, pod2, hostPod})
+	assert.Equal(t, NetnsEvent{Type: NetnsAdd, Entity: pod2}, receiveNetnsEvent(t, ch))
+
+	storeEntities([]*Entity{pod2})
+	assert.Equal(t, NetnsEvent{Type: NetnsRemove, Entity: pod1}, receiveNetnsEvent(t, ch))
+
+	cancel()
+	_, ok := <-ch
+	assert.False(t, ok)
+	cancel()
+
+	storeEntities(nil)
+	assert.Len(t, bus.subscribers, 0)
+}
@@ -0,0 +1,79 @@
+package nettop
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	nodeLabelsRefreshInterval = 5 * time.Minute
+	nodeLabelsTimeout         = 5 * time.Second
+)
+
+var nodeLabels = struct {
+	once   sync.Once
+	lock   sync.RWMutex
+	labels map[string]string
+}{}
+
+// GetNodeLabels returns labels of the current node from apiserver. Labels are fetched when
+// first used and refreshed periodically in background, nil is returned when apiserver is not
+// available.
+func GetNodeLabels() map[string]string {
+	nodeLabels.once.Do(startNodeLabelsRefresh)
+
+	nodeLabels.lock.RLock()
+	defer nodeLabels.lock.RUnlock()
+	return nodeLabels.labels
+}
+
+func startNodeLabelsRefresh() {
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		log.Warnf("failed get kubernetes config, node labels are not available: %v", err)
+		return
+	}
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		log.Warnf("failed create kubernetes client, node labels are not available: %v", err)
+		return
+	}
+
+	refreshNodeLabels(c)
+	go func() {
+		t := time.NewTicker(nodeLabelsRefreshInterval)
+		defer t.Stop()
+		for range t.C {
+			refreshNodeLabels(c)
+		}
+	}()
+}
+
+// refreshNodeLabels keeps the last labels on error.
+func refreshNodeLabels(c client.Client) {
+	labels, err := fetchNodeLabels(c)
+	if err != nil {
+		log.Warnf("failed get labels of node %s: %v", GetNodeName(), err)
+		return
+	}
+	nodeLabels.lock.Lock()
+	nodeLabels.labels = labels
+	nodeLabels.lock.Unlock()
+}
+
+func fetchNodeLabels(c client.Client) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), nodeLabelsTimeout)
+	defer cancel()
+	node := &v1.Node{}
+	if err := c.Get(ctx, client.ObjectKey{Name: GetNodeName()}, node); err != nil {
+		return nil, fmt.Errorf("failed get node: %w", err)
+	}
+	return node.Labels, nil
+}
@@ -0,0 +1,24 @@
+package nettop
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRefreshNodeLabels(t *testing.T) {
+	t.Setenv("INSPECTOR_NODENAME", "node-1")
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"zone": "a"}}}
+	c := fake.NewClientBuilder().WithObjects(node).Build()
+
+	refreshNodeLabels(c)
+	assert.Equal(t, map[string]string{"zone": "a"}, nodeLabels.labels)
+
+	// last labels are kept when the node cannot be got
+	t.Setenv("INSPECTOR_NODENAME", "node-2")
+	refreshNodeLabels(c)
+	assert.Equal(t, map[string]string{"zone": "a"}, nodeLabels.labels)
+}
@@ -0,0 +1,66 @@
+package nettop
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	labelPodTemplateHash       = "pod-template-hash"
+	labelControllerRevision    = "controller-revision-hash"
+	labelStatefulSetPodName    = "statefulset.kubernetes.io/pod-name"
+	labelPodTemplateGeneration = "pod-template-generation"
+	labelJobName               = "job-name"
+)
+
+// ownerFromLabels guesses the owner workload of a pod by well-known labels set by the
+// workload controllers, it is used when owner references are not available, e.g. from cri.
+func ownerFromLabels(podName string, labels map[string]string) (string, string) {
+	trimLast := func(name string) string {
+		if idx := strings.LastIndex(name, "-"); idx > 0 {
+			return name[:idx]
+		}
+		return name
+	}
+
+	if hash, ok := labels[labelPodTemplateHash]; ok && hash != "" {
+		// <deployment>-<hash>-<random>
+		if idx := strings.LastIndex(podName, "-"+hash+"-"); idx > 0 {
+			return "Deployment", podName[:idx]
+		}
+		return "ReplicaSet", trimLast(podName)
+	}
+
+	if _, ok := labels[labelControllerRevision]; ok {
+		if _, ok := labels[labelStatefulSetPodName]; ok {
+			return "StatefulSet", trimLast(podName)
+		}
+		if _, ok := labels[labelPodTemplateGeneration]; ok {
+			return "DaemonSet", trimLast(podName)
+		}
+	}
+
+	if job, ok := labels[labelJobName]; ok && job != "" {
+		return "Job", job
+	}
+
+	return "", ""
+}
+
+// ownerFromReferences returns the controller owner of a pod, pods owned by ReplicaSets of a
+// Deployment are reported as owned by the Deployment.
+func ownerFromReferences(labels map[string]string, refs []metav1.OwnerReference) (string, string) {
+	for _, ref := range refs {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		if ref.Kind == "ReplicaSet" {
+			if hash := labels[labelPodTemplateHash]; hash != "" && strings.HasSuffix(ref.Name, "-"+hash) {
+				return "Deployment", strings.TrimSuffix(ref.Name, "-"+hash)
+			}
+		}
+		return ref.Kind, ref.Name
+	}
+	return "", ""
+}
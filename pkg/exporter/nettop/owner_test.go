@@ -0,0 +1,40 @@
+package nettop
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOwnerFromLabels(t *testing.T) {
+	tests := []struct {
+		pod    string
+		labels map[string]string
+		kind   string
+		name   string
+	}{
+		{"web-5d8f7c9b4-x2x4z", map[string]string{labelPodTemplateHash: "5d8f7c9b4"}, "Deployment", "web"},
+		{"db-0", map[string]string{labelControllerRevision: "db-6c4f", labelStatefulSetPodName: "db-0"}, "StatefulSet", "db"},
+		{"agent-abcde", map[string]string{labelControllerRevision: "6c4f", labelPodTemplateGeneration: "1"}, "DaemonSet", "agent"},
+		{"backup-28a1-xyz", map[string]string{labelJobName: "backup-28a1"}, "Job", "backup-28a1"},
+		{"static", nil, "", ""},
+	}
+	for _, tt := range tests {
+		kind, name := ownerFromLabels(tt.pod, tt.labels)
+		assert.Equal(t, tt.kind, kind, tt.pod)
+		assert.Equal(t, tt.name, name, tt.pod)
+	}
+}
+
+func TestOwnerFromReferences(t *testing.T) {
+	controller := true
+	refs := []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-5d8f7c9b4", Controller: &controller}}
+	kind, name := ownerFromReferences(map[string]string{labelPodTemplateHash: "5d8f7c9b4"}, refs)
+	assert.Equal(t, "Deployment", kind)
+	assert.Equal(t, "web", name)
+
+	kind, name = ownerFromReferences(nil, refs)
+	assert.Equal(t, "ReplicaSet", kind)
+	assert.Equal(t, "web-5d8f7c9b4", name)
+}
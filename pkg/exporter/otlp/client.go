@@ -0,0 +1,239 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+
+	defaultTimeout  = 10 * time.Second
+	defaultInterval = 15 * time.Second
+
+	metricsPath = "/v1/metrics"
+	logsPath    = "/v1/logs"
+)
+
+// Config is the configuration of an OTLP endpoint.
+type Config struct {
+	// Endpoint is host:port for grpc, or base url for http, e.g. http://otel-collector:4318
+	Endpoint string            `yaml:"endpoint" mapstructure:"endpoint" json:"endpoint"`
+	Protocol string            `yaml:"protocol" mapstructure:"protocol" json:"protocol"`
+	Insecure bool              `yaml:"insecure" mapstructure:"insecure" json:"insecure"`
+	Headers  map[string]string `yaml:"headers" mapstructure:"headers" json:"headers"`
+	Timeout  time.Duration     `yaml:"timeout" mapstructure:"timeout" json:"timeout"`
+	// Interval is the push interval of metrics, ignored by event sink.
+	Interval time.Duration `yaml:"interval" mapstructure:"interval" json:"interval"`
+}
+
+// Validate checks the config without modifying it.
+func (c *Config) Validate() error {
+	cp := *c
+	return cp.setDefaults()
+}
+
+func (c *Config) setDefaults() error {
+	if c.Endpoint == "" {
+		return fmt.Errorf("otlp endpoint is empty")
+	}
+	if c.Protocol == "" {
+		c.Protocol = ProtocolGRPC
+	}
+	if c.Protocol != ProtocolGRPC && c.Protocol != ProtocolHTTP {
+		return fmt.Errorf("unsupported otlp protocol %s, only `grpc` and `http` are supported", c.Protocol)
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	return nil
+}
+
+// Client sends OTLP requests to the endpoint.
+type Client interface {
+	ExportMetrics(ctx context.Context, metrics []*metricspb.ResourceMetrics) error
+	ExportLogs(ctx context.Context, logs []*logspb.ResourceLogs) error
+	Close() error
+}
+
+func NewClient(cfg *Config) (Client, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Protocol {
+	case ProtocolHTTP:
+		return newHTTPClient(cfg), nil
+	default:
+		return newGRPCClient(cfg)
+	}
+}
+
+type grpcClient struct {
+	cfg     *Config
+	conn    *grpc.ClientConn
+	metrics collectormetrics.MetricsServiceClient
+	logs    collectorlogs.LogsServiceClient
+}
+
+func newGRPCClient(cfg *Config) (*grpcClient, error) {
+	creds := insecure.NewCredentials()
+	if !cfg.Insecure {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+
+	conn, err := grpc.Dial(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed dial otlp endpoint %s: %w", cfg.Endpoint, err)
+	}
+
+	return &grpcClient{
+		cfg:     cfg,
+		conn:    conn,
+		metrics: collectormetrics.NewMetricsServiceClient(conn),
+		logs:    collectorlogs.NewLogsServiceClient(conn),
+	}, nil
+}
+
+func (c *grpcClient) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if len(c.cfg.Headers) != 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(c.cfg.Headers))
+	}
+	return context.WithTimeout(ctx, c.cfg.Timeout)
+}
+
+func (c *grpcClient) ExportMetrics(ctx context.Context, metrics []*metricspb.ResourceMetrics) error {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	resp, err := c.metrics.Export(ctx, &collectormetrics.ExportMetricsServiceRequest{ResourceMetrics: metrics})
+	if err != nil {
+		return err
+	}
+	if p := resp.GetPartialSuccess(); p != nil && p.GetRejectedDataPoints() > 0 {
+		return fmt.Errorf("%d data points rejected: %s", p.GetRejectedDataPoints(), p.GetErrorMessage())
+	}
+	return nil
+}
+
+func (c *grpcClient) ExportLogs(ctx context.Context, logs []*logspb.ResourceLogs) error {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	resp, err := c.logs.Export(ctx, &collectorlogs.ExportLogsServiceRequest{ResourceLogs: logs})
+	if err != nil {
+		return err
+	}
+	if p := resp.GetPartialSuccess(); p != nil && p.GetRejectedLogRecords() > 0 {
+		return fmt.Errorf("%d log records rejected: %s", p.GetRejectedLogRecords(), p.GetErrorMessage())
+	}
+	return nil
+}
+
+func (c *grpcClient) Close() error {
+	return c.conn.Close()
+}
+
+type httpClient struct {
+	cfg     *Config
+	baseURL string
+	client  *http.Client
+}
+
+func newHTTPClient(cfg *Config) *httpClient {
+	baseURL := cfg.Endpoint
+	if !strings.Contains(baseURL, "://") {
+		if cfg.Insecure {
+			baseURL = "http://" + baseURL
+		} else {
+			baseURL = "https://" + baseURL
+		}
+	}
+
+	return &httpClient{
+		cfg:     cfg,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (c *httpClient) post(ctx context.Context, path string, req, resp proto.Message) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed marshal otlp request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range c.cfg.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("failed read otlp response: %w", err)
+	}
+	if httpResp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp endpoint %s returned %s: %s", c.baseURL+path, httpResp.Status, string(body))
+	}
+	if len(body) != 0 && strings.HasPrefix(httpResp.Header.Get("Content-Type"), "application/x-protobuf") {
+		if err := proto.Unmarshal(body, resp); err != nil {
+			return fmt.Errorf("failed unmarshal otlp response: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *httpClient) ExportMetrics(ctx context.Context, metrics []*metricspb.ResourceMetrics) error {
+	resp := &collectormetrics.ExportMetricsServiceResponse{}
+	if err := c.post(ctx, metricsPath, &collectormetrics.ExportMetricsServiceRequest{ResourceMetrics: metrics}, resp); err != nil {
+		return err
+	}
+	if p := resp.GetPartialSuccess(); p != nil && p.GetRejectedDataPoints() > 0 {
+		return fmt.Errorf("%d data points rejected: %s", p.GetRejectedDataPoints(), p.GetErrorMessage())
+	}
+	return nil
+}
+
+func (c *httpClient) ExportLogs(ctx context.Context, logs []*logspb.ResourceLogs) error {
+	resp := &collectorlogs.ExportLogsServiceResponse{}
+	if err := c.post(ctx, logsPath, &collectorlogs.ExportLogsServiceRequest{ResourceLogs: logs}, resp); err != nil {
+		return err
+	}
+	if p := resp.GetPartialSuccess(); p != nil && p.GetRejectedLogRecords() > 0 {
+		return fmt.Errorf("%d log records rejected: %s", p.GetRejectedLogRecords(), p.GetErrorMessage())
+	}
+	return nil
+}
+
+func (c *httpClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,239 @@
+package otlp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/alibaba/kubeskoop/version"
+	log "github.com/sirupsen/logrus"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+const (
+	logEntriesChanSize = 5000
+	logBatchSize       = 1000
+	logBatchWait       = 5 * time.Second
+
+	eventTypeAttribute = "event.name"
+)
+
+// eventResourceLabels maps event labels to OpenTelemetry resource attributes.
+var eventResourceLabels = map[string]string{
+	"node":      "k8s.node.name",
+	"namespace": "k8s.namespace.name",
+	"pod":       "k8s.pod.name",
+}
+
+// LogsExporter batches events and pushes them to an OTLP endpoint as log records.
+type LogsExporter struct {
+	client    Client
+	node      string
+	events    chan *probe.Event
+	quit      chan struct{}
+	waitGroup sync.WaitGroup
+}
+
+func NewLogsExporter(cfg *Config, node string) (*LogsExporter, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &LogsExporter{
+		client: client,
+		node:   node,
+		events: make(chan *probe.Event, logEntriesChanSize),
+		quit:   make(chan struct{}),
+	}
+	e.waitGroup.Add(1)
+	go e.loop()
+	return e, nil
+}
+
+// Send queues the event, it never blocks and discards the event when the queue is full.
+func (e *LogsExporter) Send(evt *probe.Event) bool {
+	select {
+	case e.events <- evt:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *LogsExporter) Close() error {
+	close(e.quit)
+	e.waitGroup.Wait()
+	return e.client.Close()
+}
+
+func (e *LogsExporter) loop() {
+	var batch []*probe.Event
+	maxWait := time.NewTimer(logBatchWait)
+
+	defer func() {
+		if len(batch) > 0 {
+			e.send(batch)
+		}
+		e.waitGroup.Done()
+	}()
+
+	for {
+		select {
+		case <-e.quit:
+			// flush queued events before exit
+			for {
+				select {
+				case evt := <-e.events:
+					batch = append(batch, evt)
+				default:
+					return
+				}
+			}
+		case evt := <-e.events:
+			batch = append(batch, evt)
+			if len(batch) >= logBatchSize {
+				e.send(batch)
+				batch = nil
+				maxWait.Reset(logBatchWait)
+			}
+		case <-maxWait.C:
+			if len(batch) > 0 {
+				e.send(batch)
+				batch = nil
+			}
+			maxWait.Reset(logBatchWait)
+		}
+	}
+}
+
+func (e *LogsExporter) send(batch []*probe.Event) {
+	if err := e.client.ExportLogs(context.Background(), ConvertEvents(batch, e.node)); err != nil {
+		log.Errorf("failed export %d events to otlp endpoint: %v", len(batch), err)
+	}
+}
+
+// ConvertEvents converts events to OTLP resource logs, grouped by the pod they belongs to.
+func ConvertEvents(events []*probe.Event, node string) []*logspb.ResourceLogs {
+	type group struct {
+		resource map[string]string
+		records  []*logspb.LogRecord
+	}
+	groups := make(map[string]*group)
+	var order []string
+
+	observed := uint64(time.Now().UnixNano())
+	for _, evt := range events {
+		resource := map[string]string{"k8s.node.name": node}
+		attrs := []*commonpb.KeyValue{stringKeyValue(eventTypeAttribute, string(evt.Type))}
+		attrs = append(attrs, eventAttributes(evt)...)
+		for _, l := range evt.Labels {
+			if key, ok := eventResourceLabels[l.Name]; ok {
+				if l.Value != "" {
+					resource[key] = l.Value
+				}
+				continue
+			}
+			attrs = append(attrs, stringKeyValue(l.Name, l.Value))
+		}
+
+		key := resourceKey(resource)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{resource: resource}
+			groups[key] = g
+			order = append(order, key)
+		}
+
+		g.records = append(g.records, &logspb.LogRecord{
+			TimeUnixNano:         uint64(evt.Timestamp),
+			ObservedTimeUnixNano: observed,
+			SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+			SeverityText:         "INFO",
+			Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: evt.Message}},
+			Attributes:           attrs,
+		})
+	}
+
+	var ret []*logspb.ResourceLogs
+	for _, key := range order {
+		g := groups[key]
+		ret = append(ret, &logspb.ResourceLogs{
+			Resource: newResource(g.resource),
+			ScopeLogs: []*logspb.ScopeLogs{
+				{
+					Scope:      &commonpb.InstrumentationScope{Name: scopeName, Version: version.Version},
+					LogRecords: g.records,
+				},
+			},
+		})
+	}
+	return ret
+}
+
+// eventAttributes converts structured fields of the event to log attributes, following
+// OpenTelemetry semantic conventions where there is one.
+func eventAttributes(evt *probe.Event) []*commonpb.KeyValue {
+	if evt.Version == 0 {
+		return nil
+	}
+	attrs := []*commonpb.KeyValue{intKeyValue("kubeskoop.event.version", int64(evt.Version))}
+	if evt.KernelTimestamp != 0 {
+		attrs = append(attrs, intKeyValue("kubeskoop.event.kernel_timestamp", int64(evt.KernelTimestamp)))
+	}
+	if evt.Netns != 0 {
+		attrs = append(attrs, intKeyValue("kubeskoop.netns", int64(evt.Netns)))
+	}
+	if evt.LatencyNs != 0 {
+		attrs = append(attrs, intKeyValue("kubeskoop.latency_ns", int64(evt.LatencyNs)))
+	}
+	if evt.Count != 0 {
+		attrs = append(attrs,
+			intKeyValue("kubeskoop.event.count", int64(evt.Count)),
+			intKeyValue("kubeskoop.event.first_seen", evt.FirstSeen),
+			intKeyValue("kubeskoop.event.last_seen", evt.LastSeen),
+		)
+	}
+	if t := evt.Tuple; t != nil {
+		attrs = append(attrs,
+			stringKeyValue("network.transport", strings.ToLower(t.Protocol)),
+			stringKeyValue("source.address", t.Src),
+			intKeyValue("source.port", int64(t.Sport)),
+			stringKeyValue("destination.address", t.Dst),
+			intKeyValue("destination.port", int64(t.Dport)),
+		)
+	}
+	if p := evt.Process; p != nil {
+		attrs = append(attrs, intKeyValue("process.pid", int64(p.Pid)))
+		if p.Comm != "" {
+			attrs = append(attrs, stringKeyValue("process.executable.name", p.Comm))
+		}
+		if p.Container != "" {
+			attrs = append(attrs, stringKeyValue("k8s.container.name", p.Container))
+		}
+		if p.Image != "" {
+			attrs = append(attrs, stringKeyValue("container.image.name", p.Image))
+		}
+	}
+	if len(evt.Stack) > 0 {
+		var frames []*commonpb.AnyValue
+		for _, f := range evt.Stack {
+			frames = append(frames, &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: f}})
+		}
+		attrs = append(attrs, &commonpb.KeyValue{
+			Key:   "kubeskoop.stack",
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: frames}}},
+		})
+	}
+	return attrs
+}
+
+func intKeyValue(k string, v int64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   k,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}},
+	}
+}
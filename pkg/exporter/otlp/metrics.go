@@ -0,0 +1,294 @@
+package otlp
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alibaba/kubeskoop/version"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+const (
+	scopeName   = "github.com/alibaba/kubeskoop/pkg/exporter"
+	serviceName = "kubeskoop-exporter"
+)
+
+// metricsResourceLabels maps standard metrics labels to OpenTelemetry resource attributes.
+var metricsResourceLabels = map[string]string{
+	"k8s_node":      "k8s.node.name",
+	"k8s_namespace": "k8s.namespace.name",
+	"k8s_pod":       "k8s.pod.name",
+}
+
+// MetricsExporter periodically gathers metrics from the gatherer and pushes them to an OTLP endpoint.
+type MetricsExporter struct {
+	client    Client
+	gatherer  prometheus.Gatherer
+	interval  time.Duration
+	node      string
+	startTime time.Time
+	done      chan struct{}
+}
+
+func NewMetricsExporter(cfg *Config, gatherer prometheus.Gatherer, node string) (*MetricsExporter, error) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetricsExporter{
+		client:    client,
+		gatherer:  gatherer,
+		interval:  cfg.Interval,
+		node:      node,
+		startTime: time.Now(),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+func (e *MetricsExporter) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-e.done:
+				return
+			case <-ticker.C:
+				if err := e.Export(ctx); err != nil {
+					log.Errorf("failed export metrics to otlp endpoint: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (e *MetricsExporter) Stop() error {
+	close(e.done)
+	return e.client.Close()
+}
+
+// Export gathers and pushes metrics once.
+func (e *MetricsExporter) Export(ctx context.Context) error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		// Gather may return partial result with error, push what we have.
+		log.Warnf("gather metrics with error: %v", err)
+	}
+
+	rms := ConvertMetricFamilies(families, e.node, e.startTime, time.Now())
+	if len(rms) == 0 {
+		return nil
+	}
+	return e.client.ExportMetrics(ctx, rms)
+}
+
+// ConvertMetricFamilies converts gathered prometheus metrics to OTLP resource metrics,
+// grouped by the pod they belongs to.
+func ConvertMetricFamilies(families []*dto.MetricFamily, node string, start, now time.Time) []*metricspb.ResourceMetrics {
+	type group struct {
+		resource map[string]string
+		metrics  map[string]*metricspb.Metric
+		order    []string
+	}
+	groups := make(map[string]*group)
+	var groupOrder []string
+
+	startNano := uint64(start.UnixNano())
+	nowNano := uint64(now.UnixNano())
+
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			resource := map[string]string{"k8s.node.name": node}
+			var attrs []*commonpb.KeyValue
+			for _, lp := range m.GetLabel() {
+				if key, ok := metricsResourceLabels[lp.GetName()]; ok {
+					if lp.GetValue() != "" {
+						resource[key] = lp.GetValue()
+					}
+					continue
+				}
+				attrs = append(attrs, stringKeyValue(lp.GetName(), lp.GetValue()))
+			}
+
+			key := resourceKey(resource)
+			g, ok := groups[key]
+			if !ok {
+				g = &group{resource: resource, metrics: make(map[string]*metricspb.Metric)}
+				groups[key] = g
+				groupOrder = append(groupOrder, key)
+			}
+
+			metric, ok := g.metrics[family.GetName()]
+			if !ok {
+				metric = newMetric(family)
+				if metric == nil {
+					continue
+				}
+				g.metrics[family.GetName()] = metric
+				g.order = append(g.order, family.GetName())
+			}
+
+			ts := nowNano
+			if m.TimestampMs != nil {
+				ts = uint64(m.GetTimestampMs()) * uint64(time.Millisecond)
+			}
+			appendDataPoint(metric, m, attrs, startNano, ts)
+		}
+	}
+
+	var ret []*metricspb.ResourceMetrics
+	for _, key := range groupOrder {
+		g := groups[key]
+		var metrics []*metricspb.Metric
+		for _, name := range g.order {
+			metrics = append(metrics, g.metrics[name])
+		}
+		ret = append(ret, &metricspb.ResourceMetrics{
+			Resource: newResource(g.resource),
+			ScopeMetrics: []*metricspb.ScopeMetrics{
+				{
+					Scope:   &commonpb.InstrumentationScope{Name: scopeName, Version: version.Version},
+					Metrics: metrics,
+				},
+			},
+		})
+	}
+	return ret
+}
+
+func newMetric(family *dto.MetricFamily) *metricspb.Metric {
+	metric := &metricspb.Metric{
+		Name:        family.GetName(),
+		Description: family.GetHelp(),
+	}
+
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}}
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}}
+	case dto.MetricType_HISTOGRAM:
+		metric.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		}}
+	case dto.MetricType_SUMMARY:
+		metric.Data = &metricspb.Metric_Summary{Summary: &metricspb.Summary{}}
+	default:
+		return nil
+	}
+	return metric
+}
+
+func appendDataPoint(metric *metricspb.Metric, m *dto.Metric, attrs []*commonpb.KeyValue, start, ts uint64) {
+	switch data := metric.Data.(type) {
+	case *metricspb.Metric_Sum:
+		data.Sum.DataPoints = append(data.Sum.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: start,
+			TimeUnixNano:      ts,
+			Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: m.GetCounter().GetValue()},
+		})
+	case *metricspb.Metric_Gauge:
+		value := m.GetGauge().GetValue()
+		if m.GetUntyped() != nil {
+			value = m.GetUntyped().GetValue()
+		}
+		data.Gauge.DataPoints = append(data.Gauge.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:   attrs,
+			TimeUnixNano: ts,
+			Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+		})
+	case *metricspb.Metric_Histogram:
+		h := m.GetHistogram()
+		sum := h.GetSampleSum()
+		dp := &metricspb.HistogramDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: start,
+			TimeUnixNano:      ts,
+			Count:             h.GetSampleCount(),
+			Sum:               &sum,
+		}
+		// prometheus buckets are cumulative, otlp buckets are not
+		var last uint64
+		for _, b := range h.GetBucket() {
+			if math.IsInf(b.GetUpperBound(), 1) {
+				continue
+			}
+			dp.ExplicitBounds = append(dp.ExplicitBounds, b.GetUpperBound())
+			dp.BucketCounts = append(dp.BucketCounts, b.GetCumulativeCount()-last)
+			last = b.GetCumulativeCount()
+		}
+		dp.BucketCounts = append(dp.BucketCounts, h.GetSampleCount()-last)
+		data.Histogram.DataPoints = append(data.Histogram.DataPoints, dp)
+	case *metricspb.Metric_Summary:
+		s := m.GetSummary()
+		dp := &metricspb.SummaryDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: start,
+			TimeUnixNano:      ts,
+			Count:             s.GetSampleCount(),
+			Sum:               s.GetSampleSum(),
+		}
+		for _, q := range s.GetQuantile() {
+			dp.QuantileValues = append(dp.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+				Quantile: q.GetQuantile(),
+				Value:    q.GetValue(),
+			})
+		}
+		data.Summary.DataPoints = append(data.Summary.DataPoints, dp)
+	}
+}
+
+func stringKeyValue(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   k,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}},
+	}
+}
+
+func newResource(attrs map[string]string) *resourcepb.Resource {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	resource := &resourcepb.Resource{
+		Attributes: []*commonpb.KeyValue{stringKeyValue("service.name", serviceName)},
+	}
+	for _, k := range keys {
+		resource.Attributes = append(resource.Attributes, stringKeyValue(k, attrs[k]))
+	}
+	return resource
+}
+
+func resourceKey(attrs map[string]string) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(attrs[k])
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
@@ -0,0 +1,228 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeCollector is a stand-in of OpenTelemetry collector which records received requests.
+type fakeCollector struct {
+	collectormetrics.UnimplementedMetricsServiceServer
+
+	lock    sync.Mutex
+	metrics []*collectormetrics.ExportMetricsServiceRequest
+	logs    []*collectorlogs.ExportLogsServiceRequest
+}
+
+func (f *fakeCollector) Export(_ context.Context, req *collectormetrics.ExportMetricsServiceRequest) (*collectormetrics.ExportMetricsServiceResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.metrics = append(f.metrics, req)
+	return &collectormetrics.ExportMetricsServiceResponse{}, nil
+}
+
+type fakeLogsService struct {
+	collectorlogs.UnimplementedLogsServiceServer
+	f *fakeCollector
+}
+
+func (s *fakeLogsService) Export(_ context.Context, req *collectorlogs.ExportLogsServiceRequest) (*collectorlogs.ExportLogsServiceResponse, error) {
+	s.f.lock.Lock()
+	defer s.f.lock.Unlock()
+	s.f.logs = append(s.f.logs, req)
+	return &collectorlogs.ExportLogsServiceResponse{}, nil
+}
+
+func (f *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	switch r.URL.Path {
+	case metricsPath:
+		req := &collectormetrics.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.metrics = append(f.metrics, req)
+	case logsPath:
+		req := &collectorlogs.ExportLogsServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.logs = append(f.logs, req)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func startGRPCCollector(t *testing.T) (*fakeCollector, string) {
+	f := &fakeCollector{}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	collectormetrics.RegisterMetricsServiceServer(s, f)
+	collectorlogs.RegisterLogsServiceServer(s, &fakeLogsService{f: f})
+	go s.Serve(lis) // nolint
+	t.Cleanup(s.Stop)
+	return f, lis.Addr().String()
+}
+
+func testRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "kubeskoop_test_total", Help: "test counter"},
+		[]string{"k8s_node", "k8s_namespace", "k8s_pod", "device"})
+	counter.WithLabelValues("node1", "default", "pod1", "eth0").Add(3)
+	counter.WithLabelValues("node1", "default", "pod2", "eth0").Add(5)
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "kubeskoop_test_latency", Help: "test histogram", Buckets: []float64{1, 10}},
+		[]string{"k8s_node", "k8s_namespace", "k8s_pod"})
+	histogram.WithLabelValues("node1", "default", "pod1").Observe(0.5)
+	histogram.WithLabelValues("node1", "default", "pod1").Observe(5)
+	histogram.WithLabelValues("node1", "default", "pod1").Observe(50)
+	r.MustRegister(counter, histogram)
+	return r
+}
+
+func resourceAttr(attrs []*commonpb.KeyValue, key string) string {
+	for _, kv := range attrs {
+		if kv.GetKey() == key {
+			return kv.GetValue().GetStringValue()
+		}
+	}
+	return ""
+}
+
+func TestConvertMetricFamilies(t *testing.T) {
+	families, err := testRegistry().Gather()
+	assert.NoError(t, err)
+
+	rms := ConvertMetricFamilies(families, "node1", time.Now(), time.Now())
+	assert.Len(t, rms, 2)
+
+	pods := map[string]int{}
+	for _, rm := range rms {
+		pod := resourceAttr(rm.GetResource().GetAttributes(), "k8s.pod.name")
+		assert.Equal(t, "default", resourceAttr(rm.GetResource().GetAttributes(), "k8s.namespace.name"))
+		pods[pod] = len(rm.GetScopeMetrics()[0].GetMetrics())
+
+		for _, m := range rm.GetScopeMetrics()[0].GetMetrics() {
+			switch m.GetName() {
+			case "kubeskoop_test_total":
+				sum := m.GetSum()
+				assert.True(t, sum.GetIsMonotonic())
+				assert.Len(t, sum.GetDataPoints(), 1)
+				assert.Equal(t, "eth0", resourceAttr(sum.GetDataPoints()[0].GetAttributes(), "device"))
+			case "kubeskoop_test_latency":
+				dp := m.GetHistogram().GetDataPoints()[0]
+				assert.Equal(t, []float64{1, 10}, dp.GetExplicitBounds())
+				assert.Equal(t, []uint64{1, 1, 1}, dp.GetBucketCounts())
+				assert.Equal(t, uint64(3), dp.GetCount())
+			}
+		}
+	}
+	assert.Equal(t, map[string]int{"pod1": 2, "pod2": 1}, pods)
+}
+
+func TestExportGRPC(t *testing.T) {
+	f, addr := startGRPCCollector(t)
+
+	e, err := NewMetricsExporter(&Config{Endpoint: addr, Insecure: true}, testRegistry(), "node1")
+	assert.NoError(t, err)
+	assert.NoError(t, e.Export(context.Background()))
+	assert.NoError(t, e.Stop())
+
+	le, err := NewLogsExporter(&Config{Endpoint: addr, Insecure: true}, "node1")
+	assert.NoError(t, err)
+	le.Send(&probe.Event{
+		Timestamp: time.Now().UnixNano(),
+		Type:      "TCPRESET_NOSOCK",
+		Labels:    []probe.Label{{Name: "pod", Value: "pod1"}, {Name: "namespace", Value: "default"}, {Name: "node", Value: "node1"}},
+		Message:   "protocol=TCP saddr=10.0.0.1",
+	})
+	assert.NoError(t, le.Close())
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	assert.Len(t, f.metrics, 1)
+	assert.Len(t, f.logs, 1)
+
+	rl := f.logs[0].GetResourceLogs()[0]
+	assert.Equal(t, "pod1", resourceAttr(rl.GetResource().GetAttributes(), "k8s.pod.name"))
+	record := rl.GetScopeLogs()[0].GetLogRecords()[0]
+	assert.Equal(t, "TCPRESET_NOSOCK", resourceAttr(record.GetAttributes(), eventTypeAttribute))
+	assert.Equal(t, "protocol=TCP saddr=10.0.0.1", record.GetBody().GetStringValue())
+}
+
+func TestExportHTTP(t *testing.T) {
+	f := &fakeCollector{}
+	s := httptest.NewServer(f)
+	defer s.Close()
+
+	c, err := NewClient(&Config{Endpoint: s.URL, Protocol: ProtocolHTTP})
+	assert.NoError(t, err)
+	families, _ := testRegistry().Gather()
+	assert.NoError(t, c.ExportMetrics(context.Background(), ConvertMetricFamilies(families, "node1", time.Now(), time.Now())))
+	assert.NoError(t, c.ExportLogs(context.Background(), ConvertEvents([]*probe.Event{{Type: "PacketLoss"}}, "node1")))
+
+	assert.Len(t, f.metrics, 1)
+	assert.Len(t, f.metrics[0].GetResourceMetrics(), 2)
+	assert.Len(t, f.logs, 1)
+}
+
+func TestConfigDefaults(t *testing.T) {
+	_, err := NewClient(&Config{})
+	assert.Error(t, err)
+	_, err = NewClient(&Config{Endpoint: "localhost:4317", Protocol: "udp"})
+	assert.Error(t, err)
+}
+
+func TestConvertEventAttributes(t *testing.T) {
+	evt := &probe.Event{
+		Type:    "TCPRESET_RECEIVE",
+		Labels:  []probe.Label{{Name: "pod", Value: "p1"}, {Name: "src_type", Value: "pod"}},
+		Message: "reset",
+		Version: probe.EventSchemaVersion,
+		Tuple:   &probe.EventTuple{Protocol: "TCP", Src: "10.0.0.1", Dst: "10.0.0.2", Sport: 80, Dport: 12345},
+		Process: &probe.EventProcess{Pid: 42},
+		Stack:   []string{"tcp_reset"},
+	}
+	logs := ConvertEvents([]*probe.Event{evt}, "node1")
+	assert.Len(t, logs, 1)
+	record := logs[0].GetScopeLogs()[0].GetLogRecords()[0]
+	assert.Equal(t, "reset", record.GetBody().GetStringValue())
+
+	attrs := map[string]*commonpb.AnyValue{}
+	for _, kv := range record.GetAttributes() {
+		attrs[kv.GetKey()] = kv.GetValue()
+	}
+	assert.Equal(t, "TCPRESET_RECEIVE", attrs["event.name"].GetStringValue())
+	assert.Equal(t, int64(1), attrs["kubeskoop.event.version"].GetIntValue())
+	assert.Equal(t, "tcp", attrs["network.transport"].GetStringValue())
+	assert.Equal(t, "10.0.0.1", attrs["source.address"].GetStringValue())
+	assert.Equal(t, int64(12345), attrs["destination.port"].GetIntValue())
+	assert.Equal(t, int64(42), attrs["process.pid"].GetIntValue())
+	assert.Len(t, attrs["kubeskoop.stack"].GetArrayValue().GetValues(), 1)
+	assert.Equal(t, "pod", attrs["src_type"].GetStringValue())
+	assert.NotContains(t, attrs, "process.executable.name")
+
+	legacy := ConvertEvents([]*probe.Event{{Type: "PacketLoss"}}, "node1")
+	assert.Len(t, legacy[0].GetScopeLogs()[0].GetLogRecords()[0].GetAttributes(), 1)
+}
@@ -0,0 +1,20 @@
+package all
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratedArgsSchemas(t *testing.T) {
+	generated, err := probe.GeneratedArgsSchemas()
+	require.NoError(t, err)
+	expect, err := json.Marshal(probe.RegisteredArgsSchemas())
+	require.NoError(t, err)
+	actual, err := json.Marshal(generated)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expect), string(actual), "schemas.json is outdated, run go generate ./pkg/exporter/probe")
+}
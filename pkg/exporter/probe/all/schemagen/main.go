@@ -0,0 +1,26 @@
+// Command schemagen writes args schemas of all probes, which are embedded in the probe
+// package to validate args without linking probes.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	_ "github.com/alibaba/kubeskoop/pkg/exporter/probe/all"
+)
+
+func main() {
+	output := flag.String("o", "schemas.json", "output file")
+	flag.Parse()
+
+	data, err := json.MarshalIndent(probe.RegisteredArgsSchemas(), "", "  ")
+	if err != nil {
+		log.Fatalf("failed marshal schemas: %v", err)
+	}
+	if err := os.WriteFile(*output, append(data, '\n'), 0644); err != nil {
+		log.Fatalf("failed write %s: %v", *output, err)
+	}
+}
@@ -0,0 +1,2 @@
+//go:generate protoc --go_out=. --go_opt=paths=source_relative event.proto
+package eventpb
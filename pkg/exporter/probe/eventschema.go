@@ -0,0 +1,105 @@
+package probe
+
+import (
+	"github.com/alibaba/kubeskoop/pkg/exporter/bpfutil"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe/eventpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// EventSchemaVersion is the version of structured fields of Event. Fields are only added
+// in a version, the version is increased when meaning of existing fields changes.
+const EventSchemaVersion = 1
+
+// NewEventTuple converts the tuple of a probe to the tuple of an event.
+func NewEventTuple(t *Tuple) *EventTuple {
+	return &EventTuple{
+		Protocol: bpfutil.GetProtoStr(t.Protocol),
+		Src:      t.Src,
+		Dst:      t.Dst,
+		Sport:    t.Sport,
+		Dport:    t.Dport,
+	}
+}
+
+// ToProto converts the event to its protobuf message.
+func (e *Event) ToProto() *eventpb.Event {
+	ret := &eventpb.Event{
+		Version:         e.Version,
+		Type:            string(e.Type),
+		Timestamp:       e.Timestamp,
+		KernelTimestamp: e.KernelTimestamp,
+		Message:         e.Message,
+		Netns:           e.Netns,
+		LatencyNs:       e.LatencyNs,
+		Stack:           e.Stack,
+		Count:           e.Count,
+		FirstSeen:       e.FirstSeen,
+		LastSeen:        e.LastSeen,
+	}
+	for _, l := range e.Labels {
+		ret.Labels = append(ret.Labels, &eventpb.Label{Name: l.Name, Value: l.Value})
+	}
+	if e.Tuple != nil {
+		ret.Tuple = &eventpb.Tuple{
+			Protocol: e.Tuple.Protocol,
+			Src:      e.Tuple.Src,
+			Dst:      e.Tuple.Dst,
+			Sport:    uint32(e.Tuple.Sport),
+			Dport:    uint32(e.Tuple.Dport),
+		}
+	}
+	if e.Process != nil {
+		ret.Process = &eventpb.Process{
+			Pid:       e.Process.Pid,
+			Comm:      e.Process.Comm,
+			CgroupId:  e.Process.CgroupID,
+			Container: e.Process.Container,
+			Image:     e.Process.Image,
+		}
+	}
+	return ret
+}
+
+// EventFromProto converts the protobuf message back to an event.
+func EventFromProto(pb *eventpb.Event) *Event {
+	ret := &Event{
+		Version:         pb.GetVersion(),
+		Type:            EventType(pb.GetType()),
+		Timestamp:       pb.GetTimestamp(),
+		KernelTimestamp: pb.GetKernelTimestamp(),
+		Message:         pb.GetMessage(),
+		Netns:           pb.GetNetns(),
+		LatencyNs:       pb.GetLatencyNs(),
+		Stack:           pb.GetStack(),
+		Count:           pb.GetCount(),
+		FirstSeen:       pb.GetFirstSeen(),
+		LastSeen:        pb.GetLastSeen(),
+	}
+	for _, l := range pb.GetLabels() {
+		ret.Labels = append(ret.Labels, Label{Name: l.GetName(), Value: l.GetValue()})
+	}
+	if t := pb.GetTuple(); t != nil {
+		ret.Tuple = &EventTuple{
+			Protocol: t.GetProtocol(),
+			Src:      t.GetSrc(),
+			Dst:      t.GetDst(),
+			Sport:    uint16(t.GetSport()),
+			Dport:    uint16(t.GetDport()),
+		}
+	}
+	if p := pb.GetProcess(); p != nil {
+		ret.Process = &EventProcess{
+			Pid:       p.GetPid(),
+			Comm:      p.GetComm(),
+			CgroupID:  p.GetCgroupId(),
+			Container: p.GetContainer(),
+			Image:     p.GetImage(),
+		}
+	}
+	return ret
+}
+
+// MarshalProto encodes the event in protobuf.
+func (e *Event) MarshalProto() ([]byte, error) {
+	return proto.Marshal(e.ToProto())
+}
@@ -0,0 +1,60 @@
+package probe
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe/eventpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEventProtoRoundTrip(t *testing.T) {
+	evt := &Event{
+		Timestamp:       1700000000000000000,
+		Type:            "TCPRetrans",
+		Labels:          []Label{{Name: "pod", Value: "p1"}, {Name: "namespace", Value: "ns1"}},
+		Message:         "retrans",
+		Version:         EventSchemaVersion,
+		KernelTimestamp: 123456,
+		Tuple:           NewEventTuple(&Tuple{Protocol: 6, Src: "fd00::1", Dst: "10.0.0.2", Sport: 80, Dport: 12345}),
+		Process:         &EventProcess{Pid: 42, Comm: "nginx", CgroupID: 1234, Container: "web", Image: "nginx:1.25"},
+		Netns:           4026531992,
+		LatencyNs:       1000,
+		Stack:           []string{"tcp_retransmit_skb", "tcp_write_timer"},
+		Count:           3,
+		FirstSeen:       1699999999000000000,
+		LastSeen:        1700000000000000000,
+	}
+	assert.Equal(t, "TCP", evt.Tuple.Protocol)
+
+	data, err := evt.MarshalProto()
+	assert.NoError(t, err)
+	pb := &eventpb.Event{}
+	assert.NoError(t, proto.Unmarshal(data, pb))
+	assert.Equal(t, evt, EventFromProto(pb))
+}
+
+func TestEventJSON(t *testing.T) {
+	legacy := &Event{Timestamp: 1, Type: "PacketLoss", Message: "msg"}
+	data, err := json.Marshal(legacy)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"timestamp":1,"type":"PacketLoss","labels":null,"msg":"msg"}`, string(data))
+
+	evt := &Event{
+		Timestamp: 1,
+		Type:      "BIOLAT_10MS",
+		Message:   "msg",
+		Version:   EventSchemaVersion,
+		Process:   &EventProcess{Pid: 1, Comm: "dd"},
+		LatencyNs: 20000000,
+	}
+	data, err = json.Marshal(evt)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"timestamp":1,"type":"BIOLAT_10MS","labels":null,"msg":"msg","version":1,
+		"process":{"pid":1,"comm":"dd"},"latencyNs":20000000}`, string(data))
+
+	decoded := &Event{}
+	assert.NoError(t, json.Unmarshal(data, decoded))
+	assert.Equal(t, evt, decoded)
+}
@@ -33,6 +33,8 @@ const (
 
 	ClsactQdisc = "clsact"
 
+	filterNamePrefix = "kubeskoop-flow-"
+
 	featureSwitchEnableFlowPort = 0
 )
 
@@ -42,11 +44,13 @@ var (
 
 func init() {
 	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
+	bpfutil.RegisterBPFObject(probeName, loadBpf)
+	bpfutil.RegisterUnpinHook(probeName, detachAll)
 }
 
 type flowArgs struct {
-	Dev               string `mapstructure:"interfaceName"`
-	EnablePortInLabel bool   `mapstructure:"enablePortInLabel"`
+	Dev               string `mapstructure:"interfaceName" description:"interface to capture flows on, default route interface if empty"`
+	EnablePortInLabel bool   `mapstructure:"enablePortInLabel" description:"add source and destination ports to metric labels"`
 }
 
 func getDefaultRouteDevice() (netlink.Link, error) {
@@ -75,7 +79,8 @@ func getDefaultRouteDevice() (netlink.Link, error) {
 
 type linkFlowHelper interface {
 	start() error
-	stop() error
+	// stop stops the helper, tc filters are kept attached if detach is false.
+	stop(detach bool) error
 }
 
 type dynamicLinkFlowHelper struct {
@@ -112,7 +117,7 @@ func (h *dynamicLinkFlowHelper) tryStopLinkFlow(name string, index int) {
 		log.Warnf("deleted interface index %d not exists, skip process", index)
 		return
 	}
-	_ = flow.stop()
+	_ = flow.stop(true)
 	delete(h.flows, index)
 }
 
@@ -163,18 +168,20 @@ func (h *dynamicLinkFlowHelper) start() error {
 	return nil
 }
 
-func (h *dynamicLinkFlowHelper) stop() error {
+func (h *dynamicLinkFlowHelper) stop(detach bool) error {
 	close(h.done)
 	h.lock.Lock()
 	defer h.lock.Unlock()
 	var first error
 	for _, flow := range h.flows {
-		if err := flow.stop(); err != nil {
+		if err := flow.stop(detach); err != nil {
 			if first == nil {
 				first = err
 			}
 		}
 	}
+	// links are attached again on the next start
+	h.flows = make(map[int]*ebpfFlow)
 	return first
 }
 
@@ -251,8 +258,10 @@ func (p *metricsProbe) Start(_ context.Context) error {
 	return p.helper.start()
 }
 
-func (p *metricsProbe) Stop(_ context.Context) error {
-	if err := p.helper.stop(); err != nil {
+func (p *metricsProbe) Stop(ctx context.Context) error {
+	// with pinned maps, programs are kept attached to count flows until the next start, unless
+	// the probe is suspended for its overhead
+	if err := p.helper.stop(!bpfutil.PinningEnabled() || probe.IsSuspend(ctx)); err != nil {
 		return err
 	}
 	return p.bpfObjs.Close()
@@ -305,14 +314,24 @@ func (p *metricsProbe) loadBPF() error {
 		},
 	}
 
-	if err := loadBpfObjects(&p.bpfObjs, &opts); err != nil {
+	if err := bpfutil.LoadObjects(probeName, &p.bpfObjs, &opts); err != nil {
 		return fmt.Errorf("loading objects: %s", err.Error())
 	}
 
+	// the switch is always set as the map may be pinned with the value of last run
+	var enablePort uint8
 	if p.enablePort {
-		if err := bpfutil.UpdateFeatureSwitch(p.bpfObjs.InspFlowFeatureSwitch, featureSwitchEnableFlowPort, 1); err != nil {
-			return fmt.Errorf("failed set flow feature switch: %w", err)
-		}
+		enablePort = 1
+	}
+	if err := bpfutil.UpdateFeatureSwitch(p.bpfObjs.InspFlowFeatureSwitch, featureSwitchEnableFlowPort, enablePort); err != nil {
+		return fmt.Errorf("failed set flow feature switch: %w", err)
+	}
+
+	if err := bpfutil.PinProgram(probeName, "tc_ingress", p.bpfObjs.TcIngress); err != nil {
+		return err
+	}
+	if err := bpfutil.PinProgram(probeName, "tc_egress", p.bpfObjs.TcEgress); err != nil {
+		return err
 	}
 
 	return nil
@@ -333,8 +352,10 @@ func (f *ebpfFlow) start() error {
 	return err
 }
 
-func (f *ebpfFlow) stop() error {
-	f.cleanup()
+func (f *ebpfFlow) stop(detach bool) error {
+	if detach {
+		f.cleanup()
+	}
 	return nil
 }
 
@@ -358,6 +379,33 @@ func (f *ebpfFlow) cleanup() {
 	}
 }
 
+// detachAll deletes flow filters on all interfaces, including filters kept attached by
+// previous runs with pinned maps.
+func detachAll() error {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return fmt.Errorf("failed list links: %w", err)
+	}
+	for _, link := range links {
+		for _, dir := range []direction{ingress, egress} {
+			filters, err := netlink.FilterList(link, filterParent(dir))
+			if err != nil {
+				return fmt.Errorf("failed list filters of dev %s: %w", link.Attrs().Name, err)
+			}
+			for _, filter := range filters {
+				f, ok := filter.(*netlink.BpfFilter)
+				if !ok || !strings.HasPrefix(f.Name, filterNamePrefix) {
+					continue
+				}
+				if err := netlink.FilterDel(f); err != nil {
+					return fmt.Errorf("failed delete filter %s: %w", f.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func directionName(dir direction) string {
 	switch dir {
 	case ingress:
@@ -380,7 +428,7 @@ func filterParent(dir direction) uint32 {
 
 func filterName(dev string, dir direction) string {
 	directionName := directionName(dir)
-	return fmt.Sprintf("kubeskoop-flow-%s-%s", dev, directionName)
+	return fmt.Sprintf("%s%s-%s", filterNamePrefix, dev, directionName)
 }
 
 func (f *ebpfFlow) getFlowFilter(direction direction) (*netlink.BpfFilter, error) {
@@ -0,0 +1,286 @@
+package probe
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+)
+
+// Additional labels are configured as `name=expression`. An expression is plain text with
+// references in the form of ${source:key | func(arg) | ...}, for example:
+//
+//	app=${labels:app | default("unknown")}
+//	zone=${node:topology.kubernetes.io/zone}
+//	team=${annotations:example.com/owner | regex(`^(\w+)@`) | default("none")}
+//	workload=${owner:kind}/${owner:name}
+//	containers=${containers}
+//
+// Sources:
+//   - labels:<key>       label of the pod
+//   - annotations:<key>  annotation of the pod
+//   - node:<key>         label of the node
+//   - owner[:kind|name]  owner workload of the pod, name by default
+//   - pod:name|namespace name or namespace of the pod
+//   - containers         comma separated container names of the pod
+//
+// Functions are applied in order:
+//   - default(value)  use value if the result is empty
+//   - regex(pattern)  extract the first capture group, or the whole match if the pattern has no group
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// LabelContext is the pod metadata which additional label expressions are evaluated with.
+type LabelContext struct {
+	PodName      string
+	PodNamespace string
+	Labels       map[string]string
+	Annotations  map[string]string
+	NodeLabels   map[string]string
+	OwnerKind    string
+	OwnerName    string
+	Containers   []string
+}
+
+func NewLabelContext(entity *nettop.Entity) *LabelContext {
+	ctx := &LabelContext{
+		PodName:      entity.GetPodName(),
+		PodNamespace: entity.GetPodNamespace(),
+		Labels:       entity.GetLabels(),
+		Annotations:  entity.GetAnnotations(),
+		Containers:   entity.GetContainerNames(),
+	}
+	ctx.OwnerKind, ctx.OwnerName = entity.GetOwner()
+	if additionalLabelsUseNode {
+		ctx.NodeLabels = nettop.GetNodeLabels()
+	}
+	return ctx
+}
+
+type labelValueFunc func(ctx *LabelContext) string
+
+type additionalLabel struct {
+	name    string
+	parts   []labelValueFunc
+	useNode bool
+}
+
+func (l *additionalLabel) value(ctx *LabelContext) string {
+	var sb strings.Builder
+	for _, part := range l.parts {
+		sb.WriteString(part(ctx))
+	}
+	return sb.String()
+}
+
+// parseAdditionalLabels parses and validates `name=expression` entries, reserved lists label names
+// which cannot be used.
+func parseAdditionalLabels(entries []string, reserved []string) ([]*additionalLabel, error) {
+	seen := make(map[string]bool)
+	for _, name := range reserved {
+		seen[name] = true
+	}
+
+	var ret []*additionalLabel
+	for _, entry := range entries {
+		name, expr, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid additional label %q: expected name=expression", entry)
+		}
+		name = strings.TrimSpace(name)
+		expr = strings.TrimSpace(expr)
+		if !labelNameRegexp.MatchString(name) || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid additional label %q: %q is not a valid label name", entry, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("invalid additional label %q: label %q is duplicated or reserved", entry, name)
+		}
+		seen[name] = true
+
+		l := &additionalLabel{name: name}
+		if err := l.parse(expr); err != nil {
+			return nil, fmt.Errorf("invalid additional label %q: %w", entry, err)
+		}
+		ret = append(ret, l)
+	}
+	return ret, nil
+}
+
+func (l *additionalLabel) parse(expr string) error {
+	for len(expr) > 0 {
+		start := strings.Index(expr, "${")
+		if start < 0 {
+			l.parts = append(l.parts, literalValue(expr))
+			break
+		}
+		if start > 0 {
+			l.parts = append(l.parts, literalValue(expr[:start]))
+		}
+
+		body := expr[start+2:]
+		end, err := indexUnquoted(body, '}')
+		if err != nil {
+			return err
+		}
+		if end < 0 {
+			return fmt.Errorf("unterminated reference %q", expr[start:])
+		}
+		ref, err := l.parseReference(body[:end])
+		if err != nil {
+			return fmt.Errorf("reference %q: %w", "${"+body[:end]+"}", err)
+		}
+		l.parts = append(l.parts, ref)
+		expr = body[end+1:]
+	}
+	return nil
+}
+
+func literalValue(s string) labelValueFunc {
+	return func(_ *LabelContext) string { return s }
+}
+
+func (l *additionalLabel) parseReference(ref string) (labelValueFunc, error) {
+	segments, err := splitUnquoted(ref, '|')
+	if err != nil {
+		return nil, err
+	}
+
+	source, key, _ := strings.Cut(segments[0], ":")
+	source, key = strings.TrimSpace(source), strings.TrimSpace(key)
+	getter, err := l.sourceGetter(source, key)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, segment := range segments[1:] {
+		fn, err := parseLabelFunc(strings.TrimSpace(segment))
+		if err != nil {
+			return nil, err
+		}
+		getter = chainLabelFunc(getter, fn)
+	}
+	return getter, nil
+}
+
+func chainLabelFunc(getter labelValueFunc, fn func(string) string) labelValueFunc {
+	return func(ctx *LabelContext) string {
+		return fn(getter(ctx))
+	}
+}
+
+func (l *additionalLabel) sourceGetter(source, key string) (labelValueFunc, error) {
+	requireKey := func() error {
+		if key == "" {
+			return fmt.Errorf("source %q requires a key", source)
+		}
+		return nil
+	}
+
+	switch source {
+	case "labels":
+		return func(ctx *LabelContext) string { return ctx.Labels[key] }, requireKey()
+	case "annotations":
+		return func(ctx *LabelContext) string { return ctx.Annotations[key] }, requireKey()
+	case "node":
+		l.useNode = true
+		return func(ctx *LabelContext) string { return ctx.NodeLabels[key] }, requireKey()
+	case "owner":
+		switch key {
+		case "", "name":
+			return func(ctx *LabelContext) string { return ctx.OwnerName }, nil
+		case "kind":
+			return func(ctx *LabelContext) string { return ctx.OwnerKind }, nil
+		}
+		return nil, fmt.Errorf("unknown key %q of owner, expected kind or name", key)
+	case "pod":
+		switch key {
+		case "name":
+			return func(ctx *LabelContext) string { return ctx.PodName }, nil
+		case "namespace":
+			return func(ctx *LabelContext) string { return ctx.PodNamespace }, nil
+		}
+		return nil, fmt.Errorf("unknown key %q of pod, expected name or namespace", key)
+	case "containers":
+		if key != "" {
+			return nil, fmt.Errorf("source containers does not accept a key")
+		}
+		return func(ctx *LabelContext) string { return strings.Join(ctx.Containers, ",") }, nil
+	}
+	return nil, fmt.Errorf("unknown source %q, expected one of labels, annotations, node, owner, pod, containers", source)
+}
+
+func parseLabelFunc(s string) (func(string) string, error) {
+	name, arg, ok := strings.Cut(s, "(")
+	if !ok || !strings.HasSuffix(arg, ")") {
+		return nil, fmt.Errorf("invalid function %q, expected name(\"argument\")", s)
+	}
+	arg, err := strconv.Unquote(strings.TrimSpace(strings.TrimSuffix(arg, ")")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid argument of function %q, argument must be a quoted string", s)
+	}
+
+	switch strings.TrimSpace(name) {
+	case "default":
+		return func(v string) string {
+			if v == "" {
+				return arg
+			}
+			return v
+		}, nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", arg, err)
+		}
+		return func(v string) string {
+			match := re.FindStringSubmatch(v)
+			switch {
+			case match == nil:
+				return ""
+			case len(match) > 1:
+				return match[1]
+			default:
+				return match[0]
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown function %q, expected default or regex", name)
+}
+
+// indexUnquoted returns the index of the first c outside of quoted strings, -1 if not found.
+func indexUnquoted(s string, c byte) (int, error) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == 0 && s[i] == c:
+			return i, nil
+		case quote == 0 && (s[i] == '"' || s[i] == '`'):
+			quote = s[i]
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		}
+	}
+	if quote != 0 {
+		return -1, fmt.Errorf("unterminated quoted string in %q", s)
+	}
+	return -1, nil
+}
+
+func splitUnquoted(s string, sep byte) ([]string, error) {
+	var ret []string
+	for {
+		idx, err := indexUnquoted(s, sep)
+		if err != nil {
+			return nil, err
+		}
+		if idx < 0 {
+			return append(ret, s), nil
+		}
+		ret = append(ret, s[:idx])
+		s = s[idx+1:]
+	}
+}
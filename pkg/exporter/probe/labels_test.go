@@ -0,0 +1,73 @@
+package probe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdditionalLabelExpressions(t *testing.T) {
+	ctx := &LabelContext{
+		PodName:      "web-7d9c8b-x2x4z",
+		PodNamespace: "default",
+		Labels:       map[string]string{"app": "web", "version": "v1.2.3"},
+		Annotations:  map[string]string{"example.com/owner": "alice@example.com"},
+		NodeLabels:   map[string]string{"topology.kubernetes.io/zone": "zone-a"},
+		OwnerKind:    "Deployment",
+		OwnerName:    "web",
+		Containers:   []string{"app", "sidecar"},
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"${labels:tier | default(\"unknown\")}", "unknown"},
+		{"${labels:app | default(\"unknown\")}", "web"},
+		{"${labels:version | regex(`^v(\\d+)`)}", "1"},
+		{"${labels:version | regex(\"[0-9.]+\")}", "1.2.3"},
+		{"${labels:version | regex(\"^x\") | default(\"none\")}", "none"},
+		{"${annotations:example.com/owner | regex(`^(\\w+)@`)}", "alice"},
+		{"${node:topology.kubernetes.io/zone}", "zone-a"},
+		{"${owner:kind}/${owner}", "Deployment/web"},
+		{"${pod:namespace}/${pod:name}", "default/web-7d9c8b-x2x4z"},
+		{"${containers}", "app,sidecar"},
+		{"${labels:app | regex(\"}|{\")}-x", "-x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			labels, err := parseAdditionalLabels([]string{"l=" + tt.expr}, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, labels[0].value(ctx))
+		})
+	}
+}
+
+func TestAdditionalLabelValidation(t *testing.T) {
+	invalid := []string{
+		"novalue",
+		"1abc=x",
+		"k8s_pod=x",
+		"pod=x",
+		"a=${labels:a",
+		"a=${unknown:a}",
+		"a=${labels}",
+		"a=${owner:uid}",
+		"a=${containers:a}",
+		"a=${labels:a | lower()}",
+		"a=${labels:a | default(x)}",
+		"a=${labels:a | regex(\"(\")}",
+		"a=${labels:a | default(\"x)}",
+	}
+	for _, entry := range invalid {
+		assert.Error(t, ValidateAdditionalLabels([]string{entry}), entry)
+	}
+	assert.Error(t, ValidateAdditionalLabels([]string{"a=x", "a=y"}))
+	assert.NoError(t, ValidateAdditionalLabels([]string{"a=x", "b=${node:zone}"}))
+
+	assert.NoError(t, InitAdditionalLabels([]string{"app=${labels:app}", "zone=${node:zone}"}))
+	assert.Equal(t, []string{"k8s_node", "k8s_namespace", "k8s_pod", "app", "zone"}, StandardMetricsLabels)
+	assert.True(t, additionalLabelsUseNode)
+	assert.NoError(t, InitAdditionalLabels(nil))
+	assert.Equal(t, []string{"k8s_node", "k8s_namespace", "k8s_pod"}, StandardMetricsLabels)
+}
@@ -2,8 +2,7 @@ package probe
 
 import (
 	"fmt"
-	"regexp"
-	"strings"
+	"sync"
 
 	"github.com/alibaba/kubeskoop/pkg/exporter/bpfutil"
 	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
@@ -13,11 +12,21 @@ import (
 
 var StandardMetricsLabels = []string{"k8s_node", "k8s_namespace", "k8s_pod"}
 var TupleMetricsLabels = []string{"protocol", "src", "src_type", "src_node", "src_namespace", "src_pod", "dst", "dst_type", "dst_node", "dst_namespace", "dst_pod", "sport", "dport"}
-var AdditionalLabelValueExpr []string
+
+var (
+	baseMetricsLabels = StandardMetricsLabels
+	// labels of legacy events, additional labels cannot use these names either
+	baseEventLabels         = []string{"pod", "namespace", "node"}
+	additionalLabels        []*additionalLabel
+	additionalLabelsUseNode bool
+)
 
 func BuildStandardMetricsLabelValues(entity *nettop.Entity) []string {
 	metaPodLabels := []string{nettop.GetNodeName(), entity.GetPodNamespace(), entity.GetPodName()}
-	return append(metaPodLabels, BuildAdditionalLabelsValues(entity.GetLabels())...)
+	if len(additionalLabels) == 0 {
+		return metaPodLabels
+	}
+	return append(metaPodLabels, BuildAdditionalLabelsValues(NewLabelContext(entity))...)
 }
 
 type LegacyMetric struct {
@@ -25,55 +34,49 @@ type LegacyMetric struct {
 	Help string
 }
 
-func InitAdditionalLabels(additionalLabels []string) error {
-	if len(additionalLabels) == 0 {
-		return nil
-	}
+// ValidateAdditionalLabels checks syntax of additional label expressions without applying them.
+func ValidateAdditionalLabels(exprs []string) error {
+	_, err := StandardMetricsLabelsOf(exprs)
+	return err
+}
 
-	//append to StandardMetricsLabels and AdditionalLabelValueExpr
-	for additionalKV := range additionalLabels {
-		labelKVPair := strings.Split(additionalLabels[additionalKV], "=")
-		StandardMetricsLabels = append(StandardMetricsLabels, strings.TrimSpace(labelKVPair[0]))
-		AdditionalLabelValueExpr = append(AdditionalLabelValueExpr, strings.TrimSpace(labelKVPair[1]))
+// StandardMetricsLabelsOf returns StandardMetricsLabels as if the additional label expressions
+// were applied, StandardMetricsLabels is not changed.
+func StandardMetricsLabelsOf(exprs []string) ([]string, error) {
+	labels, err := parseAdditionalLabels(exprs, append(baseMetricsLabels, baseEventLabels...))
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	ret := append([]string{}, baseMetricsLabels...)
+	for _, l := range labels {
+		ret = append(ret, l.name)
+	}
+	return ret, nil
 }
 
-func BuildAdditionalLabelsValues(podLabels map[string]string) []string {
-	if len(AdditionalLabelValueExpr) == 0 {
-		return []string{}
+// InitAdditionalLabels parses additional label expressions and appends them to StandardMetricsLabels,
+// it must be called before any metrics probe is created.
+func InitAdditionalLabels(exprs []string) error {
+	labels, err := parseAdditionalLabels(exprs, append(baseMetricsLabels, baseEventLabels...))
+	if err != nil {
+		return err
 	}
 
-	var values []string
-
-	var replaceAllStringSubmatchFunc = func(re *regexp.Regexp, str string, repl func([]string) string) string {
-		result := ""
-		lastIndex := 0
-
-		for _, v := range re.FindAllSubmatchIndex([]byte(str), -1) {
-			var groups []string
-			for i := 0; i < len(v); i += 2 {
-				groups = append(groups, str[v[i]:v[i+1]])
-			}
-
-			result += str[lastIndex:v[0]] + repl(groups)
-			lastIndex = v[1]
-		}
-
-		return result + str[lastIndex:]
+	StandardMetricsLabels = append([]string{}, baseMetricsLabels...)
+	additionalLabelsUseNode = false
+	for _, l := range labels {
+		StandardMetricsLabels = append(StandardMetricsLabels, l.name)
+		additionalLabelsUseNode = additionalLabelsUseNode || l.useNode
 	}
+	additionalLabels = labels
+	return nil
+}
 
-	for _, labelValueExpr := range AdditionalLabelValueExpr {
-		podLabelValue := replaceAllStringSubmatchFunc(regexp.MustCompile(`\${labels:(.*?)}`), labelValueExpr, func(groups []string) string {
-			if podLabelValue, ok := podLabels[groups[1]]; ok {
-				return podLabelValue
-			}
-			return ""
-		})
-		values = append(values, podLabelValue)
+func BuildAdditionalLabelsValues(ctx *LabelContext) []string {
+	values := make([]string, 0, len(additionalLabels))
+	for _, l := range additionalLabels {
+		values = append(values, l.value(ctx))
 	}
-
 	return values
 }
 
@@ -81,6 +84,10 @@ type legacyBatchMetrics struct {
 	module    string
 	collector LegacyCollector
 	descs     map[string]*prometheus.Desc
+
+	// last is the data of the last successful collection, it is served on errors
+	lock sync.Mutex
+	last map[string]map[uint32]uint64
 }
 
 func newMetricsName(module, name string) string {
@@ -115,10 +122,15 @@ func (l *legacyBatchMetrics) Describe(descs chan<- *prometheus.Desc) {
 func (l *legacyBatchMetrics) Collect(metrics chan<- prometheus.Metric) {
 	log.Debugf("collect data from %s", l.module)
 	data, err := l.collector()
+	l.lock.Lock()
 	if err != nil {
-		log.Errorf("%s failed collect data, err: %v", l.module, err)
-		return
+		log.Errorf("%s failed collect data, serve cached data, err: %v", l.module, err)
+		collectErrors.WithLabelValues(l.module).Inc()
+		data = l.last
+	} else {
+		l.last = data
 	}
+	l.lock.Unlock()
 
 	emit := func(name string, labelValues []string, value float64) {
 		desc, ok := l.descs[name]
@@ -147,11 +159,20 @@ func LegacyEventLabels(netns uint32) []Label {
 		log.Infof("nettop get entity failed, netns: %d, err: %v", netns, err)
 		return nil
 	}
-	return []Label{
+	labels := []Label{
 		{Name: "pod", Value: et.GetPodName()},
 		{Name: "namespace", Value: et.GetPodNamespace()},
 		{Name: "node", Value: nettop.GetNodeName()},
 	}
+	if len(additionalLabels) == 0 {
+		return labels
+	}
+
+	ctx := NewLabelContext(et)
+	for _, l := range additionalLabels {
+		labels = append(labels, Label{Name: l.name, Value: l.value(ctx)})
+	}
+	return labels
 }
 
 func BuildTupleMetricsLabels(tuple *Tuple) []string {
@@ -166,8 +187,11 @@ func BuildTupleMetricsLabels(tuple *Tuple) []string {
 			return []string{"node", info.NodeName, "", ""}
 		case nettop.IPTypePod:
 			return []string{"pod", "", info.PodNamespace, info.PodName}
+		case nettop.IPTypeService:
+			// namespace and pod labels are of pods
+			return []string{"service", "", "", ""}
 		default:
-			log.Warningf("unknown ip type %s for %s", ip, info.Type)
+			log.Warningf("unknown ip type %s for %s", info.Type, ip)
 		}
 		return []string{"unknown", "", "", ""}
 	}
@@ -208,10 +232,13 @@ func BuildTupleEventLabels(tuple *Tuple) []Label {
 			values = [...]string{"node", info.NodeName, "", ""}
 		case nettop.IPTypePod:
 			values = [...]string{"pod", "", info.PodNamespace, info.PodName}
+		case nettop.IPTypeService:
+			// namespace and pod labels are of pods
+			values = [...]string{"service", "", "", ""}
 		default:
-			log.Warningf("unknown ip type %s for %s", ip, info.Type)
+			log.Warningf("unknown ip type %s for %s", info.Type, ip)
+			values = [...]string{"unknown", "", "", ""}
 		}
-		values = [...]string{"unknown", "", "", ""}
 		return
 	}
 
@@ -23,14 +23,13 @@ func TestBuildAdditionalLabelsValues1(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			AdditionalLabelValueExpr = []string{}
 			err := InitAdditionalLabels(tt.args.additionalLabels)
 			if err != nil {
 				t.Error(err)
 				return
 			}
 
-			if got := BuildAdditionalLabelsValues(tt.args.podLabels); !reflect.DeepEqual(got, tt.want) {
+			if got := BuildAdditionalLabelsValues(&LabelContext{Labels: tt.args.podLabels}); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("BuildAdditionalLabelsValues() = %v, want %v", got, tt.want)
 			}
 		})
@@ -167,6 +167,7 @@ type metricsInfo struct {
 
 type BatchMetrics struct {
 	name           string
+	probe          string
 	infoMap        map[string]*metricsInfo
 	ProbeCollector Collector
 }
@@ -190,6 +191,7 @@ func NewBatchMetrics(opts BatchMetricsOpts, probeCollector Collector) *BatchMetr
 
 	return &BatchMetrics{
 		name:           fmt.Sprintf("%s_%s", opts.Namespace, opts.Subsystem),
+		probe:          opts.Subsystem,
 		infoMap:        m,
 		ProbeCollector: probeCollector,
 	}
@@ -216,13 +218,33 @@ func (b *BatchMetrics) Collect(metrics chan<- prometheus.Metric) {
 		metrics <- m
 	}
 
-	err := b.ProbeCollector(emit)
-	if err != nil {
+	// metrics emitted before the error are still served
+	if err := b.ProbeCollector(emit); err != nil {
 		log.Errorf("%s error collect, err: %v", b.name, err)
-		return
+		collectErrors.WithLabelValues(b.probe).Inc()
 	}
 }
 
+// collectErrors counts collection errors of metrics probes. Errors are not reported by invalid
+// metrics, which fail the whole scrape.
+var collectErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: MetricsNamespace,
+	Subsystem: "exporter",
+	Name:      "probe_collect_errors_total",
+	Help:      "Total number of collection errors of metrics probes, partial or cached metrics are served on errors.",
+}, []string{"probe"})
+
+// AddCollectErrors counts n collection errors of probe, it is for probes collecting metrics by
+// themselves rather than by BatchMetrics.
+func AddCollectErrors(probe string, n int) {
+	collectErrors.WithLabelValues(probe).Add(float64(n))
+}
+
+// CollectErrors returns the counter of collection errors, it is registered along with metrics probes.
+func CollectErrors() prometheus.Collector {
+	return collectErrors
+}
+
 func mergeLabels(opts BatchMetricsOpts, metrics SingleMetricsOpts) (map[string]string, []string) {
 	constLabels := mergeMap(opts.ConstLabels, metrics.ConstLabels)
 	variableLabels := mergeArray(opts.VariableLabels, metrics.VariableLabels)
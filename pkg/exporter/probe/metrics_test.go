@@ -0,0 +1,54 @@
+package probe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchMetricsCollectError(t *testing.T) {
+	m := NewBatchMetrics(BatchMetricsOpts{
+		Namespace: MetricsNamespace,
+		Subsystem: "errtest",
+		SingleMetricsOpts: []SingleMetricsOpts{
+			{Name: "a", ValueType: prometheus.GaugeValue},
+			{Name: "b", ValueType: prometheus.GaugeValue},
+		},
+	}, func(emit Emit) error {
+		emit("a", nil, 1)
+		return errors.New("failed")
+	})
+
+	r := prometheus.NewRegistry()
+	r.MustRegister(m)
+	families, err := r.Gather()
+	// partial metrics are served without failing the gather
+	assert.NoError(t, err)
+	assert.Len(t, families, 1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(collectErrors.WithLabelValues("errtest")))
+}
+
+func TestLegacyBatchMetricsCollectError(t *testing.T) {
+	fail := false
+	m := newLegacyBatchMetrics("legacyerrtest", []LegacyMetric{{Name: "a"}}, func() (map[string]map[uint32]uint64, error) {
+		if fail {
+			return nil, errors.New("failed")
+		}
+		return map[string]map[uint32]uint64{"a": {1: 10}}, nil
+	}).(*legacyBatchMetrics)
+
+	r := prometheus.NewRegistry()
+	r.MustRegister(m)
+	_, err := r.Gather()
+	assert.NoError(t, err)
+
+	// the data of the last collection is served on errors
+	fail = true
+	_, err = r.Gather()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[uint32]uint64{"a": {1: 10}}, m.last)
+	assert.Equal(t, 1.0, testutil.ToFloat64(collectErrors.WithLabelValues("legacyerrtest")))
+}
@@ -0,0 +1,112 @@
+package netfilter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/stretchr/testify/assert"
+)
+
+const iptablesSave = `# Generated by iptables-nft-save v1.8.10 (nf_tables) on Mon Jan  1 00:00:00 2024
+*filter
+:INPUT ACCEPT [1024:65536]
+:KUBE-SERVICES - [0:0]
+[10:600] -A INPUT -j KUBE-SERVICES
+[3:180] -A KUBE-SERVICES -d 10.96.0.10/32 -p tcp -m comment --comment "kube-system/kube-dns:dns-tcp has no endpoints" -j REJECT
+[2:120] -A KUBE-SERVICES -d 10.96.0.10/32 -p tcp -m comment --comment "kube-system/kube-dns:dns-tcp has no endpoints" -j REJECT
+COMMIT
+# Completed on Mon Jan  1 00:00:00 2024
+`
+
+func TestParseIPTablesSave(t *testing.T) {
+	lines, rules, err := parseIPTablesSave("iptables-nft", iptablesSave)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"*filter",
+		":INPUT ACCEPT",
+		":KUBE-SERVICES -",
+		"-A INPUT -j KUBE-SERVICES",
+		`-A KUBE-SERVICES -d 10.96.0.10/32 -p tcp -m comment --comment "kube-system/kube-dns:dns-tcp has no endpoints" -j REJECT`,
+		`-A KUBE-SERVICES -d 10.96.0.10/32 -p tcp -m comment --comment "kube-system/kube-dns:dns-tcp has no endpoints" -j REJECT`,
+		"COMMIT",
+	}, lines)
+	assert.Len(t, rules, 3)
+	assert.Equal(t, &rule{backend: "iptables-nft", table: "filter", chain: "INPUT", spec: "-j KUBE-SERVICES", packets: 10, bytes: 600}, rules[0])
+
+	_, _, err = parseIPTablesSave("iptables-nft", "*filter\n[1:x] -A INPUT -j ACCEPT\n")
+	assert.Error(t, err)
+
+	// counters do not change the hash
+	lines2, _, err := parseIPTablesSave("iptables-nft", strings.NewReplacer("[1024:65536]", "[0:0]", "[10:600]", "[0:0]",
+		"[3:180]", "[0:0]", "[2:120]", "[0:0]").Replace(iptablesSave))
+	assert.NoError(t, err)
+	assert.Equal(t, newSection("iptables-nft", lines).hash, newSection("iptables-nft", lines2).hash)
+}
+
+func TestDiffLines(t *testing.T) {
+	added, removed := diffLines([]string{"a", "b", "b", "c"}, []string{"b", "c", "d", "c"})
+	assert.Equal(t, []string{"d", "c"}, added)
+	assert.Equal(t, []string{"a", "b"}, removed)
+}
+
+func TestCompareSnapshots(t *testing.T) {
+	p := &netfilterEventProbe{args: eventArgs{MaxDiffLines: 1}, snapshots: make(map[int]*snapshot)}
+	s1 := &snapshot{sections: []*section{
+		newSection("iptables-nft", []string{"*filter", "-A INPUT -j ACCEPT", "COMMIT"}),
+		newSection(sectionIPSet, []string{"create KUBE-CLUSTER-IP hash:ip,port"}),
+	}}
+	assert.Empty(t, p.compare(1, s1))
+	assert.Empty(t, p.compare(1, s1))
+
+	s2 := &snapshot{sections: []*section{
+		newSection("iptables-nft", []string{"*filter", "-A INPUT -j DROP", "COMMIT"}),
+	}}
+	evts := p.compare(1, s2)
+	assert.Len(t, evts, 2)
+	assert.Equal(t, NetfilterRuleChange, evts[0].Type)
+	assert.Contains(t, evts[0].Labels, probe.Label{Name: "section", Value: "iptables-nft"})
+	assert.Contains(t, evts[0].Labels, probe.Label{Name: "added", Value: "1"})
+	assert.Contains(t, evts[0].Labels, probe.Label{Name: "removed", Value: "1"})
+	assert.Equal(t, "iptables-nft changed, 1 added, 1 removed\n+ -A INPUT -j DROP\n... 1 more", evts[0].Message)
+	assert.Contains(t, evts[1].Labels, probe.Label{Name: "section", Value: sectionIPSet})
+	assert.Contains(t, evts[1].Labels, probe.Label{Name: "hash", Value: ""})
+}
+
+func TestSumRules(t *testing.T) {
+	_, rules, err := parseIPTablesSave("iptables-nft", iptablesSave)
+	assert.NoError(t, err)
+
+	p := &netfilterMetricsProbe{chains: []string{"KUBE-*"}}
+	ret := p.sumRules(rules)
+	assert.Len(t, ret, 1)
+	for key, c := range ret {
+		assert.Equal(t, "KUBE-SERVICES", key.chain)
+		assert.Equal(t, &ruleCounter{packets: 5, bytes: 300}, c)
+	}
+}
+
+func TestSavedRulesCache(t *testing.T) {
+	installed := map[string]bool{}
+	commandExists = func(name string) bool { return installed[name] }
+	defer func() { commandExists = defaultCommandExists }()
+
+	now := time.Now()
+	cached := []*rule{{backend: "iptables-nft", table: "filter", chain: "INPUT"}}
+	p := &netfilterMetricsProbe{interval: time.Minute, cache: map[int]*cachedRules{1: {time: now, rules: cached}}}
+	rules, err := p.savedRules(1, "/proc/0/ns/net", now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, cached, rules)
+
+	// no backend is installed
+	rules, err = p.savedRules(1, "/proc/0/ns/net", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+
+	// failures of installed backends are not taken as missing backends
+	installed["iptables-nft-save"] = true
+	_, err = p.savedRules(1, "/proc/0/ns/net", now.Add(2*time.Minute))
+	assert.Error(t, err)
+	assert.NotContains(t, p.cache, 1)
+}
@@ -0,0 +1,188 @@
+package netfilter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	NetfilterRuleChange probe.EventType = "NetfilterRuleChange"
+
+	defaultInterval     = 60
+	defaultMaxDiffLines = 20
+)
+
+var probeName = "netfilter"
+
+func init() {
+	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+}
+
+type eventArgs struct {
+	Interval     int  `mapstructure:"interval" description:"seconds between snapshots of netfilter rules, default 60"`
+	IPVS         bool `mapstructure:"ipvs" description:"also audit ipvs services and real servers"`
+	MaxDiffLines int  `mapstructure:"maxDiffLines" description:"max added and removed lines in the message of an event, default 20"`
+
+	nettop.NamespaceArgs `mapstructure:",squash"`
+}
+
+func eventProbeCreator(sink chan<- *probe.Event, args eventArgs) (probe.EventProbe, error) {
+	if args.Interval < 0 || args.MaxDiffLines < 0 {
+		return nil, fmt.Errorf("interval and maxDiffLines of %s should not be negative", probeName)
+	}
+	if args.Interval == 0 {
+		args.Interval = defaultInterval
+	}
+	if args.MaxDiffLines == 0 {
+		args.MaxDiffLines = defaultMaxDiffLines
+	}
+	p := &netfilterEventProbe{
+		sink:      sink,
+		args:      args,
+		entities:  args.Filter(),
+		snapshots: make(map[int]*snapshot),
+	}
+	return probe.NewEventProbe(probeName, p), nil
+}
+
+type netfilterEventProbe struct {
+	sink     chan<- *probe.Event
+	args     eventArgs
+	entities *nettop.EntityFilter
+	// last snapshots by netns
+	snapshots map[int]*snapshot
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func (p *netfilterEventProbe) Start(_ context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(time.Duration(p.args.Interval) * time.Second)
+		defer ticker.Stop()
+		p.audit()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.audit()
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *netfilterEventProbe) Stop(_ context.Context) error {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+	return nil
+}
+
+func (p *netfilterEventProbe) audit() {
+	seen := make(map[int]bool)
+	for _, et := range nettop.GetAllUniqueNetnsEntity() {
+		if !p.entities.Match(et) {
+			continue
+		}
+		nsinum := et.GetNetns()
+		// keep the last snapshot on errors, a partial snapshot looks like rules are removed
+		seen[nsinum] = true
+		s, err := takeSnapshot(et, p.args.IPVS)
+		if err != nil {
+			log.Debugf("%s: failed snapshot netns %d: %v", probeName, nsinum, err)
+			continue
+		}
+		for _, evt := range p.compare(nsinum, s) {
+			p.sink <- evt
+		}
+	}
+	for nsinum := range p.snapshots {
+		if !seen[nsinum] {
+			delete(p.snapshots, nsinum)
+		}
+	}
+}
+
+// compare returns events of sections changed since the last snapshot of the netns, nothing
+// is reported at the first snapshot.
+func (p *netfilterEventProbe) compare(nsinum int, s *snapshot) []*probe.Event {
+	last, ok := p.snapshots[nsinum]
+	p.snapshots[nsinum] = s
+	if !ok {
+		return nil
+	}
+
+	var ret []*probe.Event
+	for _, cur := range s.sections {
+		var oldLines []string
+		oldHash := ""
+		if prev := last.section(cur.name); prev != nil {
+			if prev.hash == cur.hash {
+				continue
+			}
+			oldLines, oldHash = prev.lines, prev.hash
+		}
+		added, removed := diffLines(oldLines, cur.lines)
+		ret = append(ret, p.newChangeEvent(nsinum, cur.name, oldHash, cur.hash, added, removed))
+	}
+	for _, prev := range last.sections {
+		// the backend is flushed or its module is unloaded
+		if s.section(prev.name) == nil {
+			ret = append(ret, p.newChangeEvent(nsinum, prev.name, prev.hash, "", nil, prev.lines))
+		}
+	}
+	return ret
+}
+
+func (p *netfilterEventProbe) newChangeEvent(nsinum int, name, oldHash, newHash string, added, removed []string) *probe.Event {
+	labels := probe.LegacyEventLabels(uint32(nsinum))
+	labels = append(labels,
+		probe.Label{Name: "section", Value: name},
+		probe.Label{Name: "added", Value: strconv.Itoa(len(added))},
+		probe.Label{Name: "removed", Value: strconv.Itoa(len(removed))},
+		probe.Label{Name: "old_hash", Value: oldHash},
+		probe.Label{Name: "hash", Value: newHash},
+	)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s changed, %d added, %d removed", name, len(added), len(removed))
+	n := 0
+	for _, diff := range []struct {
+		prefix string
+		lines  []string
+	}{{"+", added}, {"-", removed}} {
+		for _, l := range diff.lines {
+			if n >= p.args.MaxDiffLines {
+				break
+			}
+			fmt.Fprintf(&sb, "\n%s %s", diff.prefix, l)
+			n++
+		}
+	}
+	if total := len(added) + len(removed); total > n {
+		fmt.Fprintf(&sb, "\n... %d more", total-n)
+	}
+
+	return &probe.Event{
+		Timestamp: time.Now().UnixNano(),
+		Type:      NetfilterRuleChange,
+		Labels:    labels,
+		Message:   sb.String(),
+		Version:   probe.EventSchemaVersion,
+		Netns:     uint32(nsinum),
+	}
+}
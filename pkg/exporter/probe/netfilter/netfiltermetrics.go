@@ -0,0 +1,185 @@
+package netfilter
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	RulePackets = "rulepackets"
+	RuleBytes   = "rulebytes"
+
+	ruleLabels    = []string{"backend", "table", "chain", "rule"}
+	defaultChains = []string{"KUBE-SERVICES", "KUBE-FORWARD"}
+)
+
+const defaultCacheInterval = 30
+
+func init() {
+	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
+}
+
+type metricsArgs struct {
+	Chains   []string `mapstructure:"chains" description:"chains to export counters of their rules, glob patterns like cali-pi-* are supported, default KUBE-SERVICES and KUBE-FORWARD"`
+	Interval int      `mapstructure:"interval" description:"seconds to reuse rule counters between scrapes, iptables-save is forked for every backend and netns, default 30"`
+
+	nettop.NamespaceArgs `mapstructure:",squash"`
+}
+
+func metricsProbeCreator(args metricsArgs) (probe.MetricsProbe, error) {
+	if len(args.Chains) == 0 {
+		args.Chains = defaultChains
+	}
+	for _, c := range args.Chains {
+		if _, err := path.Match(c, ""); err != nil {
+			return nil, fmt.Errorf("invalid chain pattern %q: %w", c, err)
+		}
+	}
+	if args.Interval < 0 {
+		return nil, fmt.Errorf("interval of %s should not be negative", probeName)
+	}
+	if args.Interval == 0 {
+		args.Interval = defaultCacheInterval
+	}
+	p := &netfilterMetricsProbe{
+		chains:   args.Chains,
+		entities: args.Filter(),
+		interval: time.Duration(args.Interval) * time.Second,
+		cache:    make(map[int]*cachedRules),
+	}
+
+	opts := probe.BatchMetricsOpts{
+		Namespace:      probe.MetricsNamespace,
+		Subsystem:      probeName,
+		VariableLabels: append(append([]string{}, probe.StandardMetricsLabels...), ruleLabels...),
+		SingleMetricsOpts: []probe.SingleMetricsOpts{
+			{Name: RulePackets, Help: "The total number of packets matched by the iptables rule.", ValueType: prometheus.CounterValue},
+			{Name: RuleBytes, Help: "The total number of bytes matched by the iptables rule.", ValueType: prometheus.CounterValue},
+		},
+	}
+	batchMetrics := probe.NewBatchMetrics(opts, p.collectOnce)
+	return probe.NewMetricsProbe(probeName, p, batchMetrics), nil
+}
+
+type netfilterMetricsProbe struct {
+	chains   []string
+	entities *nettop.EntityFilter
+	interval time.Duration
+	// rules saved by netns, guarded by lock
+	lock  sync.Mutex
+	cache map[int]*cachedRules
+}
+
+type cachedRules struct {
+	time  time.Time
+	rules []*rule
+}
+
+func (p *netfilterMetricsProbe) Start(_ context.Context) error {
+	return nil
+}
+
+func (p *netfilterMetricsProbe) Stop(_ context.Context) error {
+	return nil
+}
+
+func (p *netfilterMetricsProbe) matchChain(chain string) bool {
+	for _, c := range p.chains {
+		if ok, _ := path.Match(c, chain); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// savedRules returns rules of the netns mounted at path, rules saved within interval are reused.
+func (p *netfilterMetricsProbe) savedRules(nsinum int, path string, now time.Time) ([]*rule, error) {
+	if c, ok := p.cache[nsinum]; ok && now.Sub(c.time) < p.interval {
+		return c.rules, nil
+	}
+	_, rules, err := saveIPTables(path)
+	if err != nil {
+		delete(p.cache, nsinum)
+		return nil, err
+	}
+	p.cache[nsinum] = &cachedRules{time: now, rules: rules}
+	return rules, nil
+}
+
+func (p *netfilterMetricsProbe) collectOnce(emit probe.Emit) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var errs []string
+	now := time.Now()
+	seen := make(map[int]bool)
+	for _, et := range nettop.GetAllUniqueNetnsEntity() {
+		if !p.entities.Match(et) {
+			continue
+		}
+		seen[et.GetNetns()] = true
+		rules, err := p.savedRules(et.GetNetns(), et.GetNetnsMountPoint(), now)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("netns %d: %v", et.GetNetns(), err))
+			continue
+		}
+		labels := probe.BuildStandardMetricsLabelValues(et)
+		for key, c := range p.sumRules(rules) {
+			values := append(append([]string{}, labels...), key.backend, key.table, key.chain, key.spec)
+			emit(RulePackets, values, float64(c.packets))
+			emit(RuleBytes, values, float64(c.bytes))
+		}
+	}
+	// a pod may be deleted during collection, errors of single netns are not fatal
+	if len(errs) > 0 {
+		log.Debugf("%s: failed collect rule counters, %s", probeName, strings.Join(errs, "; "))
+		probe.AddCollectErrors(probeName, len(errs))
+	}
+	for nsinum := range p.cache {
+		if !seen[nsinum] {
+			delete(p.cache, nsinum)
+		}
+	}
+	return nil
+}
+
+type ruleKey struct {
+	backend string
+	table   string
+	chain   string
+	spec    string
+}
+
+type ruleCounter struct {
+	packets uint64
+	bytes   uint64
+}
+
+// sumRules sums counters of rules in selected chains, identical rules in a chain share the
+// same labels.
+func (p *netfilterMetricsProbe) sumRules(rules []*rule) map[ruleKey]*ruleCounter {
+	ret := make(map[ruleKey]*ruleCounter)
+	for _, r := range rules {
+		if !p.matchChain(r.chain) {
+			continue
+		}
+		key := ruleKey{backend: r.backend, table: r.table, chain: r.chain, spec: r.spec}
+		c, ok := ret[key]
+		if !ok {
+			c = &ruleCounter{}
+			ret[key] = c
+		}
+		c.packets += r.packets
+		c.bytes += r.bytes
+	}
+	return ret
+}
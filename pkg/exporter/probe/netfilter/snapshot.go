@@ -0,0 +1,289 @@
+package netfilter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/moby/ipvs"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	sectionIPSet = "ipset"
+	sectionIPVS  = "ipvs"
+
+	ipsetCmd = "ipset"
+	// ipvs is skipped when the ip_vs module is not loaded
+	ipvsModulePath = "/sys/module/ip_vs"
+)
+
+// iptables backends and their save commands, a backend is skipped if its command is missing
+var iptablesBackends = []struct {
+	name string
+	cmd  string
+}{
+	{"iptables-nft", "iptables-nft-save"},
+	{"ip6tables-nft", "ip6tables-nft-save"},
+	{"iptables-legacy", "iptables-legacy-save"},
+	{"ip6tables-legacy", "ip6tables-legacy-save"},
+}
+
+// runInNetns runs the command in the netns mounted at path and returns its stdout.
+func runInNetns(path string, name string, args ...string) (string, error) {
+	cmd := exec.Command("nsenter", append([]string{"--net=" + path, "--", name}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed run %s: %w, stderr: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// rule is a rule of iptables-save -c with its counters.
+type rule struct {
+	backend string
+	table   string
+	chain   string
+	// spec is the rule without -A and chain
+	spec    string
+	packets uint64
+	bytes   uint64
+}
+
+// section is a normalized part of a netfilter snapshot, counters and "#" lines with the time of
+// iptables-save are removed so that the hash only changes when rules change.
+type section struct {
+	name  string
+	lines []string
+	hash  string
+}
+
+func newSection(name string, lines []string) *section {
+	h := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return &section{name: name, lines: lines, hash: hex.EncodeToString(h[:])}
+}
+
+// snapshot is the netfilter state of a netns.
+type snapshot struct {
+	sections []*section
+}
+
+func (s *snapshot) section(name string) *section {
+	for _, sec := range s.sections {
+		if sec.name == name {
+			return sec
+		}
+	}
+	return nil
+}
+
+// parseIPTablesSave parses output of iptables-save -c to normalized lines and rules.
+func parseIPTablesSave(backend, out string) ([]string, []*rule, error) {
+	var lines []string
+	var rules []*rule
+	table := ""
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "*"):
+			table = line[1:]
+			lines = append(lines, line)
+		case strings.HasPrefix(line, ":"):
+			// :CHAIN POLICY [packets:bytes]
+			if i := strings.LastIndex(line, " ["); i > 0 {
+				line = line[:i]
+			}
+			lines = append(lines, line)
+		case strings.HasPrefix(line, "["):
+			// [packets:bytes] -A CHAIN spec
+			end := strings.Index(line, "]")
+			if end < 0 {
+				return nil, nil, fmt.Errorf("invalid rule %q", line)
+			}
+			counters := strings.SplitN(line[1:end], ":", 2)
+			if len(counters) != 2 {
+				return nil, nil, fmt.Errorf("invalid counters of rule %q", line)
+			}
+			packets, err := strconv.ParseUint(counters[0], 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid counters of rule %q: %w", line, err)
+			}
+			nbytes, err := strconv.ParseUint(counters[1], 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid counters of rule %q: %w", line, err)
+			}
+			spec := strings.TrimSpace(line[end+1:])
+			lines = append(lines, spec)
+
+			fields := strings.SplitN(spec, " ", 3)
+			if len(fields) < 2 || fields[0] != "-A" {
+				continue
+			}
+			r := &rule{backend: backend, table: table, chain: fields[1], packets: packets, bytes: nbytes}
+			if len(fields) == 3 {
+				r.spec = fields[2]
+			}
+			rules = append(rules, r)
+		default:
+			// COMMIT and rules without counters
+			lines = append(lines, line)
+		}
+	}
+	return lines, rules, nil
+}
+
+// parseIPSetSave normalizes output of ipset save, members of hash sets are in no particular
+// order, so lines are sorted.
+func parseIPSetSave(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// ipvsLines describes ipvs services and real servers in the format of ipvsadm -S.
+func ipvsLines(path string) ([]string, error) {
+	h, err := ipvs.New(path)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close()
+
+	services, err := h.GetServices()
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, svc := range services {
+		vs := ipvsServiceName(svc)
+		lines = append(lines, fmt.Sprintf("-A %s -s %s", vs, svc.SchedName))
+		dsts, err := h.GetDestinations(svc)
+		if err != nil {
+			return nil, err
+		}
+		for _, dst := range dsts {
+			lines = append(lines, fmt.Sprintf("-a %s -r %s:%d -w %d", vs, dst.Address, dst.Port, dst.Weight))
+		}
+	}
+	sort.Strings(lines)
+	return lines, nil
+}
+
+func ipvsServiceName(svc *ipvs.Service) string {
+	if svc.FWMark != 0 {
+		return fmt.Sprintf("-f %d", svc.FWMark)
+	}
+	proto := "-t"
+	if svc.Protocol == 17 {
+		proto = "-u"
+	}
+	return fmt.Sprintf("%s %s:%d", proto, svc.Address, svc.Port)
+}
+
+// commandExists reports whether the command is installed, nsenter only enters the netns, so
+// commands are looked up in the mount namespace of the exporter.
+var commandExists = defaultCommandExists
+
+func defaultCommandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// saveIPTables saves rules of all installed iptables backends in the netns mounted at path.
+// It fails if any installed backend fails, partial rules would be taken as removed.
+func saveIPTables(path string) ([]*section, []*rule, error) {
+	var sections []*section
+	var rules []*rule
+	for _, b := range iptablesBackends {
+		if !commandExists(b.cmd) {
+			continue
+		}
+		out, err := runInNetns(path, b.cmd, "-c")
+		if err != nil {
+			return nil, nil, err
+		}
+		lines, backendRules, err := parseIPTablesSave(b.name, out)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed parse %s: %w", b.cmd, err)
+		}
+		// tables of the backend are not loaded
+		if len(lines) == 0 {
+			continue
+		}
+		sections = append(sections, newSection(b.name, lines))
+		rules = append(rules, backendRules...)
+	}
+	return sections, rules, nil
+}
+
+// takeSnapshot takes netfilter snapshot of the netns of et, backends not installed are
+// skipped. It fails on any error of installed backends rather than returning a partial
+// snapshot.
+func takeSnapshot(et *nettop.Entity, withIPVS bool) (*snapshot, error) {
+	path := et.GetNetnsMountPoint()
+	sections, _, err := saveIPTables(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &snapshot{sections: sections}
+
+	if commandExists(ipsetCmd) {
+		out, err := runInNetns(path, ipsetCmd, "save")
+		if err != nil {
+			return nil, err
+		}
+		s.sections = append(s.sections, newSection(sectionIPSet, parseIPSetSave(out)))
+	}
+
+	if withIPVS {
+		if _, err := os.Stat(ipvsModulePath); err == nil {
+			lines, err := ipvsLines(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed dump ipvs: %w", err)
+			}
+			s.sections = append(s.sections, newSection(sectionIPVS, lines))
+		} else {
+			log.Debugf("%s: skip ipvs: %v", probeName, err)
+		}
+	}
+	return s, nil
+}
+
+// diffLines returns lines only in new and only in old, regardless of their order.
+func diffLines(old, new []string) (added, removed []string) {
+	count := make(map[string]int, len(old))
+	for _, l := range old {
+		count[l]++
+	}
+	for _, l := range new {
+		if count[l] > 0 {
+			count[l]--
+			continue
+		}
+		added = append(added, l)
+	}
+	for _, l := range old {
+		if count[l] > 0 {
+			count[l]--
+			removed = append(removed, l)
+		}
+	}
+	return added, removed
+}
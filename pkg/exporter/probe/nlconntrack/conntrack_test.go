@@ -0,0 +1,64 @@
+package nlconntrack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ti-mo/conntrack"
+)
+
+func tcpFlow(state uint8) *conntrack.Flow {
+	f := &conntrack.Flow{}
+	f.TupleOrig.Proto.Protocol = 6
+	f.ProtoInfo.TCP = &conntrack.ProtoInfoTCP{State: state}
+	return f
+}
+
+func udpFlow(replied bool) *conntrack.Flow {
+	f := &conntrack.Flow{}
+	f.TupleOrig.Proto.Protocol = 17
+	if replied {
+		f.Status.Value = conntrack.StatusSeenReply
+	}
+	return f
+}
+
+func TestFlowState(t *testing.T) {
+	assert.Equal(t, "ESTABLISHED", flowState(tcpFlow(3)))
+	assert.Equal(t, "TIME_WAIT", flowState(tcpFlow(7)))
+	assert.Equal(t, "UNKNOWN", flowState(tcpFlow(100)))
+	assert.Equal(t, "REPLIED", flowState(udpFlow(true)))
+	assert.Equal(t, "UNREPLIED", flowState(udpFlow(false)))
+}
+
+func TestCountFlowsByState(t *testing.T) {
+	flows := []conntrack.Flow{*tcpFlow(3), *tcpFlow(3), *tcpFlow(7), *udpFlow(false)}
+	assert.Equal(t, map[stateKey]int{
+		{protocol: "TCP", state: "ESTABLISHED"}: 2,
+		{protocol: "TCP", state: "TIME_WAIT"}:   1,
+		{protocol: "UDP", state: "UNREPLIED"}:   1,
+	}, countFlowsByState(flows))
+}
+
+func TestEventFilter(t *testing.T) {
+	_, err := newEventFilter(eventArgs{Types: []string{"create"}})
+	assert.Error(t, err)
+
+	f, err := newEventFilter(eventArgs{})
+	assert.NoError(t, err)
+	assert.True(t, f.matchEvent(&conntrack.Event{Type: conntrack.EventUpdate, Flow: udpFlow(true)}))
+	assert.True(t, f.matchEvent(&conntrack.Event{Type: conntrack.EventExpNew}))
+
+	f, err = newEventFilter(eventArgs{
+		Protocols: []string{"tcp"},
+		States:    []string{"syn_sent", "TIME_WAIT"},
+		Types:     []string{"New", "destroy"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, f.matchEvent(&conntrack.Event{Type: conntrack.EventNew, Flow: tcpFlow(1)}))
+	assert.True(t, f.matchEvent(&conntrack.Event{Type: conntrack.EventDestroy, Flow: tcpFlow(7)}))
+	assert.False(t, f.matchEvent(&conntrack.Event{Type: conntrack.EventUpdate, Flow: tcpFlow(1)}))
+	assert.False(t, f.matchEvent(&conntrack.Event{Type: conntrack.EventNew, Flow: tcpFlow(3)}))
+	assert.False(t, f.matchEvent(&conntrack.Event{Type: conntrack.EventNew, Flow: udpFlow(false)}))
+	assert.False(t, f.matchEvent(&conntrack.Event{Type: conntrack.EventExpNew}))
+}
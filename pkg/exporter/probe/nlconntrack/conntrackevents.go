@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netns"
+	"golang.org/x/time/rate"
 
 	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
 
@@ -28,69 +32,207 @@ const (
 	ConntrackUnknow     = "ConntrackUnknow"
 )
 
+// interval of logging events dropped by rate limit
+const droppedLogInterval = time.Minute
+
 var (
 	probeName = "conntrack"
+
+	eventTypeNames = map[string]uint8{
+		"new":        uint8(conntrack.EventNew),
+		"update":     uint8(conntrack.EventUpdate),
+		"destroy":    uint8(conntrack.EventDestroy),
+		"expnew":     uint8(conntrack.EventExpNew),
+		"expdestroy": uint8(conntrack.EventExpDestroy),
+	}
 )
 
-func eventProbeCreator(sink chan<- *probe.Event) (probe.EventProbe, error) {
+type eventArgs struct {
+	Protocols []string `mapstructure:"protocols" description:"protocols of events to report, e.g. tcp, udp, icmp, all protocols by default"`
+	States    []string `mapstructure:"states" description:"tcp states (e.g. SYN_SENT, TIME_WAIT) or replied/unreplied for other protocols of events to report, all states by default"`
+	Types     []string `mapstructure:"types" description:"types of events to report, one of new, update, destroy, expnew, expdestroy, all types by default"`
+	RateLimit float64  `mapstructure:"rateLimit" description:"max events per second reported by the probe, exceeded events are dropped, unlimited by default"`
+	Burst     int      `mapstructure:"burst" description:"max burst of events when rateLimit is set, default to rateLimit"`
+
+	nettop.NamespaceArgs `mapstructure:",squash"`
+}
+
+// eventFilter decides which conntrack events are reported, empty sets match everything.
+type eventFilter struct {
+	protocols map[string]bool
+	states    map[string]bool
+	types     map[uint8]bool
+	entities  *nettop.EntityFilter
+}
+
+func newEventFilter(args eventArgs) (*eventFilter, error) {
+	f := &eventFilter{
+		protocols: toUpperSet(args.Protocols),
+		states:    toUpperSet(args.States),
+		types:     make(map[uint8]bool),
+		entities:  args.Filter(),
+	}
+	for _, t := range args.Types {
+		et, ok := eventTypeNames[strings.ToLower(t)]
+		if !ok {
+			return nil, fmt.Errorf("unknown conntrack event type %q, expected one of new, update, destroy, expnew, expdestroy", t)
+		}
+		f.types[et] = true
+	}
+	return f, nil
+}
+
+func toUpperSet(values []string) map[string]bool {
+	ret := make(map[string]bool, len(values))
+	for _, v := range values {
+		ret[strings.ToUpper(v)] = true
+	}
+	return ret
+}
+
+func (f *eventFilter) matchEvent(evt *conntrack.Event) bool {
+	if len(f.types) > 0 && !f.types[uint8(evt.Type)] {
+		return false
+	}
+	if evt.Flow == nil {
+		// expectation events carry no flow, only filtered by type
+		return len(f.protocols) == 0 && len(f.states) == 0
+	}
+	if len(f.protocols) > 0 && !f.protocols[bpfutil.GetProtoStr(evt.Flow.TupleOrig.Proto.Protocol)] {
+		return false
+	}
+	if len(f.states) > 0 && !f.states[flowState(evt.Flow)] {
+		return false
+	}
+	return true
+}
+
+func eventProbeCreator(sink chan<- *probe.Event, args eventArgs) (probe.EventProbe, error) {
+	filter, err := newEventFilter(args)
+	if err != nil {
+		return nil, err
+	}
+	if args.RateLimit < 0 || args.Burst < 0 {
+		return nil, fmt.Errorf("rateLimit and burst of %s should not be negative", probeName)
+	}
+
 	p := &conntrackEventProbe{
-		sink: sink,
+		sink:   sink,
+		filter: filter,
+	}
+	if args.RateLimit > 0 {
+		burst := args.Burst
+		if burst == 0 {
+			burst = int(args.RateLimit)
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		p.limiter = rate.NewLimiter(rate.Limit(args.RateLimit), burst)
 	}
 	return probe.NewEventProbe(probeName, p), nil
 }
 
 type conntrackEventProbe struct {
-	sink  chan<- *probe.Event
-	conns map[int]chan struct{}
-	done  chan struct{}
+	sink    chan<- *probe.Event
+	filter  *eventFilter
+	limiter *rate.Limiter
+	dropped atomic.Uint64
+	lock    sync.Mutex
+	conns   map[int]chan struct{}
+	cancel  func()
+	done    chan struct{}
 }
 
 func (p *conntrackEventProbe) Start(ctx context.Context) error {
+	events, cancel := nettop.SubscribeNetnsEvents()
+	p.conns = make(map[int]chan struct{})
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		select {
-		case <-ticker.C:
-			log.Infof("%s: start update netns list", probeName)
-			ets := nettop.GetAllUniqueNetnsEntity()
-			for _, et := range ets {
-				if et == nil {
-					log.Infof("%s: skip empty entity", probeName)
-					continue
-				}
-				nsHandle, err := et.OpenNsHandle()
-				if err != nil {
-					log.Infof("%s: failed get netns fd, skip netns fd, err: %v", probeName, err)
-					continue
+		ticker := time.NewTicker(droppedLogInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n := p.dropped.Swap(0); n > 0 {
+					log.Warnf("%s: %d events dropped by rate limit in last %s", probeName, n, droppedLogInterval)
 				}
-				if nsHandle == 0 {
-					log.Infof("%s: invalid nsfd(0), skip empty netns fd", probeName)
-					continue
+			case ev, ok := <-events:
+				if !ok {
+					return
 				}
-				if _, ok := p.conns[et.GetNetns()]; !ok {
-					ctrch := make(chan struct{})
-					go func() {
-						err = p.startCtListen(ctx, ctrch, nsHandle, et.GetNetns())
-						if err != nil {
-							log.Infof("%s: failed start worker, err: %v", probeName, err)
-							return
-						}
-					}()
-					p.conns[et.GetNetns()] = ctrch
-					log.Infof("%s: start worker finished", probeName)
+				switch ev.Type {
+				case nettop.NetnsAdd:
+					p.attach(ctx, ev.Entity)
+				case nettop.NetnsRemove:
+					p.detach(ev.Entity.GetNetns())
 				}
+			case <-p.done:
+				return
 			}
-		case <-p.done:
-			return
 		}
 	}()
 
 	return nil
 }
 
+func (p *conntrackEventProbe) attach(ctx context.Context, et *nettop.Entity) {
+	nsinum := et.GetNetns()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.conns[nsinum]; ok {
+		return
+	}
+	if !p.filter.entities.Match(et) {
+		return
+	}
+
+	nsHandle, err := et.OpenNsHandle()
+	if err != nil {
+		log.Infof("%s: failed get netns fd of %d, skip netns, err: %v", probeName, nsinum, err)
+		return
+	}
+	if nsHandle == 0 {
+		log.Infof("%s: invalid nsfd(0), skip empty netns fd", probeName)
+		return
+	}
+
+	ctrch := make(chan struct{})
+	p.conns[nsinum] = ctrch
+	go func() {
+		if err := p.startCtListen(ctx, ctrch, nsHandle, nsinum); err != nil {
+			log.Infof("%s: failed start worker of netns %d, err: %v", probeName, nsinum, err)
+			p.lock.Lock()
+			if p.conns[nsinum] == ctrch {
+				delete(p.conns, nsinum)
+			}
+			p.lock.Unlock()
+		}
+	}()
+	log.Infof("%s: start worker of netns %d", probeName, nsinum)
+}
+
+func (p *conntrackEventProbe) detach(nsinum int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if ch, ok := p.conns[nsinum]; ok {
+		close(ch)
+		delete(p.conns, nsinum)
+		log.Infof("%s: stop worker of netns %d", probeName, nsinum)
+	}
+}
+
 func (p *conntrackEventProbe) Stop(_ context.Context) error {
 	close(p.done)
-	for _, conn := range p.conns {
+	p.cancel()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for nsinum, conn := range p.conns {
 		close(conn)
+		delete(p.conns, nsinum)
 	}
 	return nil
 }
@@ -105,8 +247,9 @@ func (p *conntrackEventProbe) startCtListen(_ context.Context, ctrch <-chan stru
 		log.Infof("%s: failed start conntrack dial, err: %v", probeName, err)
 		return err
 	}
+	defer c.Close()
 
-	log.Infof("%s: start conntrack listen in netns %d", probeName, nsHandle)
+	log.Infof("%s: start conntrack listen in netns %d", probeName, nsinum)
 	evCh := make(chan conntrack.Event, 1024)
 	errCh, err := c.Listen(evCh, 4, append(netfilter.GroupsCT, netfilter.GroupsCTExp...))
 	if err != nil {
@@ -123,8 +266,15 @@ func (p *conntrackEventProbe) startCtListen(_ context.Context, ctrch <-chan stru
 			log.Infof("%s: conntrack event listen stop, err: %v", probeName, err)
 			return err
 		case event := <-evCh:
+			log.Debugf("%s: conntrack event listen got event: %s", probeName, event.String())
+			if !p.filter.matchEvent(&event) {
+				continue
+			}
+			if p.limiter != nil && !p.limiter.Allow() {
+				p.dropped.Add(1)
+				continue
+			}
 			p.sink <- vanishEvent(event, nsinum)
-			log.Infof("%s: conntrack event listen got event: %s", probeName, event.String())
 		}
 	}
 }
@@ -139,19 +289,45 @@ var eventTypeMapping = map[uint8]probe.EventType{
 }
 
 func vanishEvent(evt conntrack.Event, nsinum int) *probe.Event {
+	labels := probe.EventMetaByNetNS(nsinum)
+	if evt.Flow == nil {
+		return &probe.Event{
+			Timestamp: time.Now().UnixNano(),
+			Type:      eventTypeMapping[uint8(evt.Type)],
+			Labels:    labels,
+			Message:   evt.String(),
+			Version:   probe.EventSchemaVersion,
+			Netns:     uint32(nsinum),
+		}
+	}
+
+	orig := evt.Flow.TupleOrig
+	state := flowState(evt.Flow)
+	rawStr := fmt.Sprintf("Proto = %s Replied = %t ", bpfutil.GetProtoStr(orig.Proto.Protocol), evt.Flow.Status.SeenReply())
+	if evt.Flow.ProtoInfo.TCP != nil {
+		rawStr += fmt.Sprintf("State = %s ", state)
+	}
+	rawStr += fmt.Sprintf("Src = %s, Dst = %s", net.JoinHostPort(orig.IP.SourceAddress.String(), strconv.Itoa(int(orig.Proto.SourcePort))),
+		net.JoinHostPort(orig.IP.DestinationAddress.String(), strconv.Itoa(int(orig.Proto.DestinationPort))))
 
-	rawStr := fmt.Sprintf("Proto = %s Replied = %t ", bpfutil.GetProtoStr(evt.Flow.TupleOrig.Proto.Protocol), evt.Flow.Status.SeenReply())
-	if evt.Flow.TupleOrig.Proto.Protocol == 6 && evt.Flow.ProtoInfo.TCP != nil {
-		rawStr += fmt.Sprintf("State = %s ", bpfutil.GetTCPState(evt.Flow.ProtoInfo.TCP.State))
+	tuple := &probe.Tuple{
+		Protocol: orig.Proto.Protocol,
+		Src:      orig.IP.SourceAddress.String(),
+		Dst:      orig.IP.DestinationAddress.String(),
+		Sport:    orig.Proto.SourcePort,
+		Dport:    orig.Proto.DestinationPort,
 	}
-	rawStr += fmt.Sprintf("Src = %s, Dst = %s", net.JoinHostPort(evt.Flow.TupleOrig.IP.SourceAddress.String(), strconv.Itoa(int(evt.Flow.TupleOrig.Proto.SourcePort))),
-		net.JoinHostPort(evt.Flow.TupleOrig.IP.DestinationAddress.String(), strconv.Itoa(int(evt.Flow.TupleOrig.Proto.DestinationPort))))
+	labels = append(labels, probe.BuildTupleEventLabels(tuple)...)
+	labels = append(labels, probe.Label{Name: "state", Value: state})
 
 	return &probe.Event{
 		Timestamp: time.Now().UnixNano(),
 		Type:      eventTypeMapping[uint8(evt.Type)],
-		Labels:    probe.EventMetaByNetNS(nsinum),
+		Labels:    labels,
 		Message:   rawStr,
+		Version:   probe.EventSchemaVersion,
+		Tuple:     probe.NewEventTuple(tuple),
+		Netns:     uint32(nsinum),
 	}
 }
 
@@ -2,12 +2,20 @@ package nlconntrack
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
+	"github.com/alibaba/kubeskoop/pkg/exporter/bpfutil"
 	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
 	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/mdlayher/netlink"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
 	"github.com/ti-mo/conntrack"
 )
 
+const defaultMaxDumpEntries = 100000
+
 var (
 	MetricPrefix = "conntrack"
 
@@ -24,6 +32,10 @@ var (
 
 	Entries    = "entries"
 	MaxEntries = "maxentries"
+	UsageRatio = "usageratio"
+
+	// StateEntries is the number of entries by protocol and state
+	StateEntries = "stateentries"
 
 	// stats of conntrack status summary
 	conntrackMetrics = []probe.LegacyMetric{
@@ -38,34 +50,98 @@ var (
 		{Name: SearchRestart, Help: "The total number of times the search for a connection entry was restarted."},
 		{Name: Entries, Help: "The current number of connections tracked in the conntrack table."},
 		{Name: MaxEntries, Help: "The maximum number of entries allowed in the conntrack table."},
+		{Name: UsageRatio, Help: "The ratio of current entries to the maximum entries of the conntrack table."},
 	}
+
+	// conntrack tcp states, see enum tcp_conntrack in kernel
+	tcpStates = []string{"NONE", "SYN_SENT", "SYN_RECV", "ESTABLISHED", "FIN_WAIT", "CLOSE_WAIT", "LAST_ACK", "TIME_WAIT", "CLOSE", "SYN_SENT2"}
 )
 
-func metricsProbeCreator() (probe.MetricsProbe, error) {
-	p := &conntrackMetricsProbe{}
+type metricsArgs struct {
+	MaxDumpEntries int `mapstructure:"maxDumpEntries" description:"skip entries by state of a netns with more entries than this, default 100000, negative to disable"`
+}
 
-	batchMetrics := probe.NewLegacyBatchMetrics(probeName, conntrackMetrics, p.CollectOnce)
+func metricsProbeCreator(args metricsArgs) (probe.MetricsProbe, error) {
+	if args.MaxDumpEntries == 0 {
+		args.MaxDumpEntries = defaultMaxDumpEntries
+	}
+	p := &conntrackMetricsProbe{args: args}
 
+	opts := probe.BatchMetricsOpts{
+		Namespace:      probe.MetricsNamespace,
+		Subsystem:      MetricPrefix,
+		VariableLabels: probe.StandardMetricsLabels,
+	}
+	for _, m := range conntrackMetrics {
+		opts.SingleMetricsOpts = append(opts.SingleMetricsOpts, probe.SingleMetricsOpts{
+			Name: m.Name, Help: m.Help, ValueType: prometheus.GaugeValue,
+		})
+	}
+	opts.SingleMetricsOpts = append(opts.SingleMetricsOpts, probe.SingleMetricsOpts{
+		Name:           StateEntries,
+		Help:           "The current number of connections tracked in the conntrack table by protocol and state.",
+		VariableLabels: []string{"protocol", "state"},
+		ValueType:      prometheus.GaugeValue,
+	})
+
+	batchMetrics := probe.NewBatchMetrics(opts, p.collectOnce)
 	return probe.NewMetricsProbe(probeName, p, batchMetrics), nil
 }
 
 type conntrackMetricsProbe struct {
-	conn *conntrack.Conn
+	args metricsArgs
 }
 
-func (c *conntrackMetricsProbe) collectStats() (map[string]uint64, error) {
-	resMap := map[string]uint64{}
+func (c *conntrackMetricsProbe) Start(_ context.Context) error {
+	// conntrack netlink is not available if nf_conntrack module is not loaded
+	conn, err := conntrack.Dial(nil)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (c *conntrackMetricsProbe) Stop(_ context.Context) error {
+	return nil
+}
+
+func (c *conntrackMetricsProbe) collectOnce(emit probe.Emit) error {
+	var errs []string
+	for _, et := range nettop.GetAllUniqueNetnsEntity() {
+		if err := c.collectNetns(et, emit); err != nil {
+			errs = append(errs, fmt.Sprintf("netns %d: %v", et.GetNetns(), err))
+		}
+	}
+	// a pod may be deleted during collection, errors of single netns are not fatal
+	if len(errs) > 0 {
+		log.Debugf("%s: failed collect conntrack stats, %s", probeName, strings.Join(errs, "; "))
+	}
+	return nil
+}
 
-	stat, err := c.conn.Stats()
+func (c *conntrackMetricsProbe) collectNetns(et *nettop.Entity, emit probe.Emit) error {
+	nsHandle, err := et.OpenNsHandle()
 	if err != nil {
-		return resMap, err
+		return err
 	}
+	defer nsHandle.Close()
 
-	globalstat, err := c.conn.StatsGlobal()
+	conn, err := conntrack.Dial(&netlink.Config{NetNS: int(nsHandle)})
 	if err != nil {
-		return resMap, err
+		return err
 	}
+	defer conn.Close()
 
+	stat, err := conn.Stats()
+	if err != nil {
+		return err
+	}
+	globalstat, err := conn.StatsGlobal()
+	if err != nil {
+		return err
+	}
+
+	resMap := map[string]uint64{}
 	for _, statpercpu := range stat {
 		resMap[Found] += uint64(statpercpu.Found)
 		resMap[Invalid] += uint64(statpercpu.Invalid)
@@ -77,35 +153,56 @@ func (c *conntrackMetricsProbe) collectStats() (map[string]uint64, error) {
 		resMap[Error] += uint64(statpercpu.Error)
 		resMap[SearchRestart] += uint64(statpercpu.SearchRestart)
 	}
-
 	resMap[Entries] = uint64(globalstat.Entries)
 	resMap[MaxEntries] = uint64(globalstat.MaxEntries)
 
-	return resMap, nil
-}
+	labels := probe.BuildStandardMetricsLabelValues(et)
+	for name, value := range resMap {
+		emit(name, labels, float64(value))
+	}
+	if globalstat.MaxEntries > 0 {
+		emit(UsageRatio, labels, float64(globalstat.Entries)/float64(globalstat.MaxEntries))
+	}
 
-func (c *conntrackMetricsProbe) CollectOnce() (map[string]map[uint32]uint64, error) {
-	resMap := map[string]map[uint32]uint64{}
-	stats, err := c.collectStats()
+	if c.args.MaxDumpEntries < 0 || int(globalstat.Entries) > c.args.MaxDumpEntries {
+		return nil
+	}
+	flows, err := conn.Dump()
 	if err != nil {
-		return resMap, err
+		return err
 	}
-
-	for _, metric := range conntrackMetrics {
-		resMap[metric.Name] = map[uint32]uint64{uint32(nettop.InitNetns): stats[metric.Name]}
+	for key, count := range countFlowsByState(flows) {
+		emit(StateEntries, append(append([]string{}, labels...), key.protocol, key.state), float64(count))
 	}
+	return nil
+}
 
-	return resMap, nil
+type stateKey struct {
+	protocol string
+	state    string
 }
 
-func (c *conntrackMetricsProbe) Start(_ context.Context) error {
-	var err error
-	c.conn, err = conntrack.Dial(nil)
-	return err
+func countFlowsByState(flows []conntrack.Flow) map[stateKey]int {
+	ret := make(map[stateKey]int)
+	for i := range flows {
+		f := &flows[i]
+		ret[stateKey{protocol: bpfutil.GetProtoStr(f.TupleOrig.Proto.Protocol), state: flowState(f)}]++
+	}
+	return ret
 }
 
-func (c *conntrackMetricsProbe) Stop(_ context.Context) error {
-	return c.conn.Close()
+// flowState returns the tcp state for tcp flows, or the reply status for other protocols.
+func flowState(f *conntrack.Flow) string {
+	if f.ProtoInfo.TCP != nil {
+		if int(f.ProtoInfo.TCP.State) < len(tcpStates) {
+			return tcpStates[f.ProtoInfo.TCP.State]
+		}
+		return "UNKNOWN"
+	}
+	if f.Status.SeenReply() {
+		return "REPLIED"
+	}
+	return "UNREPLIED"
 }
 
 func init() {
@@ -0,0 +1,103 @@
+package nlneigh
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/mdlayher/netlink"
+	"github.com/stretchr/testify/assert"
+	vnl "github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func buildNeighTable(t *testing.T, family uint8, withConfig bool) []byte {
+	ae := netlink.NewAttributeEncoder()
+	ae.String(ndtaName, "arp_cache")
+	ae.Uint32(ndtaThresh1, 128)
+	ae.Uint32(ndtaThresh2, 512)
+	ae.Uint32(ndtaThresh3, 1024)
+	if withConfig {
+		config := make([]byte, 36)
+		binary.NativeEndian.PutUint32(config[ndtConfigEntries:], 1000)
+		ae.Bytes(ndtaConfig, config)
+	}
+	stats := make([]byte, 88)
+	binary.NativeEndian.PutUint64(stats[ndtStatsResFailed:], 3)
+	binary.NativeEndian.PutUint64(stats[ndtStatsForcedGCRuns:], 4)
+	binary.NativeEndian.PutUint64(stats[ndtStatsTableFulls:], 5)
+	ae.Bytes(ndtaStats, stats)
+	attrs, err := ae.Encode()
+	assert.NoError(t, err)
+	return append([]byte{family, 0, 0, 0}, attrs...)
+}
+
+func TestParseNeighTable(t *testing.T) {
+	tbl, err := parseNeighTable(buildNeighTable(t, unix.AF_INET, true))
+	assert.NoError(t, err)
+	assert.Equal(t, &neighTable{
+		family:       "ipv4",
+		name:         "arp_cache",
+		entries:      1000,
+		thresh1:      128,
+		thresh2:      512,
+		thresh3:      1024,
+		resFailed:    3,
+		forcedGCRuns: 4,
+		tableFulls:   5,
+	}, tbl)
+
+	// messages of per device parameters
+	tbl, err = parseNeighTable(buildNeighTable(t, unix.AF_INET, false))
+	assert.NoError(t, err)
+	assert.Nil(t, tbl)
+
+	_, err = parseNeighTable([]byte{1})
+	assert.Error(t, err)
+}
+
+func TestCountNeighsByState(t *testing.T) {
+	ret := countNeighsByState([]vnl.Neigh{
+		{Family: unix.AF_INET, State: vnl.NUD_REACHABLE},
+		{Family: unix.AF_INET, State: vnl.NUD_REACHABLE},
+		{Family: unix.AF_INET, State: vnl.NUD_FAILED},
+		{Family: unix.AF_INET6, State: vnl.NUD_STALE},
+		{Family: unix.AF_INET6, State: vnl.NUD_NONE},
+		{Family: unix.AF_BRIDGE, State: vnl.NUD_REACHABLE},
+	})
+	assert.Equal(t, map[stateKey]int{
+		{family: "ipv4", state: "REACHABLE"}: 2,
+		{family: "ipv4", state: "FAILED"}:    1,
+		{family: "ipv6", state: "STALE"}:     1,
+		{family: "ipv6", state: "NONE"}:      1,
+	}, ret)
+}
+
+func TestNeighRole(t *testing.T) {
+	_, cidr, _ := net.ParseCIDR("10.1.0.0/16")
+	p := &neighEventProbe{peers: []*net.IPNet{cidr}}
+	gateways := &gatewayCache{gateways: routeGateways([]vnl.Route{
+		{Gw: net.ParseIP("192.168.0.1")},
+		{MultiPath: []*vnl.NexthopInfo{{Gw: net.ParseIP("192.168.0.2")}}},
+		{Dst: cidr},
+	})}
+
+	assert.Equal(t, "gateway", p.neighRole(net.ParseIP("192.168.0.1"), gateways))
+	assert.Equal(t, "gateway", p.neighRole(net.ParseIP("192.168.0.2"), gateways))
+	assert.Equal(t, "peer", p.neighRole(net.ParseIP("10.1.2.3"), gateways))
+	assert.Equal(t, "", p.neighRole(net.ParseIP("172.16.0.1"), gateways))
+}
+
+func TestDetectOverflow(t *testing.T) {
+	p := &neighEventProbe{tableFulls: make(map[string]uint64)}
+	tables := []*neighTable{{family: "ipv4", name: "arp_cache", tableFulls: 5}}
+	assert.Empty(t, p.detectOverflow(tables))
+	assert.Empty(t, p.detectOverflow(tables))
+
+	tables[0].tableFulls = 8
+	evts := p.detectOverflow(tables)
+	assert.Len(t, evts, 1)
+	assert.Equal(t, NeighTableOverflow, evts[0].Type)
+	assert.Contains(t, evts[0].Labels, probe.Label{Name: "overflows", Value: "3"})
+}
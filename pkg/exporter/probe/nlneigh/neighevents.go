@@ -0,0 +1,322 @@
+package nlneigh
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	NeighFailed        probe.EventType = "NeighFailed"
+	NeighTableOverflow probe.EventType = "NeighTableOverflow"
+
+	defaultTableInterval = 10
+	// interval of refreshing gateways of a netns
+	gatewayRefreshInterval = time.Minute
+)
+
+func init() {
+	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+}
+
+type eventArgs struct {
+	PeerCIDRs []string `mapstructure:"peerCIDRs" description:"report failed neighbours in these cidrs besides gateways, e.g. node or pod cidrs"`
+	Interval  int      `mapstructure:"interval" description:"seconds between checks of neighbour table overflow, default 10"`
+
+	nettop.NamespaceArgs `mapstructure:",squash"`
+}
+
+func eventProbeCreator(sink chan<- *probe.Event, args eventArgs) (probe.EventProbe, error) {
+	p := &neighEventProbe{
+		sink:     sink,
+		entities: args.Filter(),
+		interval: time.Duration(args.Interval) * time.Second,
+	}
+	if args.Interval < 0 {
+		return nil, fmt.Errorf("interval of %s should not be negative", probeName)
+	}
+	if args.Interval == 0 {
+		p.interval = defaultTableInterval * time.Second
+	}
+	for _, c := range args.PeerCIDRs {
+		_, cidr, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer cidr %q: %w", c, err)
+		}
+		p.peers = append(p.peers, cidr)
+	}
+	return probe.NewEventProbe(probeName, p), nil
+}
+
+type neighEventProbe struct {
+	sink     chan<- *probe.Event
+	entities *nettop.EntityFilter
+	peers    []*net.IPNet
+	interval time.Duration
+
+	lock    sync.Mutex
+	workers map[int]chan struct{}
+	cancel  func()
+	done    chan struct{}
+	// table fulls of neighbour tables by family at the last check
+	tableFulls map[string]uint64
+}
+
+func (p *neighEventProbe) Start(_ context.Context) error {
+	events, cancel := nettop.SubscribeNetnsEvents()
+	p.workers = make(map[int]chan struct{})
+	p.tableFulls = make(map[string]uint64)
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		p.checkTables()
+		for {
+			select {
+			case <-ticker.C:
+				p.checkTables()
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				switch ev.Type {
+				case nettop.NetnsAdd:
+					p.attach(ev.Entity)
+				case nettop.NetnsRemove:
+					p.detach(ev.Entity.GetNetns())
+				}
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *neighEventProbe) Stop(_ context.Context) error {
+	close(p.done)
+	p.cancel()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for nsinum, ch := range p.workers {
+		close(ch)
+		delete(p.workers, nsinum)
+	}
+	return nil
+}
+
+func (p *neighEventProbe) attach(et *nettop.Entity) {
+	nsinum := et.GetNetns()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.workers[nsinum]; ok || !p.entities.Match(et) {
+		return
+	}
+
+	nsHandle, err := et.OpenNsHandle()
+	if err != nil {
+		log.Infof("%s: failed get netns fd of %d, skip netns, err: %v", probeName, nsinum, err)
+		return
+	}
+
+	stop := make(chan struct{})
+	p.workers[nsinum] = stop
+	go func() {
+		if err := p.watch(stop, nsHandle, nsinum); err != nil {
+			log.Infof("%s: failed watch neighbours of netns %d, err: %v", probeName, nsinum, err)
+			p.lock.Lock()
+			if p.workers[nsinum] == stop {
+				delete(p.workers, nsinum)
+			}
+			p.lock.Unlock()
+		}
+	}()
+	log.Infof("%s: start worker of netns %d", probeName, nsinum)
+}
+
+func (p *neighEventProbe) detach(nsinum int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if ch, ok := p.workers[nsinum]; ok {
+		close(ch)
+		delete(p.workers, nsinum)
+		log.Infof("%s: stop worker of netns %d", probeName, nsinum)
+	}
+}
+
+// watch reports neighbours of gateways or peers going FAILED in the netns until stop is closed.
+func (p *neighEventProbe) watch(stop chan struct{}, nsHandle netns.NsHandle, nsinum int) error {
+	defer nsHandle.Close()
+	h, err := netlink.NewHandleAt(nsHandle)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	updates := make(chan netlink.NeighUpdate, 1024)
+	errCh := make(chan error, 1)
+	err = netlink.NeighSubscribeWithOptions(updates, stop, netlink.NeighSubscribeOptions{
+		Namespace: &nsHandle,
+		ErrorCallback: func(err error) {
+			select {
+			case errCh <- err:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	gateways := &gatewayCache{handle: h}
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-errCh:
+			return err
+		case u, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if u.Type != unix.RTM_NEWNEIGH || u.State&netlink.NUD_FAILED == 0 || u.IP == nil {
+				continue
+			}
+			role := p.neighRole(u.IP, gateways)
+			if role == "" {
+				continue
+			}
+			p.sink <- newFailedEvent(nsinum, &u.Neigh, role, linkName(h, u.LinkIndex))
+		}
+	}
+}
+
+// neighRole returns gateway or peer if ip is one of them, otherwise empty string.
+func (p *neighEventProbe) neighRole(ip net.IP, gateways *gatewayCache) string {
+	if gateways.contains(ip) {
+		return "gateway"
+	}
+	for _, c := range p.peers {
+		if c.Contains(ip) {
+			return "peer"
+		}
+	}
+	return ""
+}
+
+// gatewayCache caches next hops of routes in a netns.
+type gatewayCache struct {
+	handle    *netlink.Handle
+	gateways  map[string]bool
+	refreshed time.Time
+}
+
+func (c *gatewayCache) contains(ip net.IP) bool {
+	if c.handle != nil && time.Since(c.refreshed) > gatewayRefreshInterval {
+		routes, err := c.handle.RouteList(nil, netlink.FAMILY_ALL)
+		if err != nil {
+			log.Debugf("%s: failed list routes: %v", probeName, err)
+		} else {
+			c.gateways = routeGateways(routes)
+		}
+		c.refreshed = time.Now()
+	}
+	return c.gateways[ip.String()]
+}
+
+func routeGateways(routes []netlink.Route) map[string]bool {
+	ret := make(map[string]bool)
+	for _, r := range routes {
+		if r.Gw != nil {
+			ret[r.Gw.String()] = true
+		}
+		for _, nh := range r.MultiPath {
+			if nh.Gw != nil {
+				ret[nh.Gw.String()] = true
+			}
+		}
+	}
+	return ret
+}
+
+func linkName(h *netlink.Handle, index int) string {
+	link, err := h.LinkByIndex(index)
+	if err != nil {
+		return ""
+	}
+	return link.Attrs().Name
+}
+
+func newFailedEvent(nsinum int, n *netlink.Neigh, role, dev string) *probe.Event {
+	labels := probe.LegacyEventLabels(uint32(nsinum))
+	labels = append(labels,
+		probe.Label{Name: "ip", Value: n.IP.String()},
+		probe.Label{Name: "device", Value: dev},
+		probe.Label{Name: "role", Value: role},
+	)
+	return &probe.Event{
+		Timestamp: time.Now().UnixNano(),
+		Type:      NeighFailed,
+		Labels:    labels,
+		Message:   fmt.Sprintf("neighbour %s of %s on %s failed to resolve", n.IP, role, dev),
+		Version:   probe.EventSchemaVersion,
+		Netns:     uint32(nsinum),
+	}
+}
+
+func (p *neighEventProbe) checkTables() {
+	tables, err := dumpNeighTables()
+	if err != nil {
+		log.Debugf("%s: failed check neighbour tables: %v", probeName, err)
+		return
+	}
+	for _, evt := range p.detectOverflow(tables) {
+		p.sink <- evt
+	}
+}
+
+// detectOverflow returns events of tables whose table fulls increased since the last check.
+func (p *neighEventProbe) detectOverflow(tables []*neighTable) []*probe.Event {
+	var ret []*probe.Event
+	for _, t := range tables {
+		last, ok := p.tableFulls[t.family]
+		p.tableFulls[t.family] = t.tableFulls
+		if !ok || t.tableFulls <= last {
+			continue
+		}
+		ret = append(ret, newOverflowEvent(t, t.tableFulls-last))
+	}
+	return ret
+}
+
+func newOverflowEvent(t *neighTable, overflows uint64) *probe.Event {
+	// tables are shared by all netns, the event belongs to the node
+	labels := probe.LegacyEventLabels(0)
+	labels = append(labels,
+		probe.Label{Name: "family", Value: t.family},
+		probe.Label{Name: "entries", Value: strconv.FormatUint(uint64(t.entries), 10)},
+		probe.Label{Name: "gc_thresh3", Value: strconv.FormatUint(uint64(t.thresh3), 10)},
+		probe.Label{Name: "overflows", Value: strconv.FormatUint(overflows, 10)},
+	)
+	return &probe.Event{
+		Timestamp: time.Now().UnixNano(),
+		Type:      NeighTableOverflow,
+		Labels:    labels,
+		Message: fmt.Sprintf("neighbor table %s overflow %d times, entries %d, gc_thresh3 %d",
+			t.name, overflows, t.entries, t.thresh3),
+		Version: probe.EventSchemaVersion,
+	}
+}
@@ -0,0 +1,194 @@
+package nlneigh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
+
+var (
+	probeName = "neigh"
+
+	Entries      = "entries"
+	TableEntries = "tableentries"
+	GCThresh1    = "gcthresh1"
+	GCThresh2    = "gcthresh2"
+	GCThresh3    = "gcthresh3"
+	UsageRatio   = "usageratio"
+	ResFailed    = "resolvefailed"
+	ForcedGCRuns = "forcedgcruns"
+	TableFulls   = "tablefulls"
+
+	tableMetrics = []probe.SingleMetricsOpts{
+		{Name: TableEntries, Help: "The current number of entries of the neighbour table shared by all netns.", ValueType: prometheus.GaugeValue},
+		{Name: GCThresh1, Help: "The gc_thresh1 of the neighbour table, entries are not garbage collected below it.", ValueType: prometheus.GaugeValue},
+		{Name: GCThresh2, Help: "The gc_thresh2 of the neighbour table, entries above it are garbage collected after 5 seconds.", ValueType: prometheus.GaugeValue},
+		{Name: GCThresh3, Help: "The gc_thresh3 of the neighbour table, the hard limit of entries.", ValueType: prometheus.GaugeValue},
+		{Name: UsageRatio, Help: "The ratio of current entries to gc_thresh3 of the neighbour table.", ValueType: prometheus.GaugeValue},
+		{Name: ResFailed, Help: "The total number of failed neighbour resolutions.", ValueType: prometheus.CounterValue},
+		{Name: ForcedGCRuns, Help: "The total number of forced garbage collections when the table exceeds gc_thresh2.", ValueType: prometheus.CounterValue},
+		{Name: TableFulls, Help: "The total number of entries failed to allocate because the neighbour table overflows.", ValueType: prometheus.CounterValue},
+	}
+
+	// neighbour states, see NUD_* in linux/neighbour.h
+	neighStates = []struct {
+		state int
+		name  string
+	}{
+		{netlink.NUD_INCOMPLETE, "INCOMPLETE"},
+		{netlink.NUD_REACHABLE, "REACHABLE"},
+		{netlink.NUD_STALE, "STALE"},
+		{netlink.NUD_DELAY, "DELAY"},
+		{netlink.NUD_PROBE, "PROBE"},
+		{netlink.NUD_FAILED, "FAILED"},
+		{netlink.NUD_NOARP, "NOARP"},
+		{netlink.NUD_PERMANENT, "PERMANENT"},
+	}
+)
+
+func init() {
+	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
+}
+
+func metricsProbeCreator() (probe.MetricsProbe, error) {
+	p := &neighMetricsProbe{}
+
+	opts := probe.BatchMetricsOpts{
+		Namespace:      probe.MetricsNamespace,
+		Subsystem:      probeName,
+		VariableLabels: probe.StandardMetricsLabels,
+		SingleMetricsOpts: []probe.SingleMetricsOpts{
+			{
+				Name:           Entries,
+				Help:           "The current number of neighbour entries of the netns by family and state.",
+				VariableLabels: []string{"family", "state"},
+				ValueType:      prometheus.GaugeValue,
+			},
+		},
+	}
+	for _, m := range tableMetrics {
+		m.VariableLabels = []string{"family"}
+		opts.SingleMetricsOpts = append(opts.SingleMetricsOpts, m)
+	}
+
+	batchMetrics := probe.NewBatchMetrics(opts, p.collectOnce)
+	return probe.NewMetricsProbe(probeName, p, batchMetrics), nil
+}
+
+type neighMetricsProbe struct{}
+
+func (p *neighMetricsProbe) Start(_ context.Context) error {
+	return nil
+}
+
+func (p *neighMetricsProbe) Stop(_ context.Context) error {
+	return nil
+}
+
+func (p *neighMetricsProbe) collectOnce(emit probe.Emit) error {
+	if err := p.collectTables(emit); err != nil {
+		log.Warnf("%s: failed collect neighbour tables: %v", probeName, err)
+	}
+
+	var errs []string
+	for _, et := range nettop.GetAllUniqueNetnsEntity() {
+		neighs, err := listNeighs(et)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("netns %d: %v", et.GetNetns(), err))
+			continue
+		}
+		labels := probe.BuildStandardMetricsLabelValues(et)
+		for key, count := range countNeighsByState(neighs) {
+			emit(Entries, append(append([]string{}, labels...), key.family, key.state), float64(count))
+		}
+	}
+	// a pod may be deleted during collection, errors of single netns are not fatal
+	if len(errs) > 0 {
+		log.Debugf("%s: failed list neighbours, %s", probeName, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (p *neighMetricsProbe) collectTables(emit probe.Emit) error {
+	et, err := hostEntity()
+	if err != nil {
+		return err
+	}
+	tables, err := dumpNeighTables()
+	if err != nil {
+		return err
+	}
+	labels := probe.BuildStandardMetricsLabelValues(et)
+	for _, t := range tables {
+		values := append(append([]string{}, labels...), t.family)
+		emit(TableEntries, values, float64(t.entries))
+		emit(GCThresh1, values, float64(t.thresh1))
+		emit(GCThresh2, values, float64(t.thresh2))
+		emit(GCThresh3, values, float64(t.thresh3))
+		if t.thresh3 > 0 {
+			emit(UsageRatio, values, float64(t.entries)/float64(t.thresh3))
+		}
+		emit(ResFailed, values, float64(t.resFailed))
+		emit(ForcedGCRuns, values, float64(t.forcedGCRuns))
+		emit(TableFulls, values, float64(t.tableFulls))
+	}
+	return nil
+}
+
+// listNeighs lists ipv4 and ipv6 neighbours in the netns of et.
+func listNeighs(et *nettop.Entity) ([]netlink.Neigh, error) {
+	nsHandle, err := et.OpenNsHandle()
+	if err != nil {
+		return nil, err
+	}
+	defer nsHandle.Close()
+
+	h, err := netlink.NewHandleAt(nsHandle)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close()
+
+	v4, err := h.NeighList(0, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, err
+	}
+	// ipv6 may be disabled
+	v6, err := h.NeighList(0, netlink.FAMILY_V6)
+	if err != nil {
+		log.Debugf("%s: failed list ipv6 neighbours of netns %d: %v", probeName, et.GetNetns(), err)
+	}
+	return append(v4, v6...), nil
+}
+
+type stateKey struct {
+	family string
+	state  string
+}
+
+func countNeighsByState(neighs []netlink.Neigh) map[stateKey]int {
+	ret := make(map[stateKey]int)
+	for _, n := range neighs {
+		family := familyName(n.Family)
+		if family == "" {
+			continue
+		}
+		ret[stateKey{family: family, state: neighState(n.State)}]++
+	}
+	return ret
+}
+
+func neighState(state int) string {
+	for _, s := range neighStates {
+		if state&s.state != 0 {
+			return s.name
+		}
+	}
+	return "NONE"
+}
@@ -0,0 +1,159 @@
+package nlneigh
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// attributes of RTM_GETNEIGHTBL, see NDTA_* in linux/neighbour.h
+const (
+	ndtaName    = 1
+	ndtaThresh1 = 2
+	ndtaThresh2 = 3
+	ndtaThresh3 = 4
+	ndtaConfig  = 5
+	ndtaStats   = 7
+
+	sizeofNdtMsg = 4
+	// offset of ndtc_entries in struct ndt_config
+	ndtConfigEntries = 4
+	// offsets of counters in struct ndt_stats
+	ndtStatsResFailed    = 24
+	ndtStatsForcedGCRuns = 72
+	ndtStatsTableFulls   = 80
+)
+
+// neighTable is a neighbour table of the kernel, e.g. arp_cache or ndisc_cache. Tables are
+// shared by all netns, so are the thresholds and counters.
+type neighTable struct {
+	family       string
+	name         string
+	entries      uint32
+	thresh1      uint32
+	thresh2      uint32
+	thresh3      uint32
+	resFailed    uint64
+	forcedGCRuns uint64
+	// tableFulls is the number of times entries can not be allocated because the table is
+	// full, the kernel logs "neighbour table overflow" at the same time.
+	tableFulls uint64
+}
+
+// parseNeighTable parses a message of RTM_GETNEIGHTBL, it returns nil for messages carrying
+// per device parameters only.
+func parseNeighTable(data []byte) (*neighTable, error) {
+	if len(data) < sizeofNdtMsg {
+		return nil, fmt.Errorf("short ndtmsg of %d bytes", len(data))
+	}
+	t := &neighTable{family: familyName(int(data[0]))}
+	ad, err := netlink.NewAttributeDecoder(data[sizeofNdtMsg:])
+	if err != nil {
+		return nil, err
+	}
+	hasConfig := false
+	for ad.Next() {
+		switch ad.Type() {
+		case ndtaName:
+			t.name = ad.String()
+		case ndtaThresh1:
+			t.thresh1 = ad.Uint32()
+		case ndtaThresh2:
+			t.thresh2 = ad.Uint32()
+		case ndtaThresh3:
+			t.thresh3 = ad.Uint32()
+		case ndtaConfig:
+			b := ad.Bytes()
+			if len(b) >= ndtConfigEntries+4 {
+				t.entries = binary.NativeEndian.Uint32(b[ndtConfigEntries:])
+				hasConfig = true
+			}
+		case ndtaStats:
+			b := ad.Bytes()
+			if len(b) >= ndtStatsForcedGCRuns+8 {
+				t.resFailed = binary.NativeEndian.Uint64(b[ndtStatsResFailed:])
+				t.forcedGCRuns = binary.NativeEndian.Uint64(b[ndtStatsForcedGCRuns:])
+			}
+			// table_fulls is added in kernel 4.10
+			if len(b) >= ndtStatsTableFulls+8 {
+				t.tableFulls = binary.NativeEndian.Uint64(b[ndtStatsTableFulls:])
+			}
+		}
+	}
+	if err := ad.Err(); err != nil {
+		return nil, err
+	}
+	if !hasConfig {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// dumpNeighTables dumps arp and ndisc tables in the host netns.
+func dumpNeighTables() ([]*neighTable, error) {
+	et, err := hostEntity()
+	if err != nil {
+		return nil, err
+	}
+	nsHandle, err := et.OpenNsHandle()
+	if err != nil {
+		return nil, err
+	}
+	defer nsHandle.Close()
+
+	conn, err := netlink.Dial(unix.NETLINK_ROUTE, &netlink.Config{NetNS: int(nsHandle)})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	msgs, err := conn.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  unix.RTM_GETNEIGHTBL,
+			Flags: netlink.Request | netlink.Dump,
+		},
+		Data: make([]byte, sizeofNdtMsg),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed dump neighbour tables: %w", err)
+	}
+
+	var ret []*neighTable
+	for _, m := range msgs {
+		t, err := parseNeighTable(m.Data)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil || t.family == "" {
+			continue
+		}
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
+
+// hostEntity returns the entity of host netns, which is nil before nettop is initialized.
+func hostEntity() (*nettop.Entity, error) {
+	et, err := nettop.GetHostNetworkEntity()
+	if err != nil {
+		return nil, err
+	}
+	if et == nil {
+		return nil, fmt.Errorf("host netns not found")
+	}
+	return et, nil
+}
+
+func familyName(family int) string {
+	switch family {
+	case unix.AF_INET:
+		return "ipv4"
+	case unix.AF_INET6:
+		return "ipv6"
+	default:
+		return ""
+	}
+}
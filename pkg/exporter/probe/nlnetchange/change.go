@@ -0,0 +1,177 @@
+package nlnetchange
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	LinkAdd       probe.EventType = "LinkAdd"
+	LinkDelete    probe.EventType = "LinkDelete"
+	LinkUp        probe.EventType = "LinkUp"
+	LinkDown      probe.EventType = "LinkDown"
+	LinkMTUChange probe.EventType = "LinkMTUChange"
+	AddrAdd       probe.EventType = "AddrAdd"
+	AddrDelete    probe.EventType = "AddrDelete"
+	RouteAdd      probe.EventType = "RouteAdd"
+	RouteDelete   probe.EventType = "RouteDelete"
+	RouteReplace  probe.EventType = "RouteReplace"
+)
+
+// change is a change of links, addresses or routes in a netns.
+type change struct {
+	typ   probe.EventType
+	iface string
+	// key identifies the object changed, changes of the same key are debounced together
+	key     string
+	labels  []probe.Label
+	message string
+}
+
+type linkState struct {
+	name string
+	up   bool
+	mtu  int
+}
+
+func newLinkState(attrs *netlink.LinkAttrs) *linkState {
+	return &linkState{
+		name: attrs.Name,
+		// admin up with carrier
+		up:  attrs.RawFlags&unix.IFF_UP != 0 && attrs.RawFlags&unix.IFF_RUNNING != 0,
+		mtu: attrs.MTU,
+	}
+}
+
+// tracker converts netlink updates of a netns to changes, it keeps states of links to find
+// out what changed in link updates and names of interfaces of address and route updates.
+type tracker struct {
+	links map[int]*linkState
+}
+
+func newTracker(links []netlink.Link) *tracker {
+	t := &tracker{links: make(map[int]*linkState)}
+	for _, l := range links {
+		t.links[l.Attrs().Index] = newLinkState(l.Attrs())
+	}
+	return t
+}
+
+func (t *tracker) ifname(index int) string {
+	if l, ok := t.links[index]; ok {
+		return l.name
+	}
+	return strconv.Itoa(index)
+}
+
+func (t *tracker) linkChanges(u netlink.LinkUpdate) []*change {
+	attrs := u.Attrs()
+	if u.Header.Type == unix.RTM_DELLINK {
+		delete(t.links, attrs.Index)
+		return []*change{{
+			typ:     LinkDelete,
+			iface:   attrs.Name,
+			key:     "link/" + attrs.Name,
+			message: fmt.Sprintf("interface %s deleted", attrs.Name),
+		}}
+	}
+
+	cur := newLinkState(attrs)
+	prev, ok := t.links[attrs.Index]
+	t.links[attrs.Index] = cur
+	if !ok {
+		return []*change{{
+			typ:     LinkAdd,
+			iface:   cur.name,
+			key:     "link/" + cur.name,
+			labels:  []probe.Label{{Name: "mtu", Value: strconv.Itoa(cur.mtu)}},
+			message: fmt.Sprintf("interface %s added", cur.name),
+		}}
+	}
+
+	var ret []*change
+	if prev.up != cur.up {
+		c := &change{typ: LinkDown, iface: cur.name, key: "link/" + cur.name, message: fmt.Sprintf("interface %s down", cur.name)}
+		if cur.up {
+			c.typ = LinkUp
+			c.message = fmt.Sprintf("interface %s up", cur.name)
+		}
+		ret = append(ret, c)
+	}
+	if prev.mtu != cur.mtu {
+		ret = append(ret, &change{
+			typ:   LinkMTUChange,
+			iface: cur.name,
+			key:   "mtu/" + cur.name,
+			labels: []probe.Label{
+				{Name: "mtu", Value: strconv.Itoa(cur.mtu)},
+				{Name: "old_mtu", Value: strconv.Itoa(prev.mtu)},
+			},
+			message: fmt.Sprintf("mtu of interface %s changed from %d to %d", cur.name, prev.mtu, cur.mtu),
+		})
+	}
+	return ret
+}
+
+func (t *tracker) addrChange(u netlink.AddrUpdate) *change {
+	iface := t.ifname(u.LinkIndex)
+	addr := u.LinkAddress.String()
+	c := &change{
+		typ:     AddrAdd,
+		iface:   iface,
+		key:     "addr/" + iface + "/" + addr,
+		labels:  []probe.Label{{Name: "address", Value: addr}},
+		message: fmt.Sprintf("address %s added to %s", addr, iface),
+	}
+	if !u.NewAddr {
+		c.typ = AddrDelete
+		c.message = fmt.Sprintf("address %s removed from %s", addr, iface)
+	}
+	return c
+}
+
+// routeChange returns nil for routes of local table, which change with addresses.
+func (t *tracker) routeChange(u netlink.RouteUpdate) *change {
+	if u.Table == unix.RT_TABLE_LOCAL {
+		return nil
+	}
+	dst := "default"
+	if u.Dst != nil {
+		dst = u.Dst.String()
+	}
+	gw := ""
+	if u.Gw != nil {
+		gw = u.Gw.String()
+	}
+	iface := ""
+	if u.LinkIndex > 0 {
+		iface = t.ifname(u.LinkIndex)
+	}
+	table := strconv.Itoa(u.Table)
+
+	c := &change{
+		iface: iface,
+		key:   "route/" + table + "/" + dst,
+		labels: []probe.Label{
+			{Name: "dst", Value: dst},
+			{Name: "gateway", Value: gw},
+			{Name: "table", Value: table},
+		},
+	}
+	switch {
+	case u.Type == unix.RTM_DELROUTE:
+		c.typ = RouteDelete
+		c.message = fmt.Sprintf("route %s via %s dev %s table %s deleted", dst, gw, iface, table)
+	case u.NlFlags&unix.NLM_F_REPLACE != 0:
+		c.typ = RouteReplace
+		c.message = fmt.Sprintf("route %s replaced with via %s dev %s table %s", dst, gw, iface, table)
+	default:
+		c.typ = RouteAdd
+		c.message = fmt.Sprintf("route %s via %s dev %s table %s added", dst, gw, iface, table)
+	}
+	return c
+}
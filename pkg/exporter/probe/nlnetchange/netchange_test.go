@@ -0,0 +1,101 @@
+package nlnetchange
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func linkUpdate(msgType uint16, index int, name string, flags uint32, mtu int) netlink.LinkUpdate {
+	u := netlink.LinkUpdate{Link: &netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: index, Name: name, RawFlags: flags, MTU: mtu}}}
+	u.Header.Type = msgType
+	return u
+}
+
+func changeTypes(changes []*change) []probe.EventType {
+	var ret []probe.EventType
+	for _, c := range changes {
+		ret = append(ret, c.typ)
+	}
+	return ret
+}
+
+func TestLinkChanges(t *testing.T) {
+	up := uint32(unix.IFF_UP | unix.IFF_RUNNING)
+	tr := newTracker([]netlink.Link{&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 2, Name: "eth0", RawFlags: up, MTU: 1500}}})
+
+	assert.Empty(t, tr.linkChanges(linkUpdate(unix.RTM_NEWLINK, 2, "eth0", up, 1500)))
+	// carrier lost
+	assert.Equal(t, []probe.EventType{LinkDown}, changeTypes(tr.linkChanges(linkUpdate(unix.RTM_NEWLINK, 2, "eth0", unix.IFF_UP, 1500))))
+	changes := tr.linkChanges(linkUpdate(unix.RTM_NEWLINK, 2, "eth0", up, 9000))
+	assert.Equal(t, []probe.EventType{LinkUp, LinkMTUChange}, changeTypes(changes))
+	assert.Equal(t, []probe.Label{{Name: "mtu", Value: "9000"}, {Name: "old_mtu", Value: "1500"}}, changes[1].labels)
+
+	assert.Equal(t, []probe.EventType{LinkAdd}, changeTypes(tr.linkChanges(linkUpdate(unix.RTM_NEWLINK, 3, "veth1", 0, 1500))))
+	assert.Equal(t, []probe.EventType{LinkDelete}, changeTypes(tr.linkChanges(linkUpdate(unix.RTM_DELLINK, 3, "veth1", 0, 1500))))
+	assert.NotContains(t, tr.links, 3)
+}
+
+func TestAddrAndRouteChange(t *testing.T) {
+	tr := newTracker([]netlink.Link{&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: 2, Name: "eth0"}}})
+
+	_, ipnet, _ := net.ParseCIDR("10.0.0.0/24")
+	c := tr.addrChange(netlink.AddrUpdate{LinkIndex: 2, LinkAddress: net.IPNet{IP: net.ParseIP("10.0.0.2"), Mask: ipnet.Mask}})
+	assert.Equal(t, AddrDelete, c.typ)
+	assert.Equal(t, "eth0", c.iface)
+	assert.Equal(t, []probe.Label{{Name: "address", Value: "10.0.0.2/24"}}, c.labels)
+
+	assert.Nil(t, tr.routeChange(netlink.RouteUpdate{Type: unix.RTM_NEWROUTE, Route: netlink.Route{Table: unix.RT_TABLE_LOCAL}}))
+
+	c = tr.routeChange(netlink.RouteUpdate{Type: unix.RTM_NEWROUTE, Route: netlink.Route{Table: unix.RT_TABLE_MAIN, LinkIndex: 2, Gw: net.ParseIP("10.0.0.1")}})
+	assert.Equal(t, RouteAdd, c.typ)
+	assert.Equal(t, []probe.Label{{Name: "dst", Value: "default"}, {Name: "gateway", Value: "10.0.0.1"}, {Name: "table", Value: "254"}}, c.labels)
+
+	c = tr.routeChange(netlink.RouteUpdate{Type: unix.RTM_NEWROUTE, NlFlags: unix.NLM_F_REPLACE, Route: netlink.Route{Table: unix.RT_TABLE_MAIN, Dst: ipnet}})
+	assert.Equal(t, RouteReplace, c.typ)
+	assert.Equal(t, "", c.iface)
+	assert.Equal(t, "route/254/10.0.0.0/24", c.key)
+
+	c = tr.routeChange(netlink.RouteUpdate{Type: unix.RTM_DELROUTE, Route: netlink.Route{Table: unix.RT_TABLE_MAIN, Dst: ipnet}})
+	assert.Equal(t, RouteDelete, c.typ)
+}
+
+func TestDebouncer(t *testing.T) {
+	d := newDebouncer(5 * time.Second)
+	now := time.Now()
+	down := &change{typ: LinkDown, iface: "eth0", key: "link/eth0"}
+	up := &change{typ: LinkUp, iface: "eth0", key: "link/eth0"}
+
+	d.add(1, down, now)
+	d.add(1, up, now.Add(time.Second))
+	d.add(1, down, now.Add(2*time.Second))
+	d.add(2, up, now)
+	d.add(1, up, now.Add(3*time.Second))
+
+	evts := d.flush(now.Add(6 * time.Second))
+	assert.Len(t, evts, 1)
+	assert.Equal(t, LinkUp, evts[0].Type)
+	assert.Equal(t, uint32(2), evts[0].Netns)
+
+	evts = d.flush(now.Add(8 * time.Second))
+	assert.Len(t, evts, 1)
+	assert.Equal(t, LinkUp, evts[0].Type)
+	assert.Contains(t, evts[0].Labels, probe.Label{Name: "changes", Value: "4"})
+	assert.Empty(t, d.pending)
+}
+
+func TestCountChanges(t *testing.T) {
+	p := &netChangeMetricsProbe{counts: make(map[counterKey]uint64)}
+	p.onChange(1, &change{typ: LinkDown, iface: "eth0"})
+	p.onChange(1, &change{typ: LinkDown, iface: "eth0"})
+	p.onChange(2, &change{typ: RouteAdd, iface: "eth0"})
+	assert.Equal(t, uint64(2), p.counts[counterKey{nsinum: 1, iface: "eth0", typ: LinkDown}])
+
+	p.onDetach(1)
+	assert.Equal(t, map[counterKey]uint64{{nsinum: 2, iface: "eth0", typ: RouteAdd}: 1}, p.counts)
+}
@@ -0,0 +1,156 @@
+package nlnetchange
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+)
+
+var probeName = "netchange"
+
+func init() {
+	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+}
+
+type eventArgs struct {
+	Debounce int `mapstructure:"debounce" description:"seconds to wait for an interface, address or route to settle before reporting its last change with the number of changes, disabled by default"`
+
+	nettop.NamespaceArgs `mapstructure:",squash"`
+}
+
+func eventProbeCreator(sink chan<- *probe.Event, args eventArgs) (probe.EventProbe, error) {
+	if args.Debounce < 0 {
+		return nil, fmt.Errorf("debounce of %s should not be negative", probeName)
+	}
+	p := &netChangeEventProbe{sink: sink}
+	if args.Debounce > 0 {
+		p.debouncer = newDebouncer(time.Duration(args.Debounce) * time.Second)
+	}
+	p.watcher = newWatcher(args.Filter(), p.onChange, nil)
+	return probe.NewEventProbe(probeName, p), nil
+}
+
+type netChangeEventProbe struct {
+	sink      chan<- *probe.Event
+	watcher   *watcher
+	debouncer *debouncer
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func (p *netChangeEventProbe) Start(_ context.Context) error {
+	p.watcher.start()
+	if p.debouncer == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		// check pending changes several times a window, changes are delayed at most a tick
+		ticker := time.NewTicker(p.debouncer.window / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, evt := range p.debouncer.flush(now) {
+					p.sink <- evt
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *netChangeEventProbe) Stop(_ context.Context) error {
+	p.watcher.stop()
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+	return nil
+}
+
+func (p *netChangeEventProbe) onChange(nsinum int, c *change) {
+	if p.debouncer != nil {
+		p.debouncer.add(nsinum, c, time.Now())
+		return
+	}
+	p.sink <- newChangeEvent(nsinum, c, 1)
+}
+
+func newChangeEvent(nsinum int, c *change, changes int) *probe.Event {
+	labels := probe.LegacyEventLabels(uint32(nsinum))
+	labels = append(labels, probe.Label{Name: "interface", Value: c.iface})
+	labels = append(labels, c.labels...)
+	msg := c.message
+	if changes > 1 {
+		labels = append(labels, probe.Label{Name: "changes", Value: strconv.Itoa(changes)})
+		msg = fmt.Sprintf("%s, %d changes", msg, changes)
+	}
+	return &probe.Event{
+		Timestamp: time.Now().UnixNano(),
+		Type:      c.typ,
+		Labels:    labels,
+		Message:   msg,
+		Version:   probe.EventSchemaVersion,
+		Netns:     uint32(nsinum),
+	}
+}
+
+type pendingChange struct {
+	nsinum  int
+	last    *change
+	changes int
+	updated time.Time
+}
+
+// debouncer holds changes of the same object until no more change happens in the window,
+// so that a flapping interface is reported once with its final state.
+type debouncer struct {
+	window  time.Duration
+	lock    sync.Mutex
+	pending map[string]*pendingChange
+}
+
+func newDebouncer(window time.Duration) *debouncer {
+	return &debouncer{window: window, pending: make(map[string]*pendingChange)}
+}
+
+func (d *debouncer) add(nsinum int, c *change, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	key := strconv.Itoa(nsinum) + "/" + c.key
+	p, ok := d.pending[key]
+	if !ok {
+		p = &pendingChange{nsinum: nsinum}
+		d.pending[key] = p
+	}
+	p.last = c
+	p.changes++
+	p.updated = now
+}
+
+// flush returns events of objects which have settled for the window.
+func (d *debouncer) flush(now time.Time) []*probe.Event {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var ret []*probe.Event
+	for key, p := range d.pending {
+		if now.Sub(p.updated) < d.window {
+			continue
+		}
+		ret = append(ret, newChangeEvent(p.nsinum, p.last, p.changes))
+		delete(d.pending, key)
+	}
+	return ret
+}
@@ -0,0 +1,94 @@
+package nlnetchange
+
+import (
+	"context"
+	"sync"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var Changes = "changes"
+
+func init() {
+	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
+}
+
+type metricsArgs struct {
+	nettop.NamespaceArgs `mapstructure:",squash"`
+}
+
+func metricsProbeCreator(args metricsArgs) (probe.MetricsProbe, error) {
+	p := &netChangeMetricsProbe{counts: make(map[counterKey]uint64)}
+	p.watcher = newWatcher(args.Filter(), p.onChange, p.onDetach)
+
+	opts := probe.BatchMetricsOpts{
+		Namespace:      probe.MetricsNamespace,
+		Subsystem:      probeName,
+		VariableLabels: probe.StandardMetricsLabels,
+		SingleMetricsOpts: []probe.SingleMetricsOpts{
+			{
+				Name:           Changes,
+				Help:           "The total number of link, address and route changes by interface and type.",
+				VariableLabels: []string{"interface", "type"},
+				ValueType:      prometheus.CounterValue,
+			},
+		},
+	}
+	batchMetrics := probe.NewBatchMetrics(opts, p.collectOnce)
+	return probe.NewMetricsProbe(probeName, p, batchMetrics), nil
+}
+
+type counterKey struct {
+	nsinum int
+	iface  string
+	typ    probe.EventType
+}
+
+type netChangeMetricsProbe struct {
+	watcher *watcher
+	lock    sync.Mutex
+	counts  map[counterKey]uint64
+}
+
+func (p *netChangeMetricsProbe) Start(_ context.Context) error {
+	p.watcher.start()
+	return nil
+}
+
+func (p *netChangeMetricsProbe) Stop(_ context.Context) error {
+	p.watcher.stop()
+	return nil
+}
+
+func (p *netChangeMetricsProbe) onChange(nsinum int, c *change) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.counts[counterKey{nsinum: nsinum, iface: c.iface, typ: c.typ}]++
+}
+
+// onDetach drops counters of the removed netns.
+func (p *netChangeMetricsProbe) onDetach(nsinum int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for k := range p.counts {
+		if k.nsinum == nsinum {
+			delete(p.counts, k)
+		}
+	}
+}
+
+func (p *netChangeMetricsProbe) collectOnce(emit probe.Emit) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for k, v := range p.counts {
+		et, err := nettop.GetEntityByNetns(k.nsinum)
+		if err != nil || et == nil {
+			continue
+		}
+		labels := append(probe.BuildStandardMetricsLabelValues(et), k.iface, string(k.typ))
+		emit(Changes, labels, float64(v))
+	}
+	return nil
+}
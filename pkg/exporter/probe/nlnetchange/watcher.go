@@ -0,0 +1,181 @@
+package nlnetchange
+
+import (
+	"sync"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+const updateChanSize = 1024
+
+// watcher subscribes link, address and route updates in netns of pods and host, both event
+// and metrics probe are built on it.
+type watcher struct {
+	entities *nettop.EntityFilter
+	onChange func(nsinum int, c *change)
+	// onDetach is called when the netns is removed, it may be nil
+	onDetach func(nsinum int)
+
+	lock    sync.Mutex
+	workers map[int]chan struct{}
+	cancel  func()
+	done    chan struct{}
+}
+
+func newWatcher(entities *nettop.EntityFilter, onChange func(nsinum int, c *change), onDetach func(nsinum int)) *watcher {
+	return &watcher{
+		entities: entities,
+		onChange: onChange,
+		onDetach: onDetach,
+	}
+}
+
+func (w *watcher) start() {
+	events, cancel := nettop.SubscribeNetnsEvents()
+	w.workers = make(map[int]chan struct{})
+	w.cancel = cancel
+	w.done = make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				switch ev.Type {
+				case nettop.NetnsAdd:
+					w.attach(ev.Entity)
+				case nettop.NetnsRemove:
+					w.detach(ev.Entity.GetNetns())
+				}
+			case <-w.done:
+				return
+			}
+		}
+	}()
+}
+
+func (w *watcher) stop() {
+	close(w.done)
+	w.cancel()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for nsinum, ch := range w.workers {
+		close(ch)
+		delete(w.workers, nsinum)
+	}
+}
+
+func (w *watcher) attach(et *nettop.Entity) {
+	nsinum := et.GetNetns()
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, ok := w.workers[nsinum]; ok || !w.entities.Match(et) {
+		return
+	}
+
+	nsHandle, err := et.OpenNsHandle()
+	if err != nil {
+		log.Infof("%s: failed get netns fd of %d, skip netns, err: %v", probeName, nsinum, err)
+		return
+	}
+
+	stop := make(chan struct{})
+	w.workers[nsinum] = stop
+	go func() {
+		if err := w.watch(stop, nsHandle, nsinum); err != nil {
+			log.Infof("%s: failed watch changes of netns %d, err: %v", probeName, nsinum, err)
+			w.lock.Lock()
+			// stop subscriptions already started
+			if w.workers[nsinum] == stop {
+				delete(w.workers, nsinum)
+				close(stop)
+			}
+			w.lock.Unlock()
+		}
+	}()
+	log.Infof("%s: start worker of netns %d", probeName, nsinum)
+}
+
+func (w *watcher) detach(nsinum int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if ch, ok := w.workers[nsinum]; ok {
+		close(ch)
+		delete(w.workers, nsinum)
+		log.Infof("%s: stop worker of netns %d", probeName, nsinum)
+	}
+	if w.onDetach != nil {
+		w.onDetach(nsinum)
+	}
+}
+
+func (w *watcher) watch(stop chan struct{}, nsHandle netns.NsHandle, nsinum int) error {
+	defer nsHandle.Close()
+
+	errCh := make(chan error, 1)
+	onError := func(err error) {
+		select {
+		case errCh <- err:
+		default:
+		}
+	}
+
+	links := make(chan netlink.LinkUpdate, updateChanSize)
+	addrs := make(chan netlink.AddrUpdate, updateChanSize)
+	routes := make(chan netlink.RouteUpdate, updateChanSize)
+	if err := netlink.LinkSubscribeWithOptions(links, stop, netlink.LinkSubscribeOptions{Namespace: &nsHandle, ErrorCallback: onError}); err != nil {
+		return err
+	}
+	if err := netlink.AddrSubscribeWithOptions(addrs, stop, netlink.AddrSubscribeOptions{Namespace: &nsHandle, ErrorCallback: onError}); err != nil {
+		return err
+	}
+	if err := netlink.RouteSubscribeWithOptions(routes, stop, netlink.RouteSubscribeOptions{Namespace: &nsHandle, ErrorCallback: onError}); err != nil {
+		return err
+	}
+
+	// initial states of links, changes during subscribing and listing may be missed
+	h, err := netlink.NewHandleAt(nsHandle)
+	if err != nil {
+		return err
+	}
+	existing, err := h.LinkList()
+	h.Close()
+	if err != nil {
+		return err
+	}
+	t := newTracker(existing)
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-errCh:
+			return err
+		case u, ok := <-links:
+			if !ok {
+				return nil
+			}
+			for _, c := range t.linkChanges(u) {
+				w.onChange(nsinum, c)
+			}
+		case u, ok := <-addrs:
+			if !ok {
+				return nil
+			}
+			w.onChange(nsinum, t.addrChange(u))
+		case u, ok := <-routes:
+			if !ok {
+				return nil
+			}
+			if c := t.routeChange(u); c != nil {
+				w.onChange(nsinum, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package nlqdisc
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/mdlayher/netlink"
+	"github.com/stretchr/testify/assert"
+)
+
+func tcMessage(t *testing.T, ifindex, handle, parent uint32, kind string, xstats []byte) netlink.Message {
+	data := make([]byte, 20)
+	binary.NativeEndian.PutUint32(data[4:8], ifindex)
+	binary.NativeEndian.PutUint32(data[8:12], handle)
+	binary.NativeEndian.PutUint32(data[12:16], parent)
+
+	basic := make([]byte, 16)
+	binary.NativeEndian.PutUint64(basic[0:8], 1500)
+	binary.NativeEndian.PutUint32(basic[8:12], 10)
+	queue := make([]byte, 20)
+	binary.NativeEndian.PutUint32(queue[8:12], 2)
+	stats2, err := netlink.MarshalAttributes([]netlink.Attribute{
+		{Type: TCAStatsBasic, Data: basic},
+		{Type: TCAStatsQueue, Data: queue},
+		{Type: TCAStatsApp, Data: xstats},
+	})
+	assert.NoError(t, err)
+
+	attrs, err := netlink.MarshalAttributes([]netlink.Attribute{
+		{Type: TCAKind, Data: append([]byte(kind), 0)},
+		{Type: TCAStats2, Data: stats2},
+		{Type: TCAXStats, Data: xstats},
+	})
+	assert.NoError(t, err)
+	return netlink.Message{Data: append(data, attrs...)}
+}
+
+func TestParseHtbClass(t *testing.T) {
+	xstats := make([]byte, 20)
+	binary.NativeEndian.PutUint32(xstats[0:4], 7)
+	binary.NativeEndian.PutUint32(xstats[4:8], 3)
+	tokens := int32(-200)
+	binary.NativeEndian.PutUint32(xstats[12:16], uint32(tokens))
+
+	m, err := parseMessage(tcMessage(t, 2, 0x10010, 0x10000, "htb", xstats))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1500), m.Bytes)
+	assert.Equal(t, uint32(10), m.Packets)
+	assert.Equal(t, uint32(2), m.Drops)
+	assert.Nil(t, m.FqCodel)
+	assert.Equal(t, &HtbXStats{Lends: 7, Borrows: 3, Tokens: -200}, m.Htb)
+
+	m.IfaceName = "eth0"
+	assert.Equal(t, []string{"eth0", "htb", "1:10", "1:"}, m.tcLabelValues())
+}
+
+func TestParseFqCodelQdisc(t *testing.T) {
+	xstats := make([]byte, 40)
+	binary.NativeEndian.PutUint32(xstats[8:12], 4)
+	binary.NativeEndian.PutUint32(xstats[12:16], 5)
+	binary.NativeEndian.PutUint32(xstats[20:24], 6)
+
+	m, err := parseMessage(tcMessage(t, 2, 0, 0x10001, "fq_codel", xstats))
+	assert.NoError(t, err)
+	assert.Equal(t, &FqCodelXStats{DropOverlimit: 4, EcnMark: 5, NewFlowsLen: 6}, m.FqCodel)
+	assert.Equal(t, uint64(0), m.GcFlows)
+
+	// class stats of fq_codel are not parsed
+	binary.NativeEndian.PutUint32(xstats[0:4], 1)
+	m, err = parseMessage(tcMessage(t, 2, 0, 0x10001, "fq_codel", xstats))
+	assert.NoError(t, err)
+	assert.Nil(t, m.FqCodel)
+}
+
+func TestParseFqQdisc(t *testing.T) {
+	xstats := make([]byte, 80)
+	binary.NativeEndian.PutUint64(xstats[24:32], 8)
+	binary.NativeEndian.PutUint64(xstats[32:40], 9)
+
+	m, err := parseMessage(tcMessage(t, 2, 0x80000000, 0xFFFFFFFF, "fq", xstats))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), m.Throttled)
+	assert.Equal(t, uint64(9), m.FlowsPlimit)
+	assert.Equal(t, []string{"", "fq", "8000:", "root"}, m.tcLabelValues())
+}
+
+func TestIngress(t *testing.T) {
+	m, err := parseMessage(tcMessage(t, 2, 0xFFFF0000, tcHIngress, "clsact", nil))
+	assert.NoError(t, err)
+	assert.True(t, m.isIngress())
+	assert.Equal(t, "ingress", formatParent(m.Parent))
+}
+
+func TestSortedIndexes(t *testing.T) {
+	assert.Equal(t, []uint32{1, 2, 3}, sortedIndexes([]QdiscInfo{{IfIndex: 3}, {IfIndex: 1}, {IfIndex: 3}, {IfIndex: 2}}))
+}
@@ -2,10 +2,11 @@ package nlqdisc
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
-	"net"
+	"path"
 	"strings"
 	"syscall"
 
@@ -15,6 +16,7 @@ import (
 
 	"github.com/mdlayher/netlink"
 	"github.com/mdlayher/netlink/nlenc"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -52,6 +54,24 @@ var (
 	Backlog    = "backlog"
 	Overlimits = "overlimits"
 
+	ClassBytes      = "class_bytes"
+	ClassPackets    = "class_packets"
+	ClassDrops      = "class_drops"
+	ClassOverlimits = "class_overlimits"
+	ClassQlen       = "class_qlen"
+	ClassBacklog    = "class_backlog"
+
+	FqCodelEcnMark       = "fq_codel_ecn_mark"
+	FqCodelDropOverlimit = "fq_codel_drop_overlimit"
+	FqCodelNewFlowsLen   = "fq_codel_new_flows_len"
+	HtbLends             = "htb_lends"
+	HtbBorrows           = "htb_borrows"
+	HtbTokens            = "htb_tokens"
+	FqThrottled          = "fq_throttled"
+	FqFlowsPlimit        = "fq_flows_plimit"
+
+	IngressQdisc = "ingress_qdisc"
+
 	qdiscMetrics = []probe.LegacyMetric{
 		{Name: Bytes, Help: "The total number of bytes transmitted through the queuing discipline."},
 		{Name: Packets, Help: "The total number of packets transmitted through the queuing discipline."},
@@ -60,21 +80,69 @@ var (
 		{Name: Backlog, Help: "The total amount of data currently in the queue (in bytes)."},
 		{Name: Overlimits, Help: "The total number of packets that exceeded the configured limits."},
 	}
+
+	tcLabels      = []string{"interface", "kind", "handle", "parent"}
+	ingressLabels = []string{"interface", "kind"}
+
+	defaultInterfaces = []string{"eth*"}
 )
 
 func init() {
 	probe.MustRegisterMetricsProbe(probeName, qdiscProbeCreator)
 }
 
-func qdiscProbeCreator() (probe.MetricsProbe, error) {
-	p := &Probe{}
+type qdiscArgs struct {
+	Interfaces []string `mapstructure:"interfaces" description:"interfaces to export per qdisc and per class metrics of, glob patterns like eth* are supported, default eth*"`
+}
 
-	batchMetrics := probe.NewLegacyBatchMetrics(probeName, qdiscMetrics, p.CollectOnce)
+func qdiscProbeCreator(args qdiscArgs) (probe.MetricsProbe, error) {
+	if len(args.Interfaces) == 0 {
+		args.Interfaces = defaultInterfaces
+	}
+	for _, i := range args.Interfaces {
+		if _, err := path.Match(i, ""); err != nil {
+			return nil, fmt.Errorf("invalid interface pattern %q: %w", i, err)
+		}
+	}
+	p := &Probe{interfaces: args.Interfaces}
+
+	var metrics []probe.SingleMetricsOpts
+	// aggregated metrics of eth* interfaces, kept compatible with legacy metrics
+	for _, m := range qdiscMetrics {
+		metrics = append(metrics, probe.SingleMetricsOpts{Name: m.Name, Help: m.Help, ValueType: prometheus.GaugeValue})
+	}
+	metrics = append(metrics,
+		probe.SingleMetricsOpts{Name: ClassBytes, Help: "The total number of bytes transmitted through the traffic class.", VariableLabels: tcLabels, ValueType: prometheus.CounterValue},
+		probe.SingleMetricsOpts{Name: ClassPackets, Help: "The total number of packets transmitted through the traffic class.", VariableLabels: tcLabels, ValueType: prometheus.CounterValue},
+		probe.SingleMetricsOpts{Name: ClassDrops, Help: "The total number of packets dropped by the traffic class.", VariableLabels: tcLabels, ValueType: prometheus.CounterValue},
+		probe.SingleMetricsOpts{Name: ClassOverlimits, Help: "The total number of packets that exceeded the limits of the traffic class.", VariableLabels: tcLabels, ValueType: prometheus.CounterValue},
+		probe.SingleMetricsOpts{Name: ClassQlen, Help: "The current number of packets queued in the traffic class.", VariableLabels: tcLabels, ValueType: prometheus.GaugeValue},
+		probe.SingleMetricsOpts{Name: ClassBacklog, Help: "The current amount of data queued in the traffic class (in bytes).", VariableLabels: tcLabels, ValueType: prometheus.GaugeValue},
+		probe.SingleMetricsOpts{Name: FqCodelEcnMark, Help: "The total number of packets ECN marked by the fq_codel qdisc.", VariableLabels: tcLabels, ValueType: prometheus.CounterValue},
+		probe.SingleMetricsOpts{Name: FqCodelDropOverlimit, Help: "The total number of packets dropped by the fq_codel qdisc because its limit was exceeded.", VariableLabels: tcLabels, ValueType: prometheus.CounterValue},
+		probe.SingleMetricsOpts{Name: FqCodelNewFlowsLen, Help: "The current number of new flows of the fq_codel qdisc.", VariableLabels: tcLabels, ValueType: prometheus.GaugeValue},
+		probe.SingleMetricsOpts{Name: HtbLends, Help: "The total number of packets sent by the htb class with its own rate.", VariableLabels: tcLabels, ValueType: prometheus.CounterValue},
+		probe.SingleMetricsOpts{Name: HtbBorrows, Help: "The total number of packets sent by the htb class with rate borrowed from its parent.", VariableLabels: tcLabels, ValueType: prometheus.CounterValue},
+		probe.SingleMetricsOpts{Name: HtbTokens, Help: "The current tokens of the htb class, negative when the class is over its rate.", VariableLabels: tcLabels, ValueType: prometheus.GaugeValue},
+		probe.SingleMetricsOpts{Name: FqThrottled, Help: "The total number of flows throttled by the fq qdisc.", VariableLabels: tcLabels, ValueType: prometheus.CounterValue},
+		probe.SingleMetricsOpts{Name: FqFlowsPlimit, Help: "The total number of packets dropped by the fq qdisc because the flow limit was exceeded.", VariableLabels: tcLabels, ValueType: prometheus.CounterValue},
+		probe.SingleMetricsOpts{Name: IngressQdisc, Help: "Whether an ingress or clsact qdisc is attached to the interface.", VariableLabels: ingressLabels, ValueType: prometheus.GaugeValue},
+	)
+
+	opts := probe.BatchMetricsOpts{
+		Namespace:         probe.MetricsNamespace,
+		Subsystem:         probeName,
+		VariableLabels:    probe.StandardMetricsLabels,
+		SingleMetricsOpts: metrics,
+	}
+	batchMetrics := probe.NewBatchMetrics(opts, p.collectOnce)
 
 	return probe.NewMetricsProbe(probeName, p, batchMetrics), nil
 }
 
-type Probe struct{}
+type Probe struct {
+	interfaces []string
+}
 
 func (p *Probe) Start(_ context.Context) error {
 	return nil
@@ -84,55 +152,139 @@ func (p *Probe) Stop(_ context.Context) error {
 	return nil
 }
 
-func (p *Probe) CollectOnce() (map[string]map[uint32]uint64, error) {
-	resMap := make(map[string]map[uint32]uint64)
-	for _, metric := range qdiscMetrics {
-		resMap[metric.Name] = make(map[uint32]uint64)
+func (p *Probe) matchInterface(name string) bool {
+	for _, i := range p.interfaces {
+		if ok, _ := path.Match(i, name); ok {
+			return true
+		}
 	}
+	return false
+}
 
+func (p *Probe) collectOnce(emit probe.Emit) error {
 	ets := nettop.GetAllUniqueNetnsEntity()
 	for _, et := range ets {
-		stats, err := getQdiscStats(et)
+		qdiscs, classes, err := getTCStats(et)
 		if err != nil {
 			log.Errorf("%s failed get qdisc stats: %v", probeName, err)
 			continue
 		}
 
-		for _, stat := range stats {
+		labels := probe.BuildStandardMetricsLabelValues(et)
+		var aggregated map[string]uint64
+		for _, stat := range qdiscs {
 			// only care about eth0/eth1...
 			if strings.HasPrefix(stat.IfaceName, "eth") {
-				resMap[Bytes][uint32(et.GetNetns())] += stat.Bytes
-				resMap[Packets][uint32(et.GetNetns())] += uint64(stat.Packets)
-				resMap[Drops][uint32(et.GetNetns())] += uint64(stat.Drops)
-				resMap[Qlen][uint32(et.GetNetns())] += uint64(stat.Qlen)
-				resMap[Backlog][uint32(et.GetNetns())] += uint64(stat.Backlog)
-				resMap[Overlimits][uint32(et.GetNetns())] += uint64(stat.Overlimits)
+				if aggregated == nil {
+					aggregated = make(map[string]uint64)
+				}
+				aggregated[Bytes] += stat.Bytes
+				aggregated[Packets] += uint64(stat.Packets)
+				aggregated[Drops] += uint64(stat.Drops)
+				aggregated[Qlen] += uint64(stat.Qlen)
+				aggregated[Backlog] += uint64(stat.Backlog)
+				aggregated[Overlimits] += uint64(stat.Overlimits)
+			}
+		}
+		if aggregated != nil {
+			for _, m := range qdiscMetrics {
+				emit(m.Name, labels, float64(aggregated[m.Name]))
 			}
 		}
+
+		for _, stat := range qdiscs {
+			if !p.matchInterface(stat.IfaceName) {
+				continue
+			}
+			if stat.isIngress() {
+				emit(IngressQdisc, append(append([]string{}, labels...), stat.IfaceName, stat.Kind), 1)
+				continue
+			}
+			emitXStats(emit, labels, stat)
+		}
+
+		for _, stat := range classes {
+			if !p.matchInterface(stat.IfaceName) {
+				continue
+			}
+			values := append(append([]string{}, labels...), stat.tcLabelValues()...)
+			emit(ClassBytes, values, float64(stat.Bytes))
+			emit(ClassPackets, values, float64(stat.Packets))
+			emit(ClassDrops, values, float64(stat.Drops))
+			emit(ClassOverlimits, values, float64(stat.Overlimits))
+			emit(ClassQlen, values, float64(stat.Qlen))
+			emit(ClassBacklog, values, float64(stat.Backlog))
+			emitXStats(emit, labels, stat)
+		}
 	}
 
-	return resMap, nil
+	return nil
 }
 
-func getQdiscStats(entity *nettop.Entity) ([]QdiscInfo, error) {
+func emitXStats(emit probe.Emit, labels []string, stat QdiscInfo) {
+	values := append(append([]string{}, labels...), stat.tcLabelValues()...)
+	switch {
+	case stat.FqCodel != nil:
+		emit(FqCodelEcnMark, values, float64(stat.FqCodel.EcnMark))
+		emit(FqCodelDropOverlimit, values, float64(stat.FqCodel.DropOverlimit))
+		emit(FqCodelNewFlowsLen, values, float64(stat.FqCodel.NewFlowsLen))
+	case stat.Htb != nil:
+		emit(HtbLends, values, float64(stat.Htb.Lends))
+		emit(HtbBorrows, values, float64(stat.Htb.Borrows))
+		emit(HtbTokens, values, float64(stat.Htb.Tokens))
+	case stat.Kind == "fq":
+		emit(FqThrottled, values, float64(stat.Throttled))
+		emit(FqFlowsPlimit, values, float64(stat.FlowsPlimit))
+	}
+}
+
+// getTCStats dumps qdiscs and classes of all interfaces in the netns of entity.
+func getTCStats(entity *nettop.Entity) ([]QdiscInfo, []QdiscInfo, error) {
 	nsHandle, err := entity.OpenNsHandle()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer nsHandle.Close()
 
 	c, err := getConn(int(nsHandle))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer c.Close()
 
+	// interface indexes are resolved in the netns instead of the netns of the exporter
+	links, err := getLinks(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	qdiscs, err := dumpTC(c, rtmGetQdisc, 0, links)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var classes []QdiscInfo
+	for _, index := range sortedIndexes(qdiscs) {
+		// classes can only be dumped per interface
+		ret, err := dumpTC(c, rtmGetTClass, index, links)
+		if err != nil {
+			return nil, nil, err
+		}
+		classes = append(classes, ret...)
+	}
+
+	return qdiscs, classes, nil
+}
+
+func dumpTC(c *netlink.Conn, msgType netlink.HeaderType, ifindex uint32, links map[uint32]string) ([]QdiscInfo, error) {
+	data := make([]byte, 20)
+	binary.NativeEndian.PutUint32(data[4:8], ifindex)
 	req := netlink.Message{
 		Header: netlink.Header{
 			Flags: netlink.Request | netlink.Dump,
-			Type:  38, // RTM_GETQDISC
+			Type:  msgType,
 		},
-		Data: make([]byte, 20),
+		Data: data,
 	}
 
 	msgs, err := c.Execute(req)
@@ -147,6 +299,7 @@ func getQdiscStats(entity *nettop.Entity) ([]QdiscInfo, error) {
 			log.Errorf("failed parse qdisc msg, nlmsg: %v, err: %v", msg, err)
 			continue
 		}
+		m.IfaceName = links[m.IfIndex]
 		res = append(res, m)
 	}
 
@@ -172,6 +325,7 @@ func getConn(nsfd int) (*netlink.Conn, error) {
 }
 
 type QdiscInfo struct {
+	IfIndex     uint32
 	IfaceName   string
 	Parent      uint32
 	Handle      uint32
@@ -186,6 +340,8 @@ type QdiscInfo struct {
 	FlowsPlimit uint64
 	Qlen        uint32
 	Backlog     uint32
+	FqCodel     *FqCodelXStats
+	Htb         *HtbXStats
 }
 
 // See struct tc_stats in /usr/include/linux/pkt_sched.h
@@ -300,7 +456,8 @@ func parseMessage(msg netlink.Message) (QdiscInfo, error) {
 	var m QdiscInfo
 	var s TCStats
 	var s2 TCStats2
-	var sFq TCFqQdStats
+	var stats2 *netlink.Attribute
+	var xstats []byte
 
 	/*
 	   struct tcmsg {
@@ -318,7 +475,7 @@ func parseMessage(msg netlink.Message) (QdiscInfo, error) {
 		return m, fmt.Errorf("short message, len=%d", len(msg.Data))
 	}
 
-	ifaceIdx := nlenc.Uint32(msg.Data[4:8])
+	m.IfIndex = nlenc.Uint32(msg.Data[4:8])
 
 	m.Handle = nlenc.Uint32(msg.Data[8:12])
 	m.Parent = nlenc.Uint32(msg.Data[12:16])
@@ -334,25 +491,12 @@ func parseMessage(msg netlink.Message) (QdiscInfo, error) {
 		return m, fmt.Errorf("failed to unmarshal attributes: %v", err)
 	}
 
-	for _, attr := range attrs {
+	for i, attr := range attrs {
 		switch attr.Type {
 		case TCAKind:
 			m.Kind = nlenc.String(attr.Data)
 		case TCAStats2:
-			sFq, err = parseTCFqQdStats(attr)
-			if err != nil {
-				return m, err
-			}
-			if sFq.GcFlows > 0 {
-				m.GcFlows = sFq.GcFlows
-			}
-			if sFq.Throttled > 0 {
-				m.Throttled = sFq.Throttled
-			}
-			if sFq.FlowsPlimit > 0 {
-				m.FlowsPlimit = sFq.FlowsPlimit
-			}
-
+			stats2 = &attrs[i]
 			s2 = parseTCAStats2(attr)
 			m.Bytes = s2.Bytes
 			m.Packets = s2.Packets
@@ -371,16 +515,32 @@ func parseMessage(msg netlink.Message) (QdiscInfo, error) {
 			m.Overlimits = s.Overlimits
 			m.Qlen = s.Qlen
 			m.Backlog = s.Backlog
+		case TCAXStats:
+			// the same as TCA_STATS_APP in TCA_STATS2
+			xstats = attr.Data
 		default:
-			// TODO: TCAOptions and TCAXStats
+			// TODO: TCAOptions
 		}
 	}
 
-	iface, err := net.InterfaceByIndex(int(ifaceIdx))
-
-	if err == nil {
-		m.IfaceName = iface.Name
+	// xstats are specific to the kind, which is not guaranteed to come first
+	switch m.Kind {
+	case "fq":
+		if stats2 == nil {
+			break
+		}
+		sFq, err := parseTCFqQdStats(*stats2)
+		if err != nil {
+			return m, err
+		}
+		m.GcFlows = sFq.GcFlows
+		m.Throttled = sFq.Throttled
+		m.FlowsPlimit = sFq.FlowsPlimit
+	case "fq_codel":
+		m.FqCodel = parseFqCodelXStats(xstats)
+	case "htb":
+		m.Htb = parseHtbXStats(xstats)
 	}
 
-	return m, err
+	return m, nil
 }
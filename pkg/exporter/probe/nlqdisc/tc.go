@@ -0,0 +1,143 @@
+package nlqdisc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	rtmGetQdisc  netlink.HeaderType = unix.RTM_GETQDISC
+	rtmGetTClass netlink.HeaderType = unix.RTM_GETTCLASS
+	rtmGetLink   netlink.HeaderType = unix.RTM_GETLINK
+
+	// TC_H_INGRESS, the parent of ingress and clsact qdiscs
+	tcHIngress = 0xFFFFFFF1
+	// TCA_FQ_CODEL_XSTATS_QDISC
+	fqCodelXStatsQdisc = 0
+)
+
+// See struct tc_fq_codel_xstats in /usr/include/linux/pkt_sched.h, only qdisc stats are parsed.
+type FqCodelXStats struct {
+	MaxPacket     uint32
+	DropOverlimit uint32
+	EcnMark       uint32
+	NewFlowCount  uint32
+	NewFlowsLen   uint32
+	OldFlowsLen   uint32
+}
+
+// See struct tc_htb_xstats in /usr/include/linux/pkt_sched.h
+type HtbXStats struct {
+	Lends   uint32
+	Borrows uint32
+	Giants  uint32
+	Tokens  int32
+	CTokens int32
+}
+
+func parseFqCodelXStats(data []byte) *FqCodelXStats {
+	if len(data) < 28 || binary.NativeEndian.Uint32(data[0:4]) != fqCodelXStatsQdisc {
+		return nil
+	}
+	return &FqCodelXStats{
+		MaxPacket:     binary.NativeEndian.Uint32(data[4:8]),
+		DropOverlimit: binary.NativeEndian.Uint32(data[8:12]),
+		EcnMark:       binary.NativeEndian.Uint32(data[12:16]),
+		NewFlowCount:  binary.NativeEndian.Uint32(data[16:20]),
+		NewFlowsLen:   binary.NativeEndian.Uint32(data[20:24]),
+		OldFlowsLen:   binary.NativeEndian.Uint32(data[24:28]),
+	}
+}
+
+func parseHtbXStats(data []byte) *HtbXStats {
+	if len(data) < 20 {
+		return nil
+	}
+	return &HtbXStats{
+		Lends:   binary.NativeEndian.Uint32(data[0:4]),
+		Borrows: binary.NativeEndian.Uint32(data[4:8]),
+		Giants:  binary.NativeEndian.Uint32(data[8:12]),
+		Tokens:  int32(binary.NativeEndian.Uint32(data[12:16])),
+		CTokens: int32(binary.NativeEndian.Uint32(data[16:20])),
+	}
+}
+
+// formatHandle formats handle in the same way as tc, e.g. 1:10 and ffff:.
+func formatHandle(h uint32) string {
+	if h&0xFFFF == 0 {
+		return fmt.Sprintf("%x:", h>>16)
+	}
+	return fmt.Sprintf("%x:%x", h>>16, h&0xFFFF)
+}
+
+func formatParent(parent uint32) string {
+	switch parent {
+	case 0:
+		return "root"
+	case tcHIngress:
+		return "ingress"
+	default:
+		return formatHandle(parent)
+	}
+}
+
+func (q *QdiscInfo) isIngress() bool {
+	return q.Parent == tcHIngress
+}
+
+func (q *QdiscInfo) tcLabelValues() []string {
+	return []string{q.IfaceName, q.Kind, formatHandle(q.Handle), formatParent(q.Parent)}
+}
+
+// getLinks returns names of interfaces by index.
+func getLinks(c *netlink.Conn) (map[uint32]string, error) {
+	req := netlink.Message{
+		Header: netlink.Header{
+			Flags: netlink.Request | netlink.Dump,
+			Type:  rtmGetLink,
+		},
+		// struct ifinfomsg
+		Data: make([]byte, 16),
+	}
+
+	msgs, err := c.Execute(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump links: %w", err)
+	}
+
+	links := make(map[uint32]string, len(msgs))
+	for _, msg := range msgs {
+		if len(msg.Data) < 16 {
+			continue
+		}
+		index := binary.NativeEndian.Uint32(msg.Data[4:8])
+		attrs, err := netlink.UnmarshalAttributes(msg.Data[16:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal link attributes: %w", err)
+		}
+		for _, attr := range attrs {
+			if attr.Type == unix.IFLA_IFNAME {
+				links[index] = nlenc.String(attr.Data)
+			}
+		}
+	}
+	return links, nil
+}
+
+func sortedIndexes(qdiscs []QdiscInfo) []uint32 {
+	seen := make(map[uint32]bool)
+	var ret []uint32
+	for _, q := range qdiscs {
+		if !seen[q.IfIndex] {
+			seen[q.IfIndex] = true
+			ret = append(ret, q.IfIndex)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
@@ -0,0 +1,125 @@
+package nltcpinfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"unsafe"
+
+	"github.com/mdlayher/netlink"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// INET_DIAG_INFO attribute carries struct tcp_info
+	inetDiagInfo = 2
+	// INET_DIAG_SKMEMINFO attribute carries u32 array indexed by SK_MEMINFO_*
+	inetDiagSkMemInfo = 4
+	skMemInfoDrops    = 8
+
+	sizeofInetDiagReqV2 = 56
+	sizeofInetDiagMsg   = 72
+
+	tcpEstablished = 1
+	// request sockets of TCP_NEW_SYN_RECV are reported as TCP_SYN_RECV
+	tcpSynRecv = 3
+	tcpListen  = 10
+)
+
+// diagSocket is a tcp socket dumped by inet_diag.
+type diagSocket struct {
+	state  uint8
+	src    net.IP
+	dst    net.IP
+	sport  uint16
+	dport  uint16
+	rqueue uint32
+	wqueue uint32
+	// drops is sk_drops, counted by tcp_listendrop for listening sockets
+	drops uint32
+	// info is nil if the kernel does not report tcp_info of the socket
+	info *unix.TCPInfo
+}
+
+// newDiagRequest builds struct inet_diag_req_v2 of tcp sockets in states.
+func newDiagRequest(family uint8, states uint32) []byte {
+	b := make([]byte, sizeofInetDiagReqV2)
+	b[0] = family
+	b[1] = unix.IPPROTO_TCP
+	b[2] = 1<<(inetDiagInfo-1) | 1<<(inetDiagSkMemInfo-1)
+	binary.NativeEndian.PutUint32(b[4:], states)
+	return b
+}
+
+// parseDiagMessage parses struct inet_diag_msg and its attributes.
+func parseDiagMessage(data []byte) (*diagSocket, error) {
+	if len(data) < sizeofInetDiagMsg {
+		return nil, fmt.Errorf("short inet_diag_msg of %d bytes", len(data))
+	}
+	family := data[0]
+	s := &diagSocket{
+		state:  data[1],
+		sport:  binary.BigEndian.Uint16(data[4:6]),
+		dport:  binary.BigEndian.Uint16(data[6:8]),
+		rqueue: binary.NativeEndian.Uint32(data[56:60]),
+		wqueue: binary.NativeEndian.Uint32(data[60:64]),
+	}
+	if family == unix.AF_INET {
+		s.src = net.IP(append([]byte{}, data[8:12]...))
+		s.dst = net.IP(append([]byte{}, data[24:28]...))
+	} else {
+		s.src = net.IP(append([]byte{}, data[8:24]...))
+		s.dst = net.IP(append([]byte{}, data[24:40]...))
+	}
+
+	ad, err := netlink.NewAttributeDecoder(data[sizeofInetDiagMsg:])
+	if err != nil {
+		return nil, err
+	}
+	for ad.Next() {
+		switch ad.Type() {
+		case inetDiagInfo:
+			// tcp_info of older kernels is shorter, missing fields are left zero
+			buf := make([]byte, unix.SizeofTCPInfo)
+			copy(buf, ad.Bytes())
+			s.info = (*unix.TCPInfo)(unsafe.Pointer(&buf[0]))
+		case inetDiagSkMemInfo:
+			meminfo := ad.Bytes()
+			if len(meminfo) >= (skMemInfoDrops+1)*4 {
+				s.drops = binary.NativeEndian.Uint32(meminfo[skMemInfoDrops*4:])
+			}
+		}
+	}
+	return s, ad.Err()
+}
+
+// dumpSockets dumps tcp sockets in states of both ipv4 and ipv6 with the netlink connection.
+func dumpSockets(conn *netlink.Conn, states uint32) ([]*diagSocket, error) {
+	var ret []*diagSocket
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		msgs, err := conn.Execute(netlink.Message{
+			Header: netlink.Header{
+				Type:  unix.SOCK_DIAG_BY_FAMILY,
+				Flags: netlink.Request | netlink.Dump,
+			},
+			Data: newDiagRequest(family, states),
+		})
+		if err != nil {
+			// ipv6 may be disabled
+			if family == unix.AF_INET6 {
+				log.Debugf("failed dump ipv6 sockets: %v", err)
+				break
+			}
+			return nil, fmt.Errorf("failed dump ipv4 sockets: %w", err)
+		}
+		for _, m := range msgs {
+			s, err := parseDiagMessage(m.Data)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, s)
+		}
+	}
+	return ret, nil
+}
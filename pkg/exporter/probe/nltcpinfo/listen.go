@@ -0,0 +1,168 @@
+package nltcpinfo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	listenProbeName = "tcplisten"
+
+	Listeners   = "listeners"
+	AcceptQueue = "accept_queue"
+	Backlog     = "backlog"
+	SynQueue    = "syn_queue"
+	Drops       = "drops"
+
+	// listening sockets and request sockets waiting for the final ack
+	listenStates = 1<<tcpListen | 1<<tcpSynRecv
+)
+
+var listenLabels = []string{"port"}
+
+func init() {
+	probe.MustRegisterMetricsProbe(listenProbeName, listenMetricsProbeCreator)
+}
+
+type listenArgs struct {
+	Ports []int `mapstructure:"ports" description:"only sample listening sockets on these ports, all ports by default"`
+
+	nettop.NamespaceArgs `mapstructure:",squash"`
+}
+
+// listenStats are stats of listening sockets on the same port of a netns, there may be
+// several of them bound to different addresses or with SO_REUSEPORT.
+type listenStats struct {
+	port        uint16
+	listeners   int
+	acceptQueue uint64
+	backlog     uint64
+	synQueue    uint64
+	// drops is the sum of sk_drops of listening sockets, it is not a counter of the port
+	// since drops of closed sockets are lost
+	drops uint64
+}
+
+// saturated reports whether the accept queue is full as sk_acceptq_is_full does, new
+// connections are dropped when the accept queue exceeds the backlog.
+func (s *listenStats) saturated() bool {
+	return s.acceptQueue > s.backlog
+}
+
+// aggregateListeners groups listening sockets by local port, request sockets are counted
+// to the syn queue of the listening port they are received on.
+func aggregateListeners(sockets []*diagSocket, filter *connFilter) []*listenStats {
+	ports := make(map[uint16]*listenStats)
+	for _, s := range sockets {
+		if s.state != tcpListen || !filter.matchPort(s.sport) {
+			continue
+		}
+		stats, ok := ports[s.sport]
+		if !ok {
+			stats = &listenStats{port: s.sport}
+			ports[s.sport] = stats
+		}
+		// for listening sockets, rqueue is the accept queue length and wqueue is the backlog
+		stats.listeners++
+		stats.acceptQueue += uint64(s.rqueue)
+		stats.backlog += uint64(s.wqueue)
+		stats.drops += uint64(s.drops)
+	}
+	for _, s := range sockets {
+		if s.state != tcpSynRecv {
+			continue
+		}
+		if stats, ok := ports[s.sport]; ok {
+			stats.synQueue++
+		}
+	}
+
+	ret := make([]*listenStats, 0, len(ports))
+	for _, stats := range ports {
+		ret = append(ret, stats)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].port < ret[j].port })
+	return ret
+}
+
+// dumpListeners dumps listening sockets of the netns of et.
+func dumpListeners(et *nettop.Entity, filter *connFilter) ([]*listenStats, error) {
+	sockets, err := dumpEntitySockets(et, listenStates)
+	if err != nil {
+		return nil, err
+	}
+	return aggregateListeners(sockets, filter), nil
+}
+
+func listenMetricsProbeCreator(args listenArgs) (probe.MetricsProbe, error) {
+	filter, err := newConnFilter(probeArgs{NamespaceArgs: args.NamespaceArgs, Ports: args.Ports})
+	if err != nil {
+		return nil, err
+	}
+	p := &listenMetricsProbe{filter: filter}
+
+	opts := probe.BatchMetricsOpts{
+		Namespace:      probe.MetricsNamespace,
+		Subsystem:      listenProbeName,
+		VariableLabels: append(append([]string{}, probe.StandardMetricsLabels...), listenLabels...),
+		SingleMetricsOpts: []probe.SingleMetricsOpts{
+			{Name: Listeners, Help: "The number of listening sockets on the port.", ValueType: prometheus.GaugeValue},
+			{Name: AcceptQueue, Help: "The number of established connections waiting to be accepted on the port.", ValueType: prometheus.GaugeValue},
+			{Name: Backlog, Help: "The max length of the accept queue on the port.", ValueType: prometheus.GaugeValue},
+			{Name: SynQueue, Help: "The number of connections waiting for the final ack of handshake on the port.", ValueType: prometheus.GaugeValue},
+			// TODO: count overflows of ports by bpf, netstat listenoverflows are counters of the whole netns
+			{Name: Drops, Help: "The sum of packets dropped by the listening sockets currently on the port, including accept queue overflows. It decreases when a listening socket is closed.", ValueType: prometheus.GaugeValue},
+		},
+	}
+	batchMetrics := probe.NewBatchMetrics(opts, p.collectOnce)
+	return probe.NewMetricsProbe(listenProbeName, p, batchMetrics), nil
+}
+
+type listenMetricsProbe struct {
+	filter *connFilter
+}
+
+func (p *listenMetricsProbe) Start(_ context.Context) error {
+	return nil
+}
+
+func (p *listenMetricsProbe) Stop(_ context.Context) error {
+	return nil
+}
+
+func (p *listenMetricsProbe) collectOnce(emit probe.Emit) error {
+	var errs []string
+	for _, et := range nettop.GetAllUniqueNetnsEntity() {
+		if !p.filter.entities.Match(et) {
+			continue
+		}
+		listeners, err := dumpListeners(et, p.filter)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("netns %d: %v", et.GetNetns(), err))
+			continue
+		}
+		labels := probe.BuildStandardMetricsLabelValues(et)
+		for _, s := range listeners {
+			labelValues := append(append([]string{}, labels...), strconv.Itoa(int(s.port)))
+			emit(Listeners, labelValues, float64(s.listeners))
+			emit(AcceptQueue, labelValues, float64(s.acceptQueue))
+			emit(Backlog, labelValues, float64(s.backlog))
+			emit(SynQueue, labelValues, float64(s.synQueue))
+			emit(Drops, labelValues, float64(s.drops))
+		}
+	}
+	// a pod may be deleted during collection, errors of single netns are not fatal
+	if len(errs) > 0 {
+		log.Debugf("%s: failed sample listening sockets, %s", listenProbeName, strings.Join(errs, "; "))
+		probe.AddCollectErrors(listenProbeName, len(errs))
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package nltcpinfo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	TCPListenOverflow probe.EventType = "TCPListenOverflow"
+
+	defaultListenInterval = 5
+)
+
+func init() {
+	probe.MustRegisterEventProbe(listenProbeName, listenEventProbeCreator)
+}
+
+type listenEventArgs struct {
+	Ports    []int `mapstructure:"ports" description:"only watch listening sockets on these ports, all ports by default"`
+	Interval int   `mapstructure:"interval" description:"seconds between checks of listening sockets, default 5"`
+
+	nettop.NamespaceArgs `mapstructure:",squash"`
+}
+
+func listenEventProbeCreator(sink chan<- *probe.Event, args listenEventArgs) (probe.EventProbe, error) {
+	filter, err := newConnFilter(probeArgs{NamespaceArgs: args.NamespaceArgs, Ports: args.Ports})
+	if err != nil {
+		return nil, err
+	}
+	if args.Interval < 0 {
+		return nil, fmt.Errorf("interval of %s should not be negative", listenProbeName)
+	}
+	if args.Interval == 0 {
+		args.Interval = defaultListenInterval
+	}
+	p := &listenEventProbe{
+		sink:     sink,
+		filter:   filter,
+		interval: time.Duration(args.Interval) * time.Second,
+	}
+	return probe.NewEventProbe(listenProbeName, p), nil
+}
+
+type listenEventProbe struct {
+	sink     chan<- *probe.Event
+	filter   *connFilter
+	interval time.Duration
+	// drops of listening ports by netns at the last check
+	drops  map[int]map[uint16]uint64
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (p *listenEventProbe) Start(_ context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	p.drops = make(map[int]map[uint16]uint64)
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.check()
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *listenEventProbe) Stop(_ context.Context) error {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+	return nil
+}
+
+func (p *listenEventProbe) check() {
+	seen := make(map[int]bool)
+	for _, et := range nettop.GetAllUniqueNetnsEntity() {
+		if !p.filter.entities.Match(et) {
+			continue
+		}
+		nsinum := et.GetNetns()
+		listeners, err := dumpListeners(et, p.filter)
+		if err != nil {
+			log.Debugf("%s: failed check listening sockets of netns %d: %v", listenProbeName, nsinum, err)
+			continue
+		}
+		seen[nsinum] = true
+		for _, evt := range p.detect(nsinum, listeners) {
+			p.sink <- evt
+		}
+	}
+	for nsinum := range p.drops {
+		if !seen[nsinum] {
+			delete(p.drops, nsinum)
+		}
+	}
+}
+
+// detect returns events of listening ports whose accept queue is full or which dropped
+// connections since the last check. Drops are not reported at the first check of a netns.
+func (p *listenEventProbe) detect(nsinum int, listeners []*listenStats) []*probe.Event {
+	last, checked := p.drops[nsinum]
+	current := make(map[uint16]uint64, len(listeners))
+	p.drops[nsinum] = current
+
+	var ret []*probe.Event
+	for _, s := range listeners {
+		current[s.port] = s.drops
+		var dropped uint64
+		// drops decrease when one of the listening sockets is closed
+		if prev, ok := last[s.port]; checked && ok && s.drops > prev {
+			dropped = s.drops - prev
+		}
+		if dropped == 0 && !s.saturated() {
+			continue
+		}
+		ret = append(ret, newListenEvent(nsinum, s, dropped))
+	}
+	return ret
+}
+
+func newListenEvent(nsinum int, s *listenStats, dropped uint64) *probe.Event {
+	port := strconv.Itoa(int(s.port))
+	labels := probe.LegacyEventLabels(uint32(nsinum))
+	labels = append(labels,
+		probe.Label{Name: "port", Value: port},
+		probe.Label{Name: "accept_queue", Value: strconv.FormatUint(s.acceptQueue, 10)},
+		probe.Label{Name: "backlog", Value: strconv.FormatUint(s.backlog, 10)},
+		probe.Label{Name: "syn_queue", Value: strconv.FormatUint(s.synQueue, 10)},
+		probe.Label{Name: "drops", Value: strconv.FormatUint(dropped, 10)},
+	)
+	return &probe.Event{
+		Timestamp: time.Now().UnixNano(),
+		Type:      TCPListenOverflow,
+		Labels:    labels,
+		Message: fmt.Sprintf("listen port %s saturated, accept queue %d/%d, syn queue %d, %d dropped",
+			port, s.acceptQueue, s.backlog, s.synQueue, dropped),
+		Version: probe.EventSchemaVersion,
+		Netns:   uint32(nsinum),
+	}
+}
@@ -0,0 +1,329 @@
+package nltcpinfo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/mdlayher/netlink"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultMaxRemotes     = 50
+	defaultMaxConnections = 20000
+
+	// otherRemote is the remote of connections exceeding maxRemotes of a pod
+	otherRemote = "other"
+
+	Connections = "connections"
+	SRTT        = "srtt_seconds"
+	RTTVar      = "rttvar_seconds"
+	Cwnd        = "cwnd_segments"
+	Retrans     = "retrans_segments"
+	Unacked     = "unacked_segments"
+	SendQueue   = "sendq_bytes"
+	RecvQueue   = "recvq_bytes"
+)
+
+var (
+	probeName = "tcpinfo"
+
+	remoteLabels = []string{"remote", "remote_port"}
+
+	rttBuckets     = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+	segmentBuckets = []float64{1, 2, 4, 8, 16, 32, 64, 128, 256, 512}
+	retransBuckets = []float64{0, 1, 2, 5, 10, 50, 100, 1000}
+	queueBuckets   = []float64{0, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+
+	// histograms of established connections, value returns the sample of a connection. They are
+	// snapshots of live connections at each scrape rather than cumulative prometheus histograms,
+	// buckets are exported as gauges of connections whose value is at or below le, along with
+	// gauges of the sum of values.
+	histograms = []struct {
+		name    string
+		help    string
+		buckets []float64
+		value   func(s *diagSocket) float64
+	}{
+		{SRTT, "Distribution of smoothed round trip time of established connections.", rttBuckets,
+			func(s *diagSocket) float64 { return float64(s.info.Rtt) / 1e6 }},
+		{RTTVar, "Distribution of round trip time variance of established connections.", rttBuckets,
+			func(s *diagSocket) float64 { return float64(s.info.Rttvar) / 1e6 }},
+		{Cwnd, "Distribution of congestion window of established connections.", segmentBuckets,
+			func(s *diagSocket) float64 { return float64(s.info.Snd_cwnd) }},
+		{Retrans, "Distribution of total retransmitted segments of established connections.", retransBuckets,
+			func(s *diagSocket) float64 { return float64(s.info.Total_retrans) }},
+		{Unacked, "Distribution of unacknowledged segments of established connections.", segmentBuckets,
+			func(s *diagSocket) float64 { return float64(s.info.Unacked) }},
+		{SendQueue, "Distribution of send queue size of established connections.", queueBuckets,
+			func(s *diagSocket) float64 { return float64(s.wqueue) }},
+		{RecvQueue, "Distribution of receive queue size of established connections.", queueBuckets,
+			func(s *diagSocket) float64 { return float64(s.rqueue) }},
+	}
+)
+
+func init() {
+	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
+}
+
+type probeArgs struct {
+	Ports          []int    `mapstructure:"ports" description:"only sample connections whose local or remote port is one of them, all ports by default"`
+	RemoteCIDRs    []string `mapstructure:"remoteCIDRs" description:"only sample connections to remote addresses in these cidrs, all addresses by default"`
+	MaxRemotes     int      `mapstructure:"maxRemotes" description:"max remote endpoints per pod, connections to other endpoints are reported with remote other, default 50"`
+	MaxConnections int      `mapstructure:"maxConnections" description:"skip netns with more established connections than this, default 20000"`
+
+	nettop.NamespaceArgs `mapstructure:",squash"`
+}
+
+// connFilter decides which connections are sampled, empty sets match everything.
+type connFilter struct {
+	entities *nettop.EntityFilter
+	ports    map[uint16]bool
+	cidrs    []*net.IPNet
+}
+
+func newConnFilter(args probeArgs) (*connFilter, error) {
+	f := &connFilter{entities: args.Filter(), ports: make(map[uint16]bool)}
+	for _, p := range args.Ports {
+		if p <= 0 || p > 65535 {
+			return nil, fmt.Errorf("invalid port %d", p)
+		}
+		f.ports[uint16(p)] = true
+	}
+	for _, c := range args.RemoteCIDRs {
+		_, cidr, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote cidr %q: %w", c, err)
+		}
+		f.cidrs = append(f.cidrs, cidr)
+	}
+	return f, nil
+}
+
+// matchPort matches the local port of listening sockets.
+func (f *connFilter) matchPort(port uint16) bool {
+	return len(f.ports) == 0 || f.ports[port]
+}
+
+func (f *connFilter) matchSocket(s *diagSocket) bool {
+	if len(f.ports) > 0 && !f.ports[s.sport] && !f.ports[s.dport] {
+		return false
+	}
+	if len(f.cidrs) == 0 {
+		return true
+	}
+	for _, c := range f.cidrs {
+		if c.Contains(s.dst) {
+			return true
+		}
+	}
+	return false
+}
+
+func metricsProbeCreator(args probeArgs) (probe.MetricsProbe, error) {
+	filter, err := newConnFilter(args)
+	if err != nil {
+		return nil, err
+	}
+	if args.MaxRemotes <= 0 {
+		args.MaxRemotes = defaultMaxRemotes
+	}
+	if args.MaxConnections <= 0 {
+		args.MaxConnections = defaultMaxConnections
+	}
+
+	p := &tcpInfoProbe{args: args, filter: filter, descs: make(map[string]*prometheus.Desc)}
+	labels := append(append([]string{}, probe.StandardMetricsLabels...), remoteLabels...)
+	bucketLabels := append(append([]string{}, labels...), "le")
+	fqName := func(name string) string {
+		return prometheus.BuildFQName(probe.MetricsNamespace, probeName, name)
+	}
+	p.descs[Connections] = prometheus.NewDesc(fqName(Connections), "The number of sampled established connections.", labels, nil)
+	for _, h := range histograms {
+		p.descs[bucketName(h.name)] = prometheus.NewDesc(fqName(bucketName(h.name)), h.help+" Number of connections at or below le at scrape time.", bucketLabels, nil)
+		p.descs[sumName(h.name)] = prometheus.NewDesc(fqName(sumName(h.name)), h.help+" Sum of values of connections at scrape time.", labels, nil)
+	}
+	return probe.NewMetricsProbe(probeName, p, p), nil
+}
+
+func bucketName(name string) string {
+	return name + "_connections"
+}
+
+func sumName(name string) string {
+	return name + "_sum"
+}
+
+type tcpInfoProbe struct {
+	args   probeArgs
+	filter *connFilter
+	descs  map[string]*prometheus.Desc
+}
+
+func (p *tcpInfoProbe) Start(_ context.Context) error {
+	return nil
+}
+
+func (p *tcpInfoProbe) Stop(_ context.Context) error {
+	return nil
+}
+
+func (p *tcpInfoProbe) Describe(descs chan<- *prometheus.Desc) {
+	for _, d := range p.descs {
+		descs <- d
+	}
+}
+
+func (p *tcpInfoProbe) Collect(metrics chan<- prometheus.Metric) {
+	var errs []string
+	for _, et := range nettop.GetAllUniqueNetnsEntity() {
+		if !p.filter.entities.Match(et) {
+			continue
+		}
+		sockets, err := dumpEntitySockets(et, 1<<tcpEstablished|1<<tcpListen)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("netns %d: %v", et.GetNetns(), err))
+			continue
+		}
+		labels := probe.BuildStandardMetricsLabelValues(et)
+		for remote, stats := range p.aggregate(sockets) {
+			labelValues := append(append([]string{}, labels...), remote.addr, remote.port)
+			metrics <- prometheus.MustNewConstMetric(p.descs[Connections], prometheus.GaugeValue, float64(stats.connections), labelValues...)
+			for i, h := range histograms {
+				hist := stats.histograms[i]
+				bucketDesc := p.descs[bucketName(h.name)]
+				for b, count := range hist.cumulativeBuckets() {
+					metrics <- prometheus.MustNewConstMetric(bucketDesc, prometheus.GaugeValue, float64(count), append(labelValues, strconv.FormatFloat(b, 'g', -1, 64))...)
+				}
+				metrics <- prometheus.MustNewConstMetric(bucketDesc, prometheus.GaugeValue, float64(hist.count), append(labelValues, "+Inf")...)
+				metrics <- prometheus.MustNewConstMetric(p.descs[sumName(h.name)], prometheus.GaugeValue, hist.sum, labelValues...)
+			}
+		}
+	}
+	// a pod may be deleted during collection, errors of single netns are not fatal
+	if len(errs) > 0 {
+		log.Debugf("%s: failed sample connections, %s", probeName, strings.Join(errs, "; "))
+		probe.AddCollectErrors(probeName, len(errs))
+	}
+}
+
+// dumpEntitySockets dumps tcp sockets in states of the netns of et.
+func dumpEntitySockets(et *nettop.Entity, states uint32) ([]*diagSocket, error) {
+	nsHandle, err := et.OpenNsHandle()
+	if err != nil {
+		return nil, err
+	}
+	defer nsHandle.Close()
+
+	conn, err := netlink.Dial(unix.NETLINK_SOCK_DIAG, &netlink.Config{NetNS: int(nsHandle)})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return dumpSockets(conn, states)
+}
+
+type remoteKey struct {
+	addr string
+	// port is empty for inbound connections, whose remote ports are ephemeral
+	port string
+}
+
+type remoteStats struct {
+	connections int
+	histograms  []*histogram
+}
+
+// aggregate groups established connections of a netns by remote endpoint.
+func (p *tcpInfoProbe) aggregate(sockets []*diagSocket) map[remoteKey]*remoteStats {
+	listening := make(map[uint16]bool)
+	established := 0
+	for _, s := range sockets {
+		switch s.state {
+		case tcpListen:
+			listening[s.sport] = true
+		case tcpEstablished:
+			established++
+		}
+	}
+	if established > p.args.MaxConnections {
+		log.Debugf("%s: skip netns with %d established connections", probeName, established)
+		return nil
+	}
+
+	ret := make(map[remoteKey]*remoteStats)
+	for _, s := range sockets {
+		if s.state != tcpEstablished || s.info == nil || !p.filter.matchSocket(s) {
+			continue
+		}
+		key := remoteKey{addr: s.dst.String()}
+		if !listening[s.sport] {
+			key.port = strconv.Itoa(int(s.dport))
+		}
+		stats, ok := ret[key]
+		if !ok {
+			if len(ret) >= p.args.MaxRemotes {
+				key = remoteKey{addr: otherRemote}
+				stats = ret[key]
+			}
+			if stats == nil {
+				stats = newRemoteStats()
+				ret[key] = stats
+			}
+		}
+
+		stats.connections++
+		for i, h := range histograms {
+			stats.histograms[i].observe(h.value(s))
+		}
+	}
+	return ret
+}
+
+func newRemoteStats() *remoteStats {
+	s := &remoteStats{}
+	for _, h := range histograms {
+		s.histograms = append(s.histograms, newHistogram(h.buckets))
+	}
+	return s
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+func (h *histogram) cumulativeBuckets() map[float64]uint64 {
+	ret := make(map[float64]uint64, len(h.buckets))
+	var acc uint64
+	for i, b := range h.buckets {
+		acc += h.counts[i]
+		ret[b] = acc
+	}
+	return ret
+}
@@ -0,0 +1,169 @@
+package nltcpinfo
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"unsafe"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/mdlayher/netlink"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func buildDiagMessage(t *testing.T, family, state uint8, src, dst string, sport, dport uint16, info *unix.TCPInfo) []byte {
+	b := make([]byte, sizeofInetDiagMsg)
+	b[0] = family
+	b[1] = state
+	binary.BigEndian.PutUint16(b[4:], sport)
+	binary.BigEndian.PutUint16(b[6:], dport)
+	if family == unix.AF_INET {
+		copy(b[8:], net.ParseIP(src).To4())
+		copy(b[24:], net.ParseIP(dst).To4())
+	} else {
+		copy(b[8:], net.ParseIP(src).To16())
+		copy(b[24:], net.ParseIP(dst).To16())
+	}
+	binary.NativeEndian.PutUint32(b[56:], 10)
+	binary.NativeEndian.PutUint32(b[60:], 20)
+	if info == nil {
+		return b
+	}
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Bytes(inetDiagInfo, unsafe.Slice((*byte)(unsafe.Pointer(info)), unix.SizeofTCPInfo))
+	attrs, err := ae.Encode()
+	assert.NoError(t, err)
+	return append(b, attrs...)
+}
+
+func TestParseDiagMessage(t *testing.T) {
+	data := buildDiagMessage(t, unix.AF_INET, tcpEstablished, "10.0.0.1", "10.0.0.2", 80, 12345, &unix.TCPInfo{Rtt: 1500, Snd_cwnd: 10})
+	s, err := parseDiagMessage(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", s.src.String())
+	assert.Equal(t, "10.0.0.2", s.dst.String())
+	assert.Equal(t, uint16(80), s.sport)
+	assert.Equal(t, uint16(12345), s.dport)
+	assert.Equal(t, uint32(10), s.rqueue)
+	assert.Equal(t, uint32(20), s.wqueue)
+	assert.Equal(t, uint32(1500), s.info.Rtt)
+	assert.Equal(t, uint32(10), s.info.Snd_cwnd)
+
+	s, err = parseDiagMessage(buildDiagMessage(t, unix.AF_INET6, tcpListen, "fd00::1", "::", 443, 0, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "fd00::1", s.src.String())
+	assert.Nil(t, s.info)
+
+	_, err = parseDiagMessage(data[:10])
+	assert.Error(t, err)
+
+	meminfo := make([]byte, 9*4)
+	binary.NativeEndian.PutUint32(meminfo[skMemInfoDrops*4:], 7)
+	ae := netlink.NewAttributeEncoder()
+	ae.Bytes(inetDiagSkMemInfo, meminfo)
+	attrs, err := ae.Encode()
+	assert.NoError(t, err)
+	s, err = parseDiagMessage(append(buildDiagMessage(t, unix.AF_INET, tcpListen, "0.0.0.0", "0.0.0.0", 80, 0, nil), attrs...))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), s.drops)
+}
+
+func TestAggregate(t *testing.T) {
+	filter, err := newConnFilter(probeArgs{RemoteCIDRs: []string{"10.0.0.0/8"}})
+	assert.NoError(t, err)
+	p := &tcpInfoProbe{args: probeArgs{MaxRemotes: 2, MaxConnections: 100}, filter: filter}
+
+	conn := func(sport uint16, dst string, dport uint16, rtt uint32) *diagSocket {
+		return &diagSocket{state: tcpEstablished, sport: sport, dst: net.ParseIP(dst), dport: dport, info: &unix.TCPInfo{Rtt: rtt}}
+	}
+	sockets := []*diagSocket{
+		{state: tcpListen, sport: 80},
+		// inbound connections, remote port is omitted
+		conn(80, "10.0.0.2", 40001, 200),
+		conn(80, "10.0.0.2", 40002, 2000),
+		// outbound connection
+		conn(50000, "10.0.0.3", 3306, 300),
+		// exceeds max remotes
+		conn(50001, "10.0.0.4", 6379, 400),
+		// filtered by remote cidr
+		conn(50002, "192.168.0.1", 443, 500),
+	}
+
+	ret := p.aggregate(sockets)
+	assert.Len(t, ret, 3)
+	inbound := ret[remoteKey{addr: "10.0.0.2"}]
+	assert.Equal(t, 2, inbound.connections)
+	assert.Equal(t, uint64(2), inbound.histograms[0].count)
+	assert.InDelta(t, 0.0022, inbound.histograms[0].sum, 1e-9)
+	assert.Equal(t, map[float64]uint64{0.0001: 0, 0.0005: 1, 0.001: 1, 0.005: 2, 0.01: 2, 0.05: 2, 0.1: 2, 0.5: 2, 1: 2},
+		inbound.histograms[0].cumulativeBuckets())
+	assert.Equal(t, 1, ret[remoteKey{addr: "10.0.0.3", port: "3306"}].connections)
+	assert.Equal(t, 1, ret[remoteKey{addr: otherRemote}].connections)
+
+	p.args.MaxConnections = 2
+	assert.Empty(t, p.aggregate(sockets))
+}
+
+func TestConnFilter(t *testing.T) {
+	_, err := newConnFilter(probeArgs{Ports: []int{70000}})
+	assert.Error(t, err)
+	_, err = newConnFilter(probeArgs{RemoteCIDRs: []string{"10.0.0.1"}})
+	assert.Error(t, err)
+
+	f, err := newConnFilter(probeArgs{Ports: []int{80}})
+	assert.NoError(t, err)
+	assert.True(t, f.matchSocket(&diagSocket{sport: 80, dst: net.ParseIP("10.0.0.1")}))
+	assert.True(t, f.matchSocket(&diagSocket{dport: 80, dst: net.ParseIP("10.0.0.1")}))
+	assert.False(t, f.matchSocket(&diagSocket{sport: 8080, dport: 40000, dst: net.ParseIP("10.0.0.1")}))
+}
+
+func TestAggregateListeners(t *testing.T) {
+	filter, err := newConnFilter(probeArgs{Ports: []int{80, 443}})
+	assert.NoError(t, err)
+	sockets := []*diagSocket{
+		// listeners with SO_REUSEPORT
+		{state: tcpListen, sport: 80, rqueue: 3, wqueue: 128, drops: 1},
+		{state: tcpListen, sport: 80, rqueue: 1, wqueue: 128, drops: 2},
+		// the accept queue may exceed the backlog by one
+		{state: tcpListen, sport: 443, rqueue: 129, wqueue: 128},
+		{state: tcpListen, sport: 8080, rqueue: 1, wqueue: 128},
+		{state: tcpSynRecv, sport: 80},
+		{state: tcpSynRecv, sport: 80},
+		{state: tcpSynRecv, sport: 8080},
+		{state: tcpEstablished, sport: 80},
+	}
+	ret := aggregateListeners(sockets, filter)
+	assert.Equal(t, []*listenStats{
+		{port: 80, listeners: 2, acceptQueue: 4, backlog: 256, synQueue: 2, drops: 3},
+		{port: 443, listeners: 1, acceptQueue: 129, backlog: 128},
+	}, ret)
+	assert.False(t, ret[0].saturated())
+	assert.True(t, ret[1].saturated())
+	// as sk_acceptq_is_full, a full queue has more connections than the backlog
+	assert.False(t, (&listenStats{acceptQueue: 128, backlog: 128}).saturated())
+	assert.False(t, (&listenStats{}).saturated())
+}
+
+func TestDetectListenEvents(t *testing.T) {
+	p := &listenEventProbe{drops: make(map[int]map[uint16]uint64)}
+	listeners := []*listenStats{
+		{port: 80, acceptQueue: 1, backlog: 128, drops: 10},
+		{port: 443, acceptQueue: 129, backlog: 128},
+	}
+	// drops before the first check are not reported
+	evts := p.detect(1, listeners)
+	assert.Len(t, evts, 1)
+	assert.Equal(t, TCPListenOverflow, evts[0].Type)
+	assert.Contains(t, evts[0].Labels, probe.Label{Name: "port", Value: "443"})
+
+	listeners[0].drops = 15
+	listeners[1].acceptQueue = 0
+	evts = p.detect(1, listeners)
+	assert.Len(t, evts, 1)
+	assert.Contains(t, evts[0].Labels, probe.Label{Name: "port", Value: "80"})
+	assert.Contains(t, evts[0].Labels, probe.Label{Name: "drops", Value: "5"})
+
+	assert.Empty(t, p.detect(1, listeners))
+}
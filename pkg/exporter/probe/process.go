@@ -0,0 +1,56 @@
+package probe
+
+import (
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+)
+
+// ProcessLabels are labels of the process which triggers an event.
+var ProcessLabels = []string{"container", "process"}
+
+// NewEventProcess builds the process of an event from the current task captured by bpf, and
+// resolves its container by cgroup id or pid. The task is unrelated to the event when the event
+// happens in softirq context, so it returns nil if the container does not belong to the pod of
+// netns.
+func NewEventProcess(pid uint32, comm string, cgroupID uint64, netns uint32) *EventProcess {
+	if pid == 0 {
+		return nil
+	}
+	p := &EventProcess{Pid: pid, Comm: comm, CgroupID: cgroupID}
+
+	c := nettop.GetContainerByCgroupID(cgroupID)
+	if c == nil {
+		c = nettop.GetContainerByPid(int(pid))
+	}
+	if c == nil {
+		return p
+	}
+
+	et, err := nettop.GetEntityByNetns(int(netns))
+	if err != nil || et == nil {
+		return p
+	}
+	// containers of host network pods share the host netns
+	if !et.IsHostNetwork() && (et.GetPodName() != c.PodName || et.GetPodNamespace() != c.PodNamespace) {
+		return nil
+	}
+	p.Container = c.Name
+	p.Image = c.Image
+	return p
+}
+
+// BuildProcessEventLabels returns values of ProcessLabels as event labels.
+func BuildProcessEventLabels(p *EventProcess) []Label {
+	values := BuildProcessLabelValues(p)
+	return []Label{
+		{Name: ProcessLabels[0], Value: values[0]},
+		{Name: ProcessLabels[1], Value: values[1]},
+	}
+}
+
+// BuildProcessLabelValues returns values of ProcessLabels, values are empty if p is nil.
+func BuildProcessLabelValues(p *EventProcess) []string {
+	if p == nil {
+		return []string{"", ""}
+	}
+	return []string{p.Container, p.Comm}
+}
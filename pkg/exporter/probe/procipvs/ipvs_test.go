@@ -0,0 +1,79 @@
+package procipvs
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/moby/ipvs"
+	"github.com/stretchr/testify/assert"
+)
+
+const ipvsStats = `   Total Incoming Outgoing         Incoming         Outgoing
+   Conns  Packets  Packets            Bytes            Bytes
+       A       64       20             1000              800
+
+ Conns/s   Pkts/s   Pkts/s          Bytes/s          Bytes/s
+       0        0        0                0                0
+`
+
+func TestParseIPVSStats(t *testing.T) {
+	stats, err := parseIPVSStats(strings.NewReader(ipvsStats))
+	assert.NoError(t, err)
+	assert.Equal(t, IPVSStats{Connections: 10, IncomingPackets: 100, OutgoingPackets: 32, IncomingBytes: 4096, OutgoingBytes: 2048}, stats)
+}
+
+type fakeHandle struct {
+	services []*ipvs.Service
+	dsts     map[*ipvs.Service][]*ipvs.Destination
+}
+
+func (f *fakeHandle) GetServices() ([]*ipvs.Service, error) {
+	return f.services, nil
+}
+
+func (f *fakeHandle) GetDestinations(svc *ipvs.Service) ([]*ipvs.Destination, error) {
+	return f.dsts[svc], nil
+}
+
+func TestVirtualServers(t *testing.T) {
+	nettop.UpdateIPCache("test", 1, []*nettop.IPInfo{
+		{Type: nettop.IPTypeService, IP: "10.96.0.10", ServiceNamespace: "kube-system", ServiceName: "kube-dns"},
+		{Type: nettop.IPTypePod, IP: "172.16.0.2", PodNamespace: "kube-system", PodName: "coredns-0"},
+	})
+
+	dns := &ipvs.Service{Address: net.ParseIP("10.96.0.10"), Protocol: 6, Port: 53, SchedName: "rr", Stats: ipvs.SvcStats{Connections: 3}}
+	fwm := &ipvs.Service{FWMark: 100, SchedName: "wrr"}
+	h := &fakeHandle{
+		services: []*ipvs.Service{dns, fwm},
+		dsts: map[*ipvs.Service][]*ipvs.Destination{
+			dns: {
+				{Address: net.ParseIP("172.16.0.2"), Port: 53, Weight: 1, ActiveConnections: 2},
+				{Address: net.ParseIP("172.16.0.3"), Port: 53, Weight: 0},
+			},
+		},
+	}
+
+	servers, err := listVirtualServers(h)
+	assert.NoError(t, err)
+	assert.Len(t, servers, 2)
+	assert.Equal(t, "kube-dns", servers[0].serviceName)
+	assert.Equal(t, "", servers[1].serviceName)
+	assert.Equal(t, "TCP 10.96.0.10:53", virtualServerName(dns))
+	assert.Equal(t, "FWM 100", virtualServerName(fwm))
+	assert.Equal(t, []string{"172.16.0.2:53", "kube-system", "coredns-0"}, realServerLabelValues(servers[0].dsts[0]))
+
+	p := &ProcIPVS{namespaces: map[string]bool{"kube-system": true}}
+	assert.True(t, p.matchVirtualServer(servers[0]))
+	assert.False(t, p.matchVirtualServer(servers[1]))
+
+	values := make(map[string][]float64)
+	emitVirtualServer(func(name string, labels []string, val float64) {
+		values[name] = append(values[name], val)
+	}, []string{"node", "", ""}, servers[0])
+	assert.Equal(t, []float64{3}, values[ServiceConnections])
+	assert.Equal(t, []float64{2}, values[ServiceBackends])
+	assert.Equal(t, []float64{1}, values[ServiceZeroWeight])
+	assert.Equal(t, []float64{2, 0}, values[BackendActiveConns])
+}
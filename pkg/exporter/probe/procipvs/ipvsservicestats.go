@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
 	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/moby/ipvs"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const maxBufferSize = 1024 * 1024
@@ -38,15 +42,36 @@ func init() {
 	probe.MustRegisterMetricsProbe(probeName, ipvsProbeCreator)
 }
 
-func ipvsProbeCreator() (probe.MetricsProbe, error) {
-	p := &ProcIPVS{}
+type ipvsArgs struct {
+	Namespaces []string `mapstructure:"namespaces" description:"service namespaces to export per virtual server metrics of, virtual servers not mapped to a service are not exported when set, all virtual servers by default"`
+}
+
+func ipvsProbeCreator(args ipvsArgs) (probe.MetricsProbe, error) {
+	p := &ProcIPVS{namespaces: make(map[string]bool)}
+	for _, ns := range args.Namespaces {
+		p.namespaces[ns] = true
+	}
 
-	batchMetrics := probe.NewLegacyBatchMetrics(probeName, IPVSMetrics, p.CollectOnce)
+	var metrics []probe.SingleMetricsOpts
+	// totals of the node, kept compatible with legacy metrics
+	for _, m := range IPVSMetrics {
+		metrics = append(metrics, probe.SingleMetricsOpts{Name: m.Name, Help: m.Help, ValueType: prometheus.GaugeValue})
+	}
+	metrics = append(metrics, virtualServerMetrics...)
+
+	opts := probe.BatchMetricsOpts{
+		Namespace:         probe.MetricsNamespace,
+		Subsystem:         probeName,
+		VariableLabels:    probe.StandardMetricsLabels,
+		SingleMetricsOpts: metrics,
+	}
+	batchMetrics := probe.NewBatchMetrics(opts, p.collectOnce)
 
 	return probe.NewMetricsProbe(probeName, p, batchMetrics), nil
 }
 
 type ProcIPVS struct {
+	namespaces map[string]bool
 }
 
 func (p *ProcIPVS) Start(_ context.Context) error {
@@ -57,31 +82,57 @@ func (p *ProcIPVS) Stop(_ context.Context) error {
 	return nil
 }
 
-func (p *ProcIPVS) CollectOnce() (map[string]map[uint32]uint64, error) {
-	resMap := make(map[string]map[uint32]uint64)
+func (p *ProcIPVS) collectOnce(emit probe.Emit) error {
+	// only handle stats in default netns
+	et, err := nettop.GetHostNetworkEntity()
+	if err != nil {
+		return err
+	}
+	labels := probe.BuildStandardMetricsLabelValues(et)
+
+	stats, err := readIPVSStats()
+	if err != nil {
+		return err
+	}
+	emit(Connections, labels, float64(stats.Connections))
+	emit(IncomingPackets, labels, float64(stats.IncomingPackets))
+	emit(IncomingBytes, labels, float64(stats.IncomingBytes))
+	emit(OutgoingPackets, labels, float64(stats.OutgoingPackets))
+	emit(OutgoingBytes, labels, float64(stats.OutgoingBytes))
+
+	h, err := ipvs.New("")
+	if err != nil {
+		return fmt.Errorf("failed open ipvs handle: %w", err)
+	}
+	defer h.Close()
+
+	servers, err := listVirtualServers(h)
+	if err != nil {
+		return err
+	}
+	for _, vs := range servers {
+		if !p.matchVirtualServer(vs) {
+			continue
+		}
+		emitVirtualServer(emit, labels, vs)
+	}
+	return nil
+}
+
+func readIPVSStats() (IPVSStats, error) {
 	f, err := os.Open(statf)
 	if err != nil {
-		return resMap, err
+		return IPVSStats{}, err
 	}
 	defer f.Close()
 
 	reader := io.LimitReader(f, maxBufferSize)
 	data, err := io.ReadAll(reader)
 	if err != nil {
-		return resMap, err
+		return IPVSStats{}, err
 	}
 
-	stats, err := parseIPVSStats(bytes.NewReader(data))
-	if err != nil {
-		return resMap, err
-	}
-	// only handle stats in default netns
-	resMap[Connections] = map[uint32]uint64{0: stats.Connections}
-	resMap[IncomingPackets] = map[uint32]uint64{0: stats.IncomingBytes}
-	resMap[IncomingBytes] = map[uint32]uint64{0: stats.IncomingBytes}
-	resMap[OutgoingPackets] = map[uint32]uint64{0: stats.OutgoingPackets}
-	resMap[OutgoingBytes] = map[uint32]uint64{0: stats.OutgoingBytes}
-	return resMap, nil
+	return parseIPVSStats(bytes.NewReader(data))
 }
 
 // IPVSStats holds IPVS statistics, as exposed by the kernel in `/proc/net/ip_vs_stats`.
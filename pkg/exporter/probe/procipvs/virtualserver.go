@@ -0,0 +1,152 @@
+package procipvs
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/moby/ipvs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	ServiceConnections     = "service_connections"
+	ServiceIncomingPackets = "service_incomingpackets"
+	ServiceOutgoingPackets = "service_outgoingpackets"
+	ServiceIncomingBytes   = "service_incomingbytes"
+	ServiceOutgoingBytes   = "service_outgoingbytes"
+	ServiceCPS             = "service_cps"
+	ServiceBackends        = "service_backends"
+	ServiceZeroWeight      = "service_zeroweightbackends"
+
+	BackendActiveConns   = "backend_activeconnections"
+	BackendInactiveConns = "backend_inactiveconnections"
+	BackendWeight        = "backend_weight"
+	BackendConnections   = "backend_connections"
+	BackendIncomingBytes = "backend_incomingbytes"
+	BackendOutgoingBytes = "backend_outgoingbytes"
+	BackendCPS           = "backend_cps"
+
+	serviceLabels = []string{"virtual_server", "scheduler", "service_namespace", "service"}
+	backendLabels = append(append([]string{}, serviceLabels...), "real_server", "real_server_namespace", "real_server_pod")
+
+	virtualServerMetrics = []probe.SingleMetricsOpts{
+		{Name: ServiceConnections, Help: "The total number of connections scheduled by the IPVS virtual server.", VariableLabels: serviceLabels, ValueType: prometheus.CounterValue},
+		{Name: ServiceIncomingPackets, Help: "The total number of incoming packets of the IPVS virtual server.", VariableLabels: serviceLabels, ValueType: prometheus.CounterValue},
+		{Name: ServiceOutgoingPackets, Help: "The total number of outgoing packets of the IPVS virtual server.", VariableLabels: serviceLabels, ValueType: prometheus.CounterValue},
+		{Name: ServiceIncomingBytes, Help: "The total number of incoming bytes of the IPVS virtual server.", VariableLabels: serviceLabels, ValueType: prometheus.CounterValue},
+		{Name: ServiceOutgoingBytes, Help: "The total number of outgoing bytes of the IPVS virtual server.", VariableLabels: serviceLabels, ValueType: prometheus.CounterValue},
+		{Name: ServiceCPS, Help: "The rate of new connections per second of the IPVS virtual server estimated by the kernel.", VariableLabels: serviceLabels, ValueType: prometheus.GaugeValue},
+		{Name: ServiceBackends, Help: "The number of real servers of the IPVS virtual server.", VariableLabels: serviceLabels, ValueType: prometheus.GaugeValue},
+		{Name: ServiceZeroWeight, Help: "The number of real servers with zero weight of the IPVS virtual server, which receive no new connections.", VariableLabels: serviceLabels, ValueType: prometheus.GaugeValue},
+
+		{Name: BackendActiveConns, Help: "The current number of active connections of the IPVS real server.", VariableLabels: backendLabels, ValueType: prometheus.GaugeValue},
+		{Name: BackendInactiveConns, Help: "The current number of inactive connections of the IPVS real server.", VariableLabels: backendLabels, ValueType: prometheus.GaugeValue},
+		{Name: BackendWeight, Help: "The weight of the IPVS real server.", VariableLabels: backendLabels, ValueType: prometheus.GaugeValue},
+		{Name: BackendConnections, Help: "The total number of connections scheduled to the IPVS real server.", VariableLabels: backendLabels, ValueType: prometheus.CounterValue},
+		{Name: BackendIncomingBytes, Help: "The total number of incoming bytes of the IPVS real server.", VariableLabels: backendLabels, ValueType: prometheus.CounterValue},
+		{Name: BackendOutgoingBytes, Help: "The total number of outgoing bytes of the IPVS real server.", VariableLabels: backendLabels, ValueType: prometheus.CounterValue},
+		{Name: BackendCPS, Help: "The rate of new connections per second of the IPVS real server estimated by the kernel.", VariableLabels: backendLabels, ValueType: prometheus.GaugeValue},
+	}
+)
+
+type ipvsHandle interface {
+	GetServices() ([]*ipvs.Service, error)
+	GetDestinations(svc *ipvs.Service) ([]*ipvs.Destination, error)
+}
+
+// virtualServer is an IPVS virtual server with its real servers, mapped to the kubernetes
+// service by its virtual ip.
+type virtualServer struct {
+	svc              *ipvs.Service
+	dsts             []*ipvs.Destination
+	serviceNamespace string
+	serviceName      string
+}
+
+func listVirtualServers(h ipvsHandle) ([]*virtualServer, error) {
+	services, err := h.GetServices()
+	if err != nil {
+		return nil, fmt.Errorf("failed list ipvs services: %w", err)
+	}
+
+	var ret []*virtualServer
+	for _, svc := range services {
+		dsts, err := h.GetDestinations(svc)
+		if err != nil {
+			return nil, fmt.Errorf("failed list real servers of %s: %w", virtualServerName(svc), err)
+		}
+		vs := &virtualServer{svc: svc, dsts: dsts}
+		if svc.Address != nil {
+			if info := nettop.GetIPInfo(svc.Address.String()); info != nil && info.Type == nettop.IPTypeService {
+				vs.serviceNamespace, vs.serviceName = info.ServiceNamespace, info.ServiceName
+			}
+		}
+		ret = append(ret, vs)
+	}
+	return ret, nil
+}
+
+func (p *ProcIPVS) matchVirtualServer(vs *virtualServer) bool {
+	if len(p.namespaces) == 0 {
+		return true
+	}
+	return p.namespaces[vs.serviceNamespace]
+}
+
+func virtualServerName(svc *ipvs.Service) string {
+	if svc.FWMark != 0 {
+		return fmt.Sprintf("FWM %d", svc.FWMark)
+	}
+	proto := "IP"
+	switch svc.Protocol {
+	case syscall.IPPROTO_TCP:
+		proto = "TCP"
+	case syscall.IPPROTO_UDP:
+		proto = "UDP"
+	case syscall.IPPROTO_SCTP:
+		proto = "SCTP"
+	}
+	return fmt.Sprintf("%s %s", proto, net.JoinHostPort(svc.Address.String(), strconv.Itoa(int(svc.Port))))
+}
+
+// realServerLabelValues returns address and pod of the real server.
+func realServerLabelValues(dst *ipvs.Destination) []string {
+	addr := dst.Address.String()
+	values := []string{net.JoinHostPort(addr, strconv.Itoa(int(dst.Port))), "", ""}
+	if info := nettop.GetIPInfo(addr); info != nil && info.Type == nettop.IPTypePod {
+		values[1], values[2] = info.PodNamespace, info.PodName
+	}
+	return values
+}
+
+func emitVirtualServer(emit probe.Emit, labels []string, vs *virtualServer) {
+	svcValues := append(append([]string{}, labels...), virtualServerName(vs.svc), vs.svc.SchedName, vs.serviceNamespace, vs.serviceName)
+	stats := vs.svc.Stats
+	emit(ServiceConnections, svcValues, float64(stats.Connections))
+	emit(ServiceIncomingPackets, svcValues, float64(stats.PacketsIn))
+	emit(ServiceOutgoingPackets, svcValues, float64(stats.PacketsOut))
+	emit(ServiceIncomingBytes, svcValues, float64(stats.BytesIn))
+	emit(ServiceOutgoingBytes, svcValues, float64(stats.BytesOut))
+	emit(ServiceCPS, svcValues, float64(stats.CPS))
+
+	zeroWeight := 0
+	for _, dst := range vs.dsts {
+		if dst.Weight == 0 {
+			zeroWeight++
+		}
+		values := append(append([]string{}, svcValues...), realServerLabelValues(dst)...)
+		emit(BackendActiveConns, values, float64(dst.ActiveConnections))
+		emit(BackendInactiveConns, values, float64(dst.InactiveConnections))
+		emit(BackendWeight, values, float64(dst.Weight))
+		emit(BackendConnections, values, float64(dst.Stats.Connections))
+		emit(BackendIncomingBytes, values, float64(dst.Stats.BytesIn))
+		emit(BackendOutgoingBytes, values, float64(dst.Stats.BytesOut))
+		emit(BackendCPS, values, float64(dst.Stats.CPS))
+	}
+	emit(ServiceBackends, svcValues, float64(len(vs.dsts)))
+	emit(ServiceZeroWeight, svcValues, float64(zeroWeight))
+}
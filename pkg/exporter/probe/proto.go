@@ -73,7 +73,44 @@ type Event struct {
 	Timestamp int64     `json:"timestamp"`
 	Type      EventType `json:"type"`
 	Labels    []Label   `json:"labels"`
-	Message   string    `json:"msg"`
+	// Message is the human readable description of the event, kept for consumers of
+	// events before structured fields were added.
+	Message string `json:"msg"`
+
+	// Version is the schema version of structured fields below, see EventSchemaVersion.
+	Version uint32 `json:"version,omitempty"`
+	// KernelTimestamp is the monotonic kernel time in nanoseconds recorded by bpf_ktime_get_ns()
+	// when the event happens, it orders events across CPUs regardless of the decoding delay.
+	KernelTimestamp uint64        `json:"kernelTimestamp,omitempty"`
+	Tuple           *EventTuple   `json:"tuple,omitempty"`
+	Process         *EventProcess `json:"process,omitempty"`
+	Netns           uint32        `json:"netns,omitempty"`
+	LatencyNs       uint64        `json:"latencyNs,omitempty"`
+	// Stack is the kernel stack of the event, innermost frame first.
+	Stack []string `json:"stack,omitempty"`
+	// Count is the number of identical events aggregated into this one, FirstSeen and
+	// LastSeen are the timestamps of the first and last of them. Count is 0 if the event
+	// is not aggregated.
+	Count     uint32 `json:"count,omitempty"`
+	FirstSeen int64  `json:"firstSeen,omitempty"`
+	LastSeen  int64  `json:"lastSeen,omitempty"`
+}
+
+type EventTuple struct {
+	Protocol string `json:"protocol"`
+	Src      string `json:"src"`
+	Dst      string `json:"dst"`
+	Sport    uint16 `json:"sport"`
+	Dport    uint16 `json:"dport"`
+}
+
+type EventProcess struct {
+	Pid  uint32 `json:"pid"`
+	Comm string `json:"comm,omitempty"`
+	// CgroupID is the cgroup v2 id of the process, 0 if unknown.
+	CgroupID  uint64 `json:"cgroupId,omitempty"`
+	Container string `json:"container,omitempty"`
+	Image     string `json:"image,omitempty"`
 }
 
 type Probe interface {
@@ -97,6 +134,20 @@ type SimpleProbe interface {
 	Stop(ctx context.Context) error
 }
 
+type suspendKey struct{}
+
+// WithSuspend marks the context of Stop as a suspension by overhead throttling rather than a
+// shutdown, probes should release resources which are otherwise kept across restarts.
+func WithSuspend(ctx context.Context) context.Context {
+	return context.WithValue(ctx, suspendKey{}, true)
+}
+
+// IsSuspend returns whether the probe is stopped by a suspension.
+func IsSuspend(ctx context.Context) bool {
+	v, _ := ctx.Value(suspendKey{}).(bool)
+	return v
+}
+
 type simpleProbe struct {
 	name  string
 	state State
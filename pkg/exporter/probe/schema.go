@@ -0,0 +1,355 @@
+package probe
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:generate go run ./all/schemagen -o schemas.json
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// generatedSchemas are args schemas of all probes generated by go generate, so args can be
+// validated by processes which do not link probes, e.g. the controller.
+//
+//go:embed schemas.json
+var generatedSchemas []byte
+
+var (
+	generatedOnce sync.Once
+	generated     *ArgsSchemas
+	generatedErr  error
+)
+
+// ArgsSchema is the JSON Schema of probe args. Only the subset which can be generated
+// from args types of probe creators is supported.
+type ArgsSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Properties  map[string]*ArgsSchema `json:"properties,omitempty"`
+	Items       *ArgsSchema            `json:"items,omitempty"`
+	// AdditionalProperties is false for struct args, or the schema of values for map args.
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+}
+
+// argsSchema generates schema from the args type of a creator, nil means the creator accepts no args.
+func argsSchema(t *reflect.Type) *ArgsSchema {
+	var s *ArgsSchema
+	if t == nil {
+		s = &ArgsSchema{Type: "object", AdditionalProperties: false}
+	} else {
+		s = schemaForType(*t)
+	}
+	s.Schema = jsonSchemaDraft
+	return s
+}
+
+func schemaForType(t reflect.Type) *ArgsSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		s := &ArgsSchema{Type: "object", Properties: map[string]*ArgsSchema{}, AdditionalProperties: false}
+		addStructProperties(s, t)
+		return s
+	case reflect.Map:
+		s := &ArgsSchema{Type: "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			s.AdditionalProperties = schemaForType(t.Elem())
+		}
+		return s
+	case reflect.Slice, reflect.Array:
+		return &ArgsSchema{Type: "array", Items: schemaForType(t.Elem())}
+	case reflect.String:
+		return &ArgsSchema{Type: "string"}
+	case reflect.Bool:
+		return &ArgsSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &ArgsSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &ArgsSchema{Type: "number"}
+	default:
+		// interface{} accepts any value
+		return &ArgsSchema{}
+	}
+}
+
+// addStructProperties follows field naming rules of mapstructure, which is used to decode args.
+func addStructProperties(s *ArgsSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		if opts == "squash" || (f.Anonymous && name == "") {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addStructProperties(s, ft)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		p := schemaForType(f.Type)
+		p.Description = f.Tag.Get("description")
+		s.Properties[name] = p
+	}
+}
+
+// Validate checks args against the schema, all violations are returned.
+func (s *ArgsSchema) Validate(args map[string]interface{}) error {
+	var errs []error
+	s.validate("", args, &errs)
+	return errors.Join(errs...)
+}
+
+func (s *ArgsSchema) validate(path string, v interface{}, errs *[]error) {
+	fail := func(format string, a ...interface{}) {
+		prefix := ""
+		if path != "" {
+			prefix = fmt.Sprintf("arg %q: ", path)
+		}
+		*errs = append(*errs, fmt.Errorf(prefix+format, a...))
+	}
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	if v == nil {
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		m, ok := toStringMap(v)
+		if !ok {
+			fail("expect object, but got %T", v)
+			return
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if p := s.property(k); p != nil {
+				p.validate(join(k), m[k], errs)
+				continue
+			}
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					*errs = append(*errs, fmt.Errorf("unknown arg %q%s", join(k), s.suggest(k)))
+				}
+			case *ArgsSchema:
+				additional.validate(join(k), m[k], errs)
+			}
+		}
+	case "array":
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			fail("expect array, but got %T", v)
+			return
+		}
+		for i := 0; i < rv.Len(); i++ {
+			s.Items.validate(fmt.Sprintf("%s[%d]", path, i), rv.Index(i).Interface(), errs)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			fail("expect string, but got %T", v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("expect boolean, but got %T", v)
+		}
+	case "integer":
+		switch n := v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		case float64:
+			if n != math.Trunc(n) {
+				fail("expect integer, but got %v", v)
+			}
+		default:
+			fail("expect integer, but got %T", v)
+		}
+	case "number":
+		switch v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		default:
+			fail("expect number, but got %T", v)
+		}
+	}
+}
+
+// property finds the property case-insensitively as mapstructure does.
+func (s *ArgsSchema) property(name string) *ArgsSchema {
+	if p, ok := s.Properties[name]; ok {
+		return p
+	}
+	for k, p := range s.Properties {
+		if strings.EqualFold(k, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *ArgsSchema) suggest(name string) string {
+	if len(s.Properties) == 0 {
+		return ", the probe accepts no args"
+	}
+	var names []string
+	for k := range s.Properties {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf(", available args: %s", strings.Join(names, ", "))
+}
+
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		ret := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, false
+			}
+			ret[ks] = v
+		}
+		return ret, true
+	}
+	return nil, false
+}
+
+// UnmarshalJSON decodes additionalProperties as a bool or a schema.
+func (s *ArgsSchema) UnmarshalJSON(data []byte) error {
+	type plain ArgsSchema
+	v := struct {
+		*plain
+		AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+	}{plain: (*plain)(s)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	s.AdditionalProperties = nil
+	if len(v.AdditionalProperties) == 0 {
+		return nil
+	}
+	var b bool
+	if err := json.Unmarshal(v.AdditionalProperties, &b); err == nil {
+		s.AdditionalProperties = b
+		return nil
+	}
+	additional := &ArgsSchema{}
+	if err := json.Unmarshal(v.AdditionalProperties, additional); err != nil {
+		return err
+	}
+	s.AdditionalProperties = additional
+	return nil
+}
+
+// ArgsSchemas are args schemas of probes grouped by probe type.
+type ArgsSchemas struct {
+	Metrics map[string]*ArgsSchema `json:"metrics"`
+	Event   map[string]*ArgsSchema `json:"event"`
+}
+
+// RegisteredArgsSchemas returns args schemas of registered probes.
+func RegisteredArgsSchemas() *ArgsSchemas {
+	ret := &ArgsSchemas{
+		Metrics: make(map[string]*ArgsSchema, len(availableMetricsProbes)),
+		Event:   make(map[string]*ArgsSchema, len(availableEventProbe)),
+	}
+	for name, creator := range availableMetricsProbes {
+		ret.Metrics[name] = argsSchema(creator.s)
+	}
+	for name, creator := range availableEventProbe {
+		ret.Event[name] = argsSchema(creator.s)
+	}
+	return ret
+}
+
+// GeneratedArgsSchemas returns args schemas of all probes generated by go generate.
+func GeneratedArgsSchemas() (*ArgsSchemas, error) {
+	generatedOnce.Do(func() {
+		generated = &ArgsSchemas{}
+		if err := json.Unmarshal(generatedSchemas, generated); err != nil {
+			generatedErr = fmt.Errorf("failed parse generated args schemas: %w", err)
+		}
+	})
+	return generated, generatedErr
+}
+
+// lookupArgsSchema finds the schema of a probe in generated schemas if no probes are registered.
+func lookupArgsSchema(name string, registered int, schemas func(*ArgsSchemas) map[string]*ArgsSchema) (*ArgsSchema, error) {
+	if registered == 0 {
+		all, err := GeneratedArgsSchemas()
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := schemas(all)[name]; ok {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("undefined probe %s", name)
+}
+
+func MetricsProbeArgsSchema(name string) (*ArgsSchema, error) {
+	creator, ok := availableMetricsProbes[name]
+	if !ok {
+		return lookupArgsSchema(name, len(availableMetricsProbes), func(s *ArgsSchemas) map[string]*ArgsSchema { return s.Metrics })
+	}
+	return argsSchema(creator.s), nil
+}
+
+func EventProbeArgsSchema(name string) (*ArgsSchema, error) {
+	creator, ok := availableEventProbe[name]
+	if !ok {
+		return lookupArgsSchema(name, len(availableEventProbe), func(s *ArgsSchemas) map[string]*ArgsSchema { return s.Event })
+	}
+	return argsSchema(creator.s), nil
+}
+
+// ValidateMetricsProbeArgs checks the probe exists and args match the args type of its creator.
+func ValidateMetricsProbeArgs(name string, args map[string]interface{}) error {
+	s, err := MetricsProbeArgsSchema(name)
+	if err != nil {
+		return err
+	}
+	return s.Validate(args)
+}
+
+// ValidateEventProbeArgs checks the probe exists and args match the args type of its creator.
+func ValidateEventProbeArgs(name string, args map[string]interface{}) error {
+	s, err := EventProbeArgsSchema(name)
+	if err != nil {
+		return err
+	}
+	return s.Validate(args)
+}
@@ -0,0 +1,55 @@
+package probe
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaTestArgs struct {
+	Dev     string   `mapstructure:"interfaceName" description:"interface"`
+	Types   []string `mapstructure:"softirq-types"`
+	Count   int
+	Ignored string `mapstructure:"-"`
+}
+
+func TestArgsSchema(t *testing.T) {
+	st := reflect.TypeOf(schemaTestArgs{})
+	s := argsSchema(&st)
+	assert.Equal(t, "object", s.Type)
+	assert.Equal(t, false, s.AdditionalProperties)
+	assert.Len(t, s.Properties, 3)
+	assert.Equal(t, "interface", s.Properties["interfaceName"].Description)
+	assert.Equal(t, "string", s.Properties["softirq-types"].Items.Type)
+	assert.Equal(t, "integer", s.Properties["Count"].Type)
+
+	assert.NoError(t, s.Validate(map[string]interface{}{
+		"interfaceName": "eth0",
+		"softirq-types": []interface{}{"net_rx"},
+		"count":         1,
+	}))
+	assert.NoError(t, s.Validate(nil))
+	assert.Error(t, s.Validate(map[string]interface{}{"softirq_types": []interface{}{"net_rx"}}))
+	assert.Error(t, s.Validate(map[string]interface{}{"softirq-types": "net_rx"}))
+	assert.Error(t, s.Validate(map[string]interface{}{"softirq-types": []interface{}{1}}))
+	assert.Error(t, s.Validate(map[string]interface{}{"count": 1.5}))
+
+	noArgs := argsSchema(nil)
+	assert.NoError(t, noArgs.Validate(map[string]interface{}{}))
+	assert.Error(t, noArgs.Validate(map[string]interface{}{"a": 1}))
+
+	mt := reflect.TypeOf(map[string]interface{}{})
+	assert.NoError(t, argsSchema(&mt).Validate(map[string]interface{}{"a": 1}))
+}
+
+func TestGeneratedArgsSchemaLookup(t *testing.T) {
+	// no probes are registered in this package, generated schemas are used
+	s, err := MetricsProbeArgsSchema("conntrack")
+	assert.NoError(t, err)
+	assert.Equal(t, false, s.AdditionalProperties)
+	assert.NoError(t, ValidateMetricsProbeArgs("conntrack", map[string]interface{}{"maxDumpEntries": 10}))
+	assert.Error(t, ValidateMetricsProbeArgs("conntrack", map[string]interface{}{"maxDumpEntries": "10"}))
+	assert.Error(t, ValidateEventProbeArgs("conntrack", map[string]interface{}{"unknown": 1}))
+	assert.Error(t, ValidateMetricsProbeArgs("nonexistent", nil))
+}
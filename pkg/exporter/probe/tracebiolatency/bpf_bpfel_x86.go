@@ -21,11 +21,12 @@ type bpfInspBiolatEntryT struct {
 }
 
 type bpfInspBiolatEventT struct {
-	Target  [20]int8
-	Disk    [20]int8
-	Pid     uint32
-	_       [4]byte
-	Latency uint64
+	Target    [20]int8
+	Disk      [20]int8
+	Pid       uint32
+	_         [4]byte
+	Latency   uint64
+	Timestamp uint64
 }
 
 // loadBpf returns the embedded CollectionSpec for bpf.
@@ -27,6 +27,7 @@ var (
 
 func init() {
 	probe.MustRegisterEventProbe(probeName, bioLatencyProbeCreator)
+	bpfutil.RegisterBPFObject(probeName, loadBpf)
 }
 
 func bioLatencyProbeCreator(sink chan<- *probe.Event) (probe.EventProbe, error) {
@@ -92,10 +93,15 @@ func (p *BiolatencyProbe) perf() {
 			log.Warnf("%s got unspecified event, pid: %d, task %s", probeName, pid, bpfutil.GetCommString(event.Target))
 			continue
 		}
+		comm := bpfutil.GetCommString(event.Target)
 		evt := &probe.Event{
-			Timestamp: time.Now().UnixNano(),
-			Type:      "BIOLAT_10MS",
-			Message:   fmt.Sprintf("%s %d latency %s", bpfutil.GetCommString(event.Target), event.Pid, bpfutil.GetHumanTimes(event.Latency)),
+			Timestamp:       time.Now().UnixNano(),
+			Type:            "BIOLAT_10MS",
+			Message:         fmt.Sprintf("%s %d latency %s", comm, event.Pid, bpfutil.GetHumanTimes(event.Latency)),
+			Version:         probe.EventSchemaVersion,
+			KernelTimestamp: event.Timestamp,
+			Process:         &probe.EventProcess{Pid: event.Pid, Comm: comm},
+			LatencyNs:       event.Latency,
 		}
 
 		p.sink <- evt
@@ -134,7 +140,7 @@ func (p *BiolatencyProbe) loadAndAttachBPF() error {
 		KernelTypes: bpfutil.LoadBTFSpecOrNil(),
 	}
 	// Load pre-compiled programs and maps into the kernel.
-	if err := loadBpfObjects(&p.objs, &opts); err != nil {
+	if err := bpfutil.LoadObjects(probeName, &p.objs, &opts); err != nil {
 		return fmt.Errorf("loading objects: %s", err.Error())
 	}
 
@@ -42,6 +42,7 @@ type bpfInspKlEventT struct {
 	Point2    uint64
 	Point3    uint64
 	Point4    uint64
+	Timestamp uint64
 }
 
 type bpfRxlatencyT struct {
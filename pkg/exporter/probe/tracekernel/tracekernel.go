@@ -66,6 +66,7 @@ var (
 func init() {
 	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
 	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+	bpfutil.RegisterBPFObject(probeName, loadBpf)
 }
 
 func metricsProbeCreator() (probe.MetricsProbe, error) {
@@ -248,14 +249,26 @@ func (p *kernelLatencyProbe) perfLoop() {
 
 		netns := event.SkbMeta.Netns
 		evt := &probe.Event{
-			Timestamp: time.Now().UnixNano(),
-			Labels:    probe.LegacyEventLabels(netns),
+			Timestamp:       time.Now().UnixNano(),
+			Labels:          probe.LegacyEventLabels(netns),
+			Version:         probe.EventSchemaVersion,
+			KernelTimestamp: event.Timestamp,
+			Netns:           event.SkbMeta.Netns,
+			Process:         &probe.EventProcess{Pid: event.Pid, Comm: bpfutil.GetCommString(event.Target)},
+			LatencyNs:       event.Latency,
 		}
 		/*
 		   #define RX_KLATENCY 1
 		   #define TX_KLATENCY 2
 		*/
-		tuple := fmt.Sprintf("protocol=%s saddr=%s sport=%d daddr=%s dport=%d ", bpfutil.GetProtoStr(event.Tuple.L4Proto), bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Saddr))), bits.ReverseBytes16(event.Tuple.Sport), bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Daddr))), bits.ReverseBytes16(event.Tuple.Dport))
+		evt.Tuple = &probe.EventTuple{
+			Protocol: bpfutil.GetProtoStr(event.Tuple.L4Proto),
+			Src:      bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Saddr))),
+			Dst:      bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Daddr))),
+			Sport:    bits.ReverseBytes16(event.Tuple.Sport),
+			Dport:    bits.ReverseBytes16(event.Tuple.Dport),
+		}
+		tuple := fmt.Sprintf("protocol=%s saddr=%s sport=%d daddr=%s dport=%d ", evt.Tuple.Protocol, evt.Tuple.Src, evt.Tuple.Sport, evt.Tuple.Dst, evt.Tuple.Dport)
 		switch event.Direction {
 		case 1:
 			evt.Type = RXKERNEL_SLOW
@@ -307,7 +320,7 @@ func (p *kernelLatencyProbe) loadAndAttachBPF() error {
 	}
 
 	// 获取Loaded的程序/map的fd信息
-	if err := loadBpfObjects(&p.objs, &opts); err != nil {
+	if err := bpfutil.LoadObjects(probeName, &p.objs, &opts); err != nil {
 		return fmt.Errorf("loading objects: %v", err)
 	}
 
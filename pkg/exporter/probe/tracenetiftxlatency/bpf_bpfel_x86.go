@@ -34,11 +34,12 @@ type bpfInspNftxlatEventT struct {
 		Protocol uint16
 		Pad      uint16
 	}
-	Pid     uint32
-	Cpu     uint32
-	_       [4]byte
-	Latency uint64
-	StackId int64
+	Pid       uint32
+	Cpu       uint32
+	_         [4]byte
+	Latency   uint64
+	StackId   int64
+	Timestamp uint64
 }
 
 type bpfInspNftxlatMetricT struct {
@@ -46,6 +46,7 @@ var (
 func init() {
 	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
 	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+	bpfutil.RegisterBPFObject(probeName, loadBpf)
 }
 
 func metricsProbeCreator() (probe.MetricsProbe, error) {
@@ -211,10 +212,22 @@ func (p *netifTxlatencyProbe) perfLoop() {
 		}
 
 		evt := &probe.Event{
-			Timestamp: time.Now().UnixNano(),
-			Labels:    probe.LegacyEventLabels(event.SkbMeta.Netns),
+			Timestamp:       time.Now().UnixNano(),
+			Labels:          probe.LegacyEventLabels(event.SkbMeta.Netns),
+			Version:         probe.EventSchemaVersion,
+			KernelTimestamp: event.Timestamp,
+			Netns:           event.SkbMeta.Netns,
+			Process:         &probe.EventProcess{Pid: event.Pid, Comm: bpfutil.GetCommString(event.Target)},
+			LatencyNs:       event.Latency,
 		}
-		tuple := fmt.Sprintf("protocol=%s saddr=%s sport=%d daddr=%s dport=%d ", bpfutil.GetProtoStr(event.Tuple.L4Proto), bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Saddr))), bits.ReverseBytes16(event.Tuple.Sport), bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Daddr))), bits.ReverseBytes16(event.Tuple.Dport))
+		evt.Tuple = &probe.EventTuple{
+			Protocol: bpfutil.GetProtoStr(event.Tuple.L4Proto),
+			Src:      bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Saddr))),
+			Dst:      bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Daddr))),
+			Sport:    bits.ReverseBytes16(event.Tuple.Sport),
+			Dport:    bits.ReverseBytes16(event.Tuple.Dport),
+		}
+		tuple := fmt.Sprintf("protocol=%s saddr=%s sport=%d daddr=%s dport=%d ", evt.Tuple.Protocol, evt.Tuple.Src, evt.Tuple.Sport, evt.Tuple.Dst, evt.Tuple.Dport)
 		evt.Message = fmt.Sprintf("%s latency:%s", tuple, bpfutil.GetHumanTimes(event.Latency))
 		/*#define THRESH
 		#define ACTION_QDISC	    1
@@ -256,7 +269,7 @@ func (p *netifTxlatencyProbe) loadAndAttachBPF() error {
 	}
 
 	// 获取Loaded的程序/map的fd信息
-	if err := loadBpfObjects(&p.objs, &opts); err != nil {
+	if err := bpfutil.LoadObjects(probeName, &p.objs, &opts); err != nil {
 		return fmt.Errorf("loading objects: %v", err)
 	}
 
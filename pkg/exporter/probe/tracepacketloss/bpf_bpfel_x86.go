@@ -15,9 +15,12 @@ import (
 type bpfAddr struct{ V6addr [16]uint8 }
 
 type bpfInspPlEventT struct {
-	Tuple    bpfTuple
-	Location uint64
-	StackId  int64
+	Tuple     bpfTuple
+	Location  uint64
+	StackId   int64
+	Reason    uint32
+	_         [4]byte
+	Timestamp uint64
 }
 
 type bpfTuple struct {
@@ -0,0 +1,56 @@
+package tracepacketloss
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf/btf"
+)
+
+const (
+	dropReasonNotSpecified = "SKB_DROP_REASON_NOT_SPECIFIED"
+	dropReasonNetfilter    = "SKB_DROP_REASON_NETFILTER_DROP"
+)
+
+// reasons which are reported by kfree_skb tracepoint but are not drops
+var notDropReasons = map[string]bool{
+	"SKB_NOT_DROPPED_YET": true,
+	"SKB_CONSUMED":        true,
+}
+
+// dropReasons maps values of enum skb_drop_reason to names. Values of the enum change between
+// kernel versions, so names are resolved from kernel BTF. It is nil if the kernel does not
+// report drop reasons, then reasons are guessed from drop locations.
+type dropReasons map[uint32]string
+
+func loadDropReasons(spec *btf.Spec) (dropReasons, error) {
+	if spec == nil {
+		return nil, fmt.Errorf("kernel btf not found")
+	}
+	var enum *btf.Enum
+	if err := spec.TypeByName("skb_drop_reason", &enum); err != nil {
+		return nil, err
+	}
+	ret := make(dropReasons, len(enum.Values))
+	for _, v := range enum.Values {
+		ret[uint32(v.Value)] = v.Name
+	}
+	return ret, nil
+}
+
+// name returns the reason of a drop, ok is false if the skb is not dropped.
+func (r dropReasons) name(reason uint32, location string) (name string, ok bool) {
+	if r != nil {
+		n := r[reason]
+		if notDropReasons[n] {
+			return "", false
+		}
+		// reason of some drops is not specified yet, which can still be told by location
+		if n != "" && n != dropReasonNotSpecified {
+			return n, true
+		}
+	}
+	if location == netfilterSymbol {
+		return dropReasonNetfilter, true
+	}
+	return dropReasonNotSpecified, true
+}
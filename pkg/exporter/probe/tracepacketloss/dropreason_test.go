@@ -0,0 +1,64 @@
+package tracepacketloss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDropReasonName(t *testing.T) {
+	reasons := dropReasons{
+		0: "SKB_NOT_DROPPED_YET",
+		1: "SKB_CONSUMED",
+		2: dropReasonNotSpecified,
+		3: "SKB_DROP_REASON_NO_SOCKET",
+	}
+
+	name, ok := reasons.name(3, "tcp_v4_rcv")
+	assert.True(t, ok)
+	assert.Equal(t, "SKB_DROP_REASON_NO_SOCKET", name)
+
+	_, ok = reasons.name(1, "")
+	assert.False(t, ok)
+
+	// unspecified reason falls back to location
+	name, _ = reasons.name(2, netfilterSymbol)
+	assert.Equal(t, dropReasonNetfilter, name)
+	name, _ = reasons.name(100, "")
+	assert.Equal(t, dropReasonNotSpecified, name)
+
+	var legacy dropReasons
+	name, ok = legacy.name(0, netfilterSymbol)
+	assert.True(t, ok)
+	assert.Equal(t, dropReasonNetfilter, name)
+	name, _ = legacy.name(3, "tcp_v4_rcv")
+	assert.Equal(t, dropReasonNotSpecified, name)
+}
+
+func TestLoadDropReasonsWithoutBTF(t *testing.T) {
+	reasons, err := loadDropReasons(nil)
+	assert.Error(t, err)
+	assert.Nil(t, reasons)
+}
+
+func TestParseEvent(t *testing.T) {
+	event := bpfInspPlEventT{Location: 0xffff, StackId: 7, Reason: 3}
+	event.Tuple.Sport = 80
+	var buf bytes.Buffer
+	assert.NoError(t, binary.Write(&buf, binary.NativeEndian, &event))
+
+	parsed, err := parseEvent(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, event, *parsed)
+
+	// perf pads samples to 8 bytes alignment
+	parsed, err = parseEvent(append(buf.Bytes(), 0, 0, 0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, event, *parsed)
+
+	// sample of an object built before reason was added
+	_, err = parseEvent(buf.Bytes()[:56])
+	assert.Error(t, err)
+}
@@ -71,17 +71,24 @@ func init() {
 	}
 	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
 	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+	bpfutil.RegisterBPFObject(probeName, loadBpf)
 }
 
 type packetlossArgs struct {
-	EnableStack bool `mapstructure:"EnableStack"`
+	EnableStack bool `mapstructure:"EnableStack" description:"attach kernel stack of the drop location to events"`
 }
 
-func metricsProbeCreator() (probe.MetricsProbe, error) {
-	p := &metricsProbe{}
+type packetlossMetricsArgs struct {
+	EnablePortInLabel bool `mapstructure:"enablePortInLabel" description:"add source and destination ports to metric labels, every ephemeral port is a new series when enabled"`
+}
+
+func metricsProbeCreator(args packetlossMetricsArgs) (probe.MetricsProbe, error) {
+	p := &metricsProbe{
+		enablePort: args.EnablePortInLabel,
+	}
 
-	labels := probe.TupleMetricsLabels
-	labels = append(labels, "k8s_node")
+	labels := append([]string{}, probe.TupleMetricsLabels...)
+	labels = append(labels, "k8s_node", "reason")
 
 	opts := probe.BatchMetricsOpts{
 		Namespace:      probe.MetricsNamespace,
@@ -105,10 +112,13 @@ func eventProbeCreator(sink chan<- *probe.Event, args packetlossArgs) (probe.Eve
 }
 
 type metricsProbe struct {
+	enablePort bool
 }
 
 func (p *metricsProbe) Start(_ context.Context) error {
-	cfg := probeConfig{}
+	cfg := probeConfig{
+		enablePort: p.enablePort,
+	}
 	return _packetLossProbe.start(probe.ProbeTypeMetrics, &cfg)
 }
 
@@ -134,10 +144,12 @@ func (p *metricsProbe) collectOnce(emit probe.Emit) error {
 			Protocol: key.protocol,
 			Src:      key.src,
 			Dst:      key.dst,
+			Sport:    key.sport,
+			Dport:    key.dport,
 		}
 
 		labels := probe.BuildTupleMetricsLabels(tuple)
-		labels = append(labels, nettop.GetNodeName())
+		labels = append(labels, nettop.GetNodeName(), key.reason)
 		emit(packetLossTotal, labels, float64(counter.Total))
 		emit(packetLossNetfilter, labels, float64(counter.Netfilter))
 		counter.snatched = true
@@ -176,12 +188,17 @@ type Counter struct {
 
 type probeConfig struct {
 	enableStack bool
+	// enablePort keeps ports of counters, ports are zero in metric labels by default
+	enablePort bool
 }
 
 type cacheKey struct {
 	protocol uint8
 	src      string
 	dst      string
+	sport    uint16
+	dport    uint16
+	reason   string
 }
 
 type packetLossProbe struct {
@@ -191,6 +208,7 @@ type packetLossProbe struct {
 	probeConfig [probe.ProbeTypeCount]*probeConfig
 	lock        sync.Mutex
 	perfReader  *perf.Reader
+	dropReasons dropReasons
 
 	cache *lru.Cache[cacheKey, *Counter]
 }
@@ -283,25 +301,40 @@ func (p *packetLossProbe) enableStack() bool {
 	return cfg != nil && cfg.enableStack
 }
 
+func (p *packetLossProbe) enablePort() bool {
+	cfg := p.probeConfig[probe.ProbeTypeMetrics]
+	return cfg != nil && cfg.enablePort
+}
+
 func (p *packetLossProbe) loadAndAttachBPF() error {
 	// Allow the current process to lock memory for eBPF resources.
 	if err := rlimit.RemoveMemlock(); err != nil {
 		return fmt.Errorf("remove limit failed: %s", err.Error())
 	}
 
+	kernelTypes := bpfutil.LoadBTFSpecOrNil()
+	reasons, err := loadDropReasons(kernelTypes)
+	if err != nil {
+		log.Infof("%s: kernel drop reason is not available, guess reasons by drop locations: %v", probeName, err)
+	}
+	p.dropReasons = reasons
+
 	opts := ebpf.CollectionOptions{
 		Programs: ebpf.ProgramOptions{
-			KernelTypes: bpfutil.LoadBTFSpecOrNil(),
+			KernelTypes: kernelTypes,
 		},
 	}
-	if err := loadBpfObjects(&p.objs, &opts); err != nil {
+	if err := bpfutil.LoadObjects(probeName, &p.objs, &opts); err != nil {
 		return fmt.Errorf("loading objects: %s", err.Error())
 	}
 
+	// the switch is always set as the map may be pinned with the value of last run
+	var enableStack uint8
 	if p.enableStack() {
-		if err := bpfutil.UpdateFeatureSwitch(p.objs.InspPacketlossFeatureSwitch, featureSwitchEnablePacketLossStackKey, 1); err != nil {
-			return fmt.Errorf("failed update packetloss feature switch: %w", err)
-		}
+		enableStack = 1
+	}
+	if err := bpfutil.UpdateFeatureSwitch(p.objs.InspPacketlossFeatureSwitch, featureSwitchEnablePacketLossStackKey, enableStack); err != nil {
+		return fmt.Errorf("failed update packetloss feature switch: %w", err)
 	}
 
 	pl, err := link.Tracepoint("skb", "kfree_skb", p.objs.KfreeSkb, &link.TracepointOptions{})
@@ -312,16 +345,13 @@ func (p *packetLossProbe) loadAndAttachBPF() error {
 	return nil
 }
 
-func ignoreLocation(loc uint64) bool {
+func locationSymbol(loc uint64) string {
 	sym, err := bpfutil.GetSymPtFromBpfLocation(loc)
 	if err != nil {
 		log.Infof("cannot find location %d", loc)
-		// emit the event anyway
-		return false
+		return ""
 	}
-	_, ok := uselessSymbols[sym.GetName()]
-
-	return ok
+	return sym.GetName()
 }
 
 func toProbeTuple(t *bpfTuple) *probe.Tuple {
@@ -334,25 +364,17 @@ func toProbeTuple(t *bpfTuple) *probe.Tuple {
 	}
 }
 
-func (p *packetLossProbe) countByLocation(loc uint64, counter *Counter) {
-	sym, err := bpfutil.GetSymPtFromBpfLocation(loc)
-	if err != nil {
-		log.Warnf("%s get sym failed, location: %x, err: %v", probeName, loc, err)
-		return
-	}
-
-	switch sym.GetName() {
-	case netfilterSymbol:
-		counter.Netfilter++
-	}
-
-}
-
-func (p *packetLossProbe) add2Cache(loc uint64, tuple *probe.Tuple) {
+func (p *packetLossProbe) add2Cache(reason string, tuple *probe.Tuple) {
 	key := cacheKey{
 		protocol: tuple.Protocol,
 		src:      tuple.Src,
 		dst:      tuple.Dst,
+		sport:    tuple.Sport,
+		dport:    tuple.Dport,
+		reason:   reason,
+	}
+	if !p.enablePort() {
+		key.sport, key.dport = 0, 0
 	}
 
 	v, ok := p.cache.Get(key)
@@ -361,7 +383,9 @@ func (p *packetLossProbe) add2Cache(loc uint64, tuple *probe.Tuple) {
 		p.cache.Add(key, v)
 	}
 	v.Total++
-	p.countByLocation(loc, v)
+	if reason == dropReasonNetfilter {
+		v.Netfilter++
+	}
 
 	v.lastUpdate = time.Now().UnixNano()
 	v.snatched = false
@@ -385,24 +409,37 @@ func (p *packetLossProbe) perfLoop() {
 			continue
 		}
 
-		var event bpfInspPlEventT
-		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.NativeEndian, &event); err != nil {
+		event, err := parseEvent(record.RawSample)
+		if err != nil {
 			log.Errorf("%s failed parsing event, err: %v", probeName, err)
 			continue
 		}
 
-		if ignoreLocation(event.Location) {
+		location := locationSymbol(event.Location)
+		if uselessSymbols[location] {
+			continue
+		}
+		reason, dropped := p.dropReasons.name(event.Reason, location)
+		if !dropped {
 			continue
 		}
 
 		tuple := toProbeTuple(&event.Tuple)
 
-		p.add2Cache(event.Location, tuple)
+		p.add2Cache(reason, tuple)
 
+		labels := probe.BuildTupleEventLabels(tuple)
+		labels = append(labels,
+			probe.Label{Name: "reason", Value: reason},
+			probe.Label{Name: "location", Value: location},
+		)
 		evt := &probe.Event{
-			Timestamp: time.Now().UnixNano(),
-			Type:      PacketLoss,
-			Labels:    probe.BuildTupleEventLabels(tuple),
+			Timestamp:       time.Now().UnixNano(),
+			Type:            PacketLoss,
+			Labels:          labels,
+			Version:         probe.EventSchemaVersion,
+			KernelTimestamp: event.Timestamp,
+			Tuple:           probe.NewEventTuple(tuple),
 		}
 
 		if p.enableStack() {
@@ -419,6 +456,7 @@ func (p *packetLossProbe) perfLoop() {
 				strs = append(strs, sym.GetExpr())
 			}
 			evt.Message = strings.Join(strs, "\n")
+			evt.Stack = strs
 		}
 
 		if p.sink != nil {
@@ -426,3 +464,16 @@ func (p *packetLossProbe) perfLoop() {
 		}
 	}
 }
+
+// parseEvent decodes an event from perf sample, the sample may be longer than the event
+// as perf pads it to 8 bytes alignment.
+func parseEvent(raw []byte) (*bpfInspPlEventT, error) {
+	if size := int(unsafe.Sizeof(bpfInspPlEventT{})); len(raw) < size {
+		return nil, fmt.Errorf("invalid sample size %d, expect at least %d", len(raw), size)
+	}
+	event := &bpfInspPlEventT{}
+	if err := binary.Read(bytes.NewReader(raw), binary.NativeEndian, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
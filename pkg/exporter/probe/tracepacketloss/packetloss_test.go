@@ -0,0 +1,30 @@
+package tracepacketloss
+
+import (
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdd2CachePorts(t *testing.T) {
+	cache, err := lru.New[cacheKey, *Counter](16)
+	assert.NoError(t, err)
+	p := &packetLossProbe{cache: cache}
+	p.probeConfig[probe.ProbeTypeMetrics] = &probeConfig{}
+
+	// ports are dropped by default to bound series of ephemeral ports
+	p.add2Cache("SKB_DROP_REASON_NO_SOCKET", &probe.Tuple{Protocol: 6, Src: "10.0.0.1", Dst: "10.0.0.2", Sport: 40000, Dport: 80})
+	p.add2Cache("SKB_DROP_REASON_NO_SOCKET", &probe.Tuple{Protocol: 6, Src: "10.0.0.1", Dst: "10.0.0.2", Sport: 40001, Dport: 80})
+	assert.Equal(t, 1, cache.Len())
+	counter, ok := cache.Get(cacheKey{protocol: 6, src: "10.0.0.1", dst: "10.0.0.2", reason: "SKB_DROP_REASON_NO_SOCKET"})
+	assert.True(t, ok)
+	assert.Equal(t, uint32(2), counter.Total)
+
+	cache.Purge()
+	p.probeConfig[probe.ProbeTypeMetrics] = &probeConfig{enablePort: true}
+	p.add2Cache("SKB_DROP_REASON_NO_SOCKET", &probe.Tuple{Protocol: 6, Src: "10.0.0.1", Dst: "10.0.0.2", Sport: 40000, Dport: 80})
+	p.add2Cache("SKB_DROP_REASON_NO_SOCKET", &probe.Tuple{Protocol: 6, Src: "10.0.0.1", Dst: "10.0.0.2", Sport: 40001, Dport: 80})
+	assert.Equal(t, 2, cache.Len())
+}
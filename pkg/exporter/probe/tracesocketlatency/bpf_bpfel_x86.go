@@ -38,6 +38,8 @@ type bpfInspSklatEventT struct {
 	Direction uint32
 	_         [4]byte
 	Latency   uint64
+	CgroupId  uint64
+	Timestamp uint64
 }
 
 type bpfInspSklatMetricT struct {
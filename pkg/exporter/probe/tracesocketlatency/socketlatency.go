@@ -1,9 +1,7 @@
 package tracesocketlatency
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/bits"
@@ -69,6 +67,7 @@ var (
 func init() {
 	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
 	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+	bpfutil.RegisterBPFObject(probeName, loadBpf)
 }
 
 func metricsProbeCreator() (probe.MetricsProbe, error) {
@@ -206,7 +205,7 @@ func (p *socketLatencyProbe) perfLoop() {
 		}
 
 		var event bpfInspSklatEventT
-		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+		if err := bpfutil.ReadSample(record.RawSample, &event); err != nil {
 			log.Infof("%s failed parsing event, err: %v", probeName, err)
 			continue
 		}
@@ -214,9 +213,15 @@ func (p *socketLatencyProbe) perfLoop() {
 		if event.Tuple.Dport == 0 && event.Tuple.Sport == 0 {
 			continue
 		}
+		process := probe.NewEventProcess(event.Pid, bpfutil.GetCommString(event.Target), event.CgroupId, event.SkbMeta.Netns)
 		evt := &probe.Event{
-			Timestamp: time.Now().UnixNano(),
-			Labels:    probe.LegacyEventLabels(event.SkbMeta.Netns),
+			Timestamp:       time.Now().UnixNano(),
+			Labels:          append(probe.LegacyEventLabels(event.SkbMeta.Netns), probe.BuildProcessEventLabels(process)...),
+			Version:         probe.EventSchemaVersion,
+			KernelTimestamp: event.Timestamp,
+			Netns:           event.SkbMeta.Netns,
+			Process:         process,
+			LatencyNs:       event.Latency,
 		}
 		/*
 			#define ACTION_READ	    1
@@ -228,7 +233,14 @@ func (p *socketLatencyProbe) perfLoop() {
 			evt.Type = SOCKETLAT_SENDSLOW
 		}
 
-		tuple := fmt.Sprintf("protocol=%s saddr=%s sport=%d daddr=%s dport=%d ", bpfutil.GetProtoStr(event.Tuple.L4Proto), bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Saddr))), bits.ReverseBytes16(event.Tuple.Sport), bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Daddr))), bits.ReverseBytes16(event.Tuple.Dport))
+		evt.Tuple = &probe.EventTuple{
+			Protocol: bpfutil.GetProtoStr(event.Tuple.L4Proto),
+			Src:      bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Saddr))),
+			Dst:      bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Daddr))),
+			Sport:    bits.ReverseBytes16(event.Tuple.Sport),
+			Dport:    bits.ReverseBytes16(event.Tuple.Dport),
+		}
+		tuple := fmt.Sprintf("protocol=%s saddr=%s sport=%d daddr=%s dport=%d ", evt.Tuple.Protocol, evt.Tuple.Src, evt.Tuple.Sport, evt.Tuple.Dst, evt.Tuple.Dport)
 		evt.Message = fmt.Sprintf("%s latency=%s", tuple, bpfutil.GetHumanTimes(event.Latency))
 		if p.sink != nil {
 			log.Debugf("%s sink event %s", probeName, util.ToJSONString(evt))
@@ -296,7 +308,7 @@ func (p *socketLatencyProbe) loadAndAttachBPF() error {
 	}
 
 	// Load pre-compiled programs and maps into the kernel.
-	if err := loadBpfObjects(&p.objs, &opts); err != nil {
+	if err := bpfutil.LoadObjects(probeName, &p.objs, &opts); err != nil {
 		return fmt.Errorf("loading objects: %s", err.Error())
 	}
 
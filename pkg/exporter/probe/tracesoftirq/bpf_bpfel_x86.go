@@ -18,11 +18,12 @@ type bpfInspSoftirqEntryKey struct {
 }
 
 type bpfInspSoftirqEventT struct {
-	Pid     uint32
-	Cpu     uint32
-	Phase   uint32
-	VecNr   uint32
-	Latency uint64
+	Pid       uint32
+	Cpu       uint32
+	Phase     uint32
+	VecNr     uint32
+	Latency   uint64
+	Timestamp uint64
 }
 
 // loadBpf returns the embedded CollectionSpec for bpf.
@@ -50,10 +50,11 @@ var (
 func init() {
 	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
 	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+	bpfutil.RegisterBPFObject(probeName, loadBpf)
 }
 
 type softirqArgs struct {
-	SoftirqTypes []string `mapstructure:"softirq-types"`
+	SoftirqTypes []string `mapstructure:"softirq-types" description:"softirq types to trace, e.g. net_rx, net_tx, default net_rx"`
 }
 
 func metricsProbeCreator(args softirqArgs) (probe.MetricsProbe, error) {
@@ -261,6 +262,10 @@ func (p *softirqProbe) perfLoop() {
 					Value: convertIrqType(event.VecNr),
 				},
 			},
+			Version:         probe.EventSchemaVersion,
+			KernelTimestamp: event.Timestamp,
+			Process:         &probe.EventProcess{Pid: event.Pid},
+			LatencyNs:       event.Latency,
 		}
 
 		/*
@@ -320,7 +325,7 @@ func (p *softirqProbe) loadAndAttachBPF() error {
 		return err
 	}
 
-	err = spec.LoadAndAssign(&p.objs, &opts)
+	err = bpfutil.LoadAndAssign(probeName, spec, &p.objs, &opts)
 	if err != nil {
 		return err
 	}
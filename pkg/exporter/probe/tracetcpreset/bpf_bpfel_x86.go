@@ -36,6 +36,14 @@ type bpfInspTcpresetEventT struct {
 	}
 	_       [7]byte
 	StackId int64
+	Task    struct {
+		Pid      uint32
+		Tid      uint32
+		CgroupId uint64
+		Comm     [20]int8
+	}
+	_         [4]byte
+	Timestamp uint64
 }
 
 // loadBpf returns the embedded CollectionSpec for bpf.
@@ -1,13 +1,10 @@
 package tracetcpreset
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/bits"
-	"syscall"
 	"time"
 	"unsafe"
 
@@ -33,12 +30,21 @@ const (
 	TCPRESET_RECEIVE = "TCPRESET_RECEIVE"
 )
 
+// types of reset, same as RESET_* in tcpreset.c
+const (
+	resetNoSock  = 1
+	resetActive  = 2
+	resetProcess = 4
+	resetReceive = 8
+)
+
 var (
 	probeName = "tcpreset"
 )
 
 func init() {
 	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+	bpfutil.RegisterBPFObject(probeName, loadBpf)
 }
 
 func eventProbeCreator(sink chan<- *probe.Event) (probe.EventProbe, error) {
@@ -92,48 +98,47 @@ func (p *tcpResetProbe) perfLoop() {
 		}
 
 		var event bpfInspTcpresetEventT
-		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+		if err := bpfutil.ReadSample(record.RawSample, &event); err != nil {
 			log.Infof("%s failed parsing event, err: %v", probeName, err)
 			continue
 		}
 
-		/*
-			#define RESET_NOSOCK 1
-			#define RESET_ACTIVE 2
-			#define RESET_PROCESS 4
-			#define RESET_RECEIVE 8
-		*/
-
 		var eventType probe.EventType
 
 		switch event.Type {
-		case 1:
+		case resetNoSock:
 			eventType = TCPRESET_NOSOCK
-		case 2:
+		case resetActive:
 			eventType = TCPRESET_ACTIVE
-		case 4:
+		case resetProcess:
 			eventType = TCPRESET_PROCESS
-		case 8:
+		case resetReceive:
 			eventType = TCPRESET_RECEIVE
 		default:
 			log.Infof("%s got invalid perf event type %d, data: %s", probeName, event.Type, util.ToJSONString(event))
 			continue
 		}
 
-		if event.Tuple.L3Proto == syscall.ETH_P_IPV6 {
-			log.Infof("%s ignore event of ipv6 proto", probeName)
-			continue
-		}
+		tuple := toProbeTuple(&event)
+		labels := probe.LegacyEventLabels(event.SkbMeta.Netns)
+		labels = append(labels, probe.BuildTupleEventLabels(tuple)...)
+		process := probe.NewEventProcess(event.Task.Pid, bpfutil.GetCommString(event.Task.Comm), event.Task.CgroupId, event.SkbMeta.Netns)
+		labels = append(labels, probe.BuildProcessEventLabels(process)...)
 
 		evt := &probe.Event{
-			Timestamp: time.Now().UnixNano(),
-			Type:      eventType,
-			Labels:    probe.LegacyEventLabels(event.SkbMeta.Netns),
+			Timestamp:       time.Now().UnixNano(),
+			Type:            eventType,
+			Labels:          labels,
+			Version:         probe.EventSchemaVersion,
+			KernelTimestamp: event.Timestamp,
+			Tuple:           probe.NewEventTuple(tuple),
+			Process:         process,
+			Netns:           event.SkbMeta.Netns,
 		}
 
-		tuple := fmt.Sprintf("protocol=%s saddr=%s sport=%d daddr=%s dport=%d ", bpfutil.GetProtoStr(event.Tuple.L4Proto), bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Saddr))), bits.ReverseBytes16(event.Tuple.Sport), bpfutil.GetAddrStr(event.Tuple.L3Proto, *(*[16]byte)(unsafe.Pointer(&event.Tuple.Daddr))), bits.ReverseBytes16(event.Tuple.Dport))
+		tupleStr := fmt.Sprintf("protocol=%s saddr=%s sport=%d daddr=%s dport=%d ", bpfutil.GetProtoStr(tuple.Protocol), tuple.Src, tuple.Sport, tuple.Dst, tuple.Dport)
 		stateStr := bpfutil.GetSkcStateStr(event.State)
-		evt.Message = fmt.Sprintf("%s state:%s ", tuple, stateStr)
+		evt.Message = fmt.Sprintf("%s state:%s ", tupleStr, stateStr)
 		if p.sink != nil {
 			log.Debugf("%s sink event: %s", probeName, util.ToJSONString(evt))
 			p.sink <- evt
@@ -141,6 +146,23 @@ func (p *tcpResetProbe) perfLoop() {
 	}
 }
 
+// toProbeTuple converts tuple of both ipv4 and ipv6 events. Ports of tuples from skb are
+// in host byte order, while the remote port of tuples from sock is in network byte order.
+func toProbeTuple(event *bpfInspTcpresetEventT) *probe.Tuple {
+	t := &event.Tuple
+	tuple := &probe.Tuple{
+		Protocol: t.L4Proto,
+		Src:      bpfutil.GetAddrStr(t.L3Proto, t.Saddr.V6addr),
+		Dst:      bpfutil.GetAddrStr(t.L3Proto, t.Daddr.V6addr),
+		Sport:    t.Sport,
+		Dport:    t.Dport,
+	}
+	if event.Type == resetActive || event.Type == resetReceive {
+		tuple.Dport = bits.ReverseBytes16(t.Dport)
+	}
+	return tuple
+}
+
 func (p *tcpResetProbe) Stop(_ context.Context) error {
 	return p.cleanup()
 }
@@ -174,7 +196,7 @@ func (p *tcpResetProbe) loadAndAttachBPF() error {
 	}
 
 	// 获取Loaded的程序/map的fd信息
-	if err := loadBpfObjects(&p.objs, &opts); err != nil {
+	if err := bpfutil.LoadObjects(probeName, &p.objs, &opts); err != nil {
 		return fmt.Errorf("loading objects: %v", err)
 	}
 
@@ -184,6 +206,14 @@ func (p *tcpResetProbe) loadAndAttachBPF() error {
 	}
 	p.links = append(p.links, progsend)
 
+	// ipv6 may be disabled or built as a module which is not loaded
+	progsend6, err := link.Kprobe("tcp_v6_send_reset", p.objs.TraceSendreset, &link.KprobeOptions{})
+	if err != nil {
+		log.Warnf("%s failed link tcp_v6_send_reset, resets of ipv6 without socket are not traced: %v", probeName, err)
+	} else {
+		p.links = append(p.links, progsend6)
+	}
+
 	progactive, err := link.Kprobe("tcp_send_active_reset", p.objs.TraceSendactive, &link.KprobeOptions{})
 	if err != nil {
 		return fmt.Errorf("link tcp_send_active_reset: %s", err.Error())
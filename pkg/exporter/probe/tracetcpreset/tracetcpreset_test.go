@@ -0,0 +1,90 @@
+package tracetcpreset
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/bpfutil"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToProbeTuple(t *testing.T) {
+	var event bpfInspTcpresetEventT
+	event.Type = resetNoSock
+	event.Tuple.L3Proto = syscall.ETH_P_IPV6
+	event.Tuple.L4Proto = syscall.IPPROTO_TCP
+	copy(event.Tuple.Saddr.V6addr[:], net.ParseIP("fd00::1"))
+	copy(event.Tuple.Daddr.V6addr[:], net.ParseIP("fd00::2"))
+	event.Tuple.Sport = 12345
+	event.Tuple.Dport = 80
+
+	tuple := toProbeTuple(&event)
+	assert.Equal(t, "fd00::1", tuple.Src)
+	assert.Equal(t, "fd00::2", tuple.Dst)
+	assert.Equal(t, uint16(12345), tuple.Sport)
+	assert.Equal(t, uint16(80), tuple.Dport)
+
+	// remote port of sock is in network byte order
+	event = bpfInspTcpresetEventT{}
+	event.Type = resetReceive
+	event.Tuple.L3Proto = syscall.ETH_P_IP
+	copy(event.Tuple.Saddr.V6addr[:], net.ParseIP("10.0.0.1").To4())
+	copy(event.Tuple.Daddr.V6addr[:], net.ParseIP("10.0.0.2").To4())
+	event.Tuple.Sport = 80
+	event.Tuple.Dport = 0x3930
+
+	tuple = toProbeTuple(&event)
+	assert.Equal(t, "10.0.0.1", tuple.Src)
+	assert.Equal(t, "10.0.0.2", tuple.Dst)
+	assert.Equal(t, uint16(80), tuple.Sport)
+	assert.Equal(t, uint16(12345), tuple.Dport)
+}
+
+func TestReadIPv6Sample(t *testing.T) {
+	spec, err := loadBpf()
+	assert.NoError(t, err)
+	var event *btf.Struct
+	assert.NoError(t, spec.Types.TypeByName("insp_tcpreset_event_t", &event))
+	offsets := memberOffsets(event, "")
+
+	// sample written by the embedded object when an ipv6 socket receives a reset
+	sample := make([]byte, event.Size)
+	binary.NativeEndian.PutUint32(sample[offsets["type"]:], resetReceive)
+	copy(sample[offsets["tuple.saddr"]:], net.ParseIP("fd00::1"))
+	copy(sample[offsets["tuple.daddr"]:], net.ParseIP("fd00::2"))
+	binary.NativeEndian.PutUint16(sample[offsets["tuple.sport"]:], 80)
+	binary.BigEndian.PutUint16(sample[offsets["tuple.dport"]:], 12345)
+	binary.NativeEndian.PutUint16(sample[offsets["tuple.l3_proto"]:], syscall.ETH_P_IPV6)
+	sample[offsets["tuple.l4_proto"]] = syscall.IPPROTO_TCP
+	binary.NativeEndian.PutUint32(sample[offsets["task.pid"]:], 42)
+	binary.NativeEndian.PutUint64(sample[offsets["timestamp"]:], 123456789)
+
+	var parsed bpfInspTcpresetEventT
+	assert.NoError(t, bpfutil.ReadSample(sample, &parsed))
+	tuple := toProbeTuple(&parsed)
+	assert.Equal(t, "fd00::1", tuple.Src)
+	assert.Equal(t, "fd00::2", tuple.Dst)
+	assert.Equal(t, uint16(80), tuple.Sport)
+	assert.Equal(t, uint16(12345), tuple.Dport)
+	assert.Equal(t, uint8(syscall.IPPROTO_TCP), tuple.Protocol)
+	assert.Equal(t, uint64(123456789), parsed.Timestamp)
+	assert.Equal(t, uint32(42), parsed.Task.Pid)
+}
+
+// memberOffsets returns byte offsets of members of the struct by their dotted path.
+func memberOffsets(s *btf.Struct, prefix string) map[string]int {
+	ret := map[string]int{}
+	for _, m := range s.Members {
+		name := prefix + m.Name
+		ret[name] = int(m.Offset.Bytes())
+		if nested, ok := btf.UnderlyingType(m.Type).(*btf.Struct); ok {
+			for k, v := range memberOffsets(nested, name+".") {
+				ret[k] = ret[name] + v
+			}
+		}
+	}
+	return ret
+}
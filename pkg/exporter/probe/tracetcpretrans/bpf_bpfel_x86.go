@@ -15,8 +15,18 @@ import (
 type bpfAddr struct{ V6addr [16]uint8 }
 
 type bpfInspTcpretransEventT struct {
-	Tuple   bpfTuple
-	StackId int64
+	Tuple     bpfTuple
+	StackId   int64
+	Task      bpfTaskMeta
+	Netns     uint32
+	Timestamp uint64
+}
+
+type bpfTaskMeta struct {
+	Pid      uint32
+	Tid      uint32
+	CgroupId uint64
+	Comm     [20]int8
 }
 
 type bpfTuple struct {
@@ -1,12 +1,9 @@
 package tracetcpretrans
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"syscall"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
@@ -27,7 +24,7 @@ import (
 	"github.com/cilium/ebpf/rlimit"
 )
 
-//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target=${GOARCH} -cc clang -cflags $BPF_CFLAGS -type insp_tcpretrans_event_t -type tuple -type addr bpf ../../../../bpf/tcpretrans.c -- -I../../../../bpf/headers -D__TARGET_ARCH_${GOARCH}
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target=${GOARCH} -cc clang -cflags $BPF_CFLAGS -type insp_tcpretrans_event_t -type tuple -type addr -type task_meta bpf ../../../../bpf/tcpretrans.c -- -I../../../../bpf/headers -D__TARGET_ARCH_${GOARCH}
 
 // nolint
 const (
@@ -46,21 +43,30 @@ var (
 
 func init() {
 	var err error
-	_tcpRetransProbe.cache, err = lru.New[probe.Tuple, *Counter](102400)
+	_tcpRetransProbe.cache, err = lru.New[counterKey, *Counter](102400)
 	if err != nil {
 		panic(fmt.Sprintf("cannot create lru cache for packetloss probe:%v", err))
 	}
 	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
 	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+	bpfutil.RegisterBPFObject(probeName, loadBpf)
 }
 
-func metricsProbeCreator() (probe.MetricsProbe, error) {
-	p := &metricsProbe{}
+type metricsArgs struct {
+	ProcessLabels bool `mapstructure:"processLabels" description:"add container and process labels, which are empty if retransmits happen in softirq of other pods"`
+}
+
+func metricsProbeCreator(args metricsArgs) (probe.MetricsProbe, error) {
+	p := &metricsProbe{args: args}
 
+	labels := probe.TupleMetricsLabels
+	if args.ProcessLabels {
+		labels = append(append([]string{}, labels...), probe.ProcessLabels...)
+	}
 	opts := probe.BatchMetricsOpts{
 		Namespace:      probe.MetricsNamespace,
 		Subsystem:      probeName,
-		VariableLabels: probe.TupleMetricsLabels,
+		VariableLabels: labels,
 		SingleMetricsOpts: []probe.SingleMetricsOpts{
 			{Name: retransTotal, ValueType: prometheus.CounterValue},
 			//{Name: retransFast, ValueType: prometheus.CounterValue},
@@ -78,13 +84,15 @@ func eventProbeCreator(sink chan<- *probe.Event, _ map[string]interface{}) (prob
 }
 
 type probeConfig struct {
+	processLabels bool
 }
 
 type metricsProbe struct {
+	args metricsArgs
 }
 
 func (p *metricsProbe) Start(_ context.Context) error {
-	return _tcpRetransProbe.start(probe.ProbeTypeMetrics, &probeConfig{})
+	return _tcpRetransProbe.start(probe.ProbeTypeMetrics, &probeConfig{processLabels: p.args.ProcessLabels})
 }
 
 func (p *metricsProbe) Stop(_ context.Context) error {
@@ -93,13 +101,16 @@ func (p *metricsProbe) Stop(_ context.Context) error {
 
 func (p *metricsProbe) collectOnce(emit probe.Emit) error {
 	keys := _tcpRetransProbe.cache.Keys()
-	for _, tuple := range keys {
-		counter, ok := _tcpRetransProbe.cache.Get(tuple)
+	for _, key := range keys {
+		counter, ok := _tcpRetransProbe.cache.Get(key)
 		if !ok || counter == nil {
 			continue
 		}
 
-		labels := probe.BuildTupleMetricsLabels(&tuple)
+		labels := probe.BuildTupleMetricsLabels(&key.tuple)
+		if p.args.ProcessLabels {
+			labels = append(labels, key.container, key.process)
+		}
 		emit(retransTotal, labels, float64(counter.Total))
 		//emit(retransFast, labels, float64(counter.Fast))
 	}
@@ -129,6 +140,13 @@ type Counter struct {
 	Fast  uint32
 }
 
+// counterKey is the key of counters, container and process are empty unless processLabels is enabled.
+type counterKey struct {
+	tuple     probe.Tuple
+	container string
+	process   string
+}
+
 type tcpRetransProbe struct {
 	objs        bpfObjects
 	links       []link.Link
@@ -137,7 +155,9 @@ type tcpRetransProbe struct {
 	lock        sync.Mutex
 	perfReader  *perf.Reader
 
-	cache *lru.Cache[probe.Tuple, *Counter]
+	cache *lru.Cache[counterKey, *Counter]
+	// processLabels is set before perfLoop starts
+	processLabels bool
 }
 
 func (p *tcpRetransProbe) probeCount() int {
@@ -215,7 +235,13 @@ func (p *tcpRetransProbe) reinstallBPFLocked() (err error) {
 		return fmt.Errorf("%s error create perf reader, err: %w", probeName, err)
 	}
 
-	go p.perfLoop()
+	// the loop gets its own copies, the previous loop may still be draining when the probe
+	// is reinstalled
+	processLabels := false
+	if cfg := p.probeConfig[probe.ProbeTypeMetrics]; cfg != nil {
+		processLabels = cfg.processLabels
+	}
+	go p.perfLoop(p.perfReader, p.objs.InspTcpRetransStack, processLabels)
 
 	return nil
 }
@@ -233,7 +259,7 @@ func (p *tcpRetransProbe) loadAndAttachBPF() error {
 	}
 
 	// Load pre-compiled programs and maps into the kernel.
-	if err := loadBpfObjects(&p.objs, &opts); err != nil {
+	if err := bpfutil.LoadObjects(probeName, &p.objs, &opts); err != nil {
 		return fmt.Errorf("loading objects: %s", err.Error())
 	}
 
@@ -247,7 +273,6 @@ func (p *tcpRetransProbe) loadAndAttachBPF() error {
 }
 
 func toProbeTuple(t *bpfTuple) *probe.Tuple {
-	t.L3Proto = syscall.ETH_P_IPV6
 	return &probe.Tuple{
 		Protocol: t.L4Proto,
 		Src:      bpfutil.GetAddrStr(t.L3Proto, t.Saddr.V6addr),
@@ -257,10 +282,10 @@ func toProbeTuple(t *bpfTuple) *probe.Tuple {
 	}
 }
 
-func (p *tcpRetransProbe) perfLoop() {
+func (p *tcpRetransProbe) perfLoop(reader *perf.Reader, stackMap *ebpf.Map, processLabels bool) {
 	for {
 	anotherLoop:
-		record, err := p.perfReader.Read()
+		record, err := reader.Read()
 		if err != nil {
 			if errors.Is(err, ringbuf.ErrClosed) {
 				log.Infof("%s received signal, exiting..", probeName)
@@ -276,28 +301,39 @@ func (p *tcpRetransProbe) perfLoop() {
 		}
 
 		var event bpfInspTcpretransEventT
-		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.NativeEndian, &event); err != nil {
+		if err := bpfutil.ReadSample(record.RawSample, &event); err != nil {
 			log.Errorf("%s failed parsing event, err: %v", probeName, err)
 			continue
 		}
 
 		tuple := toProbeTuple(&event.Tuple)
+		process := probe.NewEventProcess(event.Task.Pid, bpfutil.GetCommString(event.Task.Comm), event.Task.CgroupId, event.Netns)
 
-		v, ok := p.cache.Get(*tuple)
+		key := counterKey{tuple: *tuple}
+		if processLabels {
+			values := probe.BuildProcessLabelValues(process)
+			key.container, key.process = values[0], values[1]
+		}
+		v, ok := p.cache.Get(key)
 		if !ok {
 			v = &Counter{}
-			p.cache.Add(*tuple, v)
+			p.cache.Add(key, v)
 		}
 		v.Total++
 
 		evt := &probe.Event{
-			Timestamp: time.Now().UnixNano(),
-			Type:      TCPRetrans,
-			Labels:    probe.BuildTupleEventLabels(tuple),
+			Timestamp:       time.Now().UnixNano(),
+			Type:            TCPRetrans,
+			Labels:          append(probe.BuildTupleEventLabels(tuple), probe.BuildProcessEventLabels(process)...),
+			Version:         probe.EventSchemaVersion,
+			KernelTimestamp: event.Timestamp,
+			Tuple:           probe.NewEventTuple(tuple),
+			Process:         process,
+			Netns:           event.Netns,
 		}
 
 		//TODO add trigger to enable/disable stack
-		stacks, err := bpfutil.GetSymsByStack(uint32(event.StackId), p.objs.InspTcpRetransStack)
+		stacks, err := bpfutil.GetSymsByStack(uint32(event.StackId), stackMap)
 		if err != nil {
 			log.Warnf("%s failed get sym by stack, err: %v", probeName, err)
 			continue
@@ -311,6 +347,7 @@ func (p *tcpRetransProbe) perfLoop() {
 		}
 
 		evt.Message = strings.Join(strs, "\n")
+		evt.Stack = strs
 
 		if p.sink != nil {
 			p.sink <- evt
@@ -0,0 +1,97 @@
+package tracetcpretrans
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/bpfutil"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToProbeTuple(t *testing.T) {
+	tuple := &bpfTuple{L3Proto: syscall.ETH_P_IPV6, L4Proto: syscall.IPPROTO_TCP, Sport: 80, Dport: 12345}
+	copy(tuple.Saddr.V6addr[:], net.ParseIP("fd00::1"))
+	copy(tuple.Daddr.V6addr[:], net.ParseIP("fd00::2"))
+	ret := toProbeTuple(tuple)
+	assert.Equal(t, "fd00::1", ret.Src)
+	assert.Equal(t, "fd00::2", ret.Dst)
+	assert.Equal(t, uint16(80), ret.Sport)
+
+	tuple = &bpfTuple{L3Proto: syscall.ETH_P_IP, L4Proto: syscall.IPPROTO_TCP}
+	copy(tuple.Saddr.V6addr[:], net.ParseIP("10.0.0.1").To4())
+	copy(tuple.Daddr.V6addr[:], net.ParseIP("10.0.0.2").To4())
+	ret = toProbeTuple(tuple)
+	assert.Equal(t, "10.0.0.1", ret.Src)
+	assert.Equal(t, "10.0.0.2", ret.Dst)
+}
+
+func TestReadSample(t *testing.T) {
+	// size of struct insp_tcpretrans_event_t
+	assert.Equal(t, 96, binary.Size(bpfInspTcpretransEventT{}))
+
+	sample := make([]byte, 96)
+	binary.NativeEndian.PutUint32(sample[48:], 42)
+	copy(sample[64:], "curl")
+	binary.NativeEndian.PutUint32(sample[84:], 4026531992)
+	var event bpfInspTcpretransEventT
+	assert.NoError(t, bpfutil.ReadSample(sample, &event))
+	assert.Equal(t, uint32(42), event.Task.Pid)
+	assert.Equal(t, "curl", bpfutil.GetCommString(event.Task.Comm))
+	assert.Equal(t, uint32(4026531992), event.Netns)
+
+	// perf pads samples to 8 bytes alignment
+	event = bpfInspTcpretransEventT{}
+	assert.NoError(t, bpfutil.ReadSample(append(sample, 0, 0, 0, 0), &event))
+	assert.Equal(t, uint32(42), event.Task.Pid)
+
+	// sample of an object built before task meta was added
+	assert.Error(t, bpfutil.ReadSample(sample[:48], &event))
+}
+
+func TestReadIPv6Sample(t *testing.T) {
+	spec, err := loadBpf()
+	assert.NoError(t, err)
+	var event *btf.Struct
+	assert.NoError(t, spec.Types.TypeByName("insp_tcpretrans_event_t", &event))
+	offsets := memberOffsets(event, "")
+
+	// sample written by the embedded object for an ipv6 socket
+	sample := make([]byte, event.Size)
+	copy(sample[offsets["tuple.saddr"]:], net.ParseIP("fd00::1"))
+	copy(sample[offsets["tuple.daddr"]:], net.ParseIP("fd00::2"))
+	binary.NativeEndian.PutUint16(sample[offsets["tuple.sport"]:], 80)
+	binary.NativeEndian.PutUint16(sample[offsets["tuple.dport"]:], 12345)
+	binary.NativeEndian.PutUint16(sample[offsets["tuple.l3_proto"]:], syscall.ETH_P_IPV6)
+	sample[offsets["tuple.l4_proto"]] = syscall.IPPROTO_TCP
+	binary.NativeEndian.PutUint32(sample[offsets["netns"]:], 4026531992)
+	binary.NativeEndian.PutUint64(sample[offsets["timestamp"]:], 123456789)
+
+	var parsed bpfInspTcpretransEventT
+	assert.NoError(t, bpfutil.ReadSample(sample, &parsed))
+	tuple := toProbeTuple(&parsed.Tuple)
+	assert.Equal(t, "fd00::1", tuple.Src)
+	assert.Equal(t, "fd00::2", tuple.Dst)
+	assert.Equal(t, uint16(80), tuple.Sport)
+	assert.Equal(t, uint16(12345), tuple.Dport)
+	assert.Equal(t, uint8(syscall.IPPROTO_TCP), tuple.Protocol)
+	assert.Equal(t, uint64(123456789), parsed.Timestamp)
+	assert.Equal(t, uint32(4026531992), parsed.Netns)
+}
+
+// memberOffsets returns byte offsets of members of the struct by their dotted path.
+func memberOffsets(s *btf.Struct, prefix string) map[string]int {
+	ret := map[string]int{}
+	for _, m := range s.Members {
+		name := prefix + m.Name
+		ret[name] = int(m.Offset.Bytes())
+		if nested, ok := btf.UnderlyingType(m.Type).(*btf.Struct); ok {
+			for k, v := range memberOffsets(nested, name+".") {
+				ret[k] = ret[name] + v
+			}
+		}
+	}
+	return ret
+}
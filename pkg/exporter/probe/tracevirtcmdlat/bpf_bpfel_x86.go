@@ -13,9 +13,10 @@ import (
 )
 
 type bpfInspVirtcmdlatEventT struct {
-	Pid     uint32
-	Cpu     uint32
-	Latency uint64
+	Pid       uint32
+	Cpu       uint32
+	Latency   uint64
+	Timestamp uint64
 }
 
 // loadBpf returns the embedded CollectionSpec for bpf.
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
@@ -49,6 +50,7 @@ var (
 func init() {
 	probe.MustRegisterMetricsProbe(probeName, metricsProbeCreator)
 	probe.MustRegisterEventProbe(probeName, eventProbeCreator)
+	bpfutil.RegisterBPFObject(probeName, loadBpf)
 }
 
 func metricsProbeCreator(_ map[string]interface{}) (probe.MetricsProbe, error) {
@@ -210,7 +212,12 @@ func (p *virtcmdLatencyProbe) perfLoop() {
 		}
 
 		evt := &probe.Event{
-			Type: VIRTCMDEXCUTE,
+			Timestamp:       time.Now().UnixNano(),
+			Type:            VIRTCMDEXCUTE,
+			Version:         probe.EventSchemaVersion,
+			KernelTimestamp: event.Timestamp,
+			Process:         &probe.EventProcess{Pid: event.Pid},
+			LatencyNs:       event.Latency,
 		}
 
 		p.updateMetrics(VIRTCMD)
@@ -239,7 +246,7 @@ func (p *virtcmdLatencyProbe) loadAndAttachBPF() error {
 	}
 
 	// Load pre-compiled programs and maps into the kernel.
-	if err := loadBpfObjects(&p.objs, &opts); err != nil {
+	if err := bpfutil.LoadObjects(probeName, &p.objs, &opts); err != nil {
 		return fmt.Errorf("loading objects: %s", err.Error())
 	}
 
@@ -4,6 +4,8 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/stretchr/testify/assert"
 	"golang.org/x/exp/slices"
 )
 
@@ -107,3 +109,16 @@ func TestCreateStructFromTypeWithArgs(t *testing.T) {
 		t.Fatalf("expect %+v, actual %+v", m, mr)
 	}
 }
+
+func TestCreateStructWithSquashedArgs(t *testing.T) {
+	type args struct {
+		Port                 int `mapstructure:"port"`
+		nettop.NamespaceArgs `mapstructure:",squash"`
+	}
+	v, err := createStructFromTypeWithArgs(reflect.TypeOf(args{}), map[string]interface{}{
+		"port":       80,
+		"namespaces": []interface{}{"default"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, args{Port: 80, NamespaceArgs: nettop.NamespaceArgs{Namespaces: []string{"default"}}}, v.Interface())
+}
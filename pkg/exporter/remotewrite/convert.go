@@ -0,0 +1,126 @@
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const metricNameLabel = "__name__"
+
+// ConvertMetricFamilies converts gathered metrics to a remote write request. External labels
+// are added to every series unless the series has a label with the same name.
+func ConvertMetricFamilies(families []*dto.MetricFamily, externalLabels map[string]string, timestamp int64) *WriteRequest {
+	req := &WriteRequest{}
+	for _, family := range families {
+		name := family.GetName()
+		req.Metadata = append(req.Metadata, MetricMetadata{
+			Type:             metricType(family.GetType()),
+			MetricFamilyName: name,
+			Help:             family.GetHelp(),
+		})
+
+		for _, m := range family.GetMetric() {
+			ts := timestamp
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			add := func(name string, value float64, extra ...Label) {
+				req.Timeseries = append(req.Timeseries, TimeSeries{
+					Labels:  buildLabels(name, m.GetLabel(), externalLabels, extra...),
+					Samples: []Sample{{Value: value, Timestamp: ts}},
+				})
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				hasInf := false
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						hasInf = true
+					}
+					add(name+"_bucket", float64(b.GetCumulativeCount()), Label{Name: "le", Value: formatFloat(b.GetUpperBound())})
+				}
+				if !hasInf {
+					add(name+"_bucket", float64(h.GetSampleCount()), Label{Name: "le", Value: "+Inf"})
+				}
+				add(name+"_sum", h.GetSampleSum())
+				add(name+"_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, q.GetValue(), Label{Name: "quantile", Value: formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", s.GetSampleSum())
+				add(name+"_count", float64(s.GetSampleCount()))
+			}
+		}
+	}
+	return req
+}
+
+func buildLabels(name string, pairs []*dto.LabelPair, externalLabels map[string]string, extra ...Label) []Label {
+	labels := make([]Label, 0, len(pairs)+len(externalLabels)+len(extra)+1)
+	seen := make(map[string]struct{}, len(pairs)+len(extra))
+
+	labels = append(labels, Label{Name: metricNameLabel, Value: name})
+	for _, lp := range pairs {
+		// empty label value is the same as the label not exists
+		if lp.GetValue() == "" {
+			continue
+		}
+		labels = append(labels, Label{Name: lp.GetName(), Value: lp.GetValue()})
+		seen[lp.GetName()] = struct{}{}
+	}
+	for _, l := range extra {
+		labels = append(labels, l)
+		seen[l.Name] = struct{}{}
+	}
+	for k, v := range externalLabels {
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		labels = append(labels, Label{Name: k, Value: v})
+	}
+
+	// remote write requires labels sorted by name
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels
+}
+
+func metricType(t dto.MetricType) MetricType {
+	switch t {
+	case dto.MetricType_COUNTER:
+		return MetricTypeCounter
+	case dto.MetricType_GAUGE:
+		return MetricTypeGauge
+	case dto.MetricType_HISTOGRAM:
+		return MetricTypeHistogram
+	case dto.MetricType_SUMMARY:
+		return MetricTypeSummary
+	default:
+		return MetricTypeUnknown
+	}
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,99 @@
+package remotewrite
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Minimal encoding of the remote write 1.0 protocol, see
+// https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto
+
+// MetricType is the type of metric metadata, values follow prompb.MetricMetadata_MetricType.
+type MetricType int32
+
+const (
+	MetricTypeUnknown   MetricType = 0
+	MetricTypeCounter   MetricType = 1
+	MetricTypeGauge     MetricType = 2
+	MetricTypeHistogram MetricType = 3
+	MetricTypeSummary   MetricType = 5
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+type MetricMetadata struct {
+	Type             MetricType
+	MetricFamilyName string
+	Help             string
+}
+
+type WriteRequest struct {
+	Timeseries []TimeSeries
+	Metadata   []MetricMetadata
+}
+
+func (l *Label) marshal(b []byte) []byte {
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, l.Name)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, l.Value)
+	return b
+}
+
+func (s *Sample) marshal(b []byte) []byte {
+	b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(s.Value))
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(s.Timestamp))
+	return b
+}
+
+func (t *TimeSeries) marshal(b []byte) []byte {
+	for i := range t.Labels {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, t.Labels[i].marshal(nil))
+	}
+	for i := range t.Samples {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, t.Samples[i].marshal(nil))
+	}
+	return b
+}
+
+func (m *MetricMetadata) marshal(b []byte) []byte {
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(m.Type))
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, m.MetricFamilyName)
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	b = protowire.AppendString(b, m.Help)
+	return b
+}
+
+// Marshal encodes the request in protobuf wire format.
+func (r *WriteRequest) Marshal() []byte {
+	var b []byte
+	for i := range r.Timeseries {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, r.Timeseries[i].marshal(nil))
+	}
+	for i := range r.Metadata {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, r.Metadata[i].marshal(nil))
+	}
+	return b
+}
@@ -0,0 +1,271 @@
+package remotewrite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	walSegmentSuffix = ".snappy"
+	// walCorruptSuffix is appended to segments which cannot be read, they are kept for
+	// inspection but not sent.
+	walCorruptSuffix = ".corrupt"
+)
+
+const (
+	dropReasonOverflow = "overflow"
+	dropReasonCorrupt  = "corrupt"
+	dropReasonRejected = "rejected"
+)
+
+var droppedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kubeskoop",
+	Subsystem: "exporter",
+	Name:      "remote_write_dropped_requests_total",
+	Help:      "Total number of remote write requests dropped without being sent.",
+}, []string{"reason"})
+
+// DroppedRequests returns the counter of dropped requests by reason.
+func DroppedRequests() prometheus.Collector {
+	return droppedRequests
+}
+
+// queue holds encoded requests which have not been sent yet.
+type queue interface {
+	// push appends an encoded request to the end of the queue.
+	push(data []byte) error
+	// peek returns the oldest request, ok is false when the queue is empty.
+	peek() (id uint64, data []byte, ok bool, err error)
+	// remove removes the request with the id from the queue.
+	remove(id uint64) error
+	len() int
+}
+
+// memQueue keeps pending requests in memory, they are lost on restart.
+type memQueue struct {
+	lock    sync.Mutex
+	maxSize int64
+	size    int64
+	next    uint64
+	ids     []uint64
+	entries map[uint64][]byte
+}
+
+func newMemQueue(maxSize int64) *memQueue {
+	return &memQueue{
+		maxSize: maxSize,
+		entries: make(map[uint64][]byte),
+	}
+}
+
+func (q *memQueue) push(data []byte) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.entries[q.next] = data
+	q.ids = append(q.ids, q.next)
+	q.next++
+	q.size += int64(len(data))
+
+	for q.size > q.maxSize && len(q.ids) > 1 {
+		log.Warnf("remote write queue exceeds %d bytes, drop oldest request", q.maxSize)
+		droppedRequests.WithLabelValues(dropReasonOverflow).Inc()
+		q.removeLocked(q.ids[0])
+	}
+	return nil
+}
+
+func (q *memQueue) peek() (uint64, []byte, bool, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.ids) == 0 {
+		return 0, nil, false, nil
+	}
+	return q.ids[0], q.entries[q.ids[0]], true, nil
+}
+
+func (q *memQueue) remove(id uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.removeLocked(id)
+	return nil
+}
+
+func (q *memQueue) removeLocked(id uint64) {
+	data, ok := q.entries[id]
+	if !ok {
+		return
+	}
+	delete(q.entries, id)
+	q.size -= int64(len(data))
+	for i, v := range q.ids {
+		if v == id {
+			q.ids = append(q.ids[:i], q.ids[i+1:]...)
+			break
+		}
+	}
+}
+
+func (q *memQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.ids)
+}
+
+// wal persists every pending request as a segment file in dir, so pending requests
+// survive exporter restarts. Segment files are named by a monotonic sequence number.
+type wal struct {
+	lock    sync.Mutex
+	dir     string
+	maxSize int64
+	next    uint64
+}
+
+func openWAL(dir string, maxSize int64) (*wal, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed create wal directory %s: %w", dir, err)
+	}
+
+	w := &wal{dir: dir, maxSize: maxSize}
+	segments, err := w.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		w.next = segments[len(segments)-1] + 1
+		log.Infof("remote write wal %s has %d pending requests", dir, len(segments))
+	}
+	return w, nil
+}
+
+func (w *wal) segmentPath(id uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%016d%s", id, walSegmentSuffix))
+}
+
+func (w *wal) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed read wal directory %s: %w", w.dir, err)
+	}
+
+	var ids []uint64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), walSegmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), walSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (w *wal) push(data []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	id := w.next
+	tmp := w.segmentPath(id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("failed write wal segment: %w", err)
+	}
+	if err := os.Rename(tmp, w.segmentPath(id)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed commit wal segment: %w", err)
+	}
+	w.next++
+
+	return w.truncateLocked()
+}
+
+// truncateLocked removes the oldest segments until the total size is under the limit.
+func (w *wal) truncateLocked() error {
+	segments, err := w.segments()
+	if err != nil {
+		return err
+	}
+
+	sizes := make([]int64, len(segments))
+	var total int64
+	for i, id := range segments {
+		fi, err := os.Stat(w.segmentPath(id))
+		if err != nil {
+			continue
+		}
+		sizes[i] = fi.Size()
+		total += fi.Size()
+	}
+
+	for i := 0; total > w.maxSize && i < len(segments)-1; i++ {
+		log.Warnf("remote write wal exceeds %d bytes, drop oldest request %d", w.maxSize, segments[i])
+		if err := os.Remove(w.segmentPath(segments[i])); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		droppedRequests.WithLabelValues(dropReasonOverflow).Inc()
+		total -= sizes[i]
+	}
+	return nil
+}
+
+// peek returns the oldest valid segment. Segments which cannot be read or decoded are moved
+// aside, or they would be retried forever and block the queue.
+func (w *wal) peek() (uint64, []byte, bool, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	segments, err := w.segments()
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	for _, id := range segments {
+		data, err := os.ReadFile(w.segmentPath(id))
+		if err == nil {
+			_, err = snappy.DecodedLen(data)
+		}
+		if err == nil {
+			return id, data, true, nil
+		}
+		if err := w.quarantineLocked(id, err); err != nil {
+			return 0, nil, false, err
+		}
+	}
+	return 0, nil, false, nil
+}
+
+func (w *wal) quarantineLocked(id uint64, cause error) error {
+	log.Errorf("remote write wal segment %d is corrupt, drop request: %v", id, cause)
+	path := w.segmentPath(id)
+	if err := os.Rename(path, path+walCorruptSuffix); err != nil {
+		return fmt.Errorf("failed move aside corrupt wal segment %d: %w", id, err)
+	}
+	droppedRequests.WithLabelValues(dropReasonCorrupt).Inc()
+	return nil
+}
+
+func (w *wal) remove(id uint64) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := os.Remove(w.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (w *wal) len() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	segments, _ := w.segments()
+	return len(segments)
+}
@@ -0,0 +1,269 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultInterval = 15 * time.Second
+	defaultTimeout  = 10 * time.Second
+	defaultMaxSize  = 128 << 20
+
+	userAgent = "kubeskoop-exporter"
+)
+
+type BasicAuth struct {
+	Username     string `yaml:"username" mapstructure:"username" json:"username"`
+	Password     string `yaml:"password" mapstructure:"password" json:"password"`
+	PasswordFile string `yaml:"passwordFile" mapstructure:"passwordFile" json:"passwordFile"`
+}
+
+// Config is the configuration of remote write push mode.
+type Config struct {
+	URL             string            `yaml:"url" mapstructure:"url" json:"url"`
+	Interval        time.Duration     `yaml:"interval" mapstructure:"interval" json:"interval"`
+	Timeout         time.Duration     `yaml:"timeout" mapstructure:"timeout" json:"timeout"`
+	Headers         map[string]string `yaml:"headers" mapstructure:"headers" json:"headers"`
+	ExternalLabels  map[string]string `yaml:"externalLabels" mapstructure:"externalLabels" json:"externalLabels"`
+	BasicAuth       *BasicAuth        `yaml:"basicAuth" mapstructure:"basicAuth" json:"basicAuth"`
+	BearerToken     string            `yaml:"bearerToken" mapstructure:"bearerToken" json:"bearerToken"`
+	BearerTokenFile string            `yaml:"bearerTokenFile" mapstructure:"bearerTokenFile" json:"bearerTokenFile"`
+	InsecureSkipTLS bool              `yaml:"insecureSkipVerify" mapstructure:"insecureSkipVerify" json:"insecureSkipVerify"`
+	// WALDir persists unsent requests for retrying across restarts, keep in memory if empty.
+	WALDir string `yaml:"walDir" mapstructure:"walDir" json:"walDir"`
+	// MaxPendingBytes limits the size of unsent requests, oldest requests are dropped when exceeded.
+	MaxPendingBytes int64 `yaml:"maxPendingBytes" mapstructure:"maxPendingBytes" json:"maxPendingBytes"`
+}
+
+// Validate checks the config without modifying it.
+func (c *Config) Validate() error {
+	cp := *c
+	return cp.setDefaults()
+}
+
+func (c *Config) setDefaults() error {
+	if c.URL == "" {
+		return fmt.Errorf("remote write url is empty")
+	}
+	if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+		return fmt.Errorf("invalid remote write url %s, only http and https are supported", c.URL)
+	}
+	if c.BasicAuth != nil && (c.BearerToken != "" || c.BearerTokenFile != "") {
+		return fmt.Errorf("basicAuth and bearerToken cannot be set at the same time")
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.MaxPendingBytes <= 0 {
+		c.MaxPendingBytes = defaultMaxSize
+	}
+	return nil
+}
+
+// recoverableError means the request should be retried later.
+type recoverableError struct {
+	error
+}
+
+// Writer periodically gathers metrics and pushes them with prometheus remote write protocol.
+type Writer struct {
+	cfg      *Config
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	queue    queue
+	started  atomic.Bool
+	stopOnce sync.Once
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+func NewWriter(cfg *Config, gatherer prometheus.Gatherer) (*Writer, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+
+	var q queue = newMemQueue(cfg.MaxPendingBytes)
+	if cfg.WALDir != "" {
+		w, err := openWAL(cfg.WALDir, cfg.MaxPendingBytes)
+		if err != nil {
+			return nil, err
+		}
+		q = w
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.InsecureSkipTLS {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // nolint
+	}
+
+	return &Writer{
+		cfg:      cfg,
+		gatherer: gatherer,
+		client:   &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		queue:    q,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}, nil
+}
+
+func (w *Writer) Start(ctx context.Context) {
+	if !w.started.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer close(w.stopped)
+		ticker := time.NewTicker(w.cfg.Interval)
+		defer ticker.Stop()
+
+		// send requests left by last run first
+		w.flush(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.done:
+				return
+			case <-ticker.C:
+				if err := w.Collect(); err != nil {
+					log.Errorf("remote write failed collect metrics: %v", err)
+				}
+				w.flush(ctx)
+			}
+		}
+	}()
+}
+
+// Stop stops pushing, it is safe to call without Start or more than once.
+func (w *Writer) Stop() {
+	w.stopOnce.Do(func() { close(w.done) })
+	if w.started.Load() {
+		<-w.stopped
+	}
+}
+
+// Collect gathers metrics and appends them to the pending queue.
+func (w *Writer) Collect() error {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		// Gather may return partial result with error, push what we have.
+		log.Warnf("remote write gather metrics with error: %v", err)
+	}
+	if len(families) == 0 {
+		return nil
+	}
+
+	req := ConvertMetricFamilies(families, w.cfg.ExternalLabels, time.Now().UnixMilli())
+	return w.queue.push(snappy.Encode(nil, req.Marshal()))
+}
+
+// flush sends pending requests in order until the queue is empty or an error needs retry.
+func (w *Writer) flush(ctx context.Context) {
+	for {
+		id, data, ok, err := w.queue.peek()
+		if err != nil {
+			log.Errorf("remote write failed read pending request: %v", err)
+			return
+		}
+		if !ok {
+			return
+		}
+
+		err = w.send(ctx, data)
+		var re recoverableError
+		if errors.As(err, &re) {
+			log.Warnf("remote write failed, %d requests pending, will retry: %v", w.queue.len(), err)
+			return
+		}
+		if err != nil {
+			log.Errorf("remote write failed, drop request: %v", err)
+			droppedRequests.WithLabelValues(dropReasonRejected).Inc()
+		}
+		if err := w.queue.remove(id); err != nil {
+			log.Errorf("remote write failed remove sent request: %v", err)
+			return
+		}
+	}
+}
+
+func (w *Writer) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if err := w.setAuth(req); err != nil {
+		// credential files may be missing while they are rotated, keep the request until they are back
+		return recoverableError{err}
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
+
+func (w *Writer) setAuth(req *http.Request) error {
+	if w.cfg.BasicAuth != nil {
+		password := w.cfg.BasicAuth.Password
+		if w.cfg.BasicAuth.PasswordFile != "" {
+			data, err := os.ReadFile(w.cfg.BasicAuth.PasswordFile)
+			if err != nil {
+				return fmt.Errorf("failed read password file: %w", err)
+			}
+			password = strings.TrimSpace(string(data))
+		}
+		req.SetBasicAuth(w.cfg.BasicAuth.Username, password)
+		return nil
+	}
+
+	token := w.cfg.BearerToken
+	if w.cfg.BearerTokenFile != "" {
+		// read every time, token file may be rotated
+		data, err := os.ReadFile(w.cfg.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed read bearer token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// unmarshalWriteRequest decodes timeseries of a write request, metadata is ignored.
+func unmarshalWriteRequest(t *testing.T, b []byte) []TimeSeries {
+	var ret []TimeSeries
+	fields := func(b []byte, f func(num protowire.Number, typ protowire.Type, b []byte) int) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			assert.Greater(t, n, 0)
+			b = b[n:]
+			n = f(num, typ, b)
+			assert.Greater(t, n, 0)
+			b = b[n:]
+		}
+	}
+
+	fields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num != 1 {
+			return protowire.ConsumeFieldValue(num, typ, b)
+		}
+		v, n := protowire.ConsumeBytes(b)
+		ts := TimeSeries{}
+		fields(v, func(num protowire.Number, typ protowire.Type, b []byte) int {
+			v, n := protowire.ConsumeBytes(b)
+			switch num {
+			case 1:
+				l := Label{}
+				fields(v, func(num protowire.Number, _ protowire.Type, b []byte) int {
+					s, n := protowire.ConsumeString(b)
+					if num == 1 {
+						l.Name = s
+					} else {
+						l.Value = s
+					}
+					return n
+				})
+				ts.Labels = append(ts.Labels, l)
+			case 2:
+				s := Sample{}
+				fields(v, func(num protowire.Number, typ protowire.Type, b []byte) int {
+					if num == 1 {
+						f, n := protowire.ConsumeFixed64(b)
+						s.Value = math.Float64frombits(f)
+						return n
+					}
+					i, n := protowire.ConsumeVarint(b)
+					s.Timestamp = int64(i)
+					return n
+				})
+				ts.Samples = append(ts.Samples, s)
+			}
+			return n
+		})
+		ret = append(ret, ts)
+		return n
+	})
+	return ret
+}
+
+type fakeReceiver struct {
+	lock   sync.Mutex
+	status atomic.Int32
+	auth   []string
+	series [][]TimeSeries
+	t      *testing.T
+}
+
+func (f *fakeReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if status := f.status.Load(); status != 0 {
+		w.WriteHeader(int(status))
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	data, err := snappy.Decode(nil, body)
+	assert.NoError(f.t, err)
+	assert.Equal(f.t, "snappy", r.Header.Get("Content-Encoding"))
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	f.series = append(f.series, unmarshalWriteRequest(f.t, data))
+}
+
+func testRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "kubeskoop_test", Help: "test"}, []string{"k8s_pod", "k8s_node"})
+	gauge.WithLabelValues("pod1", "node1").Set(1)
+	r.MustRegister(gauge)
+	return r
+}
+
+func TestConvertMetricFamilies(t *testing.T) {
+	r := testRegistry()
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "kubeskoop_latency", Help: "test", Buckets: []float64{1}})
+	h.Observe(0.5)
+	h.Observe(2)
+	r.MustRegister(h)
+
+	families, err := r.Gather()
+	assert.NoError(t, err)
+	req := ConvertMetricFamilies(families, map[string]string{"cluster": "c1", "k8s_node": "ignored"}, 1000)
+
+	series := unmarshalWriteRequest(t, req.Marshal())
+	assert.Len(t, series, 5)
+
+	var names []string
+	for _, ts := range series {
+		names = append(names, ts.Labels[0].Value)
+		assert.Equal(t, int64(1000), ts.Samples[0].Timestamp)
+	}
+	assert.Equal(t, []string{"kubeskoop_latency_bucket", "kubeskoop_latency_bucket", "kubeskoop_latency_sum", "kubeskoop_latency_count", "kubeskoop_test"}, names)
+
+	assert.Equal(t, []Label{
+		{Name: "__name__", Value: "kubeskoop_test"},
+		{Name: "cluster", Value: "c1"},
+		{Name: "k8s_node", Value: "node1"},
+		{Name: "k8s_pod", Value: "pod1"},
+	}, series[4].Labels)
+	assert.Equal(t, Label{Name: "le", Value: "+Inf"}, series[1].Labels[3])
+	assert.Equal(t, float64(2), series[1].Samples[0].Value)
+}
+
+func TestWriterAuth(t *testing.T) {
+	f := &fakeReceiver{t: t}
+	s := httptest.NewServer(f)
+	defer s.Close()
+
+	w, err := NewWriter(&Config{URL: s.URL, BasicAuth: &BasicAuth{Username: "user", Password: "pass"}}, testRegistry())
+	assert.NoError(t, err)
+	assert.NoError(t, w.Collect())
+	w.flush(context.Background())
+
+	w, err = NewWriter(&Config{URL: s.URL, BearerToken: "token"}, testRegistry())
+	assert.NoError(t, err)
+	assert.NoError(t, w.Collect())
+	w.flush(context.Background())
+
+	assert.Equal(t, []string{"Basic dXNlcjpwYXNz", "Bearer token"}, f.auth)
+
+	_, err = NewWriter(&Config{URL: s.URL, BearerToken: "token", BasicAuth: &BasicAuth{}}, testRegistry())
+	assert.Error(t, err)
+}
+
+func TestWriterWALRetry(t *testing.T) {
+	f := &fakeReceiver{t: t}
+	f.status.Store(http.StatusServiceUnavailable)
+	s := httptest.NewServer(f)
+	defer s.Close()
+
+	dir := t.TempDir()
+	w, err := NewWriter(&Config{URL: s.URL, WALDir: dir}, testRegistry())
+	assert.NoError(t, err)
+	assert.NoError(t, w.Collect())
+	assert.NoError(t, w.Collect())
+	w.flush(context.Background())
+	assert.Equal(t, 2, w.queue.len())
+
+	// pending requests are sent by a new writer after restart
+	f.status.Store(0)
+	w, err = NewWriter(&Config{URL: s.URL, WALDir: dir}, testRegistry())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, w.queue.len())
+	w.flush(context.Background())
+	assert.Equal(t, 0, w.queue.len())
+	assert.Len(t, f.series, 2)
+
+	// non-recoverable error drops the request
+	f.status.Store(http.StatusBadRequest)
+	assert.NoError(t, w.Collect())
+	w.flush(context.Background())
+	assert.Equal(t, 0, w.queue.len())
+}
+
+func TestWriterAuthFileRotation(t *testing.T) {
+	f := &fakeReceiver{t: t}
+	s := httptest.NewServer(f)
+	defer s.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	w, err := NewWriter(&Config{URL: s.URL, BearerTokenFile: tokenFile}, testRegistry())
+	assert.NoError(t, err)
+
+	// requests are kept while the token file is missing
+	assert.NoError(t, w.Collect())
+	w.flush(context.Background())
+	assert.Equal(t, 1, w.queue.len())
+
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("rotated\n"), 0600))
+	w.flush(context.Background())
+	assert.Equal(t, 0, w.queue.len())
+	assert.Equal(t, []string{"Bearer rotated"}, f.auth)
+}
+
+func TestMemQueueLimit(t *testing.T) {
+	q := newMemQueue(10)
+	assert.NoError(t, q.push(make([]byte, 6)))
+	assert.NoError(t, q.push(make([]byte, 6)))
+	assert.Equal(t, 1, q.len())
+	id, _, ok, _ := q.peek()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), id)
+}
+
+func TestWALCorruptSegment(t *testing.T) {
+	f := &fakeReceiver{t: t}
+	s := httptest.NewServer(f)
+	defer s.Close()
+
+	dir := t.TempDir()
+	w, err := NewWriter(&Config{URL: s.URL, WALDir: dir}, testRegistry())
+	assert.NoError(t, err)
+	assert.NoError(t, w.Collect())
+	assert.NoError(t, w.Collect())
+	q := w.queue.(*wal)
+	assert.NoError(t, os.WriteFile(q.segmentPath(0), []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, 0o640))
+
+	// the corrupt segment is moved aside instead of blocking following requests
+	w.flush(context.Background())
+	assert.Equal(t, 0, w.queue.len())
+	assert.Len(t, f.series, 1)
+	assert.FileExists(t, q.segmentPath(0)+walCorruptSuffix)
+}
+
+func TestWriterStopWithoutStart(t *testing.T) {
+	w, err := NewWriter(&Config{URL: "http://127.0.0.1:1"}, testRegistry())
+	assert.NoError(t, err)
+	w.Stop()
+	w.Stop()
+}
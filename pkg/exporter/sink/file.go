@@ -6,21 +6,38 @@ import (
 	"os"
 
 	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"google.golang.org/protobuf/encoding/protodelim"
 )
 
-func NewFileSink(path string) (*FileSink, error) {
+const (
+	// FormatJSON writes one json encoded event per line.
+	FormatJSON = "json"
+	// FormatProtobuf writes size delimited protobuf messages of eventpb.Event.
+	FormatProtobuf = "protobuf"
+)
+
+func NewFileSink(path string, format string) (*FileSink, error) {
+	if format == "" {
+		format = FormatJSON
+	}
+	if format != FormatJSON && format != FormatProtobuf {
+		return nil, fmt.Errorf("unknown format %q of file sink, expected %s or %s", format, FormatJSON, FormatProtobuf)
+	}
+
 	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed open file %s, err: %w", path, err)
 	}
 
 	return &FileSink{
-		file: file,
+		file:   file,
+		format: format,
 	}, nil
 }
 
 type FileSink struct {
-	file *os.File
+	file   *os.File
+	format string
 }
 
 func (f *FileSink) String() string {
@@ -28,6 +45,13 @@ func (f *FileSink) String() string {
 }
 
 func (f *FileSink) Write(event *probe.Event) error {
+	if f.format == FormatProtobuf {
+		if _, err := protodelim.MarshalTo(f.file, event.ToProto()); err != nil {
+			return fmt.Errorf("failed sink event to file %s, err: %w", f.file.Name(), err)
+		}
+		return nil
+	}
+
 	data, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed marshal event, err: %w", err)
@@ -42,4 +66,8 @@ func (f *FileSink) Write(event *probe.Event) error {
 
 }
 
+func (f *FileSink) Close() error {
+	return f.file.Close()
+}
+
 var _ Sink = &FileSink{}
@@ -0,0 +1,46 @@
+package sink
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe/eventpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+func TestFileSinkProtobuf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events")
+	s, err := NewFileSink(path, FormatProtobuf)
+	assert.NoError(t, err)
+
+	events := []*probe.Event{
+		{Timestamp: 1, Type: "PacketLoss", Version: probe.EventSchemaVersion, Tuple: &probe.EventTuple{Protocol: "TCP", Src: "10.0.0.1"}},
+		{Timestamp: 2, Type: "TCPRetrans", Message: "msg"},
+	}
+	for _, evt := range events {
+		assert.NoError(t, s.Write(evt))
+	}
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for _, expected := range events {
+		pb := &eventpb.Event{}
+		assert.NoError(t, protodelim.UnmarshalFrom(r, pb))
+		assert.Equal(t, expected, probe.EventFromProto(pb))
+	}
+}
+
+func TestValidateFileSinkFormat(t *testing.T) {
+	assert.NoError(t, ValidateSinkArgs(File, map[string]interface{}{"path": "/tmp/events"}))
+	assert.NoError(t, ValidateSinkArgs(File, map[string]interface{}{"path": "/tmp/events", "format": "protobuf"}))
+	assert.Error(t, ValidateSinkArgs(File, map[string]interface{}{"path": "/tmp/events", "format": "xml"}))
+
+	_, err := NewFileSink(filepath.Join(t.TempDir(), "events"), "xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package sink
+
+import (
+	"fmt"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/otlp"
+	"github.com/alibaba/kubeskoop/pkg/exporter/probe"
+	"github.com/mitchellh/mapstructure"
+)
+
+func decodeOTLPConfig(args map[string]interface{}) (*otlp.Config, error) {
+	cfg := &otlp.Config{}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:  mapstructure.StringToTimeDurationHookFunc(),
+		ErrorUnused: true,
+		Result:      cfg,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(args); err != nil {
+		return nil, fmt.Errorf("invalid otlp sink args: %w", err)
+	}
+	return cfg, nil
+}
+
+func NewOTLPSink(args map[string]interface{}, node string) (*OTLPSink, error) {
+	cfg, err := decodeOTLPConfig(args)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := otlp.NewLogsExporter(cfg, node)
+	if err != nil {
+		return nil, fmt.Errorf("failed create otlp exporter, err: %w", err)
+	}
+	return &OTLPSink{
+		exporter: exporter,
+	}, nil
+}
+
+type OTLPSink struct {
+	exporter *otlp.LogsExporter
+}
+
+func (o *OTLPSink) String() string {
+	return "otlp"
+}
+
+func (o *OTLPSink) Write(event *probe.Event) error {
+	if !o.exporter.Send(event) {
+		return fmt.Errorf("otlp sink queue is full, discard event")
+	}
+	return nil
+}
+
+// Close sends queued events and stops the exporter.
+func (o *OTLPSink) Close() error {
+	return o.exporter.Close()
+}
+
+var _ Sink = &OTLPSink{}
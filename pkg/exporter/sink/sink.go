@@ -11,14 +11,19 @@ const (
 	Stderr = "stderr"
 	File   = "file"
 	Loki   = "loki"
+	OTLP   = "otlp"
 )
 
+// Sink writes events, sinks implementing io.Closer are closed when the event server stops.
 type Sink interface {
 	Write(event *probe.Event) error
 }
 
 func CreateSink(name string, args interface{}) (Sink, error) {
 	//TODO create with register and reflect
+	if err := ValidateSinkArgs(name, args); err != nil {
+		return nil, err
+	}
 	argsMap, _ := args.(map[string]interface{})
 
 	switch name {
@@ -29,7 +34,45 @@ func CreateSink(name string, args interface{}) (Sink, error) {
 		return NewLokiSink(addr, nettop.GetNodeName())
 	case File:
 		path := argsMap["path"].(string)
-		return NewFileSink(path)
+		format, _ := argsMap["format"].(string)
+		return NewFileSink(path, format)
+	case OTLP:
+		return NewOTLPSink(argsMap, nettop.GetNodeName())
 	}
 	return nil, fmt.Errorf("unknown sink type %s", name)
 }
+
+// ValidateSinkArgs checks the sink type exists and its args are valid.
+func ValidateSinkArgs(name string, args interface{}) error {
+	argsMap, _ := args.(map[string]interface{})
+	requireString := func(key string) error {
+		if v, ok := argsMap[key].(string); !ok || v == "" {
+			return fmt.Errorf("sink %s requires string arg %q", name, key)
+		}
+		return nil
+	}
+
+	switch name {
+	case Stderr:
+		return nil
+	case Loki:
+		return requireString("addr")
+	case File:
+		if err := requireString("path"); err != nil {
+			return err
+		}
+		switch format := argsMap["format"]; format {
+		case nil, FormatJSON, FormatProtobuf:
+			return nil
+		default:
+			return fmt.Errorf("sink %s: unknown format %v, expected %s or %s", name, format, FormatJSON, FormatProtobuf)
+		}
+	case OTLP:
+		cfg, err := decodeOTLPConfig(argsMap)
+		if err != nil {
+			return err
+		}
+		return cfg.Validate()
+	}
+	return fmt.Errorf("unknown sink type %s", name)
+}
@@ -3,23 +3,37 @@ package taskagent
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/alibaba/kubeskoop/pkg/controller/rpc"
 	"github.com/alibaba/kubeskoop/pkg/exporter/nettop"
+	"github.com/alibaba/kubeskoop/pkg/exporter/tlsutil"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 )
 
-func NewTaskAgent(controllerAddr string) *Agent {
+// ValidateConfig checks the connection config of the agent, the token is only sent over tls.
+func ValidateConfig(tlsConfig *tlsutil.Config, tokenFile string) error {
+	if tokenFile != "" && !tlsConfig.Enabled() {
+		return fmt.Errorf("token file %s requires tls, token cannot be sent over plaintext", tokenFile)
+	}
+	return nil
+}
+
+func NewTaskAgent(controllerAddr string, tlsConfig *tlsutil.Config, tokenFile string) *Agent {
 	return &Agent{
 		NodeName:       nettop.GetNodeName(),
 		controllerAddr: controllerAddr,
+		tlsConfig:      tlsConfig,
+		tokenFile:      tokenFile,
 	}
 }
 
@@ -28,13 +42,44 @@ type Agent struct {
 	grpcClient     rpc.ControllerRegisterServiceClient
 	ipCacheClient  rpc.IPCacheServiceClient
 	controllerAddr string
+	tlsConfig      *tlsutil.Config
+	tokenFile      string
 }
 
 func (a *Agent) rpcConnect() (*grpc.ClientConn, error) {
 	var opts []grpc.CallOption
 	opts = append(opts, grpc.MaxCallSendMsgSize(102*1024*1024))
-	return grpc.Dial(a.controllerAddr, grpc.WithDefaultCallOptions(opts...),
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	dialOpts := []grpc.DialOption{grpc.WithDefaultCallOptions(opts...)}
+
+	if a.tlsConfig.Enabled() {
+		tlsConfig, err := a.tlsConfig.ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed load tls config: %w", err)
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	if a.tokenFile != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCredentials(a.tokenFile)))
+	}
+	return grpc.Dial(a.controllerAddr, dialOpts...)
+}
+
+// tokenCredentials sends the service account token in the file as a bearer token, the file
+// is read for each call since projected tokens are rotated by kubelet.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	token, err := os.ReadFile(string(t))
+	if err != nil {
+		return nil, fmt.Errorf("failed read token file %s: %w", string(t), err)
+	}
+	return map[string]string{"authorization": "Bearer " + strings.TrimSpace(string(token))}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return true
 }
 
 func retry(msg string, maxAttempts int, work func() error) error {
@@ -138,6 +183,10 @@ func (a *Agent) syncIPCache() error {
 			info.Type = nettop.IPTypePod
 			info.PodNamespace = v.Pod.Namespace
 			info.PodName = v.Pod.Name
+		case *rpc.CacheEntry_Service:
+			info.Type = nettop.IPTypeService
+			info.ServiceNamespace = v.Service.Namespace
+			info.ServiceName = v.Service.Name
 		default:
 			return nil
 		}
@@ -236,6 +285,9 @@ func (a *Agent) syncIPCache() error {
 }
 
 func (a *Agent) Run() error {
+	if err := ValidateConfig(a.tlsConfig, a.tokenFile); err != nil {
+		return err
+	}
 	if err := a.watchTask(); err != nil {
 		return err
 	}
@@ -0,0 +1,15 @@
+package taskagent
+
+import (
+	"testing"
+
+	"github.com/alibaba/kubeskoop/pkg/exporter/tlsutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	assert.NoError(t, ValidateConfig(nil, ""))
+	assert.NoError(t, ValidateConfig(&tlsutil.Config{}, "/var/run/secrets/token"))
+	assert.Error(t, ValidateConfig(nil, "/var/run/secrets/token"))
+	assert.Error(t, NewTaskAgent("dns:controller:10263", nil, "/var/run/secrets/token").Run())
+}
@@ -0,0 +1,230 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Config is the tls config of a server or a client. Certificates are reloaded when files
+// change, so rotated certificates take effect without restart.
+type Config struct {
+	// CertFile and KeyFile are the certificate of the server, or the client certificate for mutual tls.
+	CertFile string `yaml:"certFile" mapstructure:"certFile" json:"certFile"`
+	KeyFile  string `yaml:"keyFile" mapstructure:"keyFile" json:"keyFile"`
+	// CAFile verifies certificates of peers. Servers require client certificates signed by
+	// it when it is set, clients use system roots when it is empty.
+	CAFile string `yaml:"caFile" mapstructure:"caFile" json:"caFile"`
+	// ServerName overrides the name clients verify in the server certificate.
+	ServerName string `yaml:"serverName" mapstructure:"serverName" json:"serverName"`
+}
+
+// Enabled returns whether tls is configured, an empty client config verifies servers by system roots.
+func (c *Config) Enabled() bool {
+	return c != nil
+}
+
+// Validate checks consistency of fields as a server config if server is true. Files are
+// not checked, they may only exist on nodes the config is used on and are loaded by
+// ServerConfig and ClientConfig.
+func (c *Config) Validate(server bool) error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("certFile and keyFile should be set together")
+	}
+	if server && c.CertFile == "" {
+		return fmt.Errorf("certFile and keyFile are required")
+	}
+	return nil
+}
+
+// ServerConfig returns the tls config of a server, client certificates are required if
+// CAFile is set.
+func (c *Config) ServerConfig() (*tls.Config, error) {
+	if err := c.Validate(true); err != nil {
+		return nil, err
+	}
+	cert := newCertReloader(c.CertFile, c.KeyFile)
+	if _, err := cert.get(); err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cert.get()
+		},
+	}
+	if c.CAFile == "" {
+		return cfg, nil
+	}
+
+	ca := newCAReloader(c.CAFile)
+	if _, err := ca.get(); err != nil {
+		return nil, err
+	}
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		pool, err := ca.get()
+		if err != nil {
+			return nil, err
+		}
+		ret := cfg.Clone()
+		ret.GetConfigForClient = nil
+		ret.ClientAuth = tls.RequireAndVerifyClientCert
+		ret.ClientCAs = pool
+		return ret, nil
+	}
+	return cfg, nil
+}
+
+// ClientConfig returns the tls config of a client, the client certificate is sent if
+// CertFile is set.
+func (c *Config) ClientConfig() (*tls.Config, error) {
+	if err := c.Validate(false); err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+
+	if c.CertFile != "" {
+		cert := newCertReloader(c.CertFile, c.KeyFile)
+		if _, err := cert.get(); err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.get()
+		}
+	}
+
+	if c.CAFile != "" {
+		ca := newCAReloader(c.CAFile)
+		if _, err := ca.get(); err != nil {
+			return nil, err
+		}
+		// roots cannot be changed after the config is used, the server certificate is
+		// verified against the latest ca in VerifyConnection instead.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			pool, err := ca.get()
+			if err != nil {
+				return err
+			}
+			return verifyServer(cs, pool)
+		}
+	}
+	return cfg, nil
+}
+
+func verifyServer(cs tls.ConnectionState, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("no server certificate")
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// fileReloader reloads files when their modification time changes.
+type fileReloader struct {
+	files []string
+
+	lock    sync.Mutex
+	modTime []time.Time
+}
+
+// changed returns whether any file changed since last load, the new modification times
+// are returned to be saved after the files are loaded.
+func (r *fileReloader) changed() (bool, []time.Time, error) {
+	var modTime []time.Time
+	changed := r.modTime == nil
+	for i, f := range r.files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return false, nil, fmt.Errorf("failed stat %s: %w", f, err)
+		}
+		modTime = append(modTime, fi.ModTime())
+		if r.modTime != nil && !fi.ModTime().Equal(r.modTime[i]) {
+			changed = true
+		}
+	}
+	return changed, modTime, nil
+}
+
+type certReloader struct {
+	fileReloader
+	cert *tls.Certificate
+}
+
+func newCertReloader(certFile, keyFile string) *certReloader {
+	return &certReloader{fileReloader: fileReloader{files: []string{certFile, keyFile}}}
+}
+
+// get returns the latest certificate, the last loaded certificate is returned if files
+// are being replaced and cannot be loaded.
+func (r *certReloader) get() (*tls.Certificate, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	changed, modTime, err := r.changed()
+	if err != nil || !changed {
+		return r.last(err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.files[0], r.files[1])
+	if err != nil {
+		return r.last(fmt.Errorf("failed load certificate %s: %w", r.files[0], err))
+	}
+	r.cert, r.modTime = &cert, modTime
+	return r.cert, nil
+}
+
+func (r *certReloader) last(err error) (*tls.Certificate, error) {
+	if r.cert != nil {
+		return r.cert, nil
+	}
+	return nil, err
+}
+
+type caReloader struct {
+	fileReloader
+	pool *x509.CertPool
+}
+
+func newCAReloader(caFile string) *caReloader {
+	return &caReloader{fileReloader: fileReloader{files: []string{caFile}}}
+}
+
+func (r *caReloader) get() (*x509.CertPool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	changed, modTime, err := r.changed()
+	if err != nil || !changed {
+		return r.last(err)
+	}
+	data, err := os.ReadFile(r.files[0])
+	if err != nil {
+		return r.last(fmt.Errorf("failed read ca %s: %w", r.files[0], err))
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return r.last(fmt.Errorf("no certificate found in ca %s", r.files[0]))
+	}
+	r.pool, r.modTime = pool, modTime
+	return r.pool, nil
+}
+
+func (r *caReloader) last(err error) (*x509.CertPool, error) {
+	if r.pool != nil {
+		return r.pool, nil
+	}
+	return nil, err
+}
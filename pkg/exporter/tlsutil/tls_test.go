@@ -0,0 +1,173 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (ca *testCA) issue(t *testing.T, name string, serial int64) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// handshake runs a tls handshake and returns the serial number of the server certificate.
+func handshake(t *testing.T, server, client *tls.Config) (int64, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		s, err := l.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer s.Close()
+		serverErr <- tls.Server(s, server).Handshake()
+	}()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	conn := tls.Client(c, client)
+	err = conn.Handshake()
+	conn.Close()
+	if sErr := <-serverErr; err == nil {
+		err = sErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	ca := newTestCA(t, "ca")
+	server := &Config{
+		CertFile: filepath.Join(dir, "server.crt"),
+		KeyFile:  filepath.Join(dir, "server.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	client := &Config{
+		CertFile:   filepath.Join(dir, "client.crt"),
+		KeyFile:    filepath.Join(dir, "client.key"),
+		CAFile:     server.CAFile,
+		ServerName: "controller",
+	}
+	writeFile(t, server.CAFile, ca.pem, now)
+	cert, key := ca.issue(t, "controller", 2)
+	writeFile(t, server.CertFile, cert, now)
+	writeFile(t, server.KeyFile, key, now)
+	cert, key = ca.issue(t, "agent", 3)
+	writeFile(t, client.CertFile, cert, now)
+	writeFile(t, client.KeyFile, key, now)
+
+	serverTLS, err := server.ServerConfig()
+	require.NoError(t, err)
+	clientTLS, err := client.ClientConfig()
+	require.NoError(t, err)
+
+	serial, err := handshake(t, serverTLS, clientTLS)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), serial)
+
+	// clients without certificates are rejected
+	noCert, err := (&Config{CAFile: client.CAFile, ServerName: "controller"}).ClientConfig()
+	require.NoError(t, err)
+	_, err = handshake(t, serverTLS, noCert)
+	assert.Error(t, err)
+
+	// the server name is verified
+	wrongName, err := (&Config{CertFile: client.CertFile, KeyFile: client.KeyFile, CAFile: client.CAFile, ServerName: "other"}).ClientConfig()
+	require.NoError(t, err)
+	_, err = handshake(t, serverTLS, wrongName)
+	assert.Error(t, err)
+
+	// rotated certificates are used without rebuilding the config
+	cert, key = ca.issue(t, "controller", 4)
+	writeFile(t, server.CertFile, cert, now.Add(time.Minute))
+	writeFile(t, server.KeyFile, key, now.Add(time.Minute))
+	serial, err = handshake(t, serverTLS, clientTLS)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), serial)
+
+	// a rotated ca rejects peers signed by the old one
+	writeFile(t, server.CAFile, newTestCA(t, "other").pem, now.Add(time.Minute))
+	_, err = handshake(t, serverTLS, clientTLS)
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	assert.False(t, (*Config)(nil).Enabled())
+	assert.True(t, (&Config{}).Enabled())
+	assert.Error(t, (&Config{CertFile: "tls.crt"}).Validate(false))
+	assert.Error(t, (&Config{CAFile: "ca.crt"}).Validate(true))
+	assert.NoError(t, (&Config{}).Validate(false))
+
+	// files are only loaded by configs used on the node
+	missing := &Config{CertFile: "/nonexistent/tls.crt", KeyFile: "/nonexistent/tls.key"}
+	assert.NoError(t, missing.Validate(true))
+	_, err := missing.ServerConfig()
+	assert.Error(t, err)
+}